@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// errexitPattern matches the set commands turning on errexit that scripts
+// start with, such as "set -e" or "set -euo pipefail"; phases always run
+// with it.
+var errexitPattern = regexp.MustCompile(`^set\s+(-[a-zA-Z]*e[a-zA-Z]*|-o\s+errexit)(\s|$)`)
+
+// heredocPattern matches the start of a here-document and its delimiter.
+var heredocPattern = regexp.MustCompile(`(?:^|[^<])<<-?\s*['"]?([A-Za-z_][A-Za-z0-9_]*)['"]?`)
+
+// quotedPattern matches quoted strings, which are left out when counting
+// the compound commands a line opens and closes.
+var quotedPattern = regexp.MustCompile(`'[^']*'|"(?:[^"\\]|\\.)*"`)
+
+// setupPattern matches the statements a script's sections share, such as
+// variables, functions and the directory, which every phase repeats as
+// its pre commands.
+var setupPattern = regexp.MustCompile(`^(export\s|readonly\s|declare\s|source\s|\.\s|cd\s|set\s|shopt\s|trap\s|umask\s|function\s|[A-Za-z_][A-Za-z0-9_]*=|[A-Za-z_][A-Za-z0-9_]*\s*\(\))`)
+
+// scriptSection is a part of a script headed by a comment.
+type scriptSection struct {
+	title      string
+	statements []string
+}
+
+// scriptPhases proposes phases for the shell script at path: one per
+// section introduced by a comment after a blank line, such as "# Build" or
+// "### Run tests ###", named after the comment; a comment followed by a
+// blank line, such as a file header, heads nothing. A script without
+// sections becomes one phase named after the file. What comes before the
+// first section is shared: variables, functions, cd and the like become the
+// pre commands of every phase, other commands a phase of their own.
+// Multi-line statements such as if blocks, functions and here-documents
+// stay together as one command.
+func scriptPhases(path string) ([]bild.Phase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sections := []*scriptSection{{}}
+	var (
+		statement []string
+		depth     int
+		heredoc   string
+		header    = true // a comment here may start a section
+		title     string // of the section a statement next would start
+	)
+	// add adds the statement read to the current section, if complete.
+	add := func() {
+		if heredoc != "" || depth > 0 || strings.HasSuffix(statement[len(statement)-1], `\`) {
+			return
+		}
+		current := sections[len(sections)-1]
+		current.statements = append(current.statements, strings.Join(statement, "\n"))
+		statement, depth = nil, 0
+	}
+	scanner := bufio.NewScanner(f)
+	for first := true; scanner.Scan(); first = false {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		trimmed := strings.TrimSpace(line)
+
+		// Inside a statement, every line belongs to it.
+		if len(statement) > 0 {
+			statement = append(statement, line)
+			if heredoc != "" {
+				if trimmed == heredoc {
+					heredoc = ""
+				}
+			} else {
+				depth += blockDepth(trimmed)
+				heredoc = heredocDelimiter(trimmed)
+			}
+			add()
+			continue
+		}
+
+		switch {
+		case first && strings.HasPrefix(trimmed, "#!"):
+			continue
+		case trimmed == "":
+			// A comment followed by a blank line, such as a file header,
+			// heads nothing.
+			header, title = true, ""
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			if text := strings.Trim(trimmed, "#-=*~ \t"); header && title == "" {
+				title = text
+			}
+			continue
+		}
+		header = false
+		if title != "" {
+			sections = append(sections, &scriptSection{title: title})
+			title = ""
+		}
+		if errexitPattern.MatchString(trimmed) {
+			if rest := remainingOptions(trimmed); rest != "" {
+				current := sections[len(sections)-1]
+				current.statements = append(current.statements, rest)
+			}
+			continue
+		}
+		statement = []string{line}
+		depth = blockDepth(trimmed)
+		heredoc = heredocDelimiter(trimmed)
+		add()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(statement) > 0 {
+		current := sections[len(sections)-1]
+		current.statements = append(current.statements, strings.Join(statement, "\n"))
+	}
+
+	script := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	preamble := sections[0]
+	var pre, commands []string
+	for _, s := range preamble.statements {
+		if len(sections) > 1 && setupPattern.MatchString(s) {
+			pre = append(pre, s)
+		} else {
+			commands = append(commands, s)
+		}
+	}
+	preamble.statements = commands
+
+	var phases []bild.Phase
+	used := make(map[string]int)
+	for _, s := range sections {
+		if len(s.statements) == 0 {
+			continue
+		}
+		name := phaseNameFor(s.title)
+		if name == "" {
+			name = phaseNameFor(script)
+		}
+		if used[name]++; used[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, used[name])
+		}
+		ph := bild.Phase{Name: name, Commands: s.statements}
+		ph.Pre = pre
+		if s.title != "" && !strings.EqualFold(s.title, name) {
+			ph.Description = s.title
+		}
+		phases = append(phases, ph)
+	}
+	return phases, nil
+}
+
+// remainingOptions returns the set command that is left of line, a command
+// turning on errexit, once errexit is dropped, or "" if nothing is.
+func remainingOptions(line string) string {
+	var kept []string
+	fields := strings.Fields(line)[1:]
+	for i := 0; i < len(fields); i++ {
+		switch f := fields[i]; {
+		case f == "-o" && i+1 < len(fields) && fields[i+1] == "errexit":
+			i++
+		case f == "-o" && i+1 < len(fields):
+			kept = append(kept, f, fields[i+1])
+			i++
+		case strings.HasPrefix(f, "-"):
+			if flags := strings.ReplaceAll(f[1:], "e", ""); flags != "" {
+				kept = append(kept, "-"+flags)
+			}
+		default:
+			kept = append(kept, f)
+		}
+	}
+	if len(kept) == 0 {
+		return ""
+	}
+	return "set " + strings.Join(kept, " ")
+}
+
+// blockDepth returns how many compound commands, such as if blocks, loops
+// and functions, line opens minus how many it closes.
+func blockDepth(line string) int {
+	line = quotedPattern.ReplaceAllString(line, `""`)
+	if i := strings.Index(line, " #"); i >= 0 {
+		line = line[:i]
+	}
+	depth := 0
+	for _, word := range strings.FieldsFunc(line, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ';' || r == '&' || r == '|'
+	}) {
+		switch word {
+		case "if", "case", "for", "while", "until", "select", "{":
+			depth++
+		case "fi", "esac", "done", "}":
+			depth--
+		}
+	}
+	if strings.HasSuffix(line, "{") && !strings.HasSuffix(line, " {") && !strings.HasSuffix(line, "\t{") {
+		depth++ // e.g. "build(){"
+	}
+	return depth
+}
+
+// heredocDelimiter returns the delimiter of the here-document line starts,
+// if any.
+func heredocDelimiter(line string) string {
+	if m := heredocPattern.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// commonPhases maps words that section titles use to the usual phase names.
+var commonPhases = map[string]string{
+	"configure": "configure", "configuration": "configure",
+	"build": "build", "compile": "build",
+	"test": "test", "tests": "test", "testing": "test",
+	"lint": "lint", "install": "install", "package": "package",
+	"deploy": "deploy", "release": "release", "clean": "clean",
+	"docs": "docs", "documentation": "docs",
+}
+
+// phaseNameFor turns a section title into a phase name: the title in lower
+// case with dashes for spaces if it is short, such as "clean-cache";
+// otherwise the usual name of a phase it mentions, as "test" for "Run the
+// unit tests", or else its first word.
+func phaseNameFor(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+	})
+	if len(words) <= 2 {
+		return strings.Join(words, "-")
+	}
+	for _, word := range words {
+		if name, ok := commonPhases[word]; ok {
+			return name
+		}
+	}
+	return words[0]
+}
+
+// writePhasesJSON writes phases as indented JSON, as shown for review.
+func writePhasesJSON(w io.Writer, phases []bild.Phase) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(phases)
+}
+
+// reviewPhases opens the proposed phases in the editor as JSON and returns
+// them as saved, or nil if the file was emptied.
+func reviewPhases(phases []bild.Phase) ([]bild.Phase, error) {
+	var proposed strings.Builder
+	if err := writePhasesJSON(&proposed, phases); err != nil {
+		return nil, err
+	}
+	fmt.Println(t("adopt.review"))
+	edited, err := openEditorAs(proposed.String(), "bild_adopt_*.json")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(edited) == "" {
+		return nil, nil
+	}
+	var reviewed []bild.Phase
+	if err := json.Unmarshal([]byte(edited), &reviewed); err != nil {
+		return nil, fmt.Errorf("the reviewed phases are not valid JSON: %v", err)
+	}
+	for _, ph := range reviewed {
+		if ph.Name == "" || len(ph.Commands) == 0 {
+			return nil, fmt.Errorf("every phase needs a name and commands")
+		}
+	}
+	return reviewed, nil
+}
+
+// adoptCmd turns a build script into a project.
+var adoptCmd = &cobra.Command{
+	Use:   "adopt <script>",
+	Short: "Convert a shell script such as build.sh into a project",
+	Long: `Proposes phases for a shell script: one per section introduced by a
+comment after a blank line, such as "# Build" or "### Run tests ###", or a
+single phase for a script without sections. Variables, functions and cd
+commands before the first section are repeated in every phase, as each
+phase runs in a shell of its own; multi-line statements such as if blocks
+and here-documents are kept together. The proposal opens in $EDITOR for
+review before the project is saved; empty the file to cancel.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		phases, err := scriptPhases(args[0])
+		if err != nil {
+			return err
+		}
+		if len(phases) == 0 {
+			return fmt.Errorf("no commands found in %s", args[0])
+		}
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			return writePhasesJSON(os.Stdout, phases)
+		}
+		if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+			if phases, err = reviewPhases(phases); err != nil {
+				return err
+			}
+			if phases == nil {
+				fmt.Println(t("adopt.canceled"))
+				return nil
+			}
+		}
+		return saveImportedProject(cmd, phases)
+	},
+}
+
+func init() {
+	adoptCmd.Flags().String("name", "", "Name of the project to create (default: git repository name)")
+	adoptCmd.Flags().Bool("force", false, "Replace the project if it already exists")
+	adoptCmd.Flags().BoolP("yes", "y", false, "Save the proposed phases without opening them for review")
+	adoptCmd.Flags().Bool("dry-run", false, "Print the proposed phases as JSON instead of saving them")
+	rootCmd.AddCommand(adoptCmd)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScriptPhases(t *testing.T) {
+	script := `#!/bin/sh
+# Builds everything.
+
+set -eu
+OUT=dist
+
+# Configure
+./configure --prefix="$OUT"
+
+## Build ##
+make
+for f in a b; do
+  echo "$f done"
+done
+
+# Package the release archive
+cat > VERSION <<EOF
+if
+EOF
+tar czf "$OUT.tgz" \
+  "$OUT"
+`
+	path := filepath.Join(t.TempDir(), "build.sh")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	phases, err := scriptPhases(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pre := []string{"set -u", "OUT=dist"}
+	want := []struct {
+		name, description string
+		commands          []string
+	}{
+		{"configure", "", []string{`./configure --prefix="$OUT"`}},
+		{"build", "", []string{"make", "for f in a b; do\n  echo \"$f done\"\ndone"}},
+		{"package", "Package the release archive", []string{"cat > VERSION <<EOF\nif\nEOF", "tar czf \"$OUT.tgz\" \\\n  \"$OUT\""}},
+	}
+	if len(phases) != len(want) {
+		t.Fatalf("got %d phases, want %d: %+v", len(phases), len(want), phases)
+	}
+	for i, w := range want {
+		ph := phases[i]
+		if ph.Name != w.name || ph.Description != w.description || !reflect.DeepEqual(ph.Commands, w.commands) || !reflect.DeepEqual(ph.Pre, pre) {
+			t.Errorf("phase %d = %q %q %q pre %q, want %q %q %q pre %q", i, ph.Name, ph.Description, ph.Commands, ph.Pre, w.name, w.description, w.commands, pre)
+		}
+	}
+}
+
+func TestScriptPhasesWithoutSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci.sh")
+	if err := os.WriteFile(path, []byte("set -e\nX=1\nmake\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	phases, err := scriptPhases(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(phases) != 1 || phases[0].Name != "ci" || !reflect.DeepEqual(phases[0].Commands, []string{"X=1", "make"}) || phases[0].Pre != nil {
+		t.Errorf("phases = %+v, want ci running X=1 and make", phases)
+	}
+}
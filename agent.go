@@ -0,0 +1,477 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// sharedAgent is set once a run has been resolved by a shared agent, which
+// then also records the run's history under the user it authenticated.
+var sharedAgent *struct {
+	socket string
+	token  string
+	user   string
+}
+
+// resolveWithAgent asks a running agent for the project to run. ok is false
+// when no agent is listening, git is disabled or the agent could not resolve
+// the project, in which case the caller resolves the project itself.
+func resolveWithAgent(projectName string) (proj *bild.Project, dir string, settings runSettings, ok bool) {
+	dirs, err := getDirs()
+	if err != nil {
+		return nil, "", runSettings{}, false
+	}
+	// The agent looks up repositories, which --no-git leaves alone.
+	if bild.NoGit {
+		bild.Tracef("agent", "not asking the agent: git is disabled")
+		return nil, "", runSettings{}, false
+	}
+	// The agent reads the JSON config file; other backends resolve locally.
+	if configFile == "" && storageBackend() != bild.StorageJSON {
+		bild.Tracef("agent", "not asking the agent: it cannot read the %s backend", storageBackend())
+		return nil, "", runSettings{}, false
+	}
+	socket := dirs.AgentSocketPath()
+	if _, err := os.Stat(socket); err != nil {
+		bild.Tracef("agent", "no agent socket at %s", socket)
+		return nil, "", runSettings{}, false
+	}
+	configPath, err := getConfigFilePath()
+	if err != nil {
+		return nil, "", runSettings{}, false
+	}
+	if configPath, err = filepath.Abs(configPath); err != nil {
+		return nil, "", runSettings{}, false
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, "", runSettings{}, false
+	}
+
+	token := os.Getenv(bild.AgentTokenEnv)
+	resp, err := bild.AskAgent(socket, bild.AgentRequest{Token: token, ConfigPath: configPath, Dir: cwd, Project: projectName})
+	if err != nil || resp.Project == nil {
+		bild.Tracef("agent", "falling back to local resolution: %v", err)
+		return nil, "", runSettings{}, false
+	}
+	if resp.Global && needsCheckout(resp, projectName != "", cwd) {
+		// Recording the path of a clone, or running at the path, takes
+		// the configuration; see atCheckout.
+		bild.Tracef("agent", "resolving %s locally: it runs at its path or gets it recorded", resp.ProjectName)
+		return nil, "", runSettings{}, false
+	}
+	bild.Tracef("agent", "agent at %s resolved project %s in %s", socket, resp.ProjectName, resp.Dir)
+	if resp.Shared {
+		sharedAgent = &struct{ socket, token, user string }{socket, token, resp.User}
+	}
+	if resp.InRepo {
+		fmt.Fprintln(infoOut, t("run.chdir", resp.Dir))
+	} else {
+		fmt.Fprintln(infoOut, t("run.not_git"))
+	}
+	return resp.Project, resp.Dir, runSettings{logDir: resp.LogDir, terminal: resp.Terminal}, true
+}
+
+// needsCheckout reports whether atCheckout would run the global project
+// the agent resolved elsewhere than the agent did, or record its path.
+func needsCheckout(resp *bild.AgentResponse, named bool, cwd string) bool {
+	if resp.Project.Path == "" {
+		return resp.Clone
+	}
+	path, err := expandHome(resp.Project.Path)
+	return err != nil || named && !resp.Clone && !bild.Within(cwd, path)
+}
+
+// agentCmd runs the agent in the foreground.
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Run a background agent that makes runs start faster (experimental)",
+	Long: `Runs an agent that keeps configuration files parsed and repository roots
+looked up, so that bild run can start the first command without doing either.
+Runs use the agent automatically while it is listening and fall back to
+resolving the project themselves otherwise.
+
+The agent is usually started by the service manager: see bild agent install.
+Requires the daemon experiment.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		if !config.ExperimentEnabled("daemon") {
+			return errors.New(t("err.agent_disabled"))
+		}
+		dirs, err := getDirs()
+		if err != nil {
+			return err
+		}
+		socketPath := dirs.AgentSocketPath()
+		if socket, _ := cmd.Flags().GetString("socket"); socket != "" {
+			socketPath = socket
+		}
+
+		l, err := bild.AgentListener(socketPath)
+		if err != nil {
+			return err
+		}
+		agent := bild.NewAgent()
+		agent.IdleTimeout, _ = cmd.Flags().GetDuration("idle-timeout")
+		agent.WatchInterval, _ = cmd.Flags().GetDuration("watch-interval")
+		if shared, _ := cmd.Flags().GetBool("shared"); shared {
+			if err := shareAgent(agent, dirs, socketPath); err != nil {
+				l.Close()
+				return err
+			}
+			defer agent.History.(bild.Storage).Close()
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Println(t("agent.listening", l.Addr()))
+		return agent.Serve(ctx, l)
+	},
+}
+
+var agentStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the agent is running",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dirs, err := getDirs()
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		resp, err := bild.AskAgent(dirs.AgentSocketPath(), bild.AgentRequest{Ping: true})
+		if err != nil {
+			fmt.Println(t("agent.not_running", dirs.AgentSocketPath()))
+			return nil
+		}
+		fmt.Println(t("agent.status", resp.PID, resp.Uptime.Round(time.Second), resp.Requests, resp.Cached, time.Since(start).Round(time.Microsecond)))
+		return nil
+	},
+}
+
+// agentReloadCmd makes the agent read its cached configuration files again.
+var agentReloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Make the agent read the configuration files again",
+	Long: `Makes the agent read every configuration file it holds again and look up
+repository roots anew. Runs already started keep the configuration they were
+resolved with. The agent also checks its files for changes on its own (see
+bild agent --watch-interval); reload is for edits it can't notice, such as
+ones keeping a file's size and modification time.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dirs, err := getDirs()
+		if err != nil {
+			return err
+		}
+		resp, err := bild.AskAgent(dirs.AgentSocketPath(), bild.AgentRequest{Reload: true, Token: os.Getenv(bild.AgentTokenEnv)})
+		if resp == nil && err != nil {
+			fmt.Println(t("agent.not_running", dirs.AgentSocketPath()))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println(tn("agent.reloaded", resp.Reloaded, resp.Reloaded))
+		for _, msg := range resp.ReloadErrors {
+			fmt.Fprintln(os.Stderr, t("agent.reload_failed", msg))
+		}
+		if len(resp.ReloadErrors) > 0 {
+			return errors.New(tn("agent.reload_errors", len(resp.ReloadErrors), len(resp.ReloadErrors)))
+		}
+		return nil
+	},
+}
+
+// shareAgent prepares agent to serve several users: requests must carry the
+// token of a user added with bild agent token add, and the socket is opened
+// to everyone on the machine.
+func shareAgent(agent *bild.Agent, dirs bild.Dirs, socketPath string) error {
+	usersPath := dirs.AgentUsersPath()
+	users, err := bild.LoadAgentUsers(usersPath)
+	if err != nil {
+		return err
+	}
+	if len(users.Users) == 0 {
+		return fmt.Errorf("a shared agent needs users; add them with bild agent token add <user>")
+	}
+	configPath, err := getConfigFilePath()
+	if err != nil {
+		return err
+	}
+	storage, err := getStorage()
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(socketPath, 0666); err != nil {
+		storage.Close()
+		return err
+	}
+	agent.UsersPath = usersPath
+	agent.ConfigPath = configPath
+	agent.History = storage
+	return nil
+}
+
+// recordWithSharedAgent reports entry to the shared agent the run was resolved by.
+func recordWithSharedAgent(entry bild.HistoryEntry) error {
+	if sharedAgent == nil {
+		return nil
+	}
+	_, err := bild.AskAgent(sharedAgent.socket, bild.AgentRequest{Token: sharedAgent.token, Record: &entry})
+	return err
+}
+
+var agentTokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage the users of a shared agent",
+	Long:  "A shared agent (bild agent --shared) serves every user holding a token. Users set $" + bild.AgentTokenEnv + " to their token and $" + bild.AgentSocketEnv + " to the agent's socket.",
+}
+
+var agentTokenAddCmd = &cobra.Command{
+	Use:   "add <user>",
+	Short: "Create a token for a user (replacing any previous one)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projects, _ := cmd.Flags().GetStringSlice("projects")
+		return updateAgentUsers(func(users *bild.AgentUsers) error {
+			token, err := users.Add(args[0], projects)
+			if err != nil {
+				return err
+			}
+			fmt.Println(t("agent.token_added", args[0]))
+			fmt.Println(token)
+			return nil
+		})
+	},
+}
+
+var agentTokenListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the users of a shared agent",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dirs, err := getDirs()
+		if err != nil {
+			return err
+		}
+		users, err := bild.LoadAgentUsers(dirs.AgentUsersPath())
+		if err != nil {
+			return err
+		}
+		for _, user := range users.Users {
+			projects := "all projects"
+			if len(user.Projects) > 0 {
+				projects = strings.Join(user.Projects, ", ")
+			}
+			fmt.Printf("%-20s %s\n", user.Name, projects)
+		}
+		return nil
+	},
+}
+
+var agentTokenRemoveCmd = &cobra.Command{
+	Use:   "rm <user>",
+	Short: "Revoke a user's token",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateAgentUsers(func(users *bild.AgentUsers) error {
+			if !users.Remove(args[0]) {
+				return fmt.Errorf("no user %s", args[0])
+			}
+			fmt.Println(t("agent.token_removed", args[0]))
+			return nil
+		})
+	},
+}
+
+// updateAgentUsers loads the users of a shared agent, applies update and saves them.
+// A running agent picks up the change with its next request.
+func updateAgentUsers(update func(users *bild.AgentUsers) error) error {
+	dirs, err := getDirs()
+	if err != nil {
+		return err
+	}
+	path := dirs.AgentUsersPath()
+	users, err := bild.LoadAgentUsers(path)
+	if err != nil {
+		return err
+	}
+	if err := update(users); err != nil {
+		return err
+	}
+	return users.Save(path)
+}
+
+// agentServiceArgs returns the command line the service manager starts the agent with.
+func agentServiceArgs(idleTimeout time.Duration) ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{exe}
+	if configDir != "" {
+		base, err := expandHome(configDir)
+		if err != nil {
+			return nil, err
+		}
+		if base, err = filepath.Abs(base); err != nil {
+			return nil, err
+		}
+		args = append(args, "--config-dir", base)
+	}
+	args = append(args, "agent")
+	if idleTimeout > 0 {
+		args = append(args, "--idle-timeout", idleTimeout.String())
+	}
+	return args, nil
+}
+
+// systemdUnits returns the socket and service units for socket activation.
+// The service exits when idle; systemd starts it again on the next connection.
+func systemdUnits(socket string, args []string) (socketUnit, serviceUnit string) {
+	socketUnit = fmt.Sprintf(`[Unit]
+Description=bild agent socket
+
+[Socket]
+ListenStream=%s
+SocketMode=0600
+
+[Install]
+WantedBy=sockets.target
+`, socket)
+	serviceUnit = fmt.Sprintf(`[Unit]
+Description=bild agent
+Requires=bild-agent.socket
+
+[Service]
+ExecStart=%s
+`, strings.Join(args, " "))
+	return socketUnit, serviceUnit
+}
+
+// launchdPlist returns a launch agent keeping the agent running. launchd can
+// only pass sockets to programs using its C API, so the agent binds its
+// socket itself and is kept alive instead of activated on demand.
+func launchdPlist(args []string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+  <key>Label</key>
+  <string>io.github.rkabrick.bild-agent</string>
+  <key>ProgramArguments</key>
+  <array>
+`)
+	for _, arg := range args {
+		fmt.Fprintf(&b, "    <string>%s</string>\n", arg)
+	}
+	b.WriteString(`  </array>
+  <key>RunAtLoad</key>
+  <true/>
+  <key>KeepAlive</key>
+  <true/>
+</dict>
+</plist>
+`)
+	return b.String()
+}
+
+var agentInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the agent as a user service (systemd or launchd)",
+	Long:  "Writes systemd user units that start the agent on the first connection to its socket, or on macOS a launchd agent that keeps it running, and prints the command that enables it.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dirs, err := getDirs()
+		if err != nil {
+			return err
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+
+		launchd, _ := cmd.Flags().GetBool("launchd")
+		if !cmd.Flags().Changed("launchd") {
+			launchd = runtime.GOOS == "darwin"
+		}
+
+		var files [][2]string // path, content
+		var enable string
+		if launchd {
+			serviceArgs, err := agentServiceArgs(0)
+			if err != nil {
+				return err
+			}
+			path := filepath.Join(home, "Library", "LaunchAgents", "io.github.rkabrick.bild-agent.plist")
+			files = append(files, [2]string{path, launchdPlist(serviceArgs)})
+			enable = "launchctl load -w " + path
+		} else {
+			serviceArgs, err := agentServiceArgs(30 * time.Minute)
+			if err != nil {
+				return err
+			}
+			socket, err := filepath.Abs(dirs.AgentSocketPath())
+			if err != nil {
+				return err
+			}
+			configHome := os.Getenv("XDG_CONFIG_HOME")
+			if !filepath.IsAbs(configHome) {
+				configHome = filepath.Join(home, ".config")
+			}
+			unitDir := filepath.Join(configHome, "systemd", "user")
+			socketUnit, serviceUnit := systemdUnits(socket, serviceArgs)
+			files = append(files,
+				[2]string{filepath.Join(unitDir, "bild-agent.socket"), socketUnit},
+				[2]string{filepath.Join(unitDir, "bild-agent.service"), serviceUnit},
+			)
+			enable = "systemctl --user daemon-reload && systemctl --user enable --now bild-agent.socket"
+		}
+
+		for _, file := range files {
+			path, content := file[0], file[1]
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				return err
+			}
+			fmt.Println(t("export.done", path))
+		}
+		fmt.Println(t("agent.enable", enable))
+		return nil
+	},
+}
+
+func init() {
+	agentCmd.Flags().Duration("idle-timeout", 0, "Exit after this long without requests (0: never)")
+	agentCmd.Flags().Duration("watch-interval", 2*time.Second, "Check the configuration files for changes this often (0: only when a run asks)")
+	agentCmd.Flags().Bool("shared", false, "Serve all users on this machine who hold a token (see bild agent token)")
+	agentCmd.Flags().String("socket", "", "Listen on this socket (default: $"+bild.AgentSocketEnv+" or agent.sock in the state directory)")
+	agentTokenAddCmd.Flags().StringSlice("projects", nil, "Projects the user may see and run (default: all)")
+	agentTokenCmd.AddCommand(agentTokenAddCmd)
+	agentTokenCmd.AddCommand(agentTokenListCmd)
+	agentTokenCmd.AddCommand(agentTokenRemoveCmd)
+	agentCmd.AddCommand(agentTokenCmd)
+	agentInstallCmd.Flags().Bool("launchd", false, "Install a launchd agent instead of systemd units (default on macOS)")
+	agentCmd.AddCommand(agentStatusCmd)
+	agentCmd.AddCommand(agentReloadCmd)
+	agentCmd.AddCommand(agentInstallCmd)
+	rootCmd.AddCommand(agentCmd)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// aliasCandidate is a project+phase combination together with how often it was run.
+type aliasCandidate struct {
+	project string
+	phase   string
+	runs    int
+}
+
+// shellWords are the reserved words and builtins of sh, bash, zsh and fish,
+// which no executable on the PATH reveals, and which an alias must not
+// shadow.
+var shellWords = map[string]bool{
+	// Reserved words.
+	"if": true, "then": true, "else": true, "elif": true, "fi": true, "case": true, "esac": true,
+	"for": true, "select": true, "while": true, "until": true, "do": true, "done": true, "in": true,
+	"function": true, "time": true, "coproc": true, "repeat": true, "foreach": true, "end": true,
+	"begin": true, "switch": true, "and": true, "or": true, "not": true,
+	// Builtins.
+	"alias": true, "bg": true, "bind": true, "break": true, "builtin": true, "caller": true,
+	"cd": true, "command": true, "compgen": true, "complete": true, "continue": true,
+	"declare": true, "dirs": true, "disown": true, "echo": true, "enable": true, "eval": true,
+	"exec": true, "exit": true, "export": true, "false": true, "fc": true, "fg": true,
+	"getopts": true, "hash": true, "help": true, "history": true, "jobs": true, "kill": true,
+	"let": true, "local": true, "logout": true, "popd": true, "printf": true, "pushd": true,
+	"pwd": true, "read": true, "readonly": true, "return": true, "set": true, "shift": true,
+	"shopt": true, "source": true, "suspend": true, "test": true, "times": true, "trap": true,
+	"true": true, "type": true, "typeset": true, "ulimit": true, "umask": true, "unalias": true,
+	"unset": true, "wait": true, "bindkey": true, "emulate": true, "setopt": true, "unsetopt": true,
+	"whence": true, "where": true, "which": true, "zle": true, "abbr": true, "contains": true,
+	"count": true, "funced": true, "functions": true, "math": true, "status": true, "string": true,
+}
+
+// aliasName derives a short alias from the leading letters of project and
+// phase ("backend", "build" → "bb"), growing it until it is unused and
+// shadows neither a shell keyword or builtin nor an executable, or else
+// falls back to "project_phase", numbered if that is taken too.
+func aliasName(project, phase string, taken map[string]bool) string {
+	project = strings.ToLower(project)
+	phase = strings.ToLower(phase)
+	for p := 1; p <= len(project); p++ {
+		for q := 1; q <= len(phase); q++ {
+			name := sanitizeAlias(project[:p] + phase[:q])
+			if name == "" || taken[name] || shellWords[name] {
+				continue
+			}
+			if _, err := exec.LookPath(name); err == nil {
+				continue
+			}
+			return name
+		}
+	}
+	// Every prefix is in use; number the full name until it is not.
+	base := sanitizeAlias(project + "_" + phase)
+	name := base
+	for n := 2; taken[name]; n++ {
+		name = base + strconv.Itoa(n)
+	}
+	return name
+}
+
+// sanitizeAlias drops characters that are not valid in shell alias names.
+func sanitizeAlias(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return -1
+	}, name)
+}
+
+// shellQuote quotes s for use in a POSIX or fish shell unless it consists of
+// characters that need no quoting.
+func shellQuote(s string) string {
+	safe := s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=,+@%", r))
+	}) < 0
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// genAliasesCmd prints shell aliases for the most frequently run project phases.
+var genAliasesCmd = &cobra.Command{
+	Use:   "gen-aliases",
+	Short: "Print shell aliases for your most-run project phases",
+	Long: `Derives short aliases (e.g. bb → bild run backend build) for the project+phase
+combinations you run most often, based on the run history, and prints them in a
+form you can source from your shell rc file:
+
+  eval "$(bild gen-aliases)"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		top, _ := cmd.Flags().GetInt("top")
+		shell, _ := cmd.Flags().GetString("shell")
+		if shell != "sh" && shell != "fish" {
+			return fmt.Errorf("invalid --shell value %q (expected sh or fish)", shell)
+		}
+
+		entries, err := loadHistory("")
+		if err != nil {
+			return err
+		}
+		counts := make(map[[2]string]int)
+		for _, e := range entries {
+			if !e.AdHoc() && !e.PipelineRun() {
+				counts[[2]string{e.Project, e.Phase}]++
+			}
+		}
+
+		candidates := make([]aliasCandidate, 0, len(counts))
+		for k, n := range counts {
+			candidates = append(candidates, aliasCandidate{project: k[0], phase: k[1], runs: n})
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].runs != candidates[j].runs {
+				return candidates[i].runs > candidates[j].runs
+			}
+			if candidates[i].project != candidates[j].project {
+				return candidates[i].project < candidates[j].project
+			}
+			return candidates[i].phase < candidates[j].phase
+		})
+		if top > 0 && len(candidates) > top {
+			candidates = candidates[:top]
+		}
+
+		fmt.Println("# " + t("aliases.header"))
+		taken := make(map[string]bool)
+		for _, c := range candidates {
+			name := aliasName(c.project, c.phase, taken)
+			taken[name] = true
+			command := "bild run " + shellQuote(c.project) + " " + shellQuote(c.phase)
+			if shell == "fish" {
+				fmt.Printf("alias %s %s  # %s\n", name, shellQuote(command), tn("aliases.runs", c.runs, c.runs))
+			} else {
+				fmt.Printf("alias %s=%s  # %s\n", name, shellQuote(command), tn("aliases.runs", c.runs, c.runs))
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	genAliasesCmd.Flags().Int("top", 10, "Number of aliases to generate (0 for all)")
+	genAliasesCmd.Flags().String("shell", "sh", "Alias syntax: sh (bash, zsh) or fish")
+	genAliasesCmd.RegisterFlagCompletionFunc("shell", cobra.FixedCompletions([]string{"sh", "fish"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.AddCommand(genAliasesCmd)
+}
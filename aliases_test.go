@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestAliasName(t *testing.T) {
+	// No executables to shadow, only shell words.
+	t.Setenv("PATH", t.TempDir())
+	taken := map[string]bool{"bb": true, "gt": true, "g_t": true, "g_t2": true}
+	for _, tt := range []struct{ project, phase, want string }{
+		{"infra", "format", "ifo"},
+		{"ci", "deploy", "cde"},
+		{"Backend", "build", "bbu"},
+		{"web", "test", "wt"},
+		{"g", "t", "g_t3"},
+	} {
+		if got := aliasName(tt.project, tt.phase, taken); got != tt.want {
+			t.Errorf("aliasName(%s, %s) = %s, want %s", tt.project, tt.phase, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd groups the commands managing the artifact cache.
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect or clear the cached outputs of phases",
+	Long: `Phases with inputs and outputs have their outputs kept in the artifact cache
+after they succeed, keyed by a hash of their inputs. A later run with the same
+inputs, even in another checkout, restores the outputs instead of running the
+phase. The cache lives below the cache directory (~/.cache/bild on Linux; see
+'bild storage paths') and can be cleared at any time.`,
+}
+
+var cacheLsCmd = &cobra.Command{
+	Use:               "ls [project]",
+	Short:             "List the cached outputs, of a project or of all projects",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := cacheEntries(args)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println(t("cache.none"))
+			return nil
+		}
+		fmt.Printf("%-12s %-20s %-12s %6s %9s %5s  %s\n", "KEY", "PROJECT", "PHASE", "FILES", "SIZE", "HITS", "CREATED")
+		for _, e := range entries {
+			fmt.Printf("%-12s %-20s %-12s %6d %9s %5d  %s\n", e.Key[:12], e.Project, e.Phase, e.Files, formatBytes(uint64(e.Size)), e.Hits, e.Created.Local().Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:               "clear [project]",
+	Short:             "Remove the cached outputs of a project",
+	Long:              "Removes the cached outputs of the project, or the whole cache with --all.",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		if all == (len(args) == 1) {
+			return errors.New("give either a project or --all")
+		}
+		cache, err := artifactCache()
+		if err != nil {
+			return err
+		}
+		entries, err := cacheEntries(args)
+		if err != nil {
+			return err
+		}
+		var failed error
+		var size int64
+		removed := 0
+		for _, e := range entries {
+			if err := cache.Remove(e.Key); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", e.Key, err)
+				failed = err
+				continue
+			}
+			removed++
+			size += e.Size
+		}
+		fmt.Println(tn("cache.cleared", removed, removed, formatBytes(uint64(size))))
+		return failed
+	},
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the size of the cache and how often it was used, by project and phase",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cache, err := artifactCache()
+		if err != nil {
+			return err
+		}
+		entries, err := cache.Entries()
+		if err != nil {
+			return err
+		}
+		type stats struct {
+			entries, hits int
+			size          int64
+		}
+		var total stats
+		byPhase := make(map[string]*stats)
+		for _, e := range entries {
+			name := e.Project + "/" + e.Phase
+			if byPhase[name] == nil {
+				byPhase[name] = &stats{}
+			}
+			for _, s := range []*stats{&total, byPhase[name]} {
+				s.entries++
+				s.hits += e.Hits
+				s.size += e.Size
+			}
+		}
+		fmt.Printf("%-8s %s\n", "dir", cache.Dir)
+		fmt.Printf("%-8s %d\n", "entries", total.entries)
+		fmt.Printf("%-8s %s\n", "size", formatBytes(uint64(total.size)))
+		fmt.Printf("%-8s %d\n", "hits", total.hits)
+		if len(byPhase) == 0 {
+			return nil
+		}
+		names := make([]string, 0, len(byPhase))
+		for name := range byPhase {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Println()
+		fmt.Printf("%-33s %7s %9s %5s\n", "PHASE", "ENTRIES", "SIZE", "HITS")
+		for _, name := range names {
+			s := byPhase[name]
+			fmt.Printf("%-33s %7d %9s %5d\n", name, s.entries, formatBytes(uint64(s.size)), s.hits)
+		}
+		return nil
+	},
+}
+
+// artifactCache returns the artifact cache below the cache directory.
+func artifactCache() (*bild.ArtifactCache, error) {
+	dirs, err := getDirs()
+	if err != nil {
+		return nil, err
+	}
+	return dirs.ArtifactCache(), nil
+}
+
+// cacheEntries returns the cache entries of the project named in args, or
+// all entries if args is empty.
+func cacheEntries(args []string) ([]bild.CacheEntry, error) {
+	cache, err := artifactCache()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := cache.Entries()
+	if err != nil || len(args) == 0 {
+		return entries, err
+	}
+	var selected []bild.CacheEntry
+	for _, e := range entries {
+		if e.Project == args[0] {
+			selected = append(selected, e)
+		}
+	}
+	return selected, nil
+}
+
+func init() {
+	cacheClearCmd.Flags().Bool("all", false, "Clear the whole cache")
+	cacheCmd.AddCommand(cacheLsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// localBundle returns the bundle of the projects this machine resolves from
+// the current directory, local configs included.
+func localBundle(cmd *cobra.Command) (*bild.Bundle, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf(t("err.load_config"), err)
+	}
+	local, _, err := findLocalConfigs(config)
+	if err != nil {
+		return nil, err
+	}
+	if local != nil {
+		config = local
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if root, err := bild.RepoRoot(""); err == nil {
+		dir = root
+	}
+	return bild.NewBundle(cmd.Context(), config, dir), nil
+}
+
+// exportBundleCmd writes the bundle compare-config compares with.
+var exportBundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Write the resolved projects and tool versions of this machine",
+	Long: `Writes a bundle of every project as it resolves on this machine, with the
+versions of the tools they use, for bild compare-config on another machine.
+Secrets are listed by where they come from, never by value.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bundle, err := localBundle(cmd)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(bundle, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeExport(cmd, "bild-bundle.json", append(data, '\n'), 0644)
+	},
+}
+
+// compareConfigCmd diffs this machine's projects against a bundle.
+var compareConfigCmd = &cobra.Command{
+	Use:   "compare-config <bundle> [project...]",
+	Short: "Compare the resolved projects with a bundle from another machine",
+	Long: `Compares the projects as they resolve here with a bundle written by
+'bild export bundle' on another machine, listing the phases, settings, pins and
+tool versions that differ, to track down "it works on their machine".
+Exits with status 1 if anything differs.`,
+	Example: `  # on their machine
+  bild export bundle -o theirs.json
+  # on yours
+  bild compare-config theirs.json backend`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		theirs, err := bild.ReadBundle(args[0])
+		if err != nil {
+			return err
+		}
+		mine, err := localBundle(cmd)
+		if err != nil {
+			return err
+		}
+		if only := args[1:]; len(only) > 0 {
+			for _, b := range []*bild.Bundle{mine, theirs} {
+				projects := make(map[string]*bild.Project)
+				for _, name := range only {
+					if proj, ok := b.Projects[name]; ok {
+						projects[name] = proj
+					}
+				}
+				b.Projects = projects
+			}
+		}
+
+		fmt.Println(t("compare.header", theirs.Host, theirs.OS, theirs.Arch, theirs.Created.Local().Format("2006-01-02 15:04")))
+		diffs := bild.CompareBundles(mine, theirs)
+		if len(diffs) == 0 {
+			fmt.Println(t("compare.same"))
+			return nil
+		}
+		last := "\x00"
+		for _, d := range diffs {
+			if d.Project != last {
+				last = d.Project
+				fmt.Println()
+				if d.Project == "" {
+					fmt.Println(plain(t("compare.tools")))
+				} else {
+					fmt.Println(plain(t("compare.project", d.Project)))
+				}
+			}
+			where := d.Setting
+			if d.Phase != "" {
+				where = "phase " + d.Phase
+				if d.Setting != "" {
+					where += ": " + d.Setting
+				}
+			}
+			if where == "" {
+				where = "project"
+			}
+			fmt.Printf("  %s\n", where)
+			fmt.Printf("    %-7s %s\n", "mine:", orMissing(d.Mine))
+			fmt.Printf("    %-7s %s\n", "theirs:", orMissing(d.Theirs))
+		}
+		fmt.Println()
+		return errors.New(tn("compare.differences", len(diffs), len(diffs)))
+	},
+}
+
+// orMissing returns s, or a placeholder if it is empty.
+func orMissing(s string) string {
+	if s == "" {
+		return "(missing)"
+	}
+	return s
+}
+
+func init() {
+	exportCmd.AddCommand(exportBundleCmd)
+	rootCmd.AddCommand(compareConfigCmd)
+}
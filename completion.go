@@ -0,0 +1,127 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// completionConfig returns the configuration projects are completed from:
+// the local configs a run in the current directory would find, if any,
+// layered over the global configuration.
+func completionConfig() *bild.Config {
+	config, err := loadConfig()
+	if err != nil {
+		config = bild.NewConfig()
+	}
+	if local, _, _ := findLocalConfigs(config); local != nil {
+		return local
+	}
+	return config
+}
+
+// completionProjects returns the names of the projects known to bild: those
+// in the global configuration and those in the .bild.json of the current
+// repository, sorted.
+func completionProjects() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for config := completionConfig(); config != nil; config = config.Parent {
+		for _, name := range config.ProjectNames() {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withDescription attaches a description shown by shells that support it.
+func withDescription(name, description string) string {
+	if description == "" {
+		return name
+	}
+	return name + "\t" + description
+}
+
+// completeProjects completes the first argument with project names.
+func completeProjects(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, name := range completionProjects() {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProjectPhases completes a project name followed by one of its phases.
+func completeProjectPhases(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return completeProjects(cmd, args, toComplete)
+	case 1:
+		proj, err := completionConfig().Project(args[0])
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var names []string
+		for _, ph := range proj.Phases {
+			if strings.HasPrefix(ph.Name, toComplete) {
+				names = append(names, withDescription(ph.Name, ph.Description))
+			}
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfiles completes the profiles of the project named by the first
+// argument.
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	proj, err := completionConfig().Project(args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for name, profile := range proj.Profiles {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, withDescription(name, profile.Description))
+		}
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeExperiments completes the names of known experiments.
+func completeExperiments(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var names []string
+	for _, exp := range bild.Experiments() {
+		if strings.HasPrefix(exp.Name, toComplete) {
+			names = append(names, withDescription(exp.Name, exp.Description))
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{rootCmd, dumpCmd, oneCmd, historyCmd, statsCmd, exportGHACmd, exportGitLabCmd, exportShCmd} {
+		cmd.ValidArgsFunction = completeProjects
+	}
+	for _, cmd := range []*cobra.Command{runCmd, editCmd, mvCmd} {
+		cmd.ValidArgsFunction = completeProjectPhases
+	}
+	runCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+	experimentsEnableCmd.ValidArgsFunction = completeExperiments
+	experimentsDisableCmd.ValidArgsFunction = completeExperiments
+}
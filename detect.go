@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+// askProposal asks whether to save, edit or drop proposed phases and
+// returns the answer as 'y', 'e' or 'n'; an empty answer saves them.
+func askProposal() (byte, error) {
+	in := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Print(t("detect.ask"))
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			return 0, err
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "", "y", "yes", "j", "ja":
+			return 'y', nil
+		case "e", "edit":
+			return 'e', nil
+		case "n", "no", "nein":
+			return 'n', nil
+		}
+	}
+}
+
+// detectCmd proposes phases for the build system of the repository.
+var detectCmd = &cobra.Command{
+	Use:   "detect",
+	Short: "Detect the build system of the repository and propose phases for it",
+	Long: `Looks for the files of common build systems in the repository root, such
+as CMakeLists.txt, meson.build, go.mod, Cargo.toml, package.json and
+Makefile, and proposes the phases of the matching template (see 'bild init
+--list'). User templates take part with a "markers" list of the files, or
+glob patterns, telling their kind. If several build systems are found, the
+first of the list above is proposed; choose another with --template.
+
+The proposal is then saved as a project, opened in $EDITOR to edit it
+first, or dropped, as you answer.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := bild.RepoRoot("")
+		if err != nil {
+			dir = "."
+		}
+		tmplDir, err := templatesDir()
+		if err != nil {
+			return err
+		}
+		detections, err := bild.DetectTemplates(dir, tmplDir)
+		if err != nil {
+			return err
+		}
+		if len(detections) == 0 {
+			return errors.New(t("detect.none", dir))
+		}
+		chosen := detections[0]
+		if name, _ := cmd.Flags().GetString("template"); name != "" {
+			found := false
+			for _, d := range detections {
+				if d.Template.Name == name {
+					chosen, found = d, true
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s was not detected; use bild init --template %s to create the project anyway", name, name)
+			}
+		}
+		phases := chosen.Template.Phases
+
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			return writePhasesJSON(os.Stdout, phases)
+		}
+		for _, d := range detections {
+			fmt.Println(t("detect.found", d.Marker, d.Template.Name, d.Template.Description))
+		}
+		if len(detections) > 1 {
+			fmt.Println(t("detect.using", chosen.Template.Name))
+		}
+		if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+			for _, phase := range phases {
+				fmt.Println(t("import.phase", phase.Name, strings.Join(phase.Commands, "; ")))
+			}
+			if !isatty.IsTerminal(os.Stdin.Fd()) {
+				return errors.New("not asking without a terminal; save the proposal with --yes")
+			}
+			answer, err := askProposal()
+			if err != nil {
+				return err
+			}
+			if answer == 'e' {
+				if phases, err = reviewPhases(phases); err != nil {
+					return err
+				}
+			}
+			if answer == 'n' || phases == nil {
+				fmt.Println(t("adopt.canceled"))
+				return nil
+			}
+		}
+		return saveImportedProject(cmd, phases)
+	},
+}
+
+func init() {
+	detectCmd.Flags().String("template", "", "Propose the phases of this detected template instead of the first")
+	detectCmd.Flags().String("name", "", "Name of the project to create (default: git repository name)")
+	detectCmd.Flags().Bool("force", false, "Replace the project if it already exists")
+	detectCmd.Flags().BoolP("yes", "y", false, "Save the proposed phases without asking")
+	detectCmd.Flags().Bool("dry-run", false, "Print the proposed phases as JSON instead of saving them")
+	detectCmd.RegisterFlagCompletionFunc("template", completeTemplates)
+	rootCmd.AddCommand(detectCmd)
+}
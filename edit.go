@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// openEditor opens the user's preferred editor (from $EDITOR, defaulting to "vi")
+// on a temporary file with a .md extension (for syntax highlighting) and returns its contents.
+func openEditor(initialContent string) (string, error) {
+	return openEditorAs(initialContent, "bild_edit_*.md")
+}
+
+// openEditorAs is openEditor for a temporary file named after pattern, as
+// for os.CreateTemp, whose extension selects the syntax highlighting.
+func openEditorAs(initialContent, pattern string) (string, error) {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+
+	tmpFile, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	tmpFileName := tmpFile.Name()
+
+	if initialContent != "" {
+		if _, err := tmpFile.WriteString(initialContent); err != nil {
+			return "", err
+		}
+	}
+	tmpFile.Close()
+
+	cmd := exec.Command(editor, tmpFileName)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(tmpFileName)
+	if err != nil {
+		return "", err
+	}
+	os.Remove(tmpFileName)
+	return string(content), nil
+}
+
+// editEntireProject using Markdown format
+// TODO: Maybe just cut my losses and keep it in the JSON format - I'm just a slut for some syntax highlighting
+func editEntireProject(projectName string, config *bild.Config) error {
+	// Get or create the project configuration
+	proj, exists := config.Projects[projectName]
+	if !exists {
+		proj = bild.Project{Phases: []bild.Phase{}}
+	}
+
+	// Build the initial content in Markdown format
+	var initialContent strings.Builder
+
+	// Project header
+	initialContent.WriteString("# Project: " + projectName + "\n\n")
+
+	// Instructions
+	initialContent.WriteString("Edit commands for each phase below. Instructions:\n")
+	initialContent.WriteString("- Order of phases here determines execution order\n")
+	initialContent.WriteString("- Commands must be inside ``` blocks\n")
+	initialContent.WriteString("- Each phase must be a level 2 heading (##)\n\n")
+
+	// Add existing phases
+	for _, phase := range proj.Phases {
+		initialContent.WriteString("## " + phase.Name + "\n\n")
+		initialContent.WriteString("```bash\n")
+		for i, cmd := range phase.Commands {
+			initialContent.WriteString(cmd)
+			if i < len(phase.Commands)-1 {
+				initialContent.WriteString("\n")
+			}
+		}
+		initialContent.WriteString("\n```\n\n")
+	}
+
+	// Open editor
+	editedContent, err := openEditor(initialContent.String())
+	if err != nil {
+		return err
+	}
+
+	// Parse the edited content
+	var newPhases []bild.Phase
+	var currentPhase *bild.Phase
+	var inCodeBlock bool
+	var codeLines []string
+
+	lines := strings.Split(editedContent, "\n")
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		// Skip empty lines and the project header
+		if trimmed == "" || strings.HasPrefix(trimmed, "# Project:") ||
+			strings.HasPrefix(trimmed, "Edit commands") || strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+
+		// Check for phase headers (##)
+		if strings.HasPrefix(trimmed, "## ") {
+			// If we were building a phase, finalize it
+			if currentPhase != nil && len(codeLines) > 0 {
+				currentPhase.Commands = codeLines
+				newPhases = append(newPhases, *currentPhase)
+			}
+
+			// Start a new phase
+			phaseName := strings.TrimSpace(trimmed[3:])
+			currentPhase = &bild.Phase{
+				Name:     phaseName,
+				Commands: []string{},
+			}
+			codeLines = nil
+			inCodeBlock = false
+			continue
+		}
+
+		// Handle code blocks
+		if trimmed == "```" || trimmed == "```bash" {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+
+		// Collect commands inside code blocks
+		if inCodeBlock && currentPhase != nil && trimmed != "" {
+			codeLines = append(codeLines, trimmed)
+		}
+	}
+
+	// Add the last phase if it exists
+	if currentPhase != nil && len(codeLines) > 0 {
+		currentPhase.Commands = codeLines
+		newPhases = append(newPhases, *currentPhase)
+	}
+
+	// Update the project with the new phases
+	proj.Phases = newPhases
+	config.Projects[projectName] = proj
+
+	// Save the configuration
+	if err := saveConfig(config); err != nil {
+		return fmt.Errorf(t("err.save_config"), err)
+	}
+
+	fmt.Println(tn("edit.project_updated", len(newPhases), projectName, len(newPhases)))
+	for _, phase := range newPhases {
+		fmt.Println(tn("edit.phase_summary", len(phase.Commands), phase.Name, len(phase.Commands)))
+	}
+
+	return nil
+}
+
+// editProjectPhase opens the editor to modify the commands for a given phase of a project.
+// If the project or phase does not exist, they are created.
+func editProjectPhase(projectName string, phaseName string, config *bild.Config) error {
+	// Get or create the project configuration.
+	proj, exists := config.Projects[projectName]
+	if !exists {
+		proj = bild.Project{Phases: []bild.Phase{}}
+	}
+	// Search for the phase.
+	var phase *bild.Phase
+	for i, ph := range proj.Phases {
+		if ph.Name == phaseName {
+			phase = &proj.Phases[i]
+			break
+		}
+	}
+	if phase == nil {
+		// Create a new phase, starting from the inherited one if there is one.
+		newPhase := bild.Phase{
+			Name:     phaseName,
+			Commands: []string{},
+		}
+		if resolved, err := config.WithoutOverrides().Project(projectName); err == nil {
+			if inherited, err := resolved.Phase(phaseName); err == nil {
+				newPhase = *inherited
+				newPhase.Commands = append([]string{}, inherited.Commands...)
+			}
+		}
+		proj.Phases = append(proj.Phases, newPhase)
+		phase = &proj.Phases[len(proj.Phases)-1]
+	}
+
+	// Build the initial content for editing.
+	var initialContent string
+	if len(phase.Commands) > 0 {
+		initialContent = strings.Join(phase.Commands, "\n")
+	} else {
+		initialContent = "# Enter one command per line for phase '" + phaseName + "'.\n# Lines starting with '#' are ignored.\n"
+	}
+
+	editedContent, err := openEditor(initialContent)
+	if err != nil {
+		return err
+	}
+
+	// Parse the edited content.
+	var newCommands []string
+	for _, line := range strings.Split(editedContent, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		newCommands = append(newCommands, trimmed)
+	}
+	phase.SetCommands(newCommands)
+
+	// Update the project configuration.
+	config.Projects[projectName] = proj
+	if err := saveConfig(config); err != nil {
+		return fmt.Errorf(t("err.save_config"), err)
+	}
+	fmt.Println(tn("edit.phase_updated", len(newCommands), projectName, phaseName, len(newCommands)))
+	return nil
+}
+
+// Modify the editCmd to handle both full project and single phase editing
+// If no phase is provided, it defaults to the "build" phase.
+var editCmd = &cobra.Command{
+	Use:   "edit [project] [phase]",
+	Short: "Edit build commands for a project",
+	Long: `Opens your preferred editor to modify build commands.
+If only a project name is provided, allows editing and reordering all phases.
+If both project and phase are provided, edits only that specific phase.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectName := args[0]
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+
+		if len(args) == 1 {
+			return editEntireProject(projectName, config)
+		}
+
+		// Edit specific phase (existing behavior)
+		phaseName := args[1]
+		return editProjectPhase(projectName, phaseName, config)
+	},
+}
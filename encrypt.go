@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+)
+
+// setupKey points bild at the key file in the config directory.
+func setupKey() {
+	if dirs, err := getDirs(); err == nil {
+		bild.KeyFile = dirs.KeyPath()
+	}
+}
+
+// readSecret reads a secret value: from the terminal without echoing it,
+// after prompting for it, or else the first line of stdin.
+func readSecret(prompt string) (string, error) {
+	if term.IsTerminal(os.Stdin.Fd()) {
+		fmt.Fprint(os.Stderr, prompt)
+		value, err := term.ReadPassword(os.Stdin.Fd())
+		fmt.Fprintln(os.Stderr)
+		return string(value), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// encryptCmd groups the commands keeping parts of the config encrypted.
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Keep projects or secret values encrypted in the global config",
+	Long: `Encrypts projects of the global config, or single secret values, with
+AES-256-GCM, so that deploy commands with tokens can live in a config that is
+synced or kept in dotfiles. The key is a key file, created with 'bild
+encrypt key' next to the config (see 'bild storage paths') and copied to your
+other machines by hand, as it is never synced; $` + bild.KeyFileEnv + ` points at
+another. Alternatively, $` + bild.PassphraseEnv + ` holds a passphrase.
+
+Encrypted projects are decrypted as the config loads, and encrypted again
+when it is saved, so that they are edited and run as usual. Without the key
+they are kept as they are, and only fail to run.`,
+}
+
+var encryptKeyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Create the key file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := os.Getenv(bild.KeyFileEnv)
+		if path == "" {
+			path = bild.KeyFile
+		}
+		if err := bild.GenerateKey(path); err != nil {
+			if errors.Is(err, os.ErrExist) {
+				return fmt.Errorf("%s already exists; remove it first if it is no longer needed", path)
+			}
+			return err
+		}
+		fmt.Println(t("encrypt.key", path))
+		return nil
+	},
+}
+
+var encryptProjectCmd = &cobra.Command{
+	Use:               "project <project>",
+	Short:             "Keep a project of the global config encrypted",
+	Long:              "Encrypts the whole project in the global config, phases, commands and env included. With --decrypt, the project is saved in plain text again.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		decrypt, _ := cmd.Flags().GetBool("decrypt")
+		if decrypt {
+			err = config.Unseal(args[0])
+		} else {
+			err = config.Seal(args[0])
+		}
+		if err != nil {
+			return err
+		}
+		if err := saveConfig(config); err != nil {
+			return fmt.Errorf(t("err.save_config"), err)
+		}
+		if decrypt {
+			fmt.Println(t("encrypt.project_decrypted", args[0]))
+		} else {
+			fmt.Println(t("encrypt.project", args[0]))
+		}
+		return nil
+	},
+}
+
+var encryptEnvCmd = &cobra.Command{
+	Use:   "env <project> <NAME>",
+	Short: "Store an encrypted secret value of a project",
+	Long: `Reads a value, hidden as you type it or from stdin, and stores it encrypted
+in the project's secrets.env as ${secret:encrypted:...}. It is decrypted as
+a run starts, passed to the commands as the environment variable NAME and
+masked in the output like other secrets.`,
+	Example: `  bild encrypt env deploy NPM_TOKEN
+  pass show npm | bild encrypt env deploy NPM_TOKEN`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectName, name := args[0], args[1]
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		proj, ok := config.Projects[projectName]
+		if !ok {
+			return &bild.ProjectNotFoundError{Project: projectName}
+		}
+		value, err := readSecret(t("encrypt.prompt", name))
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			return errors.New("no value given")
+		}
+		encrypted, err := bild.Encrypt([]byte(value))
+		if err != nil {
+			return err
+		}
+		if proj.Secrets == nil {
+			proj.Secrets = &bild.Secrets{}
+		}
+		if proj.Secrets.Env == nil {
+			proj.Secrets.Env = make(map[string]string)
+		}
+		proj.Secrets.Env[name] = "${secret:encrypted:" + encrypted + "}"
+		config.Projects[projectName] = proj
+		if err := saveConfig(config); err != nil {
+			return fmt.Errorf(t("err.save_config"), err)
+		}
+		fmt.Println(t("encrypt.env", name, projectName))
+		return nil
+	},
+}
+
+func init() {
+	encryptProjectCmd.Flags().Bool("decrypt", false, "Save the project in plain text again")
+	encryptCmd.AddCommand(encryptKeyCmd, encryptProjectCmd, encryptEnvCmd)
+	rootCmd.AddCommand(encryptCmd)
+}
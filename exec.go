@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// execCmd runs a program in a project's environment.
+var execCmd = &cobra.Command{
+	Use:   "exec [project] -- command [args...]",
+	Short: "Run a program with a project's environment applied",
+	Long: `Runs a program where the project's phases run, with the project's secrets
+in the environment and, if the project (or the phase) sets "template": true,
+templates such as {{ .Dir }} in its arguments expanded. With --phase, the
+shell, umask, locale, limits and network settings of that phase apply as well. Nothing is recorded and no hooks run; unlike bild one,
+the program's input and output are passed through untouched, so that shells,
+debuggers and REPLs work. The program's exit code becomes bild's.
+
+If no project is given, it is deduced from the git repository.`,
+	Example: `  bild exec -- env
+  bild exec backend --phase test -- dlv test ./internal/store
+  bild exec -- bash`,
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dash := cmd.ArgsLenAtDash()
+		if dash < 0 || dash == len(args) {
+			return fmt.Errorf("no command given; use: bild exec [project] -- <command>")
+		}
+		if dash > 1 {
+			return fmt.Errorf("separate the command from the project with --")
+		}
+		var projectName string
+		if dash == 1 {
+			projectName = args[0]
+		}
+		phaseName, _ := cmd.Flags().GetString("phase")
+		// Keep stdout to the program, so that its output can be piped.
+		infoOut = os.Stderr
+		if quiet {
+			infoOut = io.Discard
+		}
+
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		proj, dir, err := resolveProject(projectName, config)
+		if err != nil {
+			return err
+		}
+
+		runner := bild.NewRunner()
+		runner.Dir = dir
+		ctx, stop := bild.NotifyContext(cmd.Context())
+		defer stop()
+		return runner.Exec(ctx, proj, phaseName, args[dash:])
+	},
+}
+
+func init() {
+	execCmd.Flags().StringP("phase", "p", "", "Apply the settings of this phase of the project")
+	rootCmd.AddCommand(execCmd)
+}
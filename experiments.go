@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// experimentsCmd groups the commands that toggle unstable features.
+var experimentsCmd = &cobra.Command{
+	Use:   "experiments",
+	Short: "List and toggle experimental features",
+	Long:  "Unstable features ship behind experiment flags stored in the global config. Experiments can also be enabled for a single invocation via $" + bild.ExperimentsEnv + "=name,name.",
+}
+
+var experimentsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List known experiments and whether they are enabled",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		for _, e := range bild.Experiments() {
+			state := "disabled"
+			if config.ExperimentEnabled(e.Name) {
+				state = "enabled"
+			}
+			fmt.Printf("%-12s %-9s %s\n", e.Name, state, e.Description)
+		}
+		return nil
+	},
+}
+
+// setExperiments enables or disables the named experiments and saves the config.
+func setExperiments(names []string, enabled bool) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf(t("err.load_config"), err)
+	}
+	for _, name := range names {
+		if err := config.SetExperiment(name, enabled); err != nil {
+			return err
+		}
+	}
+	if err := saveConfig(config); err != nil {
+		return fmt.Errorf(t("err.save_config"), err)
+	}
+
+	key := "experiments.disabled"
+	if enabled {
+		key = "experiments.enabled"
+	}
+	for _, name := range names {
+		fmt.Println(t(key, name))
+	}
+	return nil
+}
+
+var experimentsEnableCmd = &cobra.Command{
+	Use:   "enable [experiment...]",
+	Short: "Enable experiments",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setExperiments(args, true)
+	},
+}
+
+var experimentsDisableCmd = &cobra.Command{
+	Use:   "disable [experiment...]",
+	Short: "Disable experiments",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setExperiments(args, false)
+	},
+}
+
+func init() {
+	experimentsCmd.AddCommand(experimentsListCmd)
+	experimentsCmd.AddCommand(experimentsEnableCmd)
+	experimentsCmd.AddCommand(experimentsDisableCmd)
+	rootCmd.AddCommand(experimentsCmd)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// exportCmd groups the commands that render a project into other build formats.
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Render a project's phases into another build format",
+	Long:  "Generates CI configurations or scripts from a project's phases, so the local build definition can double as CI or run where bild isn't installed.",
+}
+
+// findProject looks a project up like a run does: in the local configs of
+// the current directory, over the global configuration.
+func findProject(projectName string) (*bild.Project, error) {
+	config, err := findConfig()
+	if err != nil {
+		return nil, err
+	}
+	return config.Project(projectName)
+}
+
+// findConfig returns the local configs of the current directory layered
+// over the global configuration, or the latter if there are none.
+func findConfig() (*bild.Config, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf(t("err.load_config"), err)
+	}
+	local, _, err := findLocalConfigs(config)
+	if err != nil {
+		return nil, err
+	}
+	if local != nil {
+		config = local
+	}
+	return config, nil
+}
+
+// exportArgs resolves the project to export from args (default: the git repository name).
+func exportArgs(args []string) (*bild.Project, error) {
+	var projectName string
+	if len(args) == 1 {
+		projectName = args[0]
+	} else {
+		var err error
+		projectName, err = repoProjectName()
+		if err != nil {
+			return nil, errors.New(t("err.no_project_name"))
+		}
+	}
+	config, err := findConfig()
+	if err != nil {
+		return nil, err
+	}
+	// Generated files are shared, unlike this machine's overrides.
+	proj, err := config.WithoutOverrides().Project(projectName)
+	if err != nil {
+		return nil, err
+	}
+	for _, ph := range proj.Phases {
+		if _, err := ph.ShellPreamble(); err != nil {
+			return nil, err
+		}
+		for _, c := range exportedCommands(ph) {
+			if (proj.Template || ph.Template) && strings.Contains(c, "{{") {
+				fmt.Fprintf(os.Stderr, "Warning: phase %s contains a command template that is exported unexpanded: %s\n", ph.Name, c)
+			}
+		}
+		if len(ph.OnFailure) > 0 || len(ph.Always) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: the on_failure and always hooks of phase %s are not exported\n", ph.Name)
+		}
+		if ph.Container != nil {
+			fmt.Fprintf(os.Stderr, "Warning: phase %s runs in container %s, but is exported to run without it\n", ph.Name, ph.Container.Image)
+		} else if nix := cmp.Or(ph.Nix, proj.Nix); nix != "" && nix != "off" {
+			fmt.Fprintf(os.Stderr, "Warning: phase %s runs in a Nix environment, but is exported to run without it\n", ph.Name)
+		}
+	}
+	if h := proj.Hooks; len(h.Pre) > 0 || len(h.OnSuccess) > 0 || len(h.OnFailure) > 0 || len(h.Always) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: the hooks of project %s are not exported\n", proj.Name)
+	}
+	if proj.Secrets != nil && !proj.Secrets.Empty() {
+		fmt.Fprintf(os.Stderr, "Warning: the secrets of project %s are not exported; provide them to the CI job or script yourself\n", proj.Name)
+	}
+	return proj, nil
+}
+
+// warnShells warns about phases that run in a shell of their own, which
+// formats without a per-job shell cannot reproduce.
+func warnShells(proj *bild.Project, format string) {
+	for _, ph := range proj.Phases {
+		if ph.Shell != "" || ph.LoginShell {
+			shell, flags := ph.ShellArgs("")
+			fmt.Fprintf(os.Stderr, "Warning: phase %s runs in %s; the %s export runs it in the default shell\n", ph.Name, strings.Join(append([]string{shell}, flags...), " "), format)
+		}
+	}
+}
+
+// exportedCommands returns the commands a phase runs when it succeeds: its
+// umask, ulimits and locale, its pre hook, its commands and its on_success
+// hook. exportArgs has validated the settings.
+func exportedCommands(ph bild.Phase) []string {
+	commands, _ := ph.ShellPreamble()
+	commands = append(commands, ph.Pre...)
+	commands = append(commands, ph.Commands...)
+	return append(commands, ph.OnSuccess...)
+}
+
+// writeExport writes data to the --output flag's path, or to defaultPath
+// (relative to the repository root) if unset. "-" writes to stdout.
+func writeExport(cmd *cobra.Command, defaultPath string, data []byte, mode os.FileMode) error {
+	path, _ := cmd.Flags().GetString("output")
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if path == "" {
+		root, err := bild.RepoRoot("")
+		if err != nil {
+			root = "."
+		}
+		path = filepath.Join(root, defaultPath)
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists; use --force to overwrite it", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return err
+	}
+	fmt.Println(t("export.done", path))
+	return nil
+}
+
+func init() {
+	exportCmd.PersistentFlags().StringP("output", "o", "", "File to write (\"-\" for stdout; default depends on the format)")
+	exportCmd.PersistentFlags().Bool("force", false, "Overwrite the output file if it exists")
+	rootCmd.AddCommand(exportCmd)
+}
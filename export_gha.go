@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Workflow structures in the field order GitHub's documentation uses.
+type ghaWorkflow struct {
+	Name string         `yaml:"name"`
+	On   map[string]any `yaml:"on"`
+	Jobs yaml.Node      `yaml:"jobs"`
+}
+
+type ghaJob struct {
+	Name   string    `yaml:"name,omitempty"`
+	Needs  []string  `yaml:"needs,omitempty"`
+	RunsOn string    `yaml:"runs-on"`
+	Steps  []ghaStep `yaml:"steps"`
+}
+
+type ghaStep struct {
+	Name  string `yaml:"name,omitempty"`
+	Uses  string `yaml:"uses,omitempty"`
+	Shell string `yaml:"shell,omitempty"`
+	Run   string `yaml:"run,omitempty"`
+}
+
+// phaseStep returns the step running a phase, in the phase's shell if it
+// has one; otherwise GitHub's default shell is kept.
+func phaseStep(ph bild.Phase) ghaStep {
+	step := ghaStep{Name: ph.Name, Run: phaseScript(ph)}
+	if ph.Shell != "" || ph.LoginShell {
+		shell, flags := ph.ShellArgs("")
+		step.Shell = strings.Join(append(append([]string{shell}, flags...), "-e", "{0}"), " ")
+	}
+	return step
+}
+
+// ciJobID turns a phase name into an identifier accepted by CI job keys.
+func ciJobID(name string) string {
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, name)
+	if id == "" || id[0] >= '0' && id[0] <= '9' || id[0] == '-' {
+		id = "phase-" + id
+	}
+	return id
+}
+
+// phaseScript joins the commands of a phase into one script.
+func phaseScript(ph bild.Phase) string {
+	return strings.Join(exportedCommands(ph), "\n") + "\n"
+}
+
+// hasNeeds reports whether any phase declares dependencies, i.e. whether
+// phases can be split into separately scheduled jobs.
+func hasNeeds(phases []bild.Phase) bool {
+	for _, ph := range phases {
+		if len(ph.Needs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// githubWorkflow renders proj as a GitHub Actions workflow. Phases become
+// steps of a single job, or separate jobs linked by their needs when
+// asJobs is set.
+func githubWorkflow(proj *bild.Project, runsOn string, asJobs bool) ([]byte, error) {
+	checkout := ghaStep{Uses: "actions/checkout@v4"}
+	jobs := yaml.Node{Kind: yaml.MappingNode}
+	addJob := func(id string, job ghaJob) error {
+		var value yaml.Node
+		if err := value.Encode(job); err != nil {
+			return err
+		}
+		jobs.Content = append(jobs.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: id}, &value)
+		return nil
+	}
+
+	if asJobs {
+		known := make(map[string]bool, len(proj.Phases))
+		for _, ph := range proj.Phases {
+			known[ph.Name] = true
+		}
+		for _, ph := range proj.Phases {
+			job := ghaJob{
+				Name:   ph.Name,
+				RunsOn: runsOn,
+				Steps:  []ghaStep{checkout, phaseStep(ph)},
+			}
+			for _, need := range ph.Needs {
+				if known[need] {
+					job.Needs = append(job.Needs, ciJobID(need))
+				}
+			}
+			if err := addJob(ciJobID(ph.Name), job); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		job := ghaJob{RunsOn: runsOn, Steps: []ghaStep{checkout}}
+		for _, ph := range proj.Phases {
+			job.Steps = append(job.Steps, phaseStep(ph))
+		}
+		if err := addJob("build", job); err != nil {
+			return nil, err
+		}
+	}
+
+	workflow := ghaWorkflow{
+		Name: proj.Name,
+		On: map[string]any{
+			"push":         map[string]any{},
+			"pull_request": map[string]any{},
+		},
+		Jobs: jobs,
+	}
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by `bild export gha " + proj.Name + "`.\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(workflow); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// exportGHACmd renders a project as a GitHub Actions workflow.
+var exportGHACmd = &cobra.Command{
+	Use:   "gha [project]",
+	Short: "Generate a GitHub Actions workflow from a project",
+	Long:  "Writes .github/workflows/bild.yml with one step per phase. If phases declare needs (or --jobs is given), each phase becomes its own job so independent phases can run in parallel.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		proj, err := exportArgs(args)
+		if err != nil {
+			return err
+		}
+		runsOn, _ := cmd.Flags().GetString("runs-on")
+		asJobs, _ := cmd.Flags().GetBool("jobs")
+
+		data, err := githubWorkflow(proj, runsOn, asJobs || hasNeeds(proj.Phases))
+		if err != nil {
+			return err
+		}
+		return writeExport(cmd, ".github/workflows/bild.yml", data, 0644)
+	},
+}
+
+func init() {
+	exportGHACmd.Flags().String("runs-on", "ubuntu-latest", "Runner label for the generated jobs")
+	exportGHACmd.Flags().Bool("jobs", false, "Generate one job per phase even if no phase declares needs")
+	exportCmd.AddCommand(exportGHACmd)
+}
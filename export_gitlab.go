@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+type gitlabJob struct {
+	Stage  string   `yaml:"stage"`
+	Needs  []string `yaml:"needs,omitempty"`
+	Script []string `yaml:"script"`
+}
+
+// gitlabPipeline renders proj as a .gitlab-ci.yml with one stage and job per phase.
+func gitlabPipeline(proj *bild.Project, image string) ([]byte, error) {
+	doc := yaml.Node{Kind: yaml.MappingNode}
+	add := func(key string, value any) error {
+		var node yaml.Node
+		if err := node.Encode(value); err != nil {
+			return err
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &node)
+		return nil
+	}
+
+	if image != "" {
+		if err := add("image", image); err != nil {
+			return nil, err
+		}
+	}
+	known := make(map[string]bool, len(proj.Phases))
+	stages := make([]string, 0, len(proj.Phases))
+	for _, ph := range proj.Phases {
+		known[ph.Name] = true
+		stages = append(stages, ciJobID(ph.Name))
+	}
+	if err := add("stages", stages); err != nil {
+		return nil, err
+	}
+	for _, ph := range proj.Phases {
+		job := gitlabJob{Stage: ciJobID(ph.Name), Script: exportedCommands(ph)}
+		for _, need := range ph.Needs {
+			if known[need] {
+				job.Needs = append(job.Needs, ciJobID(need))
+			}
+		}
+		if err := add(ciJobID(ph.Name), job); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by `bild export gitlab-ci " + proj.Name + "`.\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// exportGitLabCmd renders a project as a GitLab CI pipeline.
+var exportGitLabCmd = &cobra.Command{
+	Use:   "gitlab-ci [project]",
+	Short: "Generate a .gitlab-ci.yml from a project",
+	Long:  "Writes .gitlab-ci.yml with one stage and job per phase, in phase order. Phase needs become job needs, letting GitLab start jobs as soon as their dependencies finish.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		proj, err := exportArgs(args)
+		if err != nil {
+			return err
+		}
+		warnShells(proj, "GitLab CI")
+		image, _ := cmd.Flags().GetString("image")
+		data, err := gitlabPipeline(proj, image)
+		if err != nil {
+			return err
+		}
+		return writeExport(cmd, ".gitlab-ci.yml", data, 0644)
+	},
+}
+
+func init() {
+	exportGitLabCmd.Flags().String("image", "", "Default Docker image for the pipeline")
+	exportCmd.AddCommand(exportGitLabCmd)
+}
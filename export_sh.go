@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// shellFunctionName turns a phase name into a valid POSIX function name.
+func shellFunctionName(phase string) string {
+	return "phase_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, phase)
+}
+
+// shellScript renders proj as a standalone POSIX shell script. Each phase is
+// a function running in its own subshell, like a bild phase runs in its own
+// shell; the script runs the phases given as arguments, or all of them.
+func shellScript(proj *bild.Project) []byte {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Generated by `bild export sh %s`.\n", proj.Name)
+	b.WriteString("# Usage: ./build.sh [phase...]  (default: all phases in order)\n")
+	b.WriteString("set -e\n")
+	b.WriteString("cd \"$(dirname \"$0\")\"\n\n")
+
+	for _, ph := range proj.Phases {
+		if ph.Description != "" {
+			fmt.Fprintf(&b, "# %s\n", ph.Description)
+		}
+		fmt.Fprintf(&b, "%s() (\n", shellFunctionName(ph.Name))
+		b.WriteString("  set -e\n")
+		for _, c := range exportedCommands(ph) {
+			fmt.Fprintf(&b, "  %s\n", c)
+		}
+		b.WriteString(")\n\n")
+	}
+
+	b.WriteString("run_phase() {\n")
+	b.WriteString("  case \"$1\" in\n")
+	for _, ph := range proj.Phases {
+		fmt.Fprintf(&b, "    %s) echo \"==> $1\"; %s ;;\n", shellQuote(ph.Name), shellFunctionName(ph.Name))
+	}
+	b.WriteString("    *) echo \"unknown phase: $1\" >&2; exit 1 ;;\n")
+	b.WriteString("  esac\n")
+	b.WriteString("}\n\n")
+
+	b.WriteString("if [ $# -eq 0 ]; then\n")
+	names := make([]string, len(proj.Phases))
+	for i, ph := range proj.Phases {
+		names[i] = shellQuote(ph.Name)
+	}
+	fmt.Fprintf(&b, "  set -- %s\n", strings.Join(names, " "))
+	b.WriteString("fi\n")
+	b.WriteString("for phase in \"$@\"; do\n")
+	b.WriteString("  run_phase \"$phase\"\n")
+	b.WriteString("done\n")
+	return []byte(b.String())
+}
+
+// exportShCmd renders a project as a standalone shell script.
+var exportShCmd = &cobra.Command{
+	Use:   "sh [project]",
+	Short: "Generate a standalone build.sh from a project",
+	Long:  "Writes an executable build.sh to the repository root that runs the project's phases without bild. Pass phase names to the script to run only those.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		proj, err := exportArgs(args)
+		if err != nil {
+			return err
+		}
+		warnShells(proj, "sh")
+		return writeExport(cmd, "build.sh", shellScript(proj), 0755)
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(exportShCmd)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"bild/pkg/bild"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ghaEnv names the environment variable turning GitHub Actions workflow
+// commands off ("0") or on ("1") regardless of whether bild runs in a job.
+const ghaEnv = "BILD_GITHUB_ACTIONS"
+
+// ghaTailLines is how many of the last lines of a failing phase its failure
+// annotation shows.
+const ghaTailLines = 20
+
+// ghaMaxDiagnostics bounds the annotations made for the diagnostics of one
+// phase; GitHub shows only a few per step anyway.
+const ghaMaxDiagnostics = 50
+
+// diagnosticPattern matches the diagnostics compilers and linters print, as
+// in "src/main.c:12:5: error: expected ';'" or "main.go:7:2: undefined: x".
+var diagnosticPattern = regexp.MustCompile(`^\s*([^\s:]+\.\w+):(\d+)(?::(\d+))?:\s*(?:(fatal error|error|warning|note)\s*:\s*)?(.+)$`)
+
+// ghaEnabled reports whether runs should emit GitHub Actions workflow
+// commands: by default only in a GitHub Actions job.
+func ghaEnabled() bool {
+	switch os.Getenv(ghaEnv) {
+	case "0":
+		return false
+	case "1":
+		return true
+	}
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// ghaDiagnostic is a diagnostic found in the output of a phase.
+type ghaDiagnostic struct {
+	level   string // "error" or "warning"
+	file    string // relative to the workspace, or empty if outside of it
+	line    string
+	col     string
+	message string
+}
+
+// ghaAnnotator turns the output of a run into GitHub Actions workflow
+// commands: each phase is folded into a group, and the phase's failure and
+// the diagnostics it printed become annotations of the job.
+type ghaAnnotator struct {
+	dir       string // where the phases run; relative paths start there
+	workspace string // GITHUB_WORKSPACE, which annotated paths are relative to
+
+	mu      sync.Mutex
+	writers []*ghaWriter
+	partial bool // whether output ended in the middle of a line
+	diags   []ghaDiagnostic
+	seen    map[ghaDiagnostic]bool
+}
+
+// newGHAAnnotator returns an annotator for a run in dir.
+func newGHAAnnotator(dir string) *ghaAnnotator {
+	workspace := os.Getenv("GITHUB_WORKSPACE")
+	if workspace == "" {
+		workspace = dir
+	}
+	return &ghaAnnotator{dir: dir, workspace: workspace}
+}
+
+// writer returns a writer passing output on to w while collecting the
+// diagnostics in it.
+func (a *ghaAnnotator) writer(w io.Writer) io.Writer {
+	g := &ghaWriter{w: w, a: a}
+	a.writers = append(a.writers, g)
+	return g
+}
+
+// startPhase opens the group holding the output of phase.
+func (a *ghaAnnotator) startPhase(phase *bild.Phase) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.diags, a.seen, a.partial = nil, make(map[ghaDiagnostic]bool), false
+	fmt.Println("::group::" + plain(t("run.phase_header", phase.Name)))
+}
+
+// finishPhase closes the group of phase and annotates its diagnostics and
+// its failure, if err is not nil.
+func (a *ghaAnnotator) finishPhase(phase *bild.Phase, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, g := range a.writers {
+		// The last line of the phase's output may lack a newline.
+		if len(g.line) > 0 {
+			a.scan(string(g.line))
+			g.line = nil
+		}
+	}
+	if a.partial {
+		// Workflow commands are only recognized at the start of a line.
+		fmt.Println()
+		a.partial = false
+	}
+	fmt.Println("::endgroup::")
+	for _, d := range a.diags {
+		var props []string
+		if d.file != "" {
+			props = append(props, "file="+ghaEscapeProperty(d.file), "line="+d.line)
+			if d.col != "" {
+				props = append(props, "col="+d.col)
+			}
+		}
+		props = append(props, "title="+ghaEscapeProperty(phase.Name))
+		fmt.Printf("::%s %s::%s\n", d.level, strings.Join(props, ","), ghaEscapeData(d.message))
+	}
+	if err != nil {
+		message := t("run.gha_failed", phase.Name, exitCode(err))
+		var phaseErr *bild.PhaseError
+		if errors.As(err, &phaseErr) && len(phaseErr.Tail) > 0 {
+			message += "\n\n" + ansi.Strip(strings.Join(phaseErr.Tail, "\n"))
+		}
+		fmt.Printf("::error title=%s::%s\n", ghaEscapeProperty("bild"), ghaEscapeData(message))
+	}
+}
+
+// scan records the diagnostic on line, if any.
+func (a *ghaAnnotator) scan(line string) {
+	m := diagnosticPattern.FindStringSubmatch(ansi.Strip(line))
+	if m == nil || m[4] == "note" || len(a.diags) >= ghaMaxDiagnostics {
+		return
+	}
+	d := ghaDiagnostic{level: "error", file: a.relative(m[1]), line: m[2], col: m[3], message: m[5]}
+	if m[4] == "warning" {
+		d.level = "warning"
+	}
+	if !a.seen[d] {
+		a.seen[d] = true
+		a.diags = append(a.diags, d)
+	}
+}
+
+// relative returns file, as printed by a phase, relative to the workspace,
+// or "" if it is outside of it.
+func (a *ghaAnnotator) relative(file string) string {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(a.dir, file)
+	}
+	rel, err := filepath.Rel(a.workspace, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// ghaWriter is the writer returned by ghaAnnotator.writer. Each stream has
+// its own, so that lines of stdout and stderr aren't mixed up.
+type ghaWriter struct {
+	w    io.Writer
+	a    *ghaAnnotator
+	line []byte
+}
+
+func (g *ghaWriter) Write(p []byte) (int, error) {
+	n, err := g.w.Write(p)
+	g.a.mu.Lock()
+	defer g.a.mu.Unlock()
+	g.line = append(g.line, p[:n]...)
+	for {
+		i := bytes.IndexByte(g.line, '\n')
+		if i < 0 {
+			break
+		}
+		g.a.scan(strings.TrimRight(string(g.line[:i]), "\r"))
+		g.line = g.line[i+1:]
+	}
+	if n > 0 {
+		g.a.partial = p[n-1] != '\n'
+	}
+	return n, err
+}
+
+// ghaEscapeData escapes the message of a workflow command.
+func ghaEscapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// ghaEscapeProperty escapes the value of a property of a workflow command.
+func ghaEscapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
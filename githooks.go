@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// hooksCmd groups the commands managing the git hooks that run phases.
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Run phases from git hooks, gating commits and pushes",
+	Long: `Installs git hooks in the current repository that run phases of a project
+with bild, so that a failing phase stops the commit or push, as in
+
+  bild hooks install --phase lint --on pre-commit
+  bild hooks install api --phase test --on pre-push
+
+The hooks go where git looks for them, core.hooksPath included, and are
+shared by all worktrees. They run the bild on the PATH, or else the one that
+installed them. git commit and git push skip them with --no-verify.`,
+}
+
+var hooksInstallCmd = &cobra.Command{
+	Use:   "install [project]",
+	Short: "Install a git hook running phases of a project",
+	Long: `Writes the git hook given with --on, pre-commit by default, running the
+phases given with --phase, or the project's default phases. If no project is
+given, it is deduced from the git repository. A hook not installed by bild
+is only replaced with --force; it is then kept and put back on uninstall.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hook, _ := cmd.Flags().GetString("on")
+		phases, _ := cmd.Flags().GetStringSlice("phase")
+		force, _ := cmd.Flags().GetBool("force")
+		var projectName string
+		if len(args) > 0 {
+			projectName = args[0]
+		} else {
+			name, err := repoProjectName()
+			if err != nil {
+				return errors.New(t("err.no_project_name"))
+			}
+			projectName = name
+		}
+		config, err := findConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		proj, err := config.Project(projectName)
+		if err != nil {
+			return err
+		}
+		for _, name := range phases {
+			if _, err := proj.Phase(name); err != nil {
+				return err
+			}
+		}
+		hooks, err := bild.GitHooks("")
+		if err != nil {
+			return err
+		}
+		path, err := bild.InstallGitHook("", hook, projectName, phases, force)
+		if err != nil {
+			return err
+		}
+		fmt.Println(t("githooks.installed", hook, gitHookRuns(projectName, phases), path))
+		for _, h := range hooks {
+			if h.Name == hook && h.Foreign {
+				fmt.Println(t("githooks.replaced", hook))
+			}
+		}
+		return nil
+	},
+}
+
+var hooksUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the git hooks installed by bild",
+	Long: `Removes the git hook given with --on, or all git hooks installed by bild,
+putting back the hooks they replaced. Hooks not installed by bild are left
+alone.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names := bild.GitHookNames
+		if hook, _ := cmd.Flags().GetString("on"); hook != "" {
+			names = []string{hook}
+		}
+		removed := 0
+		for _, name := range names {
+			ok, err := bild.UninstallGitHook("", name)
+			if err != nil {
+				return err
+			}
+			if ok {
+				removed++
+				fmt.Println(t("githooks.uninstalled", name))
+			}
+		}
+		if removed == 0 {
+			fmt.Println(t("githooks.none"))
+		}
+		return nil
+	},
+}
+
+var hooksStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the git hooks of the repository and what they run",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hooks, err := bild.GitHooks("")
+		if err != nil {
+			return err
+		}
+		if len(hooks) == 0 {
+			fmt.Println(t("githooks.none"))
+			return nil
+		}
+		fmt.Printf("%-14s %s\n", "HOOK", "RUNS")
+		for _, h := range hooks {
+			runs := t("githooks.foreign")
+			if !h.Foreign {
+				runs = gitHookRuns(h.Project, h.Phases)
+			}
+			fmt.Printf("%-14s %s\n", h.Name, runs)
+		}
+		return nil
+	},
+}
+
+// gitHookRuns describes what a git hook running phases of project runs.
+func gitHookRuns(project string, phases []string) string {
+	if len(phases) == 0 {
+		return fmt.Sprintf("%s (%s)", project, t("githooks.default"))
+	}
+	return project + " " + strings.Join(phases, ",")
+}
+
+func init() {
+	hooksInstallCmd.Flags().StringSlice("phase", nil, "Run this phase; repeat or separate with commas for several (default: the project's default phases)")
+	hooksInstallCmd.Flags().String("on", "pre-commit", "Git hook to install: "+strings.Join(bild.GitHookNames, ", "))
+	hooksInstallCmd.Flags().Bool("force", false, "Replace a hook not installed by bild, keeping it to put back on uninstall")
+	hooksInstallCmd.RegisterFlagCompletionFunc("on", cobra.FixedCompletions(bild.GitHookNames, cobra.ShellCompDirectiveNoFileComp))
+	hooksUninstallCmd.Flags().String("on", "", "Only remove this git hook")
+	hooksUninstallCmd.RegisterFlagCompletionFunc("on", cobra.FixedCompletions(bild.GitHookNames, cobra.ShellCompDirectiveNoFileComp))
+	hooksCmd.AddCommand(hooksInstallCmd, hooksUninstallCmd, hooksStatusCmd)
+	rootCmd.AddCommand(hooksCmd)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// historyObserver appends an entry to the run history for every finished phase.
+type historyObserver struct {
+	runID   string
+	project string
+	commit  string
+	command string // set when recording an ad-hoc command instead of phases
+	// pipeline is set when the phases are run by a pipeline.
+	pipeline string
+	// tests holds the test reports of phases until they finish.
+	tests map[string]*bild.TestReport
+	// coverage holds what coverage gates measured until their phase finishes.
+	coverage map[string]float64
+
+	// state remembers the last successful run of each phase, which lastGreen
+	// holds as of the start of the run.
+	state     *bild.ProjectState
+	lastGreen map[string]bild.GreenRun
+}
+
+// newHistoryObserver returns an observer recording the phases of projectName
+// run in dir, or nil if the history cannot be located.
+func newHistoryObserver(projectName string, dir string) bild.Observer {
+	dirs, err := getDirs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: run history disabled: %v\n", err)
+		return nil
+	}
+	commit, _ := bild.HeadCommit(dir)
+	state := dirs.ProjectState(projectName)
+	lastGreen, err := state.LastGreen()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not read the last successful runs: %v\n", err)
+	}
+	return &historyObserver{
+		runID:     bild.NewRunID(),
+		project:   projectName,
+		commit:    commit,
+		state:     state,
+		lastGreen: lastGreen,
+	}
+}
+
+func (o *historyObserver) PhaseStarted(phase *bild.Phase) {}
+
+func (o *historyObserver) TestsReported(phase *bild.Phase, report *bild.TestReport) {
+	if o.tests == nil {
+		o.tests = make(map[string]*bild.TestReport)
+	}
+	o.tests[phase.Name] = report
+}
+
+func (o *historyObserver) CoverageReported(phase *bild.Phase, result bild.CoverageResult) {
+	if o.coverage == nil {
+		o.coverage = make(map[string]float64)
+	}
+	o.coverage[phase.Name] = result.Percent
+}
+
+func (o *historyObserver) PhaseFinished(phase *bild.Phase, err error, elapsed time.Duration) {
+	entry := bild.HistoryEntry{
+		RunID:    o.runID,
+		Project:  o.project,
+		Phase:    phase.Name,
+		Start:    time.Now().Add(-elapsed),
+		Duration: elapsed,
+		Commit:   o.commit,
+		Pipeline: o.pipeline,
+		Tests:    o.tests[phase.Name],
+	}
+	if percent, ok := o.coverage[phase.Name]; ok {
+		entry.Coverage = &percent
+	}
+	if o.command != "" {
+		entry.Phase = ""
+		entry.Command = o.command
+	}
+	if err != nil {
+		entry.ExitCode = exitCode(err)
+		var phaseErr *bild.PhaseError
+		if errors.As(err, &phaseErr) {
+			entry.Step = phaseErr.Step
+		}
+	}
+	if o.command == "" {
+		entry.Since = o.lastGreen[phase.Name].Commit
+		if err == nil && o.commit != "" {
+			green := bild.GreenRun{Commit: o.commit, RunID: o.runID, Time: time.Now()}
+			if err := o.state.RecordGreen(phase.Name, green); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not record the successful run: %v\n", err)
+			}
+		}
+	}
+	entry.User = runUser()
+	if err := appendHistory(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record run history: %v\n", err)
+	}
+	if err := recordWithSharedAgent(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record run with the shared agent: %v\n", err)
+	}
+}
+
+// runUser returns the name runs are attributed to: the user a shared agent
+// authenticated, or else the local account.
+func runUser() string {
+	if sharedAgent != nil {
+		return sharedAgent.user
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// appendHistory records entry in the storage backend. The backend is opened
+// per entry so that no database handle is held while phases run.
+func appendHistory(entry bild.HistoryEntry) error {
+	storage, err := getStorage()
+	if err != nil {
+		return err
+	}
+	if err := storage.AppendHistory(entry); err != nil {
+		storage.Close()
+		return err
+	}
+	return storage.Close()
+}
+
+// loadHistory reads the run history, optionally restricted to one project.
+func loadHistory(projectName string) ([]bild.HistoryEntry, error) {
+	storage, err := getStorage()
+	if err != nil {
+		return nil, err
+	}
+	defer storage.Close()
+	entries, err := storage.History(projectName)
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+	return entries, nil
+}
+
+// shortCommit abbreviates a commit hash for display.
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+// historyCmd lists past runs, most recent first.
+var historyCmd = &cobra.Command{
+	Use:   "history [project]",
+	Short: "List past runs with their timings",
+	Long:  "Lists recorded phase executions (most recent first) with their start time, duration, git commit, user and exit status. If a project is given, only its runs are shown. The runs of whole pipelines are listed under the pipeline's name.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var projectName string
+		if len(args) == 1 {
+			projectName = args[0]
+		}
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		entries, err := loadHistory(projectName)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println(t("history.none"))
+			return nil
+		}
+
+		shown := 0
+		for i := len(entries) - 1; i >= 0 && (limit <= 0 || shown < limit); i-- {
+			e := entries[i]
+			status := resultText(e.Succeeded(), e.ExitCode)
+			if e.Tests != nil {
+				status += "  " + testsText(e.Tests)
+			}
+			if e.Coverage != nil {
+				status += "  " + t("history.coverage", *e.Coverage)
+			}
+			project, phase := e.Project, e.Phase
+			if e.AdHoc() {
+				phase = "$ " + e.Command
+			} else if e.Step != "" {
+				phase += ":" + e.Step
+			} else if e.PipelineRun() {
+				project, phase = e.Pipeline, "(pipeline)"
+			}
+			fmt.Printf("%s  %-20s %-12s %10s  %-7s %-10s %s\n",
+				e.Start.Local().Format("2006-01-02 15:04:05"),
+				project,
+				phase,
+				e.Duration.Round(time.Millisecond),
+				shortCommit(e.Commit),
+				e.User,
+				status,
+			)
+			shown++
+		}
+		return nil
+	},
+}
+
+// statsCmd summarizes phase durations across the recorded history.
+var statsCmd = &cobra.Command{
+	Use:   "stats [project]",
+	Short: "Show average and percentile phase durations",
+	Long:  "Aggregates the run history per project and phase, showing the number of runs, failures, and the mean, median (p50), p90 and maximum duration of successful runs.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var projectName string
+		if len(args) == 1 {
+			projectName = args[0]
+		}
+
+		entries, err := loadHistory(projectName)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println(t("history.none"))
+			return nil
+		}
+
+		fmt.Printf("%-20s %-12s %5s %6s %10s %10s %10s %10s\n", "PROJECT", "PHASE", "RUNS", "FAILED", "MEAN", "P50", "P90", "MAX")
+		for _, s := range bild.ComputeStats(entries) {
+			fmt.Printf("%-20s %-12s %5d %6d %10s %10s %10s %10s\n",
+				s.Project,
+				s.Phase,
+				s.Runs,
+				s.Failures,
+				s.Mean.Round(time.Millisecond),
+				s.P50.Round(time.Millisecond),
+				s.P90.Round(time.Millisecond),
+				s.Max.Round(time.Millisecond),
+			)
+		}
+		return nil
+	},
+}
+
+func init() {
+	historyCmd.Flags().IntP("limit", "n", 20, "Maximum number of runs to show (0 for all)")
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(statsCmd)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// importCmd groups the commands that convert other build definitions into bild projects.
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Create a project from an existing build definition",
+	Long:  "Converts the targets, scripts or recipes of another build tool into a bild project with one phase per entry, so existing setups can be migrated gradually.",
+}
+
+// importSource returns the file to import: the --file flag if given, otherwise
+// the first of the candidate names found in the repository root (or current directory).
+func importSource(cmd *cobra.Command, candidates ...string) (string, error) {
+	if file, _ := cmd.Flags().GetString("file"); file != "" {
+		return file, nil
+	}
+	dir, err := bild.RepoRoot("")
+	if err != nil {
+		dir = "."
+	}
+	for _, name := range candidates {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no %s found in %s; use --file to point at one", candidates[0], dir)
+}
+
+// selectImported filters names down to those listed in the --only flag (all if unset).
+func selectImported(cmd *cobra.Command, names []string) ([]string, error) {
+	only, _ := cmd.Flags().GetStringSlice("only")
+	if len(only) == 0 {
+		return names, nil
+	}
+	available := make(map[string]bool, len(names))
+	for _, name := range names {
+		available[name] = true
+	}
+	for _, name := range only {
+		if !available[name] {
+			return nil, fmt.Errorf("%s not found in the imported file", name)
+		}
+	}
+	return only, nil
+}
+
+// selectPhases filters phases down to those listed in the --only flag (all if
+// unset), keeping their original order.
+func selectPhases(cmd *cobra.Command, phases []bild.Phase) ([]bild.Phase, error) {
+	names := make([]string, len(phases))
+	for i, ph := range phases {
+		names[i] = ph.Name
+	}
+	selected, err := selectImported(cmd, names)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool, len(selected))
+	for _, name := range selected {
+		keep[name] = true
+	}
+	var result []bild.Phase
+	for _, ph := range phases {
+		if keep[ph.Name] {
+			result = append(result, ph)
+		}
+	}
+	return result, nil
+}
+
+// saveImportedProject registers phases as a project named by the --name flag
+// (default: the git repository name) in the global configuration.
+func saveImportedProject(cmd *cobra.Command, phases []bild.Phase) error {
+	if len(phases) == 0 {
+		return fmt.Errorf("nothing to import")
+	}
+	phases, err := bild.OrderByNeeds(phases)
+	if err != nil {
+		return err
+	}
+
+	projectName, _ := cmd.Flags().GetString("name")
+	if projectName == "" {
+		projectName, err = bild.RepoName("")
+		if err != nil {
+			return fmt.Errorf("could not determine project name from git repository; please provide --name")
+		}
+	}
+	force, _ := cmd.Flags().GetBool("force")
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf(t("err.load_config"), err)
+	}
+	if _, exists := config.Projects[projectName]; exists && !force {
+		return fmt.Errorf("project %s already exists; use --force to replace it", projectName)
+	}
+	config.Projects[projectName] = bild.Project{Phases: phases}
+	if err := saveConfig(config); err != nil {
+		return fmt.Errorf(t("err.save_config"), err)
+	}
+
+	fmt.Println(tn("import.done", len(phases), projectName, len(phases)))
+	for _, phase := range phases {
+		first, _, _ := strings.Cut(phase.Commands[0], "\n")
+		fmt.Println(t("import.phase", phase.Name, first))
+	}
+	return nil
+}
+
+func init() {
+	importCmd.PersistentFlags().String("name", "", "Name of the project to create (default: git repository name)")
+	importCmd.PersistentFlags().String("file", "", "File to import (default: detected in the repository root)")
+	importCmd.PersistentFlags().StringSlice("only", nil, "Only import these entries (comma-separated or repeated)")
+	importCmd.PersistentFlags().Bool("force", false, "Replace the project if it already exists")
+	rootCmd.AddCommand(importCmd)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// justRecipePattern matches a recipe header: optional "@", the name, its
+// parameters, a colon that is not an assignment (":="), and the dependencies.
+var justRecipePattern = regexp.MustCompile(`^@?([A-Za-z_][A-Za-z0-9_-]*)([^:]*):([^=].*|)$`)
+
+// justDependencyPattern matches a dependency, either bare or with arguments in parentheses.
+var justDependencyPattern = regexp.MustCompile(`\(\s*([A-Za-z_][A-Za-z0-9_-]*)[^)]*\)|([A-Za-z_][A-Za-z0-9_-]*)`)
+
+// justfilePhases returns one phase per public recipe of a justfile that can be
+// invoked without arguments, in order of declaration. A comment directly
+// above a recipe becomes the phase description.
+func justfilePhases(path string, commandPrefix string) ([]bild.Phase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var phases []bild.Phase
+	var comment string
+	private := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t"):
+			// Blank lines and recipe bodies reset the pending documentation.
+			comment, private = "", false
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			comment = strings.TrimSpace(strings.TrimPrefix(trimmed, "#"))
+			continue
+		case strings.HasPrefix(trimmed, "["):
+			if strings.Contains(trimmed, "private") {
+				private = true
+			}
+			continue
+		}
+
+		m := justRecipePattern.FindStringSubmatch(line)
+		if m == nil || strings.HasPrefix(line, "set ") || strings.HasPrefix(line, "alias ") || strings.HasPrefix(line, "export ") {
+			comment, private = "", false
+			continue
+		}
+		name, params, deps := m[1], m[2], m[3]
+		if private || strings.HasPrefix(name, "_") || hasRequiredParam(params) {
+			comment, private = "", false
+			continue
+		}
+
+		phase := bild.Phase{
+			Name:        name,
+			Description: comment,
+			Commands:    []string{commandPrefix + " " + name},
+		}
+		// Dependencies after "&&" run after the recipe, so only those before it are needs.
+		if i := strings.Index(deps, "&&"); i >= 0 {
+			deps = deps[:i]
+		}
+		for _, dep := range justDependencyPattern.FindAllStringSubmatch(deps, -1) {
+			if dep[1] != "" {
+				phase.Needs = append(phase.Needs, dep[1])
+			} else {
+				phase.Needs = append(phase.Needs, dep[2])
+			}
+		}
+		phases = append(phases, phase)
+		comment, private = "", false
+	}
+	return phases, scanner.Err()
+}
+
+// hasRequiredParam reports whether a recipe parameter list contains a
+// parameter without a default value.
+func hasRequiredParam(params string) bool {
+	for _, param := range strings.Fields(params) {
+		if !strings.Contains(param, "=") && !strings.HasPrefix(param, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// importJustCmd creates a project with one phase per justfile recipe.
+var importJustCmd = &cobra.Command{
+	Use:   "just",
+	Short: "Create a project from the recipes of a justfile",
+	Long:  "Parses the justfile in the repository root (or --file) and creates a project with one phase per public recipe that needs no arguments, each running `just <recipe>`. Doc comments are kept as descriptions and dependencies are recorded as phase needs.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := importSource(cmd, "justfile", "Justfile", ".justfile")
+		if err != nil {
+			return err
+		}
+
+		prefix := "just"
+		if file, _ := cmd.Flags().GetString("file"); file != "" {
+			if abs, err := filepath.Abs(file); err == nil {
+				file = abs
+			}
+			prefix = "just --justfile " + shellQuote(file)
+		}
+
+		phases, err := justfilePhases(path, prefix)
+		if err != nil {
+			return err
+		}
+		phases, err = selectPhases(cmd, phases)
+		if err != nil {
+			return err
+		}
+		return saveImportedProject(cmd, phases)
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importJustCmd)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// makeRulePattern matches a rule line ("targets: prerequisites") while
+// excluding variable assignments such as "FOO := bar" or "FOO = a:b".
+var makeRulePattern = regexp.MustCompile(`^([^\s:=#][^:=#]*?)\s*::?(?:[^=]|$)`)
+
+// makefileTargets returns the explicit targets of a Makefile in order of
+// appearance, skipping special targets (.PHONY), pattern rules and
+// targets built from variables.
+func makefileTargets(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var targets []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\t") {
+			continue // recipe line
+		}
+		m := makeRulePattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		for _, target := range strings.Fields(m[1]) {
+			if strings.HasPrefix(target, ".") || strings.ContainsAny(target, "%$") || seen[target] {
+				continue
+			}
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	return targets, scanner.Err()
+}
+
+// importMakefileCmd creates a project with one phase per Makefile target.
+var importMakefileCmd = &cobra.Command{
+	Use:   "makefile",
+	Short: "Create a project from the targets of a Makefile",
+	Long:  "Parses the Makefile in the repository root (or --file) and creates a project with one phase per target, each running `make <target>`.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := importSource(cmd, "Makefile", "makefile", "GNUmakefile")
+		if err != nil {
+			return err
+		}
+		targets, err := makefileTargets(path)
+		if err != nil {
+			return err
+		}
+		targets, err = selectImported(cmd, targets)
+		if err != nil {
+			return err
+		}
+
+		// Refer to non-default Makefiles explicitly so the phases work from the repository root.
+		makeCmd := "make"
+		if file, _ := cmd.Flags().GetString("file"); file != "" {
+			if abs, err := filepath.Abs(file); err == nil {
+				file = abs
+			}
+			makeCmd = "make -f " + shellQuote(file)
+		}
+
+		var phases []bild.Phase
+		for _, target := range targets {
+			phases = append(phases, bild.Phase{
+				Name:     target,
+				Commands: []string{makeCmd + " " + target},
+			})
+		}
+		return saveImportedProject(cmd, phases)
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importMakefileCmd)
+}
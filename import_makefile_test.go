@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMakefileTargets(t *testing.T) {
+	tests := []struct {
+		name, makefile string
+		want           []string
+	}{
+		{"rules", "all: build test\nbuild:\n\tgo build\ntest: build\n\tgo test\n", []string{"all", "build", "test"}},
+		{"several targets", "lint vet: deps\n", []string{"lint", "vet"}},
+		{"double colon", "clean::\n\trm -f a\nclean::\n\trm -f b\n", []string{"clean"}},
+		{"special and pattern", ".PHONY: all\n%.o: %.c\n$(BIN): main.go\nall:\n", []string{"all"}},
+		{"assignments", "FOO := bar\nBAR = a:b\nBAZ ?= x\nQUX += y\nall:\n", []string{"all"}},
+		{"comments", "# build: the binary\nall: # everything\n", []string{"all"}},
+		{"recipe lines", "all:\n\techo x: y\n", []string{"all"}},
+		{"none", "FOO = 1\n", nil},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "Makefile")
+		if err := os.WriteFile(path, []byte(tt.makefile), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := makefileTargets(path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: makefileTargets = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// packageScripts returns the names of the scripts in a package.json file in
+// the order they are declared.
+func packageScripts(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pkg struct {
+		Scripts json.RawMessage `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if len(pkg.Scripts) == 0 {
+		return nil, nil
+	}
+	return orderedKeys(pkg.Scripts)
+}
+
+// orderedKeys returns the keys of a JSON object in document order, which a
+// Go map would lose.
+func orderedKeys(object json.RawMessage) ([]string, error) {
+	var keys []string
+	dec := json.NewDecoder(bytes.NewReader(object))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, fmt.Errorf("scripts must be an object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, tok.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// packageManager guesses the package manager of the project containing
+// package.json from its lock file.
+func packageManager(packageJSON string) string {
+	dir := filepath.Dir(packageJSON)
+	for _, candidate := range []struct{ lock, manager string }{
+		{"pnpm-lock.yaml", "pnpm"},
+		{"yarn.lock", "yarn"},
+		{"bun.lockb", "bun"},
+	} {
+		if _, err := os.Stat(filepath.Join(dir, candidate.lock)); err == nil {
+			return candidate.manager
+		}
+	}
+	return "npm"
+}
+
+// importNpmCmd creates a project with one phase per package.json script.
+var importNpmCmd = &cobra.Command{
+	Use:   "npm",
+	Short: "Create a project from the scripts of a package.json",
+	Long:  "Reads the scripts of the package.json in the repository root (or --file) and creates a project with one phase per script, each running `npm run <script>` (or the package manager given by --runner / detected from the lock file).",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := importSource(cmd, "package.json")
+		if err != nil {
+			return err
+		}
+		scripts, err := packageScripts(path)
+		if err != nil {
+			return err
+		}
+		scripts, err = selectImported(cmd, scripts)
+		if err != nil {
+			return err
+		}
+
+		runner, _ := cmd.Flags().GetString("runner")
+		if runner == "" {
+			runner = packageManager(path)
+		}
+		// Scripts of a package.json outside the repository root need their own directory.
+		prefix := runner + " run"
+		if file, _ := cmd.Flags().GetString("file"); file != "" {
+			if abs, err := filepath.Abs(filepath.Dir(file)); err == nil {
+				prefix = "cd " + shellQuote(abs) + " && " + prefix
+			}
+		}
+
+		var phases []bild.Phase
+		for _, script := range scripts {
+			phases = append(phases, bild.Phase{
+				Name:     script,
+				Commands: []string{prefix + " " + script},
+			})
+		}
+		return saveImportedProject(cmd, phases)
+	},
+}
+
+func init() {
+	importNpmCmd.Flags().String("runner", "", "Package manager used to run scripts: npm, yarn, pnpm or bun (default: detected from the lock file)")
+	importCmd.AddCommand(importNpmCmd)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// taskfileTask is the subset of a Taskfile task definition bild understands.
+type taskfileTask struct {
+	Desc     string `yaml:"desc"`
+	Internal bool   `yaml:"internal"`
+	Deps     []any  `yaml:"deps"` // names or {task: name, vars: ...}
+}
+
+// taskfilePhases returns one phase per public task of a Taskfile, in order of declaration.
+func taskfilePhases(path string, commandPrefix string) ([]bild.Phase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Tasks yaml.Node `yaml:"tasks"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if doc.Tasks.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	var phases []bild.Phase
+	// Mapping nodes alternate keys and values, preserving declaration order.
+	for i := 0; i+1 < len(doc.Tasks.Content); i += 2 {
+		name := doc.Tasks.Content[i].Value
+		var task taskfileTask
+		if value := doc.Tasks.Content[i+1]; value.Kind == yaml.MappingNode {
+			if err := value.Decode(&task); err != nil {
+				return nil, fmt.Errorf("task %s: %v", name, err)
+			}
+		}
+		if task.Internal {
+			continue
+		}
+
+		phase := bild.Phase{
+			Name:        name,
+			Description: task.Desc,
+			Commands:    []string{commandPrefix + " " + name},
+		}
+		for _, dep := range task.Deps {
+			switch dep := dep.(type) {
+			case string:
+				phase.Needs = append(phase.Needs, dep)
+			case map[string]any:
+				if name, ok := dep["task"].(string); ok {
+					phase.Needs = append(phase.Needs, name)
+				}
+			}
+		}
+		phases = append(phases, phase)
+	}
+	return phases, nil
+}
+
+// importTaskCmd creates a project with one phase per Taskfile task.
+var importTaskCmd = &cobra.Command{
+	Use:   "task",
+	Short: "Create a project from the tasks of a Taskfile.yml",
+	Long:  "Parses the Taskfile in the repository root (or --file) and creates a project with one phase per public task, each running `task <name>`. Task descriptions are kept and dependencies are recorded as phase needs.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := importSource(cmd, "Taskfile.yml", "Taskfile.yaml", "taskfile.yml", "taskfile.yaml")
+		if err != nil {
+			return err
+		}
+
+		prefix := "task"
+		if file, _ := cmd.Flags().GetString("file"); file != "" {
+			if abs, err := filepath.Abs(file); err == nil {
+				file = abs
+			}
+			prefix = "task --taskfile " + shellQuote(file)
+		}
+
+		phases, err := taskfilePhases(path, prefix)
+		if err != nil {
+			return err
+		}
+		phases, err = selectPhases(cmd, phases)
+		if err != nil {
+			return err
+		}
+		return saveImportedProject(cmd, phases)
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importTaskCmd)
+}
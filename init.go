@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// templatesDir returns the directory holding user-defined project templates.
+func templatesDir() (string, error) {
+	dirs, err := getDirs()
+	if err != nil {
+		return "", err
+	}
+	return dirs.TemplatesDir(), nil
+}
+
+// listTemplates prints the available project templates.
+func listTemplates() error {
+	dir, err := templatesDir()
+	if err != nil {
+		return err
+	}
+	templates, err := bild.ProjectTemplates(dir)
+	if err != nil {
+		return err
+	}
+	for _, tmpl := range templates {
+		source := "built-in"
+		if tmpl.Path != "" {
+			source = tmpl.Path
+		}
+		fmt.Printf("%-10s %-40s %s\n", tmpl.Name, tmpl.Description, source)
+	}
+	return nil
+}
+
+// initCmd creates a project from a template.
+var initCmd = &cobra.Command{
+	Use:   "init [project]",
+	Short: "Create a project from a template",
+	Long: `Creates a project with a default set of phases for a common ecosystem
+(cmake, meson, go, rust, node or make). Templates are JSON files of the form
+{"description": "...", "phases": [...]} in the templates directory below the
+config directory (~/.config/bild/templates/<name>.json on Linux; see 'bild
+storage paths'); a file there named like a built-in template replaces it.`,
+	Example: `  bild init --template go
+  bild init backend --template cmake --local
+  bild init --list`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if list, _ := cmd.Flags().GetBool("list"); list {
+			return listTemplates()
+		}
+		templateName, _ := cmd.Flags().GetString("template")
+		if templateName == "" {
+			return errors.New("choose a template with --template (see bild init --list)")
+		}
+		dir, err := templatesDir()
+		if err != nil {
+			return err
+		}
+		tmpl, err := bild.LookupProjectTemplate(dir, templateName)
+		if err != nil {
+			return err
+		}
+
+		var projectName string
+		if len(args) == 1 {
+			projectName = args[0]
+		} else {
+			projectName, err = bild.RepoName("")
+			if err != nil {
+				return errors.New(t("err.no_project_name"))
+			}
+		}
+		force, _ := cmd.Flags().GetBool("force")
+		proj := bild.Project{Phases: tmpl.Phases}
+
+		if local, _ := cmd.Flags().GetBool("local"); local {
+			root, err := bild.RepoRoot("")
+			if err != nil {
+				return fmt.Errorf("failed to get git repository root: %v", err)
+			}
+			existing := bild.LocalConfigPath(root)
+			if localConfig, exists, _ := bild.LoadLocalConfig(root); exists && !force {
+				if _, ok := localConfig.Projects[projectName]; ok {
+					return fmt.Errorf("%s already defines %s; use --force to replace it", filepath.Base(existing), projectName)
+				}
+			}
+			path, err := bild.WriteLocalConfigAs(root, projectName, proj, bild.ConfigFormat(existing))
+			if err != nil {
+				return err
+			}
+			fmt.Println(t("export.done", path))
+		} else {
+			config, err := loadConfig()
+			if err != nil {
+				return fmt.Errorf(t("err.load_config"), err)
+			}
+			if _, exists := config.Projects[projectName]; exists && !force {
+				return fmt.Errorf("project %s already exists; use --force to replace it", projectName)
+			}
+			config.Projects[projectName] = proj
+			if err := saveConfig(config); err != nil {
+				return fmt.Errorf(t("err.save_config"), err)
+			}
+		}
+
+		fmt.Println(tn("init.done", len(proj.Phases), projectName, tmpl.Name, len(proj.Phases)))
+		for _, phase := range proj.Phases {
+			fmt.Println(t("import.phase", phase.Name, strings.Join(phase.Commands, "; ")))
+		}
+		return nil
+	},
+}
+
+// completeTemplates completes the names of available project templates.
+func completeTemplates(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	dir, err := templatesDir()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	templates, err := bild.ProjectTemplates(dir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var names []string
+	for _, tmpl := range templates {
+		names = append(names, withDescription(tmpl.Name, tmpl.Description))
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	initCmd.Flags().String("template", "", "Template to create the project from")
+	initCmd.Flags().Bool("list", false, "List the available templates")
+	initCmd.Flags().Bool("local", false, "Write the project to .bild.json in the repository root instead of the global config")
+	initCmd.Flags().Bool("force", false, "Replace an existing project")
+	initCmd.RegisterFlagCompletionFunc("template", completeTemplates)
+	rootCmd.AddCommand(initCmd)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// insightsCmd reports on one's own use of bild from the local history.
+var insightsCmd = &cobra.Command{
+	Use:   "insights",
+	Short: "Show your most-run phases, time spent waiting on builds and failure hotspots",
+	Long: `Analyzes the run history on this machine to show which phases you run most,
+how long you waited on builds each week, and which phases fail most often.
+Only your own runs are counted unless --all-users is given. The report is
+computed on demand from the local history; nothing is collected or sent
+anywhere.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		top, _ := cmd.Flags().GetInt("top")
+		allUsers, _ := cmd.Flags().GetBool("all-users")
+		var since time.Time
+		if sinceFlag != "" {
+			window, err := parseDuration(sinceFlag)
+			if err != nil {
+				return err
+			}
+			since = time.Now().Add(-window)
+		}
+		user := runUser()
+		if allUsers {
+			user = ""
+		}
+
+		entries, err := loadHistory("")
+		if err != nil {
+			return err
+		}
+		in := bild.ComputeInsights(entries, user, since, top)
+		if in.Runs == 0 {
+			fmt.Println(t("history.none"))
+			return nil
+		}
+		printInsights(in)
+		return nil
+	},
+}
+
+// printInsights prints the report of bild insights.
+func printInsights(in bild.Insights) {
+	if in.Since.IsZero() {
+		fmt.Println(tn("insights.header_all", in.Runs, in.Runs, in.Waited.Round(time.Second)))
+	} else {
+		fmt.Println(tn("insights.header", in.Runs, in.Since.Local().Format("2006-01-02"), in.Runs, in.Waited.Round(time.Second)))
+	}
+
+	fmt.Println()
+	fmt.Println(t("insights.top"))
+	fmt.Printf("%-20s %-12s %5s %12s\n", "PROJECT", "PHASE", "RUNS", "TOTAL")
+	for _, u := range in.TopPhases {
+		fmt.Printf("%-20s %-12s %5d %12s\n", u.Project, u.Phase, u.Runs, u.Total.Round(time.Second))
+	}
+
+	fmt.Println()
+	fmt.Println(t("insights.weeks"))
+	fmt.Printf("%-10s  %5s %12s\n", "WEEK OF", "RUNS", "WAITED")
+	var most time.Duration
+	for _, w := range in.Weeks {
+		most = max(most, w.Waited)
+	}
+	for _, w := range in.Weeks {
+		bar := ""
+		if most > 0 {
+			bar = strings.Repeat("█", int(30*w.Waited/most))
+		}
+		line := fmt.Sprintf("%s  %5d %12s  %s", w.Start.Format("2006-01-02"), w.Runs, w.Waited.Round(time.Second), bar)
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+
+	fmt.Println()
+	if len(in.Hotspots) == 0 {
+		fmt.Println(t("insights.no_failures"))
+		return
+	}
+	fmt.Println(t("insights.hotspots"))
+	fmt.Printf("%-20s %-12s %6s %5s %7s\n", "PROJECT", "PHASE", "FAILED", "RUNS", "RATE")
+	for _, u := range in.Hotspots {
+		fmt.Printf("%-20s %-12s %6d %5d %6.0f%%\n", u.Project, u.Phase, u.Failures, u.Runs, 100*u.FailureRate())
+	}
+}
+
+func init() {
+	insightsCmd.Flags().String("since", "84d", "Only analyze runs within this long before now, e.g. 30d or 72h (empty for all)")
+	insightsCmd.Flags().IntP("top", "n", 5, "Number of phases to list as most run and as failure hotspots (0 for all)")
+	insightsCmd.Flags().Bool("all-users", false, "Count everyone's runs recorded here, not just your own")
+	rootCmd.AddCommand(insightsCmd)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// lintCmd checks project configurations against the team's conventions.
+var lintCmd = &cobra.Command{
+	Use:   "lint [project...]",
+	Short: "Check projects against the phase naming and ordering conventions",
+	Long: `Checks the given projects (default: all projects of the global config, and
+those of the .bild.json in the current repository) for problems such as
+duplicate phase names or needs of unknown phases, and against the
+conventions set in the global config:
+
+  "conventions": {
+    "phase_order": ["configure", "build", "test"],
+    "allowed_phases": ["clean", "lint", "package"],
+    "required_phases": ["build"],
+    "name_pattern": "^[a-z][a-z0-9-]*$"
+  }
+
+Exits with status 1 if any problem is found.`,
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		issues, err := config.Lint(args...)
+		if err != nil {
+			return err
+		}
+		checked := len(args)
+		if checked == 0 {
+			checked = len(config.Projects)
+			local, _, err := findLocalConfigs(config)
+			if err != nil {
+				return err
+			}
+			// Nested local configs are each linted, as each can be run.
+			for ; local != nil && local != config; local = local.Parent {
+				localIssues, err := local.Lint()
+				if err != nil {
+					return err
+				}
+				for i := range localIssues {
+					localIssues[i].Project = bild.LocalConfigName + ":" + localIssues[i].Project
+				}
+				issues = append(issues, localIssues...)
+				checked += len(local.Projects)
+			}
+		}
+
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+		if len(issues) > 0 {
+			return errors.New(tn("lint.problems", len(issues), len(issues)))
+		}
+		fmt.Println(tn("lint.ok", checked, checked))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// listOptions selects what bild list shows and how.
+type listOptions struct {
+	namesOnly bool   // only project names
+	phases    bool   // project and phase names, without commands
+	format    string // plain, table or json
+}
+
+// listProjects prints the projects of config in the requested format.
+func listProjects(config *bild.Config, names []string, opts listOptions) error {
+	switch opts.format {
+	case "plain":
+		listPlain(config, names, opts)
+	case "table":
+		listTable(config, names, opts)
+	case "json":
+		return listJSON(config, names, opts)
+	default:
+		return fmt.Errorf("unknown format %q (want plain, table or json)", opts.format)
+	}
+	return nil
+}
+
+// listedProject returns project name with its inherited phases merged in,
+// but still naming the project it extends. A project whose base cannot be
+// resolved is listed as written.
+func listedProject(config *bild.Config, name string) bild.Project {
+	proj := config.Projects[name]
+	if resolved, err := config.Project(name); err == nil {
+		resolved.Extends = proj.Extends
+		return *resolved
+	}
+	return proj
+}
+
+// listPlain prints projects for humans, with highlighted commands. With
+// --names-only it prints one name per line, which suits shell scripts.
+func listPlain(config *bild.Config, names []string, opts listOptions) {
+	if opts.namesOnly {
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return
+	}
+	if len(names) == 0 {
+		fmt.Println(t("list.none"))
+		return
+	}
+
+	fmt.Println(t("list.header"))
+	for _, projName := range names {
+		projConfig := listedProject(config, projName)
+		fmt.Println()
+		fmt.Println(t("list.project", projName))
+		if projConfig.Extends != "" {
+			fmt.Println(t("list.extends", projConfig.Extends))
+		}
+		if from := config.IncludedFrom(projName); from != "" {
+			fmt.Println(t("list.included", from))
+		}
+		if projConfig.Path != "" {
+			fmt.Println(t("list.path", projConfig.Path))
+		}
+		if len(projConfig.Tags) > 0 {
+			fmt.Println(t("list.tags", strings.Join(projConfig.Tags, ", ")))
+		}
+		if len(projConfig.Profiles) > 0 {
+			fmt.Println(t("list.profiles", strings.Join(slices.Sorted(maps.Keys(projConfig.Profiles)), ", ")))
+		}
+		if len(projConfig.Phases) == 0 {
+			fmt.Println(t("list.noPhases"))
+			continue
+		}
+		for _, ph := range projConfig.Phases {
+			fmt.Println(tn("list.phase", len(ph.Commands), ph.Name, len(ph.Commands)))
+			if ph.Skip {
+				fmt.Println(t("list.skip"))
+			}
+			if opts.phases {
+				continue
+			}
+
+			// Show highlighted commands
+			for i := range ph.Commands {
+				highlighted := highlightCommand(stepCommand(&ph, i))
+				fmt.Printf("      $ %s\n", highlighted)
+			}
+		}
+	}
+}
+
+// listTable prints one row per project, or per phase with --phases.
+func listTable(config *bild.Config, names []string, opts listOptions) {
+	switch {
+	case opts.namesOnly:
+		fmt.Println("PROJECT")
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	case opts.phases:
+		fmt.Printf("%-20s %-12s %8s  %s\n", "PROJECT", "PHASE", "COMMANDS", "DESCRIPTION")
+		for _, name := range names {
+			for _, ph := range listedProject(config, name).Phases {
+				row := fmt.Sprintf("%-20s %-12s %8d  %s", name, ph.Name, len(ph.Commands), ph.Description)
+				fmt.Println(strings.TrimRight(row, " "))
+			}
+		}
+	default:
+		fmt.Printf("%-20s %6s %8s  %s\n", "PROJECT", "PHASES", "COMMANDS", "PHASE NAMES")
+		for _, name := range names {
+			proj := listedProject(config, name)
+			commands := 0
+			phaseNames := make([]string, len(proj.Phases))
+			for i, ph := range proj.Phases {
+				commands += len(ph.Commands)
+				phaseNames[i] = ph.Name
+			}
+			fmt.Printf("%-20s %6d %8d  %s\n", name, len(proj.Phases), commands, strings.Join(phaseNames, ", "))
+		}
+	}
+}
+
+// listJSON prints the projects as a JSON array: of names with --names-only,
+// of projects with their phase names with --phases, and of complete projects
+// otherwise.
+func listJSON(config *bild.Config, names []string, opts listOptions) error {
+	type projectPhases struct {
+		Name   string   `json:"name"`
+		Phases []string `json:"phases"`
+	}
+	type project struct {
+		Name string `json:"name"`
+		bild.Project
+	}
+
+	var v any
+	switch {
+	case opts.namesOnly:
+		v = append([]string{}, names...)
+	case opts.phases:
+		list := []projectPhases{}
+		for _, name := range names {
+			p := projectPhases{Name: name, Phases: []string{}}
+			for _, ph := range listedProject(config, name).Phases {
+				p.Phases = append(p.Phases, ph.Name)
+			}
+			list = append(list, p)
+		}
+		v = list
+	default:
+		list := []project{}
+		for _, name := range names {
+			list = append(list, project{Name: name, Project: listedProject(config, name)})
+		}
+		v = list
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// listCmd lists the registered projects.
+var listCmd = &cobra.Command{
+	Use:   "list [project]",
+	Short: "List registered projects and their phases",
+	Long:  "Lists the projects of the global configuration with their phases and commands. If a project is given, only that project is shown; with --tag, only the projects carrying the tags. Use --format json or --names-only for output that scripts can consume.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts listOptions
+		opts.namesOnly, _ = cmd.Flags().GetBool("names-only")
+		opts.phases, _ = cmd.Flags().GetBool("phases")
+		opts.format, _ = cmd.Flags().GetString("format")
+
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		names := config.ProjectNames()
+		if len(args) == 1 {
+			if _, err := config.Project(args[0]); err != nil {
+				return err
+			}
+			names = []string{args[0]}
+		}
+		if tags, _ := cmd.Flags().GetStringSlice("tag"); len(tags) > 0 {
+			names = slices.DeleteFunc(names, func(name string) bool {
+				proj := config.Projects[name]
+				return !proj.HasTags(tags)
+			})
+		}
+		return listProjects(config, names, opts)
+	},
+}
+
+func init() {
+	listCmd.Flags().Bool("names-only", false, "Only list project names")
+	listCmd.Flags().Bool("phases", false, "List phase names without their commands")
+	listCmd.Flags().String("format", "plain", "Output format: plain, table or json")
+	listCmd.Flags().StringSlice("tag", nil, "Only list projects with this tag; repeat or separate with commas to require several")
+	listCmd.MarkFlagsMutuallyExclusive("names-only", "phases")
+	listCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"plain", "table", "json"}, cobra.ShellCompDirectiveNoFileComp))
+	listCmd.ValidArgsFunction = completeProjects
+	rootCmd.AddCommand(listCmd)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// loadCmd adopts a project of the repository's local config into the global
+// config, the inverse of dumpCmd.
+var loadCmd = &cobra.Command{
+	Use:   "load [name]",
+	Short: "Load a project of the local .bild.json into the global config",
+	Long: `Adopts a project shared in the repository's .bild.json (or .bild.toml) into
+the global config under name, by default the project of the repository, so
+that it runs wherever you are and can be adjusted to your setup. It is the
+inverse of 'bild dump'.
+
+The local project taken is the one named like name, the one named with
+--from, or else the only one. If the global config already holds the
+project, --overwrite replaces it and --merge lays the local project over it
+as 'extends' would: phases of the same name are replaced, the others are
+added, and your own phases and settings are kept.`,
+	Example: `  bild load
+  bild load api --from backend
+  bild load --merge`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		merge, _ := cmd.Flags().GetBool("merge")
+		overwrite, _ := cmd.Flags().GetBool("overwrite")
+		from, _ := cmd.Flags().GetString("from")
+
+		repoRoot, err := bild.RepoRoot("")
+		if err != nil {
+			return fmt.Errorf("failed to get git repository root: %v", err)
+		}
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		local, ok, err := bild.LoadLocalConfig(repoRoot)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("no %s or %s in %s", bild.LocalConfigName, bild.LocalConfigTOMLName, repoRoot)
+		}
+		local.Dir = repoRoot
+		// A local project extending the global one of its name resolves
+		// against the global config.
+		local.Parent = config.WithoutOverrides()
+
+		var name string
+		if len(args) > 0 {
+			name = args[0]
+		} else if name, err = repoProjectName(); err != nil {
+			return fmt.Errorf("could not determine the project name from the git repository; please provide one")
+		}
+		if from == "" {
+			if _, ok := local.Projects[name]; ok {
+				from = name
+			}
+		} else if _, ok := local.Projects[from]; !ok {
+			return &bild.ProjectNotFoundError{Project: from}
+		}
+		proj, _, err := local.WithoutOverrides().LocalProject(from, name)
+		if err != nil {
+			var ambiguous *bild.AmbiguousProjectError
+			if errors.As(err, &ambiguous) {
+				return fmt.Errorf("%s holds the projects %s; choose one with --from", bild.LocalConfigPath(repoRoot), strings.Join(ambiguous.Projects, ", "))
+			}
+			return err
+		}
+		loaded := *proj
+		loaded.Name = ""
+
+		existing, exists := config.Projects[name]
+		switch {
+		case exists && merge:
+			loaded = bild.MergeProject(existing, loaded)
+			if loaded.Tags == nil {
+				loaded.Tags = existing.Tags
+			}
+			if loaded.Remote == "" {
+				loaded.Remote = existing.Remote
+			}
+			loaded.Path = existing.Path
+		case exists && !overwrite:
+			return fmt.Errorf("project %s already exists; use --merge to add the local phases to it or --overwrite to replace it", name)
+		}
+		// Where the project is checked out only holds on this machine.
+		if loaded.Path == "" {
+			loaded.Path = repoRoot
+		}
+		config.Projects[name] = loaded
+		if err := saveConfig(config); err != nil {
+			return fmt.Errorf(t("err.save_config"), err)
+		}
+
+		if exists && merge {
+			fmt.Println(t("load.merged", bild.LocalConfigPath(repoRoot), name))
+		} else {
+			fmt.Println(t("load.done", name, bild.LocalConfigPath(repoRoot)))
+		}
+		for _, phase := range loaded.Phases {
+			first := ""
+			if len(phase.Commands) > 0 {
+				first, _, _ = strings.Cut(phase.Commands[0], "\n")
+			}
+			fmt.Println(t("import.phase", phase.Name, first))
+		}
+		return nil
+	},
+}
+
+func init() {
+	loadCmd.Flags().String("from", "", "Project of the local config to load (default: the one named like the project, or the only one)")
+	loadCmd.Flags().Bool("merge", false, "Merge the local project into an existing project of the same name")
+	loadCmd.Flags().Bool("overwrite", false, "Replace an existing project of the same name")
+	loadCmd.MarkFlagsMutuallyExclusive("merge", "overwrite")
+	rootCmd.AddCommand(loadCmd)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// logsCmd shows the output logged by runs with a log directory.
+var logsCmd = &cobra.Command{
+	Use:   "logs [project] [phase]",
+	Short: "Show the most recent output log of a project or phase",
+	Long: `Prints the most recent log written by a run with --log-dir (or log_dir set
+in the config) for the project (default: the one named after the git
+repository), optionally restricted to one phase. With --list, the available
+logs are listed newest first instead.`,
+	Args:              cobra.RangeArgs(0, 2),
+	ValidArgsFunction: completeProjectPhases,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var projectName, phaseName string
+		var err error
+		if len(args) >= 1 {
+			projectName = args[0]
+		} else if projectName, err = repoProjectName(); err != nil {
+			return errors.New(t("err.no_project_name"))
+		}
+		if len(args) == 2 {
+			phaseName = args[1]
+		}
+		list, _ := cmd.Flags().GetBool("list")
+
+		dir, err := logDir(cmd)
+		if err != nil {
+			return err
+		}
+		logs, err := bild.PhaseLogs(dir, projectName, phaseName)
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			fmt.Println(t("logs.none", projectName, dir))
+			return nil
+		}
+		if list {
+			for _, path := range logs {
+				fmt.Println(path)
+			}
+			return nil
+		}
+
+		f, err := os.Open(logs[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(os.Stdout, f)
+		return err
+	},
+}
+
+// logDir returns the directory logs are read from: --log-dir, the log_dir of
+// the config, or the logs directory below the data directory.
+func logDir(cmd *cobra.Command) (string, error) {
+	dir, _ := cmd.Flags().GetString("log-dir")
+	if dir == "" {
+		config, err := loadConfig()
+		if err != nil {
+			return "", fmt.Errorf(t("err.load_config"), err)
+		}
+		dir = config.EffectiveLogDir()
+	}
+	if dir == "" {
+		dirs, err := getDirs()
+		if err != nil {
+			return "", err
+		}
+		return dirs.LogsDir(), nil
+	}
+	return expandHome(dir)
+}
+
+func init() {
+	logsCmd.Flags().String("log-dir", "", "Directory the logs were written to (default: log_dir from the config)")
+	logsCmd.Flags().Bool("list", false, "List the logs, newest first, instead of printing the most recent one")
+	rootCmd.AddCommand(logsCmd)
+}
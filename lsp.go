@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"bild/pkg/bild"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/spf13/cobra"
+)
+
+// Error codes of JSON-RPC 2.0, and the one bild lsp answers a run with
+// while another one is going on.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcRunInProgress  = -32000
+)
+
+// Severities of LSP diagnostics.
+const (
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+)
+
+// rpcMessage is a JSON-RPC request, notification or response as read.
+type rpcMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcError is the error of a failed request.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// lspPosition and lspRange locate a diagnostic, zero-based.
+type (
+	lspPosition struct {
+		Line      int `json:"line"`
+		Character int `json:"character"`
+	}
+	lspRange struct {
+		Start lspPosition `json:"start"`
+		End   lspPosition `json:"end"`
+	}
+)
+
+// lspDiagnostic is a diagnostic as published to the editor.
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+// runDiagnostic is a diagnostic found in the output of a run, as answered
+// to bild/run: ready for a quickfix list, with the file absolute and the
+// line and column counted from one.
+type runDiagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"` // "error" or "warning"
+	Message  string `json:"message"`
+	Phase    string `json:"phase"`
+}
+
+// lspRunParams are the parameters of bild/run. Without a project, it is
+// deduced from the Git repository of the workspace.
+type lspRunParams struct {
+	Project string `json:"project,omitempty"`
+	runRequest
+}
+
+// lspServer speaks JSON-RPC 2.0 framed as in the Language Server Protocol,
+// so that editor plugins can start runs, follow their events and get the
+// diagnostics they print.
+type lspServer struct {
+	wmu sync.Mutex // serializes writes to out
+	out io.Writer
+
+	mu        sync.Mutex
+	run       *exec.Cmd       // the run going on, if any
+	runID     string          // the ID of its bild/run request
+	published map[string]bool // the files diagnostics were published for
+	shutdown  bool
+}
+
+func newLSPServer(out io.Writer) *lspServer {
+	return &lspServer{out: out, published: make(map[string]bool)}
+}
+
+// readRPCMessage reads the body of the next message framed by a
+// Content-Length header.
+func readRPCMessage(in *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, _ := strings.Cut(line, ":")
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("bad Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("message without Content-Length")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(in, body)
+	return body, err
+}
+
+// write sends v as a message.
+func (s *lspServer) write(v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		bild.Tracef("lsp", "cannot encode %T: %v", v, err)
+		return
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// reply answers the request id with result.
+func (s *lspServer) reply(id json.RawMessage, result any) {
+	s.write(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  any             `json:"result"`
+	}{"2.0", id, result})
+}
+
+// fail answers the request id with an error.
+func (s *lspServer) fail(id json.RawMessage, code int, err error) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	s.write(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Error   rpcError        `json:"error"`
+	}{"2.0", id, rpcError{code, err.Error()}})
+}
+
+// notify sends the notification method.
+func (s *lspServer) notify(method string, params any) {
+	s.write(struct {
+		JSONRPC string `json:"jsonrpc"`
+		Method  string `json:"method"`
+		Params  any    `json:"params"`
+	}{"2.0", method, params})
+}
+
+// serve handles the messages read from in until the exit notification or
+// the end of in. A run still going on then is interrupted.
+func (s *lspServer) serve(in io.Reader) error {
+	r := bufio.NewReader(in)
+	defer s.stop("")
+	for {
+		body, err := readRPCMessage(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.fail(nil, rpcParseError, err)
+			continue
+		}
+		bild.Tracef("lsp", "%s %s", msg.Method, msg.ID)
+		if msg.Method == "exit" {
+			return nil
+		}
+		s.handle(msg)
+	}
+}
+
+// handle answers a request or acts on a notification. Responses, of which
+// bild lsp asks for none, and unknown notifications are ignored.
+func (s *lspServer) handle(msg rpcMessage) {
+	isRequest := msg.ID != nil
+	s.mu.Lock()
+	shutdown := s.shutdown
+	s.mu.Unlock()
+	if shutdown && isRequest {
+		s.fail(msg.ID, rpcInvalidRequest, errors.New("the server is shutting down"))
+		return
+	}
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			RootURI string `json:"rootUri"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		// Projects are deduced from the workspace, as from the current
+		// directory on the command line.
+		if u, err := url.Parse(params.RootURI); err == nil && u.Scheme == "file" {
+			if err := os.Chdir(u.Path); err != nil {
+				bild.Tracef("lsp", "staying in the current directory: %v", err)
+			}
+		}
+		s.reply(msg.ID, map[string]any{
+			"capabilities": map[string]any{},
+			"serverInfo":   map[string]string{"name": "bild"},
+		})
+	case "shutdown":
+		s.mu.Lock()
+		s.shutdown = true
+		s.mu.Unlock()
+		s.reply(msg.ID, nil)
+	case "bild/projects":
+		config, err := loadConfig()
+		if err != nil {
+			s.fail(msg.ID, rpcInvalidRequest, err)
+			return
+		}
+		s.reply(msg.ID, apiProjects(config))
+	case "bild/run":
+		var params lspRunParams
+		if len(msg.Params) > 0 {
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				s.fail(msg.ID, rpcInvalidParams, err)
+				return
+			}
+		}
+		s.startRun(msg.ID, params)
+	case "bild/stop":
+		s.stop("")
+		s.reply(msg.ID, nil)
+	case "$/cancelRequest":
+		var params struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if json.Unmarshal(msg.Params, &params) == nil {
+			s.stop(string(params.ID))
+		}
+	default:
+		if isRequest {
+			s.fail(msg.ID, rpcMethodNotFound, fmt.Errorf("unknown method %s", msg.Method))
+		}
+	}
+}
+
+// stop interrupts the run going on, as Ctrl-C would; if id is set, only if
+// it is the run of that request.
+func (s *lspServer) stop(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.run == nil || id != "" && id != s.runID {
+		return
+	}
+	if err := s.run.Process.Signal(os.Interrupt); err != nil {
+		bild.Tracef("lsp", "cannot interrupt the run: %v", err)
+	}
+}
+
+// startRun starts the run of a bild/run request, answered once it ended.
+func (s *lspServer) startRun(id json.RawMessage, params lspRunParams) {
+	out := infoOut
+	infoOut = io.Discard
+	proj, dir, _, err := resolveRun(params.Project)
+	infoOut = out
+	if err != nil {
+		s.fail(id, rpcInvalidParams, err)
+		return
+	}
+	for _, phase := range params.Phases {
+		if _, err := proj.Phase(phase); err != nil {
+			s.fail(id, rpcInvalidParams, err)
+			return
+		}
+	}
+	self, err := os.Executable()
+	if err != nil {
+		s.fail(id, rpcInvalidRequest, err)
+		return
+	}
+	cmd := exec.Command(self, params.args(proj.Name)...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		s.fail(id, rpcInvalidRequest, err)
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		s.fail(id, rpcInvalidRequest, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.run != nil {
+		s.fail(id, rpcRunInProgress, errors.New("a run is already going on"))
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		s.fail(id, rpcInvalidRequest, err)
+		return
+	}
+	s.run, s.runID = cmd, string(id)
+	// The diagnostics of the last run are stale now.
+	for uri := range s.published {
+		s.notify("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": []lspDiagnostic{}})
+	}
+	s.published = make(map[string]bool)
+	go s.follow(id, cmd, dir, stdout, stderr)
+}
+
+// follow passes the events of cmd on as bild/event notifications and
+// publishes the diagnostics in its output as it goes, then answers the
+// request id with the exit code and all diagnostics.
+func (s *lspServer) follow(id json.RawMessage, cmd *exec.Cmd, dir string, stdout, stderr io.Reader) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// What bild itself has to say, such as why a run could not start.
+		lines := bufio.NewScanner(stderr)
+		for lines.Scan() {
+			s.notify("window/logMessage", map[string]any{"type": 4, "message": lines.Text()})
+		}
+	}()
+
+	diags := []runDiagnostic{}
+	seen := make(map[runDiagnostic]bool)
+	byURI := make(map[string][]lspDiagnostic)
+	lines := bufio.NewScanner(stdout)
+	lines.Buffer(nil, 1<<20)
+	for lines.Scan() {
+		var event struct {
+			Event string `json:"event"`
+			Phase string `json:"phase"`
+			Text  string `json:"text"`
+		}
+		if err := json.Unmarshal(lines.Bytes(), &event); err != nil {
+			continue
+		}
+		s.notify("bild/event", json.RawMessage(append([]byte(nil), lines.Bytes()...)))
+		if event.Event != "line" {
+			continue
+		}
+		d, ok := parseRunDiagnostic(event.Text, dir)
+		if !ok || seen[d] {
+			continue
+		}
+		d.Phase = event.Phase
+		seen[d] = true
+		diags = append(diags, d)
+
+		uri := (&url.URL{Scheme: "file", Path: filepath.ToSlash(d.File)}).String()
+		byURI[uri] = append(byURI[uri], d.lsp())
+		s.mu.Lock()
+		s.published[uri] = true
+		s.mu.Unlock()
+		s.notify("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": byURI[uri]})
+	}
+	wg.Wait()
+	cmd.Wait()
+
+	s.mu.Lock()
+	s.run, s.runID = nil, ""
+	s.mu.Unlock()
+	s.reply(id, map[string]any{"exit_code": cmd.ProcessState.ExitCode(), "diagnostics": diags})
+}
+
+// parseRunDiagnostic returns the diagnostic on line, printed by a phase
+// running in dir, if there is one.
+func parseRunDiagnostic(line, dir string) (runDiagnostic, bool) {
+	m := diagnosticPattern.FindStringSubmatch(ansi.Strip(line))
+	if m == nil || m[4] == "note" {
+		return runDiagnostic{}, false
+	}
+	d := runDiagnostic{File: m[1], Severity: "error", Message: m[5]}
+	if m[4] == "warning" {
+		d.Severity = "warning"
+	}
+	if !filepath.IsAbs(d.File) {
+		d.File = filepath.Join(dir, d.File)
+	}
+	d.Line, _ = strconv.Atoi(m[2])
+	d.Column, _ = strconv.Atoi(m[3])
+	return d, true
+}
+
+// lsp returns d as published to the editor.
+func (d runDiagnostic) lsp() lspDiagnostic {
+	pos := lspPosition{Line: max(d.Line-1, 0), Character: max(d.Column-1, 0)}
+	severity := lspSeverityError
+	if d.Severity == "warning" {
+		severity = lspSeverityWarning
+	}
+	return lspDiagnostic{Range: lspRange{pos, pos}, Severity: severity, Source: "bild/" + d.Phase, Message: d.Message}
+}
+
+// lspCmd speaks JSON-RPC on stdin and stdout for editor plugins.
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Speak JSON-RPC on stdin and stdout for editor plugins",
+	Long: `Speaks JSON-RPC 2.0 on stdin and stdout, framed as in the Language Server
+Protocol, so that Neovim, VS Code and other editors can start runs from
+their plugins, show their progress and fill quickfix lists and problem
+panels with the diagnostics compilers and linters print, such as
+"src/main.c:12:5: error: expected ';'".
+
+Besides initialize, shutdown and exit, it answers:
+
+  bild/projects   the projects with their phases
+  bild/run        run a project: {"project": "...", "phases": [...],
+                  "skip": [...], "profile": "...", "force": true}, all
+                  optional; answered once the run ended with its
+                  "exit_code" and "diagnostics"
+  bild/stop       interrupt the run going on; so does $/cancelRequest
+
+While a run goes on, each event of 'bild run --output json' is sent as a
+bild/event notification, and the diagnostics found in the output are
+published with textDocument/publishDiagnostics as they come. Without a
+project, it is deduced from the Git repository of the workspace.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return newLSPServer(os.Stdout).serve(os.Stdin)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lspCmd)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"bild/pkg/bild"
+)
+
+func TestLSPServer(t *testing.T) {
+	t.Setenv(bild.ConfigDirEnv, t.TempDir())
+	var in bytes.Buffer
+	for _, msg := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"bild/projects"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"bild/nope"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+		`{"jsonrpc":"2.0","id":5,"method":"bild/projects"}`,
+	} {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+	var out bytes.Buffer
+	if err := newLSPServer(&out).serve(&in); err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(&out)
+	var got []string
+	for {
+		body, err := readRPCMessage(r)
+		if err != nil {
+			break
+		}
+		var resp struct {
+			ID     int              `json:"id"`
+			Result *json.RawMessage `json:"result"`
+			Error  *rpcError        `json:"error"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case resp.Error != nil:
+			got = append(got, fmt.Sprintf("%d:%d", resp.ID, resp.Error.Code))
+		case resp.Result != nil:
+			got = append(got, fmt.Sprintf("%d:%s", resp.ID, *resp.Result))
+		default:
+			got = append(got, fmt.Sprintf("%d:null", resp.ID))
+		}
+	}
+	// Nothing is answered after exit.
+	want := []string{`1:{"capabilities":{},"serverInfo":{"name":"bild"}}`, "2:[]", "3:-32601", "4:null"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("responses:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseRunDiagnostic(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		want runDiagnostic
+		ok   bool
+	}{
+		{"src/main.c:12:5: error: expected ';'", runDiagnostic{File: "/p/src/main.c", Line: 12, Column: 5, Severity: "error", Message: "expected ';'"}, true},
+		{"\x1b[1m/abs/x.go:7: warning: unused\x1b[0m", runDiagnostic{File: "/abs/x.go", Line: 7, Severity: "warning", Message: "unused"}, true},
+		{"main.go:3:1: note: declared here", runDiagnostic{}, false},
+		{"ok  \tbild/pkg/bild\t0.1s", runDiagnostic{}, false},
+	} {
+		got, ok := parseRunDiagnostic(tt.line, "/p")
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRunDiagnostic(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -1,464 +1,1037 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"bild/pkg/bild"
+	"github.com/alecthomas/chroma/formatters"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
 	"github.com/spf13/cobra"
-  "github.com/alecthomas/chroma/formatters"
-  "github.com/alecthomas/chroma/lexers"
-  "github.com/alecthomas/chroma/styles"
 )
 
-// Global variable to hold the configuration file path (set via --config flag).
-var configFile string
+// Global variables to hold the configuration file path, directory and storage backend
+// (set via --config, --config-dir and --storage flags).
+var (
+	configFile  string
+	configDir   string
+	storageName string
+	debugDest   string // set via --debug; see bild.DebugEnv
+	noGit       bool   // set via --no-git; see bild.NoGitEnv
+)
+
+// expandHome expands a leading "~" in path to the user's home directory.
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// parseDuration parses a Go duration, additionally accepting a whole number
+// of days such as "30d".
+func parseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
 
-// Phase represents an ordered set of commands for one phase (e.g. "configure", "build", "test").
-type Phase struct {
-	Name     string   `json:"name"`
-	Commands []string `json:"commands"`
+// getDirs returns the directories bild keeps its files in.
+// If the --config-dir flag was provided, everything lives below that directory;
+// otherwise $BILD_CONFIG_DIR or the per-user defaults are used.
+func getDirs() (bild.Dirs, error) {
+	if configDir != "" {
+		base, err := expandHome(configDir)
+		if err != nil {
+			return bild.Dirs{}, err
+		}
+		bild.Tracef("dirs", "using --config-dir %s", base)
+		return bild.DirsUnder(base), nil
+	}
+	return bild.DefaultDirs()
 }
 
-// ProjectConfig holds the phases for a given project.
-type ProjectConfig struct {
-	Phases []Phase `json:"phases"`
+// setupTrace points bild's decision trace at the destination given by
+// --debug or $BILD_DEBUG.
+func setupTrace() error {
+	dest := debugDest
+	if dest == "" {
+		dest = os.Getenv(bild.DebugEnv)
+	}
+	w, _, err := bild.OpenTrace(dest)
+	if err != nil {
+		return fmt.Errorf("cannot open debug trace: %v", err)
+	}
+	// A trace file stays open until the process exits.
+	bild.Trace = w
+	return nil
 }
 
-// Config holds a mapping from project names to their configurations.
-type Config struct {
-	Projects map[string]ProjectConfig `json:"projects"`
+// setupGit applies --no-git or $BILD_NO_GIT, and lets the repository roots
+// found be remembered across invocations.
+func setupGit() {
+	bild.NoGit = noGit || os.Getenv(bild.NoGitEnv) == "1"
+	if dirs, err := getDirs(); err == nil {
+		bild.RepoCache = dirs.RepoCachePath()
+	}
 }
 
 // getConfigFilePath returns the configuration file path.
 // If the --config flag was provided, that value is used (with "~" expanded).
-// Otherwise, it defaults to ~/.config/bild/bild.json.
+// Otherwise, it defaults to bild.json (or bild.toml) in the config directory.
+// Files ending in .toml are read and written as TOML.
 func getConfigFilePath() (string, error) {
 	if configFile != "" {
-		if strings.HasPrefix(configFile, "~") {
-			home, err := os.UserHomeDir()
-			if err != nil {
-				return "", err
-			}
-			return filepath.Join(home, configFile[1:]), nil
-		}
-		return configFile, nil
+		return expandHome(configFile)
 	}
-
-	home, err := os.UserHomeDir()
+	dirs, err := getDirs()
 	if err != nil {
 		return "", err
 	}
-	configDir := filepath.Join(home, ".config", "bild")
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return "", err
+	return dirs.ConfigPath(), nil
+}
+
+// storageBackend returns the name of the storage backend selected by
+// --storage or $BILD_STORAGE.
+func storageBackend() string {
+	if storageName != "" {
+		return storageName
 	}
-	return filepath.Join(configDir, "bild.json"), nil
+	if name := os.Getenv(bild.StorageEnv); name != "" {
+		return name
+	}
+	return bild.StorageJSON
 }
 
-// loadConfig reads the configuration from file (or returns an empty config if the file doesn't exist).
-func loadConfig() (*Config, error) {
-	path, err := getConfigFilePath()
+// memoryStorage is the memory backend, opened once so that what one part of
+// a command saves, the next loads.
+var memoryStorage struct {
+	sync.Mutex
+	store *bild.MemoryStore
+}
+
+// getStorage opens the storage backend selected by --storage or $BILD_STORAGE.
+// The caller must close it.
+func getStorage() (bild.Storage, error) {
+	dirs, err := getDirs()
 	if err != nil {
 		return nil, err
 	}
-
-	config := &Config{
-		Projects: make(map[string]ProjectConfig),
+	if storageBackend() != bild.StorageMemory {
+		return bild.OpenStorage(storageBackend(), dirs)
 	}
+	memoryStorage.Lock()
+	defer memoryStorage.Unlock()
+	if memoryStorage.store == nil {
+		if memoryStorage.store, err = bild.OpenMemoryStore(dirs); err != nil {
+			return nil, err
+		}
+	}
+	return memoryStorage.store, nil
+}
 
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return config, nil
+// getConfigStore returns the store holding the global configuration and a
+// function releasing it. An explicit --config file always wins over the
+// storage backend.
+func getConfigStore() (bild.ConfigStore, func() error, error) {
+	if configFile != "" {
+		path, err := getConfigFilePath()
+		if err != nil {
+			return nil, nil, err
+		}
+		bild.Tracef("config", "using --config %s instead of the storage backend", path)
+		return &bild.FileStore{Path: path}, func() error { return nil }, nil
+	}
+	storage, err := getStorage()
+	if err != nil {
+		return nil, nil, err
 	}
+	return storage, storage.Close, nil
+}
 
-	data, err := ioutil.ReadFile(path)
+// loadConfig reads the configuration from file (or returns an empty config if the file doesn't exist).
+func loadConfig() (*bild.Config, error) {
+	store, closeStore, err := getConfigStore()
 	if err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal(data, config); err != nil {
-		return nil, err
+	defer closeStore()
+	config, err := store.Load()
+	if err == nil && config.Migrated != nil {
+		if config.Migrated.Backup != "" {
+			fmt.Fprintln(os.Stderr, t("config.migrated_backup", config.Migrated.From, bild.ConfigVersion, config.Migrated.Backup))
+		} else {
+			fmt.Fprintln(os.Stderr, t("config.migrated", config.Migrated.From, bild.ConfigVersion))
+		}
 	}
-	return config, nil
+	return config, err
 }
 
 // saveConfig writes the configuration to file.
-func saveConfig(config *Config) error {
-	path, err := getConfigFilePath()
+func saveConfig(config *bild.Config) error {
+	store, closeStore, err := getConfigStore()
 	if err != nil {
 		return err
 	}
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
+	if err := store.Save(config); err != nil {
+		closeStore()
 		return err
 	}
-	return ioutil.WriteFile(path, data, 0644)
+	return closeStore()
 }
 
-// getGitRepoName determines the repository name by running "git rev-parse --show-toplevel"
-// and returning the basename of the resulting path.
-// TODO: Maybe augment this to use git remote -v to get the actual repo name in case the directory name differs.
-//      This would require a more complex parsing of the output... And probably wouldn't work. 
-func getGitRepoName() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	output, err := cmd.Output()
+// dumpProjectConfig dumps a project's configuration to the local .bild.json
+// file, or .bild.toml if format is toml. An empty format keeps the format of
+// the existing local file. With link, the project is kept in sync with the
+// local file by bild sync-local.
+func dumpProjectConfig(projectName string, config *bild.Config, format string, link bool) error {
+	// Verify project exists; this machine's overrides and where the project
+	// is checked out stay out of the repository.
+	proj, err := config.SharedProject(projectName)
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	// Get git repository root
+	repoRoot, err := bild.RepoRoot("")
+	if err != nil {
+		return fmt.Errorf("failed to get git repository root: %v", err)
+	}
+
+	if format == "" {
+		format = bild.ConfigFormat(bild.LocalConfigPath(repoRoot))
+	}
+	localConfigPath, err := bild.WriteLocalConfigAs(repoRoot, projectName, *proj, format)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(t("dump.done", projectName, localConfigPath))
+	if config.Projects[projectName].Path == "" {
+		recordPath(config, projectName, repoRoot)
+	}
+	if link {
+		if err := recordLink(config, projectName, repoRoot); err != nil {
+			return err
+		}
+		fmt.Println(t("dump.linked", projectName))
+	}
+	return nil
+}
+
+// stepCommand returns command i of ph, followed by the name and description
+// of its step as a shell comment if it was written as a step.
+func stepCommand(ph *bild.Phase, i int) string {
+	step := ph.Step(i)
+	if label := step.Label(); label != "" {
+		return step.Run + "  # " + label
 	}
-	repoPath := strings.TrimSpace(string(output))
-	return filepath.Base(repoPath), nil
+	return step.Run
 }
 
-// openEditor opens the user's preferred editor (from $EDITOR, defaulting to "vi")
-// on a temporary file with a .md extension (for syntax highlighting) and returns its contents.
-func openEditor(initialContent string) (string, error) {
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		editor = "vi"
+// highlightCommand returns a syntax-highlighted version of the command
+func highlightCommand(command string) string {
+	if noColor {
+		return command
+	}
+	lexer := lexers.Get("bash")
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	style := styles.Get("monokai")
+	if style == nil {
+		style = styles.Fallback
+	}
+	formatter := formatters.Get("terminal")
+	if formatter == nil {
+		formatter = formatters.Fallback
 	}
 
-	// Create a temporary file with .md extension for Markdown highlighting
-	tmpFile, err := ioutil.TempFile("", "bild_edit_*.md")
+	iterator, err := lexer.Tokenise(nil, command)
 	if err != nil {
-		return "", err
+		return command // Return original if highlighting fails
 	}
-	tmpFileName := tmpFile.Name()
 
-	if initialContent != "" {
-		if _, err := tmpFile.WriteString(initialContent); err != nil {
-			return "", err
+	var buf strings.Builder
+	err = formatter.Format(&buf, style, iterator)
+	if err != nil {
+		return command // Return original if formatting fails
+	}
+
+	return buf.String()
+}
+
+// consoleObserver prints phase headers and the highlighted commands about to
+// run, marking each phase for the terminal if marks is set (see osc133.go).
+// With quiet set, only the marks are printed. With gha set, each phase is
+// instead folded into a group of the GitHub Actions log (see gha.go).
+type consoleObserver struct {
+	marks bool
+	quiet bool
+	gha   *ghaAnnotator
+}
+
+// newConsoleObserver returns the observer printing the run of runner to the
+// console: phases are marked when stdout is a terminal, and --quiet and
+// --verbose print less and more. In GitHub Actions jobs, runner's output is
+// also scanned for diagnostics to annotate.
+func newConsoleObserver(runner *bild.Runner) bild.Observer {
+	console := consoleObserver{marks: osc133Enabled(), quiet: quiet}
+	if ghaEnabled() {
+		console.marks = false
+		console.gha = newGHAAnnotator(runner.Dir)
+		runner.TailLines = ghaTailLines
+		runner.Stdout = console.gha.writer(runner.Stdout)
+		runner.Stderr = console.gha.writer(runner.Stderr)
+	}
+	if verbose {
+		return bild.MultiObserver(console, &verboseObserver{})
+	}
+	return console
+}
+
+func (o consoleObserver) PhaseStarted(phase *bild.Phase) {
+	if o.gha != nil {
+		o.gha.startPhase(phase)
+	} else if !o.quiet {
+		fmt.Println()
+	}
+	if o.marks {
+		fmt.Print(osc133PromptStart)
+	}
+	if !o.quiet {
+		if o.gha == nil {
+			fmt.Println(t("run.phase_header", phase.Name))
+		}
+		for i := range phase.Commands {
+			fmt.Printf("$ %s\n", highlightCommand(stepCommand(phase, i)))
 		}
 	}
-	tmpFile.Close()
+	if o.marks {
+		fmt.Print(osc133CommandStart + osc133OutputStart)
+	}
+}
 
-	cmd := exec.Command(editor, tmpFileName)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return "", err
+func (o consoleObserver) PhaseFinished(phase *bild.Phase, err error, elapsed time.Duration) {
+	if o.marks {
+		exit := 0
+		if err != nil {
+			exit = exitCode(err)
+		}
+		fmt.Print(osc133Done(exit))
+	}
+	if o.gha != nil {
+		o.gha.finishPhase(phase, err)
+	}
+}
+
+func (o consoleObserver) PhaseSkipped(phase *bild.Phase, when string) {
+	if !o.quiet {
+		fmt.Println()
+		fmt.Println(t("run.phase_skipped", phase.Name, when))
+	}
+}
+
+func (o consoleObserver) PhaseUpToDate(phase *bild.Phase) {
+	if !o.quiet {
+		fmt.Println()
+		fmt.Println(t("run.up_to_date", phase.Name))
+	}
+}
+
+func (o consoleObserver) PhaseRestored(phase *bild.Phase) {
+	if !o.quiet {
+		fmt.Println()
+		fmt.Println(t("run.restored", phase.Name))
+	}
+}
+
+// maxShownFailures bounds the failed tests listed after a phase.
+const maxShownFailures = 10
+
+func (o consoleObserver) TestsReported(phase *bild.Phase, report *bild.TestReport) {
+	if o.quiet {
+		return
 	}
+	fmt.Println(t("run.tests", phase.Name, testsText(report)))
+	for i, f := range report.Failures {
+		if i == maxShownFailures {
+			break
+		}
+		fmt.Println("   ✗ " + f.String())
+	}
+	if more := report.Failed - min(len(report.Failures), maxShownFailures); more > 0 {
+		fmt.Println("   " + t("run.tests_more", more))
+	}
+}
 
-	content, err := ioutil.ReadFile(tmpFileName)
+func (o consoleObserver) CoverageReported(phase *bild.Phase, result bild.CoverageResult) {
+	if o.quiet {
+		return
+	}
+	if result.Previous != nil {
+		fmt.Println(t("run.coverage_was", phase.Name, result.Percent, *result.Previous))
+	} else {
+		fmt.Println(t("run.coverage", phase.Name, result.Percent))
+	}
+}
+
+// testsText sums up report in a few words.
+func testsText(report *bild.TestReport) string {
+	return tn("tests.summary", report.Tests, report.Tests, report.Passed(), report.Failed, report.Skipped)
+}
+
+// runOptions holds the flags that change how a run is carried out or presented.
+type runOptions struct {
+	tui    bool   // show the live dashboard instead of streaming output
+	output string // "text" or "json"
+	ref    string // run in a temporary worktree at this git ref
+	logDir string // log each phase's output below this directory
+	// deadline, if set, is when no more phases are started.
+	deadline time.Time
+	// failFastOrder runs the phases likeliest to fail soonest first.
+	failFastOrder bool
+	// checkPins, "warn" or "fail", compares tool versions with the pins.
+	checkPins string
+	// all runs all phases even if the project has a default phase.
+	all bool
+	// skip names phases left out of the run.
+	skip []string
+	// profile names the profile of the project to apply.
+	profile string
+	// windows runs parallel variants in terminal windows of their own.
+	windows bool
+	// force runs phases with inputs even if these haven't changed.
+	force bool
+	// from and until, "phase" or "phase:step", bound the phases run.
+	from, until string
+	// retry names the phases and matrix variants failed before to run
+	// again; see Project.Retry.
+	retry []string
+	// problems names the file the problems the phases reported are
+	// written to, as SARIF if it ends in .sarif.
+	problems string
+}
+
+// skipPhases returns the names of the phases to run instead of phases, as
+// passed to runProject, if some are left out: those named in skip, and
+// without phases those the config skips. It tells which phases are skipped.
+func skipPhases(proj *bild.Project, phases, skip []string) ([]string, error) {
+	selected, err := proj.SelectPhases(phases)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	os.Remove(tmpFileName)
-	return string(content), nil
-}
-
-// editEntireProject using Markdown format
-// TODO: Maybe just cut my losses and keep it in the JSON format - I'm just a slut for some syntax highlighting
-func editEntireProject(projectName string, config *Config) error {
-	// Get or create the project configuration
-	proj, exists := config.Projects[projectName]
-	if !exists {
-		proj = ProjectConfig{Phases: []Phase{}}
-	}
-
-	// Build the initial content in Markdown format
-	var initialContent strings.Builder
-	
-	// Project header
-	initialContent.WriteString("# Project: " + projectName + "\n\n")
-	
-	// Instructions
-	initialContent.WriteString("Edit commands for each phase below. Instructions:\n")
-	initialContent.WriteString("- Order of phases here determines execution order\n")
-	initialContent.WriteString("- Commands must be inside ``` blocks\n")
-	initialContent.WriteString("- Each phase must be a level 2 heading (##)\n\n")
-
-	// Add existing phases
-	for _, phase := range proj.Phases {
-		initialContent.WriteString("## " + phase.Name + "\n\n")
-		initialContent.WriteString("```bash\n")
-		for i, cmd := range phase.Commands {
-			initialContent.WriteString(cmd)
-			if i < len(phase.Commands)-1 {
-				initialContent.WriteString("\n")
-			}
+	kept, err := proj.WithoutPhases(selected, skip)
+	if err != nil {
+		return nil, err
+	}
+	var skipped []string
+	for i := range proj.Phases {
+		ph := &proj.Phases[i]
+		if slices.Contains(kept, ph) {
+			continue
 		}
-		initialContent.WriteString("\n```\n\n")
+		if slices.Contains(selected, ph) || (len(phases) == 0 && ph.Skip) {
+			skipped = append(skipped, ph.Name)
+		}
+	}
+	if len(skipped) == 0 {
+		return phases, nil
 	}
+	fmt.Fprintln(infoOut, t("run.skipped", strings.Join(skipped, ", ")))
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("all phases to run are skipped")
+	}
+	names := make([]string, len(kept))
+	for i, ph := range kept {
+		names[i] = ph.Name
+	}
+	return names, nil
+}
 
-	// Open editor
-	editedContent, err := openEditor(initialContent.String())
+// orderByRisk reorders the phases of proj for --fail-fast-order. Phases
+// keep their order if the history records no failures of the project.
+func orderByRisk(proj *bild.Project) error {
+	entries, err := loadHistory(proj.Name)
 	if err != nil {
 		return err
 	}
+	if !slices.ContainsFunc(entries, func(e bild.HistoryEntry) bool { return !e.Succeeded() }) {
+		fmt.Fprintln(infoOut, t("run.risk_no_history", proj.Name))
+		return nil
+	}
+	phases, err := bild.OrderByRisk(proj.Phases, entries)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(phases))
+	for i, ph := range phases {
+		names[i] = ph.Name
+	}
+	fmt.Fprintln(infoOut, t("run.risk_order", strings.Join(names, " → ")))
+	proj.Phases = phases
+	return nil
+}
 
-	// Parse the edited content
-	var newPhases []Phase
-	var currentPhase *Phase
-	var inCodeBlock bool
-	var codeLines []string
+// parseDeadline parses the --deadline of a run: a time of day, which is the
+// next time the clock shows it after now, or a duration from now.
+func parseDeadline(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(d), nil
+	}
+	clock, err := time.Parse("15:04", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid deadline %q (want a time of day such as 07:00 or a duration such as 6h)", s)
+	}
+	deadline := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !deadline.After(now) {
+		deadline = deadline.AddDate(0, 0, 1)
+	}
+	return deadline, nil
+}
 
-	lines := strings.Split(editedContent, "\n")
-	
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
+// infoOut receives informational messages that are not part of a run's
+// output, such as the directory a run changes to. Machine-readable output
+// modes point it at stderr so that stdout stays parseable.
+var infoOut io.Writer = os.Stdout
+
+// resolveProject determines the project to run and the directory to run it in.
+// The git repository root is preferred as working directory; a .bild.json found
+// in the current directory or one of its parents up to the repository root
+// takes precedence over the global configuration, and the run then happens in
+// the directory holding it. An empty projectName means the project of the
+// workspace the current directory is in, else the project of the local
+// config, if it has only one, or else the one named like the repository.
+func resolveProject(projectName string, config *bild.Config) (*bild.Project, string, error) {
+	l, err := lookupProject(config)
+	if err != nil {
+		return nil, "", err
+	}
+	// Always attempt to run from the git repository root
+	dir := l.cwd
+	switch {
+	case l.rootErr == nil:
+		fmt.Fprintln(infoOut, t("run.chdir", l.stop))
+		dir = l.stop
+	case errors.Is(l.rootErr, bild.ErrNoGit):
+		fmt.Fprintln(infoOut, t("run.no_git"))
+	default:
+		fmt.Fprintln(infoOut, t("run.not_git"))
+	}
+	repoName := l.repoProject()
+	named := projectName != ""
 
-		// Skip empty lines and the project header
-		if trimmed == "" || strings.HasPrefix(trimmed, "# Project:") || 
-		   strings.HasPrefix(trimmed, "Edit commands") || strings.HasPrefix(trimmed, "-") {
-			continue
-		}
+	// Try to load local config first, from the nearest directory that has one
+	localConfig, localDir := l.local, l.localDir
 
-		// Check for phase headers (##)
-		if strings.HasPrefix(trimmed, "## ") {
-			// If we were building a phase, finalize it
-			if currentPhase != nil && len(codeLines) > 0 {
-				currentPhase.Commands = codeLines
-				newPhases = append(newPhases, *currentPhase)
-			}
-			
-			// Start a new phase
-			phaseName := strings.TrimSpace(trimmed[3:])
-			currentPhase = &Phase{
-				Name:     phaseName,
-				Commands: []string{},
-			}
-			codeLines = nil
-			inCodeBlock = false
-			continue
+	// A partial name stands for the project it matches
+	if projectName != "" {
+		if projectName, err = matchProject(projectName, l.configs); err != nil {
+			return nil, "", err
 		}
+	}
 
-		// Handle code blocks
-		if trimmed == "```" || trimmed == "```bash" {
-			inCodeBlock = !inCodeBlock
-			continue
+	// A workspace names the project, which then runs in the workspace
+	workspace := ""
+	if projectName == "" {
+		if name, wsDir, ok := l.workspace(); ok {
+			fmt.Fprintln(infoOut, t("run.workspace", wsDir, name))
+			projectName, workspace = name, wsDir
 		}
+	}
 
-		// Collect commands inside code blocks
-		if inCodeBlock && currentPhase != nil && trimmed != "" {
-			codeLines = append(codeLines, trimmed)
+	if localConfig != nil && (projectName != "" || len(localConfig.Projects) > 0) {
+		bild.Tracef("resolve", "%s found in %s; it takes precedence over the global config", bild.LocalConfigName, localDir)
+		proj, projDir, err := localConfig.LocalProject(projectName, repoName)
+		var ambiguous *bild.AmbiguousProjectError
+		if errors.As(err, &ambiguous) {
+			err = errors.New(t("err.local_ambiguous", ambiguous.Dir, strings.Join(ambiguous.Projects, ", ")))
+			return nil, "", &unresolvedError{err: err, config: l.configs, projects: ambiguous.Projects}
+		}
+		var notFound *bild.ProjectNotFoundError
+		if errors.As(err, &notFound) {
+			return nil, "", &unresolvedError{err: err, config: l.configs, query: projectName}
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if workspace != "" {
+			return proj, workspace, nil
+		}
+		if projDir == "" {
+			// Named, but defined in the global config
+			return atCheckout(config, l, proj, named, dir)
+		}
+		if projDir != dir {
+			fmt.Fprintln(infoOut, t("run.chdir_local", projDir))
 		}
+		return proj, projDir, nil
 	}
 
-	// Add the last phase if it exists
-	if currentPhase != nil && len(codeLines) > 0 {
-		currentPhase.Commands = codeLines
-		newPhases = append(newPhases, *currentPhase)
+	// Fall back to global config
+	if projectName == "" {
+		projectName = repoName
+		bild.Tracef("resolve", "project name %q taken from the repository", projectName)
 	}
+	bild.Tracef("resolve", "looking up project %q in the global config", projectName)
+	if projectName == "" {
+		return nil, "", &unresolvedError{err: errors.New(t("err.project_needed")), config: l.configs}
+	}
+	proj, err := config.Project(projectName)
+	if err != nil {
+		return nil, "", &unresolvedError{err: err, config: l.configs, query: projectName}
+	}
+	if workspace != "" {
+		return proj, workspace, nil
+	}
+	return atCheckout(config, l, proj, named, dir)
+}
 
-	// Update the project with the new phases
-	proj.Phases = newPhases
-	config.Projects[projectName] = proj
+// atCheckout returns proj, a project of the global config, and where to run
+// it instead of dir: in its path, with the local configs there, if it was
+// named outside of both the path and a clone of the project, as
+// projectLookup.repoProject tells. A project without a path run in a clone
+// of it gets the clone recorded as its path.
+func atCheckout(config *bild.Config, l *projectLookup, proj *bild.Project, named bool, dir string) (*bild.Project, string, error) {
+	inRepo := l.rootErr == nil && l.repoProject() == proj.Name
+	if proj.Path == "" {
+		if inRepo {
+			// Worktrees come and go; the main working tree stays.
+			root := l.stop
+			if main, _ := bild.MainWorktree(root); main != "" {
+				root = main
+			}
+			recordPath(config, proj.Name, root)
+		}
+		return proj, dir, nil
+	}
+	path, err := expandHome(proj.Path)
+	if err != nil {
+		return nil, "", err
+	}
+	if !named || inRepo || bild.Within(l.cwd, path) {
+		return proj, dir, nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, "", fmt.Errorf("project %s: its path %s: %w", proj.Name, proj.Path, err)
+	}
+	if proj, dir, err = resolveStep(bild.PipelineStep{Project: proj.Name, Dir: path}, config); err != nil {
+		return nil, "", err
+	}
+	fmt.Fprintln(infoOut, t("run.path", proj.Name, dir))
+	return proj, dir, nil
+}
 
-	// Save the configuration
+// recordPath records path as the path of project name in the global
+// config, for runs naming it elsewhere. Failing to do so only warns.
+func recordPath(config *bild.Config, name, path string) {
+	proj, ok := config.Projects[name]
+	if !ok {
+		return
+	}
+	proj.Path = path
+	config.Projects[name] = proj
 	if err := saveConfig(config); err != nil {
-		return fmt.Errorf("error saving config: %v", err)
+		fmt.Fprintf(os.Stderr, "Warning: could not record the path of %s: %v\n", name, err)
+		return
 	}
+	fmt.Fprintln(infoOut, t("run.path_recorded", path, name))
+}
 
-	fmt.Printf("Project %s updated with %d phase(s).\n", projectName, len(newPhases))
-	for _, phase := range newPhases {
-		fmt.Printf("  Phase %s: %d command(s)\n", phase.Name, len(phase.Commands))
+// projectLookup is what the project of the current directory is looked up
+// in, by a run and by the commands defaulting to the project a run would
+// take: the local configs from the current directory up to the git
+// repository root, or, outside a repository and with --no-git, up to the
+// root of the file system, layered over the global configuration.
+type projectLookup struct {
+	cwd string
+	// stop is the root of the repository, where the search for local
+	// configs stops; it is empty outside a repository, which rootErr then
+	// tells about.
+	stop    string
+	rootErr error
+	// local is the nearest local config, in localDir, and configs local or
+	// else the global configuration.
+	local    *bild.Config
+	localDir string
+	configs  *bild.Config
+	// repo caches repoProject once it was asked for.
+	repo *string
+}
+
+// repoProject returns the project the repository is a clone of, as
+// Config.RepoProject finds it, or "" outside a repository.
+func (l *projectLookup) repoProject() string {
+	if l.repo == nil {
+		name := ""
+		if l.rootErr == nil {
+			name, _ = l.configs.RepoProject(l.stop)
+		}
+		l.repo = &name
 	}
-	
-	return nil
+	return *l.repo
 }
 
-// dumpProjectConfig dumps a project's configuration to the local .bild.json file
-func dumpProjectConfig(projectName string, config *Config) error {
-	// Verify project exists
-	proj, exists := config.Projects[projectName]
-	if !exists {
-		return fmt.Errorf("project %s not found", projectName)
+// repoProjectName returns the project the repository of the current
+// directory is a clone of, looked up in its local configs over the global
+// configuration; see Config.RepoProject.
+func repoProjectName() (string, error) {
+	config, err := findConfig()
+	if err != nil {
+		return "", err
 	}
+	return config.RepoProject("")
+}
 
-	// Get git repository root
-	gitCmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	output, err := gitCmd.Output()
+// lookupProject finds the local configs of the current directory over
+// config.
+func lookupProject(config *bild.Config) (*projectLookup, error) {
+	cwd, err := os.Getwd()
 	if err != nil {
-		return fmt.Errorf("failed to get git repository root: %v", err)
+		return nil, err
+	}
+	l := &projectLookup{cwd: cwd, configs: config}
+	var root string
+	if root, l.rootErr = bild.RepoRoot(""); l.rootErr == nil {
+		// A submodule may run as part of its superproject.
+		if l.stop, err = config.ProjectRoot(root); err != nil {
+			return nil, err
+		}
+	}
+	if l.local, l.localDir, err = bild.FindLocalConfig(cwd, l.stop, config); err != nil {
+		return nil, err
+	}
+	if l.local != nil {
+		l.configs = l.local
 	}
-	repoRoot := strings.TrimSpace(string(output))
+	return l, nil
+}
+
+// workspace returns the project of the workspace the current directory is
+// in, and the workspace's directory.
+func (l *projectLookup) workspace() (string, string, bool) {
+	return l.configs.Workspace(l.cwd, l.stop)
+}
 
-	// Create local config with just this project
-	localConfig := map[string]ProjectConfig{
-		projectName: proj,
+// findLocalConfigs finds the local configs a run in the current directory
+// would, layered over config; see projectLookup. It returns the nearest one
+// and its directory, or nil if there is none.
+func findLocalConfigs(config *bild.Config) (*bild.Config, string, error) {
+	l, err := lookupProject(config)
+	if err != nil {
+		return nil, "", err
 	}
+	return l.local, l.localDir, nil
+}
 
-	// Marshal the config with proper indentation
-	data, err := json.MarshalIndent(localConfig, "", "  ")
+// runSettings holds the settings of the global configuration that apply to
+// every run, returned along with the project so that the configuration is
+// not loaded twice.
+type runSettings struct {
+	logDir   string
+	terminal string
+}
+
+// resolveRun determines the project to run like resolveProject, asking the
+// agent first when one is running.
+func resolveRun(projectName string) (*bild.Project, string, runSettings, error) {
+	if proj, dir, settings, ok := resolveWithAgent(projectName); ok {
+		return proj, dir, settings, nil
+	}
+
+	config, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("failed to marshal config: %v", err)
+		return nil, "", runSettings{}, fmt.Errorf(t("err.load_config"), err)
 	}
+	proj, dir, err := resolveProject(projectName, config)
+	return proj, dir, runSettings{logDir: config.EffectiveLogDir(), terminal: config.EffectiveTerminal()}, err
+}
 
-	// Write to file
-	localConfigPath := filepath.Join(repoRoot, ".bild.json")
-	if err := os.WriteFile(localConfigPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write config: %v", err)
+// checkoutRef checks out ref of the repository dir is in into a temporary
+// worktree, leaving the current checkout alone. It returns the worktree and
+// where dir is in it; the returned function removes the worktree again.
+func checkoutRef(dir, ref string) (string, string, func(), error) {
+	repoRoot, err := bild.RepoRoot(dir)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("--ref needs a git repository")
 	}
+	tmp, err := os.MkdirTemp("", "bild-ref-")
+	if err != nil {
+		return "", "", nil, err
+	}
+	worktree := filepath.Join(tmp, filepath.Base(repoRoot))
+	commit, err := bild.AddWorktree(repoRoot, worktree, ref)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", "", nil, err
+	}
+	fmt.Fprintln(infoOut, t("run.ref", ref, shortCommit(commit), worktree))
 
-	fmt.Printf("Successfully dumped configuration for project '%s' to %s\n", projectName, localConfigPath)
-	return nil
+	cleanup := func() {
+		if err := bild.RemoveWorktree(repoRoot, worktree); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		os.RemoveAll(tmp)
+	}
+	return worktree, checkoutPath(repoRoot, dir, worktree), cleanup, nil
 }
 
-// highlightCommand returns a syntax-highlighted version of the command
-func highlightCommand(command string) string {
-    lexer := lexers.Get("bash")
-    if lexer == nil {
-        lexer = lexers.Fallback
-    }
-    style := styles.Get("monokai")
-    if style == nil {
-        style = styles.Fallback
-    }
-    formatter := formatters.Get("terminal")
-    if formatter == nil {
-        formatter = formatters.Fallback
-    }
-
-    iterator, err := lexer.Tokenise(nil, command)
-    if err != nil {
-        return command // Return original if highlighting fails
-    }
-
-    var buf strings.Builder
-    err = formatter.Format(&buf, style, iterator)
-    if err != nil {
-        return command // Return original if formatting fails
-    }
-
-    return buf.String()
-}
-
-
-// loadLocalConfig attempts to load a .bild.json file from the current directory
-func loadLocalConfig() (*Config, bool, error) {
-    // Check if .bild.json exists in current directory
-    if _, err := os.Stat(".bild.json"); os.IsNotExist(err) {
-        return nil, false, nil
-    }
-
-    data, err := ioutil.ReadFile(".bild.json")
-    if err != nil {
-        return nil, false, fmt.Errorf("failed to read local config: %v", err)
-    }
-
-    var config Config
-    if err := json.Unmarshal(data, &config.Projects); err != nil {
-        return nil, false, fmt.Errorf("failed to parse local config: %v", err)
-    }
-
-    return &config, true, nil
-}
-
-// runProject executes the commands for a project.
-// If phaseName is empty, all phases are run in order.
-// Otherwise, only the specified phase is executed.
-func runProject(projectName string, phaseName string, config *Config) error {
-    // Always attempt to change to the git repository root
-    gitCmd := exec.Command("git", "rev-parse", "--show-toplevel")
-    gitOutput, err := gitCmd.Output()
-    if err == nil {
-        repoRoot := strings.TrimSpace(string(gitOutput))
-        fmt.Printf("Changing working directory to repository root: %s\n", repoRoot)
-        if err := os.Chdir(repoRoot); err != nil {
-            os.Exit(1)  // Exit directly on directory change failure
-        }
-    } else {
-        fmt.Println("Not a git repository; running in current directory.")
-    }
-
-    // Try to load local config first
-    localConfig, hasLocal, err := loadLocalConfig()
-    if err != nil {
-        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-        os.Exit(1)  // Exit directly on config load failure
-    }
-
-    var proj ProjectConfig
-    if hasLocal {
-        // For local config, just take the first project regardless of name
-        for _, p := range localConfig.Projects {
-            proj = p
-            break
-        }
-    } else {
-        // Fall back to global config
-        if projectName == "" {
-            fmt.Fprintf(os.Stderr, "Error: project name required when no local config exists\n")
-            os.Exit(1)
-        }
-        var exists bool
-        proj, exists = config.Projects[projectName]
-        if !exists {
-            fmt.Fprintf(os.Stderr, "Error: project %s not found\n", projectName)
-            os.Exit(1)
-        }
-    }
-
-    // If no phase is specified, run all phases
-    if phaseName == "" {
-        for _, ph := range proj.Phases {
-            fmt.Printf("\n📦 Running phase: %s\n", ph.Name)
-            
-            // Create a shell script that combines all commands in the phase
-            var script strings.Builder
-            script.WriteString("set -e\n") // Exit on any error
-            
-            // Add each command to the script
-            for _, cmd := range ph.Commands {
-                script.WriteString(cmd + "\n")
-                // Show the command that will be executed
-                highlighted := highlightCommand(cmd)
-                fmt.Printf("$ %s\n", highlighted)
-            }
-            
-            // Execute all commands in a single shell process
-            cmd := exec.Command("sh", "-c", script.String())
-            cmd.Stdout = os.Stdout
-            cmd.Stderr = os.Stderr
-            cmd.Stdin = os.Stdin
-            
-            if err := cmd.Run(); err != nil {
-                fmt.Fprintf(os.Stderr, "Error: phase %s failed: %v\n", ph.Name, err)
-                os.Exit(1)  // Exit directly on command failure
-            }
-        }
-        return nil
-    }
-
-    // Run specific phase
-    var found bool
-    for _, ph := range proj.Phases {
-        if ph.Name == phaseName {
-            found = true
-            fmt.Printf("\n📦 Running phase: %s\n", phaseName)
-            
-            // Create a shell script for the specific phase
-            var script strings.Builder
-            script.WriteString("set -e\n")
-            
-            for _, cmd := range ph.Commands {
-                script.WriteString(cmd + "\n")
-                highlighted := highlightCommand(cmd)
-                fmt.Printf("$ %s\n", highlighted)
-            }
-            
-            cmd := exec.Command("sh", "-c", script.String())
-            cmd.Stdout = os.Stdout
-            cmd.Stderr = os.Stderr
-            cmd.Stdin = os.Stdin
-            
-            if err := cmd.Run(); err != nil {
-                fmt.Fprintf(os.Stderr, "Error: phase %s failed: %v\n", phaseName, err)
-                os.Exit(1)  // Exit directly on command failure
-            }
-            break
-        }
-    }
-    if !found {
-        fmt.Fprintf(os.Stderr, "Error: phase %s not found\n", phaseName)
-        os.Exit(1)
-    }
-    return nil
+// checkoutPath returns where dir, a directory of the git repository at root,
+// is in checkout, a clone or worktree of the repository: a project defined
+// in a subdirectory runs in that subdirectory there too.
+func checkoutPath(root, dir, checkout string) string {
+	// The root git reports has its symlinks resolved.
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || !filepath.IsLocal(rel) {
+		return checkout
+	}
+	return filepath.Join(checkout, rel)
+}
+
+// runProject resolves the project to run and executes the named phases in
+// the project's order. Without phases, the project's default phase is run,
+// or, without one or with opts.all, all phases but those to skip.
+func runProject(ctx context.Context, projectName string, phases []string, opts runOptions) error {
+	if opts.output == "json" && !quiet {
+		infoOut = os.Stderr
+	}
+	ctx, stop := bild.NotifyContext(ctx)
+	defer stop()
+	proj, dir, settings, err := resolveRun(projectName)
+	// Asked in a terminal, the user picks what bild cannot tell.
+	var unresolved *unresolvedError
+	if errors.As(err, &unresolved) && canPick(opts) {
+		var picked pickerItem
+		if picked, err = pickRun(unresolved, len(phases) == 0); err == nil {
+			// What resolving said the first time stands.
+			out := infoOut
+			infoOut = io.Discard
+			proj, dir, settings, err = resolveRun(picked.project)
+			infoOut = out
+			if picked.phase != "" {
+				phases = []string{picked.phase}
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if opts.ref != "" {
+		worktree, refDir, cleanup, err := checkoutRef(dir, opts.ref)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		dir = refDir
+		// The phases as of the ref fit its sources better than today's.
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		if local, _, _ := bild.FindLocalConfig(dir, worktree, config); local != nil {
+			if refProj, localDir, err := local.LocalProject(proj.Name, ""); err == nil && localDir != "" {
+				bild.Tracef("resolve", "using the %s of ref %s in %s", bild.LocalConfigName, opts.ref, localDir)
+				proj = refProj
+			}
+		}
+	}
+
+	if opts.profile != "" {
+		if proj, err = proj.WithProfile(opts.profile); err != nil {
+			return err
+		}
+		fmt.Fprintln(infoOut, t("run.profile", opts.profile))
+	}
+	if phases, err = matchPhases(proj, phases); err != nil {
+		return err
+	}
+	if opts.skip, err = proj.WithoutSteps(opts.skip); err != nil {
+		return err
+	}
+	if opts.skip, err = matchPhases(proj, opts.skip); err != nil {
+		return err
+	}
+	if opts.retry != nil {
+		if phases, err = proj.Retry(opts.retry); err != nil {
+			return err
+		}
+	}
+	if opts.from, err = matchBound(proj, opts.from); err != nil {
+		return err
+	}
+	if opts.until, err = matchBound(proj, opts.until); err != nil {
+		return err
+	}
+	// A bound picks its phases from all of the project's.
+	bounded := opts.from != "" || opts.until != ""
+	if len(phases) == 0 && !opts.all && !bounded && proj.DefaultPhase != "" {
+		fmt.Fprintln(infoOut, t("run.default_phase", proj.DefaultPhase, proj.Name))
+		phases = []string{proj.DefaultPhase}
+	}
+	if len(phases) == 0 || len(opts.skip) > 0 {
+		if phases, err = skipPhases(proj, phases, opts.skip); err != nil {
+			return err
+		}
+	}
+	if bounded {
+		if phases, err = proj.Between(phases, opts.from, opts.until); err != nil {
+			return err
+		}
+	}
+	if opts.checkPins != "" {
+		if err := checkPins(ctx, proj, dir, opts.checkPins); err != nil {
+			return err
+		}
+	}
+	if opts.failFastOrder && len(phases) != 1 {
+		if err := orderByRisk(proj); err != nil {
+			return err
+		}
+	}
+
+	runner := bild.NewRunner()
+	runner.Dir = dir
+	if opts.logDir == "" {
+		opts.logDir = settings.logDir
+	}
+	if opts.logDir != "" {
+		if runner.LogDir, err = expandHome(opts.logDir); err != nil {
+			return err
+		}
+	}
+	runner.Deadline = opts.deadline
+	if dirs, err := getDirs(); err == nil {
+		runner.State = dirs.ProjectState(proj.Name)
+		runner.Cache = dirs.ArtifactCache()
+	}
+	runner.Force = opts.force
+	if opts.windows {
+		runner.Terminal = strings.Fields(settings.terminal)
+		if len(runner.Terminal) == 0 {
+			runner.Terminal = bild.DefaultTerminal()
+		}
+	}
+	observers := []bild.Observer{
+		&activeRunObserver{project: proj.Name},
+		newHistoryObserver(proj.Name, dir),
+	}
+
+	// Scanning the output for problems takes it through a pipe, so it is
+	// only done when asked for.
+	if opts.problems != "" || hasProblemMatchers(proj) {
+		runner.Problems = bild.NewProblems()
+	}
+
+	bild.Tracef("run", "project %s in %s: %d phases, tui=%t output=%s", proj.Name, dir, len(proj.Phases), opts.tui, opts.output)
+	if opts.tui {
+		err = runWithTUI(ctx, runner, proj, phases, observers)
+	} else if opts.output == "json" {
+		err = runWithJSON(ctx, runner, proj, phases, observers)
+	} else {
+		timings := bild.NewTimings()
+		var timer bild.Observer = timings
+		if verbose {
+			timer = timings.WithCommands()
+		}
+		runner.Observer = bild.MultiObserver(append([]bild.Observer{newConsoleObserver(runner), timer}, observers...)...)
+		err = runner.RunPhases(ctx, proj, phases)
+		if !quiet {
+			printSummary(proj.Name, timings, err)
+			if runner.Problems != nil {
+				printProblems(runner.Problems.List())
+			}
+		}
+	}
+	if opts.problems != "" {
+		if werr := writeProblems(opts.problems, runner.Problems.List(), dir); werr != nil {
+			return errors.Join(err, werr)
+		}
+	}
+	return err
+}
+
+// runTagged runs phases, as runProject would, in each project carrying
+// every one of tags, of the local configs of the current directory and of
+// the global configuration. Projects lacking one of the phases are left
+// out. A failing project does not keep the others from running, but
+// interrupting one does.
+func runTagged(ctx context.Context, tags, phases []string, opts runOptions) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf(t("err.load_config"), err)
+	}
+	l, err := lookupProject(config)
+	if err != nil {
+		return err
+	}
+	names := l.configs.TaggedProjects(tags)
+	if len(names) == 0 {
+		return fmt.Errorf("no project is tagged %s", strings.Join(tags, " and "))
+	}
+	var errs []error
+	var run []string
+	for _, name := range names {
+		proj, err := l.configs.Project(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if missing := missingPhase(proj, phases); missing != "" {
+			fmt.Fprintln(infoOut, t("run.tag_skip", name, missing))
+			continue
+		}
+		run = append(run, name)
+	}
+	for i, name := range run {
+		fmt.Fprintln(infoOut, t("run.tagged", i+1, len(run), name))
+		err := runProject(ctx, name, phases, opts)
+		var interrupted *bild.InterruptedError
+		if errors.As(err, &interrupted) {
+			return err
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// missingPhase returns the first of names that stands for no phase of
+// proj, or "" if all of them do.
+func missingPhase(proj *bild.Project, names []string) string {
+	for _, name := range names {
+		if _, err := proj.Phase(name); err != nil && len(matchName(name, phaseNames(proj))) == 0 {
+			return name
+		}
+	}
+	return ""
 }
+
 //
 // Cobra commands
 //
@@ -466,201 +1039,194 @@ func runProject(projectName string, phaseName string, config *Config) error {
 // rootCmd is the primary command. If no subcommand is provided and no arguments are given,
 // it deduces the project from the Git repository and runs all phases.
 var rootCmd = &cobra.Command{
-	Use:   "bild",
-	Short: "Bild is a CLI tool for managing build commands for your projects with explicit phases",
-	Long:  "Bild is a CLI tool for registering, editing, and executing build commands organized into explicit phases (e.g. configure, build, test). When no phase is specified, all phases are run.",
+	Use:           "bild",
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	Short:         "Bild is a CLI tool for managing build commands for your projects with explicit phases",
+	Long:          "Bild is a CLI tool for registering, editing, and executing build commands organized into explicit phases (e.g. configure, build, test). When no phase is specified, all phases are run.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := setupOutput(); err != nil {
+			return err
+		}
+		if err := setupTrace(); err != nil {
+			return err
+		}
+		setupGit()
+		setupKey()
+		return nil
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var projectName string
-		if len(args) == 0 {
-			var err error
-			projectName, err = getGitRepoName()
-			if err != nil {
-				return fmt.Errorf("could not determine project name from git repository; please provide project name explicitly")
-			}
-		} else {
+		if len(args) > 0 {
 			projectName = args[0]
 		}
-		config, err := loadConfig()
-		if err != nil {
-			return fmt.Errorf("error loading config: %v", err)
-		}
-		// No phase specified → run all phases.
-		return runProject(projectName, "", config)
+		// No phase specified → run the default phase, else all phases.
+		return runProject(cmd.Context(), projectName, nil, runOptions{})
 	},
 }
 
-// runCmd executes the build commands for a project. Optionally, a specific phase can be run.
+// runCmd executes the build commands for a project. Optionally, specific phases can be run.
 // If no project is provided, it is deduced from the git repository. If no phase is provided,
-// all phases are run.
+// the default phase or else all phases are run.
 var runCmd = &cobra.Command{
-	Use:   "run [project] [phase]",
+	Use:   "run [project] [phase[,phase...]]",
 	Short: "Run build commands for a project (default: run all phases)",
-	Long:  "Executes the build commands for the given project. If a phase is specified, only that phase is executed; otherwise, all phases are run in order. If no project is provided, it is deduced from the Git repository.",
+	Long:  "Executes the build commands for the given project. If phases are specified, as a comma-separated list or with repeated --phase flags, only those are executed, in the project's order; otherwise, the project's default_phase is run if it has one, or else all phases in order but those marked skip in the config. Phases passed to --skip are left out either way. If no project is provided, it is deduced from the Git repository.",
 	Args:  cobra.RangeArgs(0, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var projectName, phaseName string
-		if len(args) == 0 {
-			var err error
-			projectName, err = getGitRepoName()
-			if err != nil {
-				return fmt.Errorf("could not determine project name from git repository; please provide project name explicitly")
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+		if len(tags) > 0 {
+			// The tags pick the projects; an argument names the phases.
+			if len(args) == 2 {
+				return fmt.Errorf("--tag picks the projects to run; name only the phases")
 			}
-		} else if len(args) == 1 {
-			projectName = args[0]
-		} else if len(args) == 2 {
+			args = append([]string{""}, args...)
+		}
+		var projectName string
+		if len(args) >= 1 {
 			projectName = args[0]
-			phaseName = args[1]
 		}
-		config, err := loadConfig()
-		if err != nil {
-			return fmt.Errorf("error loading config: %v", err)
+		phases, _ := cmd.Flags().GetStringSlice("phase")
+		if len(args) == 2 {
+			if len(phases) > 0 {
+				return fmt.Errorf("name the phases either as an argument or with --phase, not both")
+			}
+			phases = strings.Split(args[1], ",")
 		}
-		return runProject(projectName, phaseName, config)
-	},
-}
-
-// editProjectPhase opens the editor to modify the commands for a given phase of a project.
-// If the project or phase does not exist, they are created.
-func editProjectPhase(projectName string, phaseName string, config *Config) error {
-    // Get or create the project configuration.
-    proj, exists := config.Projects[projectName]
-    if !exists {
-        proj = ProjectConfig{Phases: []Phase{}}
-    }
-    // Search for the phase.
-    var phase *Phase
-    for i, ph := range proj.Phases {
-        if ph.Name == phaseName {
-            phase = &proj.Phases[i]
-            break
-        }
-    }
-    if phase == nil {
-        // Create a new phase.
-        newPhase := Phase{
-            Name:     phaseName,
-            Commands: []string{},
-        }
-        proj.Phases = append(proj.Phases, newPhase)
-        phase = &proj.Phases[len(proj.Phases)-1]
-    }
-
-    // Build the initial content for editing.
-    var initialContent string
-    if len(phase.Commands) > 0 {
-        initialContent = strings.Join(phase.Commands, "\n")
-    } else {
-        initialContent = "# Enter one command per line for phase '" + phaseName + "'.\n# Lines starting with '#' are ignored.\n"
-    }
-
-    editedContent, err := openEditor(initialContent)
-    if err != nil {
-        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-        os.Exit(1)
-    }
-
-    // Parse the edited content.
-    var newCommands []string
-    for _, line := range strings.Split(editedContent, "\n") {
-        trimmed := strings.TrimSpace(line)
-        if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-            continue
-        }
-        newCommands = append(newCommands, trimmed)
-    }
-    phase.Commands = newCommands
-
-    // Update the project configuration.
-    config.Projects[projectName] = proj
-    if err := saveConfig(config); err != nil {
-        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-        os.Exit(1)
-    }
-    fmt.Printf("Project %s, phase %s updated with %d command(s).\n", projectName, phaseName, len(newCommands))
-    return nil
-}
-// Modify the editCmd to handle both full project and single phase editing
-// If no phase is provided, it defaults to the "build" phase.
-var editCmd = &cobra.Command{
-	Use:   "edit [project] [phase]",
-	Short: "Edit build commands for a project",
-	Long: `Opens your preferred editor to modify build commands.
-If only a project name is provided, allows editing and reordering all phases.
-If both project and phase are provided, edits only that specific phase.`,
-	Args: cobra.RangeArgs(1, 2),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		projectName := args[0]
-		config, err := loadConfig()
-		if err != nil {
-			return fmt.Errorf("error loading config: %v", err)
+		for _, name := range phases {
+			if name == "" {
+				return fmt.Errorf("empty phase name in %q", strings.Join(phases, ","))
+			}
 		}
-
-		if len(args) == 1 {
-			return editEntireProject(projectName, config)
+		var opts runOptions
+		opts.tui, _ = cmd.Flags().GetBool("tui")
+		opts.output, _ = cmd.Flags().GetString("output")
+		opts.ref, _ = cmd.Flags().GetString("ref")
+		opts.logDir, _ = cmd.Flags().GetString("log-dir")
+		if opts.output != "text" && opts.output != "json" {
+			return fmt.Errorf("unknown output format %q (want text or json)", opts.output)
 		}
-
-		// Edit specific phase (existing behavior)
-		phaseName := args[1]
-		return editProjectPhase(projectName, phaseName, config)
+		opts.failFastOrder, _ = cmd.Flags().GetBool("fail-fast-order")
+		opts.all, _ = cmd.Flags().GetBool("all")
+		if opts.all && len(phases) > 0 {
+			return fmt.Errorf("--all runs every phase; don't name any")
+		}
+		opts.skip, _ = cmd.Flags().GetStringSlice("skip")
+		opts.windows, _ = cmd.Flags().GetBool("windows")
+		opts.force, _ = cmd.Flags().GetBool("force")
+		opts.profile, _ = cmd.Flags().GetString("profile")
+		opts.from, _ = cmd.Flags().GetString("from")
+		opts.until, _ = cmd.Flags().GetString("until")
+		opts.problems, _ = cmd.Flags().GetString("problems")
+		opts.checkPins, _ = cmd.Flags().GetString("check-pins")
+		if opts.checkPins != "" && opts.checkPins != "warn" && opts.checkPins != "fail" {
+			return fmt.Errorf("unknown --check-pins mode %q (want warn or fail)", opts.checkPins)
+		}
+		if deadline, _ := cmd.Flags().GetString("deadline"); deadline != "" {
+			var err error
+			if opts.deadline, err = parseDeadline(deadline, time.Now()); err != nil {
+				return err
+			}
+			bild.Tracef("run", "not starting phases after %s", opts.deadline.Format(time.DateTime))
+		}
+		if len(tags) > 0 {
+			if opts.from != "" || opts.until != "" {
+				return fmt.Errorf("--from and --until bound the phases of a single project; they don't go with --tag")
+			}
+			if opts.problems != "" {
+				return fmt.Errorf("--problems writes the problems of a single project; it doesn't go with --tag")
+			}
+			return runTagged(cmd.Context(), tags, phases, opts)
+		}
+		return runProject(cmd.Context(), projectName, phases, opts)
 	},
 }
 
-// Modify the listProjects function to use highlighting
-func listProjects(config *Config) {
-    if len(config.Projects) == 0 {
-        fmt.Println("No projects registered.")
-        return
-    }
-    
-    fmt.Println("📋 Registered projects:")
-    for projName, projConfig := range config.Projects {
-        fmt.Printf("\n🔷 Project: %s\n", projName)
-        if len(projConfig.Phases) == 0 {
-            fmt.Println("  No phases defined.")
-        } else {
-            for _, ph := range projConfig.Phases {
-                fmt.Printf("  📎 Phase: %s (%d command%s)\n", 
-                    ph.Name, 
-                    len(ph.Commands), 
-                    map[bool]string{true: "", false: "s"}[len(ph.Commands) == 1],
-                )
-                
-                // Show highlighted commands
-                for _, cmd := range ph.Commands {
-                    highlighted := highlightCommand(cmd)
-                    fmt.Printf("      $ %s\n", highlighted)
-                }
-            }
-        }
-    }
-}
-
 // dumpCmd dumps a project's configuration to .bild.json in the git repository root
 var dumpCmd = &cobra.Command{
 	Use:   "dump [project]",
 	Short: "Dump a project's configuration to local .bild.json",
-	Long:  "Exports a project's configuration to .bild.json in the git repository root",
+	Long:  "Exports a project's configuration to .bild.json (or .bild.toml with --format toml) in the git repository root, replacing a local config in the other format",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		projectName := args[0]
+		format, _ := cmd.Flags().GetString("format")
+		if format != "" && format != bild.FormatJSON && format != bild.FormatTOML {
+			return fmt.Errorf("unknown format %q (want json or toml)", format)
+		}
 		config, err := loadConfig()
 		if err != nil {
-			return fmt.Errorf("error loading config: %v", err)
+			return fmt.Errorf(t("err.load_config"), err)
 		}
-		return dumpProjectConfig(projectName, config)
+		link, _ := cmd.Flags().GetBool("link")
+		return dumpProjectConfig(projectName, config, format, link)
 	},
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Path to configuration file (default: ~/.config/bild/bild.json)")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Path to configuration file (default: bild.json in the config directory; see bild storage paths)")
+	rootCmd.PersistentFlags().StringVar(&configDir, "config-dir", "", "Keep config, history and state below this directory (default: $"+bild.ConfigDirEnv+" or per-user directories)")
+	rootCmd.PersistentFlags().StringVar(&storageName, "storage", "", "Storage backend for config and history: json, sqlite or memory (default: $"+bild.StorageEnv+" or json)")
+	rootCmd.PersistentFlags().StringVar(&debugDest, "debug", "", "Trace bild's own decisions to stderr, or to the given file with --debug=FILE (default: $"+bild.DebugEnv+")")
+	rootCmd.PersistentFlags().Lookup("debug").NoOptDefVal = "1"
+	rootCmd.PersistentFlags().BoolVar(&noGit, "no-git", false, "Don't look for a git repository; run in the current directory (default: $"+bild.NoGitEnv+"=1)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only show the output of commands, not bild's own messages")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Also show each phase's shell, directory and environment, and the time each command took")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colors, syntax highlighting and emoji (default: on if $NO_COLOR is set)")
+	runCmd.Flags().Bool("tui", false, "Show a live dashboard with per-phase status and logs")
+	runCmd.Flags().String("output", "text", "Output format: text, or json for one event per line on stdout")
+	runCmd.Flags().String("ref", "", "Run in a temporary worktree checked out at this git ref (branch, tag or commit)")
+	runCmd.Flags().String("check-pins", "", "Compare tool versions with those recorded by bild pin: warn, or fail the run (default warn)")
+	runCmd.Flags().Lookup("check-pins").NoOptDefVal = "warn"
+	runCmd.Flags().StringSliceP("phase", "p", nil, "Run this phase; repeat or separate with commas to run several, in the project's order")
+	runCmd.Flags().BoolP("all", "a", false, "Run all phases, even if the project has a default_phase")
+	runCmd.Flags().StringSlice("tag", nil, "Run the phases in every project with this tag instead of one project; repeat or separate with commas to require several")
+	runCmd.Flags().StringSlice("skip", nil, "Leave this phase, or the step of a phase given as phase:step, out of the run; repeat or separate with commas to skip several")
+	runCmd.Flags().String("from", "", "Start the run with this phase, or with the step of a phase given as phase:step, leaving out those before it")
+	runCmd.Flags().String("until", "", "End the run with this phase, or with the step of a phase given as phase:step, leaving out those after it")
+	runCmd.Flags().String("profile", "", "Apply this profile of the project, such as release, to its vars, env and phases")
+	runCmd.Flags().Bool("windows", false, "Run the variants of parallel matrix phases each in a terminal window of its own (see terminal in the config)")
+	runCmd.Flags().Bool("force", false, "Run phases with inputs even if these haven't changed since the phase last succeeded, instead of restoring their outputs from the cache")
+	runCmd.Flags().Bool("fail-fast-order", false, "Run phases that failed often and quickly in past runs first, as far as their needs allow")
+	runCmd.Flags().String("deadline", "", "Start no phases after this time of day (15:04) or duration from now (6h30m)")
+	runCmd.Flags().String("problems", "", "Write the problems the phases' problem matchers found to this file, as SARIF if it ends in .sarif, else as JSON")
+	runCmd.Flags().String("log-dir", "", "Also write each phase's output to <dir>/<project>/<phase>-<time>.log (default: log_dir from the config)")
+	runCmd.MarkFlagsMutuallyExclusive("tui", "output")
+	runCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions([]string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp))
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(editCmd)
+	dumpCmd.Flags().String("format", "", "File format: json or toml (default: that of the existing local config, else json)")
+	dumpCmd.Flags().Bool("link", false, "Keep the project in sync with the local config; see bild sync-local")
 	rootCmd.AddCommand(dumpCmd)
 }
 
+// exitCode maps an error returned by a command to the process exit status.
+func exitCode(err error) int {
+	var phaseErr *bild.PhaseError
+	if errors.As(err, &phaseErr) {
+		return phaseErr.ExitCode
+	}
+	var hookErr *bild.HookError
+	if errors.As(err, &hookErr) {
+		return hookErr.ExitCode
+	}
+	var deadline *bild.DeadlineError
+	if errors.As(err, &deadline) {
+		return deadline.ExitCode()
+	}
+	var interrupted *bild.InterruptedError
+	if errors.As(err, &interrupted) {
+		return interrupted.ExitCode()
+	}
+	return 1
+}
+
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	finish := finishBackground()
+	if err := rootCmd.ExecuteContext(context.Background()); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		finish(exitCode(err))
+		os.Exit(exitCode(err))
 	}
+	finish(0)
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bild/pkg/bild"
+)
+
+func TestConfigDir(t *testing.T) {
+	home, env := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(bild.ConfigDirEnv, env)
+	defer func(dir string) { configDir = dir }(configDir)
+
+	configDir = ""
+	if dirs, err := getDirs(); err != nil || dirs != bild.DirsUnder(env) {
+		t.Errorf("with $%s: %+v, %v", bild.ConfigDirEnv, dirs, err)
+	}
+	// --config-dir wins over the environment, and may start with ~.
+	configDir = "~/bild-test"
+	if dirs, err := getDirs(); err != nil || dirs != bild.DirsUnder(filepath.Join(home, "bild-test")) {
+		t.Errorf("with --config-dir: %+v, %v", dirs, err)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	dir := t.TempDir()
+	original := `{"version": 1, "projects": {"api": {"phases": [{"name": "build", "commands": ["make"]}]}}}`
+	if err := os.WriteFile(filepath.Join(dir, "bild.json"), []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(dir, name string) {
+		configDir, storageName, memoryStorage.store = dir, name, nil
+	}(configDir, storageName)
+	configDir, storageName = dir, bild.StorageMemory
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Projects["web"] = bild.Project{Phases: []bild.Phase{{Name: "test", Commands: []string{"npm test"}}}}
+	if err := saveConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	// Later loads of the command see the change, the file never does.
+	if config, err = loadConfig(); err != nil || len(config.Projects) != 2 {
+		t.Errorf("projects after saving = %+v, %v", config.Projects, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "bild.json")); err != nil || string(data) != original {
+		t.Errorf("bild.json = %q, %v; want it untouched", data, err)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"bild/pkg/bild"
+)
+
+// matchName returns the names query may stand for: query itself if it is
+// one of them, or else those it is a prefix of, ignoring case, or else
+// those it fuzzily matches, the best matches first.
+func matchName(query string, names []string) []string {
+	if slices.Contains(names, query) {
+		return []string{query}
+	}
+	var prefixed []string
+	for _, name := range names {
+		if strings.HasPrefix(strings.ToLower(name), strings.ToLower(query)) {
+			prefixed = append(prefixed, name)
+		}
+	}
+	if len(prefixed) > 0 {
+		return prefixed
+	}
+	items := make([]pickerItem, len(names))
+	for i, name := range names {
+		items[i] = pickerItem{project: name}
+	}
+	var matched []string
+	for _, item := range fuzzyFilter(items, query) {
+		matched = append(matched, item.project)
+	}
+	return matched
+}
+
+// matchProject returns the project of configs, or of a config under it,
+// that name stands for. A name matching several projects is an error the
+// user may resolve in the picker; one matching none is left to the lookup
+// to report.
+func matchProject(name string, configs *bild.Config) (string, error) {
+	var names []string
+	for cfg := configs; cfg != nil; cfg = cfg.Parent {
+		for _, n := range cfg.ProjectNames() {
+			if !slices.Contains(names, n) {
+				names = append(names, n)
+			}
+		}
+	}
+	if slices.Contains(names, name) {
+		return name, nil
+	}
+	switch matched := matchName(name, names); len(matched) {
+	case 0:
+		return name, nil
+	case 1:
+		fmt.Fprintln(infoOut, t("run.matched_project", matched[0], name))
+		return matched[0], nil
+	default:
+		err := errors.New(t("err.project_match", name, strings.Join(matched, ", ")))
+		return "", &unresolvedError{err: err, config: configs, projects: matched}
+	}
+}
+
+// matchPhases returns names with the phases of proj they stand for in
+// place of partial names. Names of phases, aliases and deprecated names
+// are taken as they are, as are names matching no phase, for the run to
+// report.
+func matchPhases(proj *bild.Project, names []string) ([]string, error) {
+	all := phaseNames(proj)
+	matched := slices.Clone(names)
+	for i, name := range names {
+		var notFound *bild.PhaseNotFoundError
+		if _, _, err := proj.LookupPhase(name); !errors.As(err, &notFound) || notFound.RenamedTo != "" {
+			continue
+		}
+		switch candidates := matchName(name, all); len(candidates) {
+		case 0:
+		case 1:
+			fmt.Fprintln(infoOut, t("run.matched_phase", candidates[0], name))
+			matched[i] = candidates[0]
+		default:
+			return nil, errors.New(t("err.phase_match", name, proj.Name, strings.Join(candidates, ", ")))
+		}
+	}
+	return matched, nil
+}
+
+// matchBound returns bound, a phase or "phase:step" as --from and --until
+// take it, with its phase matched as by matchPhases.
+func matchBound(proj *bild.Project, bound string) (string, error) {
+	if bound == "" {
+		return "", nil
+	}
+	phase, step, ok := strings.Cut(bound, ":")
+	matched, err := matchPhases(proj, []string{phase})
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return matched[0], nil
+	}
+	return matched[0] + ":" + step, nil
+}
+
+// phaseNames returns the names of the phases of proj, in order.
+func phaseNames(proj *bild.Project) []string {
+	names := make([]string, len(proj.Phases))
+	for i, ph := range proj.Phases {
+		names[i] = ph.Name
+	}
+	return names
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchName(t *testing.T) {
+	names := []string{"build", "bundle", "configure", "test", "test-e2e"}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"test", []string{"test"}},
+		{"conf", []string{"configure"}},
+		{"B", []string{"build", "bundle"}},
+		{"cfg", []string{"configure"}},
+		{"te2", []string{"test-e2e"}},
+		{"xyz", nil},
+	}
+	for _, tt := range tests {
+		if got := matchName(tt.query, names); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("matchName(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package main
+
+import "bild/pkg/msg"
+
+// printer formats all human-facing output in the user's language.
+var printer = msg.NewPrinter(msg.DetectLanguage())
+
+// t formats the message for key.
+func t(key string, args ...any) string {
+	return plain(printer.Sprintf(key, args...))
+}
+
+// tn formats the singular or plural form of the message for key depending on n.
+func tn(key string, n int, args ...any) string {
+	return plain(printer.Plural(key, n, args...))
+}
+
+func init() {
+	msg.Register("en", msg.Catalog{
+		"err.load_config":     {Other: "error loading config: %v"},
+		"err.save_config":     {Other: "error saving config: %v"},
+		"err.no_project_name": {Other: "could not determine project name from git repository; please provide project name explicitly"},
+		"err.project_needed":  {Other: "project name required when no local config exists"},
+		"err.local_ambiguous": {Other: "the local config in %s has several projects (%s); pick one with bild run <project> [phase]"},
+		"err.project_match":   {Other: "%s matches several projects: %s"},
+		"err.phase_match":     {Other: "%s matches several phases of %s: %s"},
+		"err.agent_disabled":  {Other: "the agent requires the daemon experiment; enable it with `bild experiments enable daemon`"},
+		"err.no_sync":         {Other: "no sync repository set up; set one up with bild sync init <repository>"},
+		"err.sync_conflict":   {Other: "%v; see bild sync status, then keep one side with bild sync pull --force or bild sync push --force"},
+
+		"config.migrated":        {Other: "Upgraded the config from version %d to %d; it is stored in the new format when saved next."},
+		"config.migrated_backup": {Other: "Upgraded the config from version %d to %d; the previous file was saved as %s."},
+
+		"run.chdir":           {Other: "Changing working directory to repository root: %s"},
+		"run.not_git":         {Other: "Not a git repository; running in current directory."},
+		"run.workspace":       {Other: "In the workspace %s of project %s; running there."},
+		"run.chdir_local":     {Other: "Using the local config in %s and running there."},
+		"run.no_git":          {Other: "Not looking for a git repository (--no-git); running in current directory."},
+		"run.risk_order":      {Other: "Running phases in order of past failures: %s"},
+		"run.risk_no_history": {Other: "No failed runs of %s are recorded; keeping the order of its phases."},
+		"run.default_phase":   {Other: "Running the default phase %s of %s; use --all to run every phase."},
+		"run.skipped":         {Other: "Skipping %s."},
+		"run.profile":         {Other: "Using profile %s."},
+		"run.phase_header":    {Other: "📦 Running phase: %s"},
+		"run.phase_skipped":   {Other: "⏭️ Skipping phase %s: %s does not hold"},
+		"run.up_to_date":      {Other: "⏭️ Skipping phase %s: its inputs haven't changed since it last succeeded (--force runs it)"},
+		"run.restored":        {Other: "♻️ Restored the outputs of phase %s from the cache (--force runs it)"},
+		"run.summary":         {Other: "📊 Summary of %s"},
+		"run.gha_failed":      {Other: "Phase %s failed (exit %d)"},
+		"run.verbose_shell":   {Other: "🐚 %s in %s"},
+		"run.verbose_env":     {Other: "   with the environment variables:"},
+		"run.verbose_command": {Other: "⏱️ %s  %s"},
+		"run.verbose_phase":   {Other: "⏱️ %s took %s"},
+		"run.ref":             {Other: "Checked out %s (%s) in a temporary worktree: %s"},
+		"run.matched_project": {Other: "Taking project %s for %s."},
+		"run.matched_phase":   {Other: "Taking phase %s for %s."},
+		"run.tagged":          {Other: "🏷️ Project %d of %d: %s"},
+		"run.tag_skip":        {Other: "Leaving out %s: it has no phase %s."},
+		"run.path":            {Other: "Running %s in its checkout %s."},
+		"run.path_recorded":   {Other: "Recorded %s as the path of %s; naming it elsewhere runs it there."},
+
+		"list.none":     {Other: "No projects registered."},
+		"list.header":   {Other: "📋 Registered projects:"},
+		"list.project":  {Other: "🔷 Project: %s"},
+		"list.extends":  {Other: "   extends %s"},
+		"list.noPhases": {Other: "  No phases defined."},
+		"list.phase":    {One: "  📎 Phase: %s (%d command)", Other: "  📎 Phase: %s (%d commands)"},
+		"list.skip":     {Other: "      (skipped unless named)"},
+		"list.path":     {Other: "   checked out in %s"},
+		"list.tags":     {Other: "   tags: %s"},
+		"list.profiles": {Other: "   profiles: %s"},
+		"list.included": {Other: "   defined in %s"},
+
+		"edit.project_updated": {One: "Project %s updated with %d phase.", Other: "Project %s updated with %d phases."},
+		"edit.phase_summary":   {One: "  Phase %s: %d command", Other: "  Phase %s: %d commands"},
+		"edit.phase_updated":   {One: "Project %s, phase %s updated with %d command.", Other: "Project %s, phase %s updated with %d commands."},
+
+		"dump.done":   {Other: "Successfully dumped configuration for project '%s' to %s"},
+		"load.done":   {Other: "📥 Loaded project %s from %s:"},
+		"load.merged": {Other: "📥 Merged %s into project %s:"},
+		"dump.linked": {Other: "🔗 Project %s is kept in sync with it; run bild sync-local after changing either."},
+
+		"synclocal.none":      {Other: "No projects are linked to a local config; link one with bild dump <project> --link."},
+		"synclocal.in_sync":   {Other: "✅ %s is in sync."},
+		"synclocal.to_local":  {Other: "⬇️  %s changed; to be carried over to %s:"},
+		"synclocal.to_global": {Other: "⬆️  %s changed; to be carried over to project %s:"},
+		"synclocal.both":      {Other: "⚠️  %s changed both in the global config and in %s:"},
+		"synclocal.ask":       {Other: "Apply these changes? [y/N] "},
+		"synclocal.synced":    {Other: "🔗 %s synced."},
+		"synclocal.unlinked":  {Other: "%s is no longer kept in sync with its local config."},
+
+		"lint.ok":       {One: "No problems found in %d project.", Other: "No problems found in %d projects."},
+		"lint.problems": {One: "%d problem found", Other: "%d problems found"},
+
+		"export.done": {Other: "Wrote %s"},
+
+		"mv.done":        {Other: "Project %s: renamed phase %s to %s."},
+		"mv.alias":       {Other: "  %s is kept as a deprecated alias."},
+		"mv.alias_until": {Other: "  %s is kept as a deprecated alias until %s."},
+
+		"import.done":  {One: "Project %s imported with %d phase.", Other: "Project %s imported with %d phases."},
+		"import.phase": {Other: "  Phase %s: %s"},
+
+		"adopt.review":   {Other: "Review the proposed phases in your editor; save to adopt them, or empty the file to cancel."},
+		"adopt.canceled": {Other: "Nothing adopted."},
+
+		"init.done": {One: "Project %s created from template %s with %d phase.", Other: "Project %s created from template %s with %d phases."},
+
+		"detect.found": {Other: "🔎 Found %s: %s (%s)"},
+		"detect.using": {Other: "Proposing the phases of %s; choose another with --template."},
+		"detect.none":  {Other: "found no known build system in %s; see bild init --list for templates"},
+		"detect.ask":   {Other: "Create the project with these phases? [Y]es, [e]dit, [n]o: "},
+
+		"experiments.enabled":  {Other: "Experiment %s enabled."},
+		"experiments.disabled": {Other: "Experiment %s disabled."},
+
+		"one.saved": {Other: "Saved %q to project %s, phase %s."},
+
+		"aliases.header": {Other: "Generated by `bild gen-aliases` from your run history."},
+		"aliases.runs":   {One: "%d run", Other: "%d runs"},
+
+		"history.none": {Other: "No runs recorded."},
+		"top.none":     {Other: "No active bild runs."},
+		"top.run":      {One: "🔷 %s › %s (running %s, %d process, %.1f%% CPU, %s)", Other: "🔷 %s › %s (running %s, %d processes, %.1f%% CPU, %s)"},
+
+		"resume.none":  {Other: "✅ The last run of %s succeeded; nothing to resume."},
+		"resume.from":  {Other: "↪️  Resuming %s from %s, where the run of %s failed."},
+		"retry.none":   {Other: "✅ The last run of %s succeeded; nothing to retry."},
+		"retry.phases": {Other: "🔁 Retrying what failed in the last run of %s: %s"},
+
+		"run.problems":         {One: "🔎 %d problem: %d errors, %d warnings", Other: "🔎 %d problems: %d errors, %d warnings"},
+		"run.problems_more":    {Other: "… and %d more"},
+		"run.problems_written": {Other: "📝 Wrote %d problems to %s"},
+
+		"run.tests":      {Other: "🧪 Tests of %s: %s"},
+		"run.tests_more": {Other: "… and %d more failed"},
+		"tests.summary":  {One: "%d test, %d passed, %d failed, %d skipped", Other: "%d tests, %d passed, %d failed, %d skipped"},
+
+		"run.coverage":     {Other: "📈 Coverage of %s: %.1f%%"},
+		"run.coverage_was": {Other: "📈 Coverage of %s: %.1f%% (was %.1f%%)"},
+		"history.coverage": {Other: "%.1f%% covered"},
+
+		"background.started":     {Other: "🚀 Started %s in the background as %[2]s; follow it with bild attach %[2]s, stop it with bild stop %[2]s."},
+		"background.running":     {Other: "⏳ %s is running in the background as %[2]s; follow it with bild attach %[2]s."},
+		"background.finished":    {Other: "%s finished: %s"},
+		"background.detached":    {Other: "Detached from %s; it keeps running."},
+		"background.stopped":     {Other: "🛑 Stopped %s."},
+		"background.not_running": {Other: "%s is not running."},
+		"background.removed":     {Other: "Removed %s."},
+		"background.none":        {Other: "No background runs."},
+
+		"serve.listening": {Other: "🌐 Serving the bild API on %s://%s"},
+		"serve.token":     {Other: "Token: %s"},
+
+		"agent.listening":     {Other: "Agent listening on %s"},
+		"agent.not_running":   {Other: "No agent is listening on %s."},
+		"agent.status":        {Other: "Agent running (pid %d, up %s, %d requests, %d configs cached); round trip %s"},
+		"agent.enable":        {Other: "Enable it with: %s"},
+		"agent.reloaded":      {One: "Agent reloaded %d configuration file.", Other: "Agent reloaded %d configuration files."},
+		"agent.reload_failed": {Other: "⚠️  %s"},
+		"agent.reload_errors": {One: "%d configuration file failed to load", Other: "%d configuration files failed to load"},
+		"agent.token_added":   {Other: "Token for %s (shown only once):"},
+		"agent.token_removed": {Other: "Token of %s revoked."},
+
+		"storage.migrated": {Other: "Copied %d projects and %d history entries to the %s backend."},
+		"state.none":       {Other: "No project state kept."},
+		"state.cleared":    {Other: "Cleared the state of %s."},
+		"cache.none":       {Other: "No outputs cached."},
+		"cache.cleared":    {One: "Removed %d cache entry (%s).", Other: "Removed %d cache entries (%s)."},
+		"logs.none":        {Other: "No logs of %s in %s; run with --log-dir or set log_dir in the config."},
+
+		"pin.done":         {One: "📌 Pinned %d tool of %s:", Other: "📌 Pinned %d tools of %s:"},
+		"pin.none":         {Other: "found no tools to pin for %s; add probes to the project"},
+		"pin.unpinned":     {Other: "⚠️  %s has no pins; record them with bild pin."},
+		"pin.drift":        {Other: "⚠️  %v"},
+		"pin.drift_failed": {One: "%d tool differs from the pins of %s (run bild pin to accept the change)", Other: "%d tools differ from the pins of %s (run bild pin to accept the changes)"},
+
+		"compare.header":      {Other: "Comparing with the bundle of %s (%s/%s) from %s"},
+		"compare.same":        {Other: "✅ No differences."},
+		"compare.project":     {Other: "🔷 %s"},
+		"compare.tools":       {Other: "🔧 Tools"},
+		"compare.differences": {One: "%d difference", Other: "%d differences"},
+
+		"pipeline.none":     {Other: "No pipelines defined."},
+		"pipeline.name":     {Other: "⛓️  Pipeline: %s"},
+		"pipeline.step":     {Other: "⛓️  Step %d of %d: %s in %s"},
+		"pipeline.artifact": {Other: "   artifact %s: %s"},
+		"pipeline.done":     {Other: "✅ Pipeline %s finished in %s"},
+
+		"runall.project":     {Other: "🔁 Project %d of %d: %s in %s"},
+		"runall.done":        {Other: "%s %s in %s"},
+		"runall.no_path":     {Other: "(no path or workspace tells where it is checked out)"},
+		"runall.no_checkout": {Other: "(%s does not exist)"},
+		"runall.no_phase":    {Other: "(no phase %s)"},
+		"runall.interrupted": {Other: "(interrupted)"},
+		"runall.summary":     {Other: "📊 Summary of %s in all projects"},
+		"runall.totals":      {Other: "%d passed, %d failed, %d skipped"},
+
+		"githooks.installed":   {Other: "🪝 Installed the %s hook running %s: %s"},
+		"githooks.replaced":    {Other: "The hook it replaces is kept as %s.orig and comes back on uninstall."},
+		"githooks.uninstalled": {Other: "Removed the %s hook of bild."},
+		"githooks.none":        {Other: "No git hooks of bild installed."},
+		"githooks.foreign":     {Other: "(not installed by bild)"},
+		"githooks.default":     {Other: "default phases"},
+
+		"sync.init":          {Other: "🔗 Syncing the config with %s; push or pull to start."},
+		"sync.pushed":        {Other: "⬆️  Pushed the config to %s."},
+		"sync.pulled":        {Other: "⬇️  Pulled the config from %s."},
+		"sync.up_to_date":    {Other: "✅ The config is in sync."},
+		"sync.status_local":  {Other: "The config changed here; push it with bild sync push."},
+		"sync.status_remote": {Other: "The config changed in the repository; pull it with bild sync pull."},
+		"sync.status_both":   {Other: "⚠️  The config changed both here and in the repository."},
+
+		"encrypt.key":               {Other: "🔑 Created the key file %s. Copy it to your other machines yourself; it is never synced."},
+		"encrypt.project":           {Other: "🔒 Project %s is kept encrypted."},
+		"encrypt.project_decrypted": {Other: "🔓 Project %s is saved in plain text again."},
+		"encrypt.env":               {Other: "🔒 Stored %s encrypted in project %s."},
+		"encrypt.prompt":            {Other: "Value of %s: "},
+
+		"insights.header":      {One: "📈 Since %s: %d run, %s spent waiting", Other: "📈 Since %s: %d runs, %s spent waiting"},
+		"insights.header_all":  {One: "📈 %d run, %s spent waiting", Other: "📈 %d runs, %s spent waiting"},
+		"insights.top":         {Other: "🔁 Most-run phases"},
+		"insights.weeks":       {Other: "⏳ Time spent waiting per week"},
+		"insights.hotspots":    {Other: "🔥 Failure hotspots"},
+		"insights.no_failures": {Other: "🔥 No failures."},
+
+		"verify.dirty":   {Other: "Note: uncommitted changes and untracked files are not part of the verification."},
+		"verify.cloning": {Other: "Cloning %s into %s"},
+		"verify.passed":  {Other: "✅ %s builds from a clean clone of %s."},
+		"verify.failed":  {Other: "❌ %s fails in a clean clone of %s; it may depend on untracked files or local state."},
+		"verify.kept":    {Other: "The clone was kept at %s"},
+	})
+
+	msg.Register("de", msg.Catalog{
+		"err.load_config":     {Other: "Fehler beim Laden der Konfiguration: %v"},
+		"err.save_config":     {Other: "Fehler beim Speichern der Konfiguration: %v"},
+		"err.no_project_name": {Other: "Projektname konnte nicht aus dem Git-Repository ermittelt werden; bitte Projektnamen explizit angeben"},
+		"err.project_needed":  {Other: "Projektname erforderlich, wenn keine lokale Konfiguration existiert"},
+		"err.local_ambiguous": {Other: "die lokale Konfiguration in %s enthält mehrere Projekte (%s); wähle eines mit bild run <Projekt> [Phase]"},
+		"err.project_match":   {Other: "%s passt auf mehrere Projekte: %s"},
+		"err.phase_match":     {Other: "%s passt auf mehrere Phasen von %s: %s"},
+		"err.agent_disabled":  {Other: "der Agent erfordert das Experiment daemon; aktivieren mit `bild experiments enable daemon`"},
+		"err.no_sync":         {Other: "kein Sync-Repository eingerichtet; einrichten mit bild sync init <Repository>"},
+		"err.sync_conflict":   {Other: "%v; siehe bild sync status, dann eine Seite behalten mit bild sync pull --force oder bild sync push --force"},
+
+		"config.migrated":        {Other: "Konfiguration von Version %d auf %d aktualisiert; sie wird beim nächsten Speichern im neuen Format abgelegt."},
+		"config.migrated_backup": {Other: "Konfiguration von Version %d auf %d aktualisiert; die vorherige Datei wurde als %s gesichert."},
+
+		"run.chdir":           {Other: "Wechsle in das Wurzelverzeichnis des Repositorys: %s"},
+		"run.not_git":         {Other: "Kein Git-Repository; Ausführung im aktuellen Verzeichnis."},
+		"run.workspace":       {Other: "Im Arbeitsbereich %s des Projekts %s; Ausführung dort."},
+		"run.chdir_local":     {Other: "Verwende die lokale Konfiguration in %s und führe dort aus."},
+		"run.no_git":          {Other: "Keine Suche nach einem Git-Repository (--no-git); Ausführung im aktuellen Verzeichnis."},
+		"run.risk_order":      {Other: "Phasen in der Reihenfolge bisheriger Fehlschläge: %s"},
+		"run.risk_no_history": {Other: "Für %s sind keine fehlgeschlagenen Läufe verzeichnet; die Reihenfolge der Phasen bleibt."},
+		"run.default_phase":   {Other: "Führe die Standardphase %s von %s aus; --all führt alle Phasen aus."},
+		"run.skipped":         {Other: "Überspringe %s."},
+		"run.profile":         {Other: "Verwende Profil %s."},
+		"run.phase_header":    {Other: "📦 Phase wird ausgeführt: %s"},
+		"run.phase_skipped":   {Other: "⏭️ Phase %s übersprungen: %s trifft nicht zu"},
+		"run.up_to_date":      {Other: "⏭️ Phase %s übersprungen: ihre Eingaben sind seit dem letzten Erfolg unverändert (--force führt sie aus)"},
+		"run.restored":        {Other: "♻️ Ausgaben der Phase %s aus dem Cache wiederhergestellt (--force führt sie aus)"},
+		"run.summary":         {Other: "📊 Zusammenfassung von %s"},
+		"run.gha_failed":      {Other: "Phase %s fehlgeschlagen (Exit-Code %d)"},
+		"run.verbose_shell":   {Other: "🐚 %s in %s"},
+		"run.verbose_command": {Other: "⏱️ %s  %s"},
+		"run.verbose_phase":   {Other: "⏱️ %s dauerte %s"},
+		"run.verbose_env":     {Other: "   mit den Umgebungsvariablen:"},
+		"run.ref":             {Other: "%s (%s) in temporären Worktree ausgecheckt: %s"},
+		"run.matched_project": {Other: "Verwende Projekt %s für %s."},
+		"run.matched_phase":   {Other: "Verwende Phase %s für %s."},
+		"run.tagged":          {Other: "🏷️ Projekt %d von %d: %s"},
+		"run.tag_skip":        {Other: "Lasse %s aus: es hat keine Phase %s."},
+		"run.path":            {Other: "Führe %s in seinem Checkout %s aus."},
+		"run.path_recorded":   {Other: "%s als Pfad von %s gespeichert; anderswo genannt läuft es dort."},
+
+		"list.none":     {Other: "Keine Projekte registriert."},
+		"list.header":   {Other: "📋 Registrierte Projekte:"},
+		"list.project":  {Other: "🔷 Projekt: %s"},
+		"list.extends":  {Other: "   erweitert %s"},
+		"list.noPhases": {Other: "  Keine Phasen definiert."},
+		"list.phase":    {One: "  📎 Phase: %s (%d Befehl)", Other: "  📎 Phase: %s (%d Befehle)"},
+		"list.skip":     {Other: "      (wird übersprungen, wenn nicht genannt)"},
+		"list.path":     {Other: "   ausgecheckt in %s"},
+		"list.tags":     {Other: "   Tags: %s"},
+		"list.profiles": {Other: "   Profile: %s"},
+		"list.included": {Other: "   definiert in %s"},
+
+		"edit.project_updated": {One: "Projekt %s mit %d Phase aktualisiert.", Other: "Projekt %s mit %d Phasen aktualisiert."},
+		"edit.phase_summary":   {One: "  Phase %s: %d Befehl", Other: "  Phase %s: %d Befehle"},
+		"edit.phase_updated":   {One: "Projekt %s, Phase %s mit %d Befehl aktualisiert.", Other: "Projekt %s, Phase %s mit %d Befehlen aktualisiert."},
+
+		"dump.done":   {Other: "Konfiguration von Projekt '%s' nach %s geschrieben"},
+		"load.done":   {Other: "📥 Projekt %s aus %s geladen:"},
+		"load.merged": {Other: "📥 %s in Projekt %s übernommen:"},
+		"dump.linked": {Other: "🔗 Projekt %s wird damit synchron gehalten; nach Änderungen an einer Seite bild sync-local ausführen."},
+
+		"synclocal.none":      {Other: "Keine Projekte sind mit einer lokalen Konfiguration verknüpft; verknüpfen mit bild dump <Projekt> --link."},
+		"synclocal.in_sync":   {Other: "✅ %s ist synchron."},
+		"synclocal.to_local":  {Other: "⬇️  %s wurde geändert; wird nach %s übernommen:"},
+		"synclocal.to_global": {Other: "⬆️  %s wurde geändert; wird in Projekt %s übernommen:"},
+		"synclocal.both":      {Other: "⚠️  %s wurde in der globalen Konfiguration und in %s geändert:"},
+		"synclocal.ask":       {Other: "Diese Änderungen übernehmen? [j/N] "},
+		"synclocal.synced":    {Other: "🔗 %s synchronisiert."},
+		"synclocal.unlinked":  {Other: "%s wird nicht mehr mit seiner lokalen Konfiguration synchron gehalten."},
+
+		"lint.ok":       {One: "Keine Probleme in %d Projekt gefunden.", Other: "Keine Probleme in %d Projekten gefunden."},
+		"lint.problems": {One: "%d Problem gefunden", Other: "%d Probleme gefunden"},
+
+		"export.done": {Other: "%s geschrieben"},
+
+		"mv.done":        {Other: "Projekt %s: Phase %s in %s umbenannt."},
+		"mv.alias":       {Other: "  %s bleibt als veralteter Alias erhalten."},
+		"mv.alias_until": {Other: "  %s bleibt bis %s als veralteter Alias erhalten."},
+
+		"import.done":  {One: "Projekt %s mit %d Phase importiert.", Other: "Projekt %s mit %d Phasen importiert."},
+		"import.phase": {Other: "  Phase %s: %s"},
+
+		"adopt.review":   {Other: "Prüfe die vorgeschlagenen Phasen im Editor; speichere, um sie zu übernehmen, oder leere die Datei zum Abbrechen."},
+		"adopt.canceled": {Other: "Nichts übernommen."},
+
+		"init.done": {One: "Projekt %s aus Vorlage %s mit %d Phase angelegt.", Other: "Projekt %s aus Vorlage %s mit %d Phasen angelegt."},
+
+		"detect.found": {Other: "🔎 %s gefunden: %s (%s)"},
+		"detect.using": {Other: "Vorgeschlagen werden die Phasen von %s; wähle eine andere Vorlage mit --template."},
+		"detect.none":  {Other: "kein bekanntes Build-System in %s gefunden; Vorlagen zeigt bild init --list"},
+		"detect.ask":   {Other: "Projekt mit diesen Phasen anlegen? [J]a, [e]ditieren, [n]ein: "},
+
+		"experiments.enabled":  {Other: "Experiment %s aktiviert."},
+		"experiments.disabled": {Other: "Experiment %s deaktiviert."},
+
+		"one.saved": {Other: "%q in Projekt %s, Phase %s gespeichert."},
+
+		"aliases.header": {Other: "Von `bild gen-aliases` aus dem Laufverlauf erzeugt."},
+		"aliases.runs":   {One: "%d Lauf", Other: "%d Läufe"},
+
+		"history.none": {Other: "Keine Läufe aufgezeichnet."},
+		"top.none":     {Other: "Keine aktiven bild-Läufe."},
+		"top.run":      {One: "🔷 %s › %s (läuft seit %s, %d Prozess, %.1f%% CPU, %s)", Other: "🔷 %s › %s (läuft seit %s, %d Prozesse, %.1f%% CPU, %s)"},
+
+		"resume.none":  {Other: "✅ Der letzte Lauf von %s war erfolgreich; nichts fortzusetzen."},
+		"resume.from":  {Other: "↪️  Setze %s bei %s fort, wo der Lauf vom %s fehlschlug."},
+		"retry.none":   {Other: "✅ Der letzte Lauf von %s war erfolgreich; nichts zu wiederholen."},
+		"retry.phases": {Other: "🔁 Wiederhole, was im letzten Lauf von %s fehlschlug: %s"},
+
+		"run.problems":         {One: "🔎 %d Problem: %d Fehler, %d Warnungen", Other: "🔎 %d Probleme: %d Fehler, %d Warnungen"},
+		"run.problems_more":    {Other: "… und %d weitere"},
+		"run.problems_written": {Other: "📝 %d Probleme nach %s geschrieben"},
+
+		"run.tests":      {Other: "🧪 Tests von %s: %s"},
+		"run.tests_more": {Other: "… und %d weitere fehlgeschlagen"},
+		"tests.summary":  {One: "%d Test, %d bestanden, %d fehlgeschlagen, %d übersprungen", Other: "%d Tests, %d bestanden, %d fehlgeschlagen, %d übersprungen"},
+
+		"run.coverage":     {Other: "📈 Abdeckung von %s: %.1f %%"},
+		"run.coverage_was": {Other: "📈 Abdeckung von %s: %.1f %% (zuvor %.1f %%)"},
+		"history.coverage": {Other: "%.1f %% abgedeckt"},
+
+		"background.started":     {Other: "🚀 %s im Hintergrund als %[2]s gestartet; verfolgen mit bild attach %[2]s, anhalten mit bild stop %[2]s."},
+		"background.running":     {Other: "⏳ %s läuft im Hintergrund als %[2]s; verfolgen mit bild attach %[2]s."},
+		"background.finished":    {Other: "%s beendet: %s"},
+		"background.detached":    {Other: "Von %s getrennt; der Lauf geht weiter."},
+		"background.stopped":     {Other: "🛑 %s angehalten."},
+		"background.not_running": {Other: "%s läuft nicht."},
+		"background.removed":     {Other: "%s entfernt."},
+		"background.none":        {Other: "Keine Hintergrundläufe."},
+
+		"serve.listening": {Other: "🌐 bild-API unter %s://%s"},
+		"serve.token":     {Other: "Token: %s"},
+
+		"agent.listening":     {Other: "Agent lauscht auf %s"},
+		"agent.not_running":   {Other: "Kein Agent lauscht auf %s."},
+		"agent.status":        {Other: "Agent läuft (PID %d, seit %s, %d Anfragen, %d Konfigurationen zwischengespeichert); Antwortzeit %s"},
+		"agent.enable":        {Other: "Aktivieren mit: %s"},
+		"agent.reloaded":      {One: "Agent hat %d Konfigurationsdatei neu geladen.", Other: "Agent hat %d Konfigurationsdateien neu geladen."},
+		"agent.reload_failed": {Other: "⚠️  %s"},
+		"agent.reload_errors": {One: "%d Konfigurationsdatei konnte nicht geladen werden", Other: "%d Konfigurationsdateien konnten nicht geladen werden"},
+		"agent.token_added":   {Other: "Token für %s (wird nur einmal angezeigt):"},
+		"agent.token_removed": {Other: "Token von %s widerrufen."},
+
+		"storage.migrated": {Other: "%d Projekte und %d Verlaufseinträge in das Backend %s kopiert."},
+		"state.none":       {Other: "Kein Projektzustand gespeichert."},
+		"state.cleared":    {Other: "Zustand von %s gelöscht."},
+		"cache.none":       {Other: "Keine Ausgaben im Cache."},
+		"cache.cleared":    {One: "%d Cache-Eintrag entfernt (%s).", Other: "%d Cache-Einträge entfernt (%s)."},
+		"logs.none":        {Other: "Keine Logs von %s in %s; mit --log-dir ausführen oder log_dir in der Konfiguration setzen."},
+
+		"pin.done":         {One: "📌 %d Werkzeug von %s festgehalten:", Other: "📌 %d Werkzeuge von %s festgehalten:"},
+		"pin.none":         {Other: "keine Werkzeuge zum Festhalten für %s gefunden; füge dem Projekt probes hinzu"},
+		"pin.unpinned":     {Other: "⚠️  %s hat keine Pins; mit bild pin festhalten."},
+		"pin.drift":        {Other: "⚠️  %v"},
+		"pin.drift_failed": {One: "%d Werkzeug weicht von den Pins von %s ab (bild pin übernimmt die Änderung)", Other: "%d Werkzeuge weichen von den Pins von %s ab (bild pin übernimmt die Änderungen)"},
+
+		"compare.header":      {Other: "Vergleich mit dem Bündel von %s (%s/%s) vom %s"},
+		"compare.same":        {Other: "✅ Keine Unterschiede."},
+		"compare.project":     {Other: "🔷 %s"},
+		"compare.tools":       {Other: "🔧 Werkzeuge"},
+		"compare.differences": {One: "%d Unterschied", Other: "%d Unterschiede"},
+
+		"pipeline.none":     {Other: "Keine Pipelines definiert."},
+		"pipeline.name":     {Other: "⛓️  Pipeline: %s"},
+		"pipeline.step":     {Other: "⛓️  Schritt %d von %d: %s in %s"},
+		"pipeline.artifact": {Other: "   Artefakt %s: %s"},
+		"pipeline.done":     {Other: "✅ Pipeline %s nach %s abgeschlossen"},
+
+		"runall.project":     {Other: "🔁 Projekt %d von %d: %s in %s"},
+		"runall.done":        {Other: "%s %s in %s"},
+		"runall.no_path":     {Other: "(weder path noch ein Workspace sagt, wo es ausgecheckt ist)"},
+		"runall.no_checkout": {Other: "(%s existiert nicht)"},
+		"runall.no_phase":    {Other: "(keine Phase %s)"},
+		"runall.interrupted": {Other: "(abgebrochen)"},
+		"runall.summary":     {Other: "📊 Übersicht von %s in allen Projekten"},
+		"runall.totals":      {Other: "%d erfolgreich, %d fehlgeschlagen, %d übersprungen"},
+
+		"githooks.installed":   {Other: "🪝 %s-Hook installiert, er führt %s aus: %s"},
+		"githooks.replaced":    {Other: "Der ersetzte Hook bleibt als %s.orig erhalten und kehrt beim Deinstallieren zurück."},
+		"githooks.uninstalled": {Other: "%s-Hook von bild entfernt."},
+		"githooks.none":        {Other: "Keine Git-Hooks von bild installiert."},
+		"githooks.foreign":     {Other: "(nicht von bild installiert)"},
+		"githooks.default":     {Other: "Standardphasen"},
+
+		"sync.init":          {Other: "🔗 Die Konfiguration wird mit %s synchronisiert; push oder pull zum Start."},
+		"sync.pushed":        {Other: "⬆️  Konfiguration nach %s hochgeladen."},
+		"sync.pulled":        {Other: "⬇️  Konfiguration von %s geholt."},
+		"sync.up_to_date":    {Other: "✅ Die Konfiguration ist synchron."},
+		"sync.status_local":  {Other: "Die Konfiguration wurde hier geändert; hochladen mit bild sync push."},
+		"sync.status_remote": {Other: "Die Konfiguration wurde im Repository geändert; holen mit bild sync pull."},
+		"sync.status_both":   {Other: "⚠️  Die Konfiguration wurde hier und im Repository geändert."},
+
+		"encrypt.key":               {Other: "🔑 Schlüsseldatei %s erstellt. Kopiere sie selbst auf deine anderen Rechner; sie wird nie synchronisiert."},
+		"encrypt.project":           {Other: "🔒 Projekt %s wird verschlüsselt gespeichert."},
+		"encrypt.project_decrypted": {Other: "🔓 Projekt %s wird wieder im Klartext gespeichert."},
+		"encrypt.env":               {Other: "🔒 %s verschlüsselt in Projekt %s gespeichert."},
+		"encrypt.prompt":            {Other: "Wert von %s: "},
+
+		"insights.header":      {One: "📈 Seit %s: %d Lauf, %s Wartezeit", Other: "📈 Seit %s: %d Läufe, %s Wartezeit"},
+		"insights.header_all":  {One: "📈 %d Lauf, %s Wartezeit", Other: "📈 %d Läufe, %s Wartezeit"},
+		"insights.top":         {Other: "🔁 Am häufigsten ausgeführte Phasen"},
+		"insights.weeks":       {Other: "⏳ Wartezeit pro Woche"},
+		"insights.hotspots":    {Other: "🔥 Fehlerschwerpunkte"},
+		"insights.no_failures": {Other: "🔥 Keine Fehlschläge."},
+
+		"verify.dirty":   {Other: "Hinweis: Nicht committete Änderungen und unversionierte Dateien werden nicht geprüft."},
+		"verify.cloning": {Other: "Klone %s nach %s"},
+		"verify.passed":  {Other: "✅ %s baut aus einem sauberen Klon von %s."},
+		"verify.failed":  {Other: "❌ %s schlägt in einem sauberen Klon von %s fehl; möglicherweise hängt es von unversionierten Dateien oder lokalem Zustand ab."},
+		"verify.kept":    {Other: "Der Klon wurde unter %s behalten"},
+	})
+}
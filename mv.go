@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// mvCmd renames a phase of a project, optionally keeping the old name as a deprecated alias.
+var mvCmd = &cobra.Command{
+	Use:   "mv [project] [old-phase] [new-phase]",
+	Short: "Rename a phase of a project",
+	Long: `Renames a phase of a project in the global configuration, along with the
+needs of other phases and the default phase naming it.
+With --keep-alias, the old name keeps working as a deprecated alias that prints
+a warning on use, until --alias-for has elapsed (default 30 days; 0 keeps it forever).`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectName, oldName, newName := args[0], args[1], args[2]
+		keepAlias, _ := cmd.Flags().GetBool("keep-alias")
+		aliasFor, _ := cmd.Flags().GetString("alias-for")
+
+		var until time.Time
+		if keepAlias {
+			ttl, err := parseDuration(aliasFor)
+			if err != nil {
+				return err
+			}
+			if ttl > 0 {
+				until = time.Now().Add(ttl)
+			}
+		}
+
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		// Rename within the project as written; inherited phases belong to the
+		// project they are defined in.
+		proj, ok := config.Projects[projectName]
+		if !ok {
+			return &bild.ProjectNotFoundError{Project: projectName}
+		}
+		if err := proj.RenamePhase(oldName, newName, keepAlias, until); err != nil {
+			if proj.Extends != "" {
+				return fmt.Errorf("%v (phases inherited from %s must be renamed there)", err, proj.Extends)
+			}
+			return err
+		}
+		config.Projects[projectName] = proj
+		if err := saveConfig(config); err != nil {
+			return fmt.Errorf(t("err.save_config"), err)
+		}
+
+		fmt.Println(t("mv.done", projectName, oldName, newName))
+		if keepAlias {
+			if until.IsZero() {
+				fmt.Println(t("mv.alias", oldName))
+			} else {
+				fmt.Println(t("mv.alias_until", oldName, until.Format("2006-01-02")))
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	mvCmd.Flags().Bool("keep-alias", false, "Keep the old name as a deprecated alias that warns on use")
+	mvCmd.Flags().String("alias-for", "30d", "How long the deprecated alias stays valid (e.g. 14d, 72h; 0 for forever)")
+	rootCmd.AddCommand(mvCmd)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// lastAdHocCommand returns the most recent ad-hoc command recorded for a project.
+func lastAdHocCommand(projectName string) (string, error) {
+	entries, err := loadHistory(projectName)
+	if err != nil {
+		return "", err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].AdHoc() {
+			return entries[i].Command, nil
+		}
+	}
+	return "", fmt.Errorf("no ad-hoc command recorded for project %s", projectName)
+}
+
+// promoteCommand appends command to the given phase of a project in the
+// global configuration, creating the phase if needed.
+func promoteCommand(projectName, phaseName, command string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf(t("err.load_config"), err)
+	}
+	resolved, err := config.WithoutOverrides().Project(projectName)
+	if err != nil {
+		return err
+	}
+	proj := config.Projects[projectName]
+	if ph, err := proj.Phase(phaseName); err == nil {
+		ph.Commands = append(ph.Commands, command)
+	} else if inherited, err := resolved.Phase(phaseName); err == nil {
+		// Override the inherited phase with its commands plus the new one.
+		override := *inherited
+		override.Commands = append(append([]string(nil), inherited.Commands...), command)
+		proj.Phases = append(proj.Phases, override)
+	} else {
+		proj.Phases = append(proj.Phases, bild.Phase{Name: phaseName, Commands: []string{command}})
+	}
+	config.Projects[projectName] = proj
+	if err := saveConfig(config); err != nil {
+		return fmt.Errorf(t("err.save_config"), err)
+	}
+	fmt.Println(t("one.saved", command, projectName, phaseName))
+	return nil
+}
+
+// commandLine returns the shell command running args: a single argument is
+// taken as a shell command already, and several are quoted, so that each
+// stays one argument.
+func commandLine(args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// oneCmd runs an ad-hoc command in a project's context and records it in the history.
+var oneCmd = &cobra.Command{
+	Use:   "one [project] [-- command...]",
+	Short: "Run an ad-hoc command in a project's context",
+	Long: `Runs a single command the way a phase of the project would run (from the
+repository root, with the project's runner) and records it in the run history.
+A single argument after -- is taken as a shell command, such as 'make && ./app';
+several are passed on as they are. With --save, the command is promoted into the given phase afterwards; without a
+command, --save promotes the most recent ad-hoc command of the project.`,
+	Example: `  bild one backend -- go test ./internal/...
+  bild one backend --save test`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var projectArgs, commandArgs []string
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			projectArgs, commandArgs = args[:dash], args[dash:]
+		} else {
+			projectArgs = args
+		}
+		if len(projectArgs) > 1 {
+			return fmt.Errorf("separate the command from the project with --")
+		}
+		savePhase, _ := cmd.Flags().GetString("save")
+
+		var projectName string
+		if len(projectArgs) == 1 {
+			projectName = projectArgs[0]
+		}
+
+		if len(commandArgs) == 0 {
+			if savePhase == "" {
+				return fmt.Errorf("no command given; use: bild one [project] -- <command>")
+			}
+			if projectName == "" {
+				var err error
+				projectName, err = repoProjectName()
+				if err != nil {
+					return errors.New(t("err.no_project_name"))
+				}
+			}
+			command, err := lastAdHocCommand(projectName)
+			if err != nil {
+				return err
+			}
+			return promoteCommand(projectName, savePhase, command)
+		}
+
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		proj, dir, err := resolveProject(projectName, config)
+		if err != nil {
+			return err
+		}
+
+		command := commandLine(commandArgs)
+		// The command runs with the project's secrets and environment, in
+		// place of its phases.
+		adHoc := *proj
+		adHoc.Phases = []bild.Phase{{Name: "one", Commands: []string{command}}}
+		history := newHistoryObserver(proj.Name, dir)
+		if h, ok := history.(*historyObserver); ok {
+			h.command = command
+		}
+
+		runner := bild.NewRunner()
+		runner.Dir = dir
+		runner.Observer = bild.MultiObserver(
+			newConsoleObserver(runner),
+			&activeRunObserver{project: proj.Name},
+			history,
+		)
+		ctx, stop := bild.NotifyContext(cmd.Context())
+		defer stop()
+		if err := runner.Run(ctx, &adHoc, ""); err != nil {
+			return err
+		}
+
+		if savePhase != "" {
+			return promoteCommand(proj.Name, savePhase, command)
+		}
+		return nil
+	},
+}
+
+func init() {
+	oneCmd.Flags().String("save", "", "Promote the command into this phase of the project")
+	rootCmd.AddCommand(oneCmd)
+}
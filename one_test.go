@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestCommandLine(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"grep", "foo bar", "x"}, "grep 'foo bar' x"},
+		{[]string{"echo", "it's"}, `echo 'it'\''s'`},
+		{[]string{"make && ./app"}, "make && ./app"},
+	} {
+		if got := commandLine(tt.args); got != tt.want {
+			t.Errorf("commandLine(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
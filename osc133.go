@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// osc133Env names the environment variable turning the OSC 133 marks off
+// ("0") or on ("1") regardless of whether stdout is a terminal.
+const osc133Env = "BILD_OSC133"
+
+// OSC 133 ("semantic prompt") marks let terminals such as WezTerm, kitty and
+// iTerm2 recognize each phase like a command typed at a shell prompt: they
+// can jump between phases in the scrollback, select a phase's output and
+// show its exit status and duration.
+const (
+	osc133PromptStart  = "\x1b]133;A\x07" // before the phase header
+	osc133CommandStart = "\x1b]133;B\x07" // after the header, where typed input would end
+	osc133OutputStart  = "\x1b]133;C\x07" // before the phase's output
+	osc133Finished     = "\x1b]133;D;%d\x07"
+)
+
+// osc133Enabled reports whether phases should be marked with OSC 133
+// sequences: by default only when stdout is an interactive terminal.
+func osc133Enabled() bool {
+	switch os.Getenv(osc133Env) {
+	case "0":
+		return false
+	case "1":
+		return true
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// osc133Done returns the mark ending a phase that exited with exitCode.
+func osc133Done(exitCode int) string {
+	return fmt.Sprintf(osc133Finished, exitCode)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"bild/pkg/bild"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// quiet, verbose and noColor hold the root command's output flags.
+var quiet, verbose, noColor bool
+
+// setupOutput applies the output flags once they are parsed. Setting
+// NO_COLOR (see https://no-color.org) has the effect of --no-color.
+func setupOutput() error {
+	if quiet && verbose {
+		return errors.New("--quiet and --verbose cannot be combined")
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		noColor = true
+	}
+	if noColor {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+	if quiet {
+		infoOut = io.Discard
+	}
+	return nil
+}
+
+// plain removes emoji, and the space following them, from s if colors are
+// off, for terminals and logs that render them poorly.
+func plain(s string) string {
+	if !noColor {
+		return s
+	}
+	var b strings.Builder
+	skipSpace := false
+	for _, r := range s {
+		switch {
+		case isEmoji(r):
+			skipSpace = true
+			continue
+		case r == ' ' && skipSpace:
+			skipSpace = false
+			continue
+		}
+		skipSpace = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isEmoji reports whether r is in one of the blocks bild's emoji come from.
+func isEmoji(r rune) bool {
+	return r >= 0x1F300 && r <= 0x1FAFF || // pictographs, emoticons, transport
+		r >= 0x2600 && r <= 0x27BF || // miscellaneous symbols, dingbats
+		r >= 0x231A && r <= 0x23FF || // watch, hourglass, stopwatch
+		r == 0xFE0F // emoji presentation selector
+}
+
+// resultText describes the outcome of a recorded run for listings.
+func resultText(succeeded bool, exitCode int) string {
+	if succeeded {
+		return plainOr("✅", "ok")
+	}
+	return fmt.Sprintf("%s (exit %d)", plainOr("❌", "failed"), exitCode)
+}
+
+// plainOr returns emoji, or text if colors are off.
+func plainOr(emoji, text string) string {
+	if noColor {
+		return text
+	}
+	return emoji
+}
+
+// verboseObserver prints, with --verbose, how the shell of each phase is
+// started and how long each of its commands took.
+type verboseObserver struct {
+	// running maps the phases running, more than one for the variants of a
+	// parallel matrix, to the command each ran last.
+	running map[string]verboseCommand
+}
+
+type verboseCommand struct {
+	command string
+	start   time.Time
+}
+
+func (o *verboseObserver) PhaseStarted(phase *bild.Phase) {}
+
+func (o *verboseObserver) ShellStarting(phase *bild.Phase, args []string, dir string, env []string) {
+	fmt.Println(t("run.verbose_shell", strings.Join(args, " "), dir))
+	if len(env) > 0 {
+		fmt.Println(t("run.verbose_env"))
+	}
+	for _, kv := range env {
+		fmt.Printf("     %s\n", kv)
+	}
+}
+
+func (o *verboseObserver) CommandStarted(phase *bild.Phase, index int, command string) {
+	o.finish(phase.Name)
+	if o.running == nil {
+		o.running = make(map[string]verboseCommand)
+	}
+	if index >= 0 && index < len(phase.Commands) {
+		command = stepCommand(phase, index)
+	}
+	o.running[phase.Name] = verboseCommand{command, time.Now()}
+}
+
+func (o *verboseObserver) PhaseFinished(phase *bild.Phase, err error, elapsed time.Duration) {
+	o.finish(phase.Name)
+	fmt.Println(t("run.verbose_phase", phase.Name, elapsed.Round(time.Millisecond)))
+}
+
+// finish reports the time taken by the command phase ran last, if any.
+func (o *verboseObserver) finish(phase string) {
+	if c, ok := o.running[phase]; ok {
+		fmt.Println(t("run.verbose_command", time.Since(c.start).Round(time.Millisecond), c.command))
+		delete(o.running, phase)
+	}
+}
+
+// printSummary prints a table of the phases of a finished run, with the
+// time each command took if it was recorded, followed by the phases left
+// out because the run's deadline passed.
+func printSummary(project string, timings *bild.Timings, err error) {
+	phases := timings.Phases()
+	var skipped []string
+	var deadline *bild.DeadlineError
+	if errors.As(err, &deadline) {
+		skipped = deadline.Skipped
+	}
+	if len(phases) == 0 && len(skipped) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println(t("run.summary", project))
+	fmt.Printf("%-20s %10s  %s\n", "PHASE", "DURATION", "RESULT")
+	for _, ph := range phases {
+		code := 0
+		if ph.Err != nil {
+			code = exitCode(ph.Err)
+		}
+		fmt.Printf("%-20s %10s  %s\n", ph.Name, ph.Elapsed.Round(time.Millisecond), resultText(ph.Err == nil, code))
+		for _, c := range ph.Commands {
+			if c.Step != "" {
+				fmt.Printf("%-20s %10s  $ %s  # %s\n", "", c.Elapsed.Round(time.Millisecond), c.Command, c.Step)
+			} else {
+				fmt.Printf("%-20s %10s  $ %s\n", "", c.Elapsed.Round(time.Millisecond), c.Command)
+			}
+		}
+	}
+	for _, name := range skipped {
+		fmt.Printf("%-20s %10s  %s\n", name, "-", plainOr("⏭️", "skipped"))
+	}
+	fmt.Printf("%-20s %10s\n", "TOTAL", timings.Total().Round(time.Millisecond))
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"bild/pkg/bild"
+)
+
+// Events written by `bild run --output json`, one JSON object per line.
+// Every event has an "event" name and a "time".
+type (
+	jsonEvent struct {
+		Event string    `json:"event"`
+		Time  time.Time `json:"time"`
+	}
+	jsonRunEvent struct {
+		jsonEvent
+		Project    string `json:"project"`
+		DurationMS *int64 `json:"duration_ms,omitempty"`
+		ExitCode   *int   `json:"exit_code,omitempty"`
+		Error      string `json:"error,omitempty"`
+	}
+	jsonPhaseEvent struct {
+		jsonEvent
+		Phase      string   `json:"phase"`
+		Commands   []string `json:"commands,omitempty"`
+		DurationMS *int64   `json:"duration_ms,omitempty"`
+		ExitCode   *int     `json:"exit_code,omitempty"`
+		Error      string   `json:"error,omitempty"`
+	}
+	jsonSkipEvent struct {
+		jsonEvent
+		Phase string `json:"phase"`
+		When  string `json:"when"`
+	}
+	jsonCommandEvent struct {
+		jsonEvent
+		Phase   string `json:"phase"`
+		Index   int    `json:"index"`
+		Command string `json:"command"`
+		Step    string `json:"step,omitempty"`
+	}
+	jsonTestsEvent struct {
+		jsonEvent
+		Phase string `json:"phase"`
+		*bild.TestReport
+		Passed int `json:"passed"`
+	}
+	jsonCoverageEvent struct {
+		jsonEvent
+		Phase string `json:"phase"`
+		bild.CoverageResult
+	}
+	jsonLineEvent struct {
+		jsonEvent
+		Phase  string `json:"phase"`
+		Stream string `json:"stream"` // stdout or stderr
+		Text   string `json:"text"`
+	}
+)
+
+// jsonEmitter writes events as JSON Lines and turns the output of the running
+// phase into line events.
+type jsonEmitter struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	project string
+	phase   string            // name of the running phase
+	partial map[string]string // unterminated output per stream
+}
+
+func newJSONEmitter(w io.Writer, project string) *jsonEmitter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &jsonEmitter{enc: enc, project: project, partial: make(map[string]string)}
+}
+
+func newJSONEvent(name string) jsonEvent {
+	return jsonEvent{Event: name, Time: time.Now()}
+}
+
+// emit writes one event; callers hold e.mu.
+func (e *jsonEmitter) emit(v any) {
+	e.enc.Encode(v)
+}
+
+// result returns the duration and exit code fields of a finished run or phase.
+func result(err error, elapsed time.Duration) (*int64, *int, string) {
+	ms := elapsed.Milliseconds()
+	code := 0
+	var msg string
+	if err != nil {
+		code = exitCode(err)
+		msg = err.Error()
+	}
+	return &ms, &code, msg
+}
+
+func (e *jsonEmitter) RunStarted() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.emit(jsonRunEvent{jsonEvent: newJSONEvent("run_started"), Project: e.project})
+}
+
+func (e *jsonEmitter) RunFinished(err error, elapsed time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ev := jsonRunEvent{jsonEvent: newJSONEvent("run_finished"), Project: e.project}
+	ev.DurationMS, ev.ExitCode, ev.Error = result(err, elapsed)
+	e.emit(ev)
+}
+
+func (e *jsonEmitter) PhaseStarted(phase *bild.Phase) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.phase = phase.Name
+	e.emit(jsonPhaseEvent{jsonEvent: newJSONEvent("phase_started"), Phase: phase.Name, Commands: phase.Commands})
+}
+
+func (e *jsonEmitter) PhaseSkipped(phase *bild.Phase, when string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.emit(jsonSkipEvent{jsonEvent: newJSONEvent("phase_skipped"), Phase: phase.Name, When: when})
+}
+
+func (e *jsonEmitter) PhaseUpToDate(phase *bild.Phase) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.emit(jsonPhaseEvent{jsonEvent: newJSONEvent("phase_up_to_date"), Phase: phase.Name})
+}
+
+func (e *jsonEmitter) PhaseRestored(phase *bild.Phase) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.emit(jsonPhaseEvent{jsonEvent: newJSONEvent("phase_restored"), Phase: phase.Name})
+}
+
+func (e *jsonEmitter) CommandStarted(phase *bild.Phase, index int, command string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	event := jsonCommandEvent{jsonEvent: newJSONEvent("command_started"), Phase: phase.Name, Index: index, Command: command}
+	if index >= 0 && index < len(phase.Commands) {
+		event.Step = phase.Step(index).Name
+	}
+	e.emit(event)
+}
+
+func (e *jsonEmitter) PhaseFinished(phase *bild.Phase, err error, elapsed time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// Output without a final newline still makes a line.
+	for _, stream := range []string{"stdout", "stderr"} {
+		if text := e.partial[stream]; text != "" {
+			e.emit(jsonLineEvent{jsonEvent: newJSONEvent("line"), Phase: e.phase, Stream: stream, Text: text})
+			delete(e.partial, stream)
+		}
+	}
+	ev := jsonPhaseEvent{jsonEvent: newJSONEvent("phase_finished"), Phase: phase.Name}
+	ev.DurationMS, ev.ExitCode, ev.Error = result(err, elapsed)
+	e.emit(ev)
+}
+
+func (e *jsonEmitter) TestsReported(phase *bild.Phase, report *bild.TestReport) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.emit(jsonTestsEvent{jsonEvent: newJSONEvent("tests"), Phase: phase.Name, TestReport: report, Passed: report.Passed()})
+}
+
+func (e *jsonEmitter) CoverageReported(phase *bild.Phase, result bild.CoverageResult) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.emit(jsonCoverageEvent{jsonEvent: newJSONEvent("coverage"), Phase: phase.Name, CoverageResult: result})
+}
+
+// writeLines emits a line event for every complete line of data written to stream.
+func (e *jsonEmitter) writeLines(stream string, data []byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	text := e.partial[stream] + string(data)
+	for {
+		line, rest, found := strings.Cut(text, "\n")
+		if !found {
+			break
+		}
+		e.emit(jsonLineEvent{jsonEvent: newJSONEvent("line"), Phase: e.phase, Stream: stream, Text: strings.TrimSuffix(line, "\r")})
+		text = rest
+	}
+	e.partial[stream] = text
+}
+
+// WriteStream makes e a bild.Sink turning the output of the run into line
+// events.
+func (e *jsonEmitter) WriteStream(stream bild.Stream, data []byte) error {
+	e.writeLines(stream.String(), data)
+	return nil
+}
+
+// runWithJSON runs the project, reporting its progress and output as JSON
+// events on stdout.
+func runWithJSON(ctx context.Context, runner *bild.Runner, proj *bild.Project, phaseNames []string, observers []bild.Observer) error {
+	emitter := newJSONEmitter(os.Stdout, proj.Name)
+	runner.Stdout, runner.Stderr = nil, nil
+	runner.Sinks = append(runner.Sinks, emitter)
+	runner.Observer = bild.MultiObserver(append([]bild.Observer{emitter}, observers...)...)
+
+	start := time.Now()
+	emitter.RunStarted()
+	err := runner.RunPhases(ctx, proj, phaseNames)
+	emitter.RunFinished(err, time.Since(start))
+	return err
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"bild/pkg/bild"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-isatty"
+)
+
+// unresolvedError is an error of resolveProject after which an interactive
+// run lets the user pick the project instead: none could be deduced, the
+// local config has several, or the one named does not exist.
+type unresolvedError struct {
+	err error
+	// config holds the projects to pick from, all of them unless projects
+	// names some; query is what the picker starts with.
+	config   *bild.Config
+	projects []string
+	query    string
+}
+
+func (e *unresolvedError) Error() string { return e.err.Error() }
+func (e *unresolvedError) Unwrap() error { return e.err }
+
+// canPick reports whether a run can ask the user to pick a project: it
+// talks to a terminal and is not read by a program.
+func canPick(opts runOptions) bool {
+	return opts.output == "text" && isatty.IsTerminal(os.Stdin.Fd()) && isatty.IsTerminal(os.Stderr.Fd())
+}
+
+// pickerItem is a project, or a phase of one, offered by the picker.
+type pickerItem struct {
+	project, phase string
+}
+
+func (i pickerItem) String() string {
+	if i.phase == "" {
+		return i.project
+	}
+	return i.project + " › " + i.phase
+}
+
+// pickRun lets the user pick one of the projects of u, or with phases one
+// of their phases, by fuzzy search. It returns u itself if the user cancels.
+func pickRun(u *unresolvedError, phases bool) (pickerItem, error) {
+	projects := u.projects
+	if len(projects) == 0 {
+		projects = u.config.ProjectNames()
+	}
+	var items []pickerItem
+	for _, name := range projects {
+		items = append(items, pickerItem{project: name})
+		if !phases {
+			continue
+		}
+		if proj, err := u.config.Project(name); err == nil {
+			for _, ph := range proj.Phases {
+				items = append(items, pickerItem{project: name, phase: ph.Name})
+			}
+		}
+	}
+	if len(items) == 0 {
+		return pickerItem{}, u
+	}
+	m := &pickerModel{title: u.Error(), items: items, query: []rune(u.query), height: 12}
+	m.filter()
+	final, err := tea.NewProgram(m, tea.WithOutput(os.Stderr)).Run()
+	if err != nil {
+		return pickerItem{}, errors.Join(u, err)
+	}
+	if chosen := final.(*pickerModel).chosen; chosen != nil {
+		bild.Tracef("resolve", "picked %s", chosen)
+		return *chosen, nil
+	}
+	return pickerItem{}, u
+}
+
+// pickerModel is the bubbletea model of the picker.
+type pickerModel struct {
+	title   string
+	items   []pickerItem
+	query   []rune
+	matches []pickerItem
+	cursor  int
+	height  int
+	chosen  *pickerItem
+	done    bool
+}
+
+// filter selects the items matching the query, the best matches first.
+func (m *pickerModel) filter() {
+	m.matches = fuzzyFilter(m.items, string(m.query))
+	m.cursor = 0
+}
+
+// fuzzyFilter returns the items query matches, as fuzzyScore rates them,
+// the best first; items rated alike keep their order.
+func fuzzyFilter(items []pickerItem, query string) []pickerItem {
+	type match struct {
+		item  pickerItem
+		score int
+	}
+	var matches []match
+	for _, item := range items {
+		if score, ok := fuzzyScore(query, item.String()); ok {
+			matches = append(matches, match{item, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	filtered := make([]pickerItem, len(matches))
+	for i, m := range matches {
+		filtered[i] = m.item
+	}
+	return filtered
+}
+
+// fuzzyScore reports whether the characters of query appear in text in
+// order, ignoring case, and how well they do: runs of consecutive
+// characters and characters starting words count more, and shorter texts
+// win ties. An empty query matches all texts alike.
+func fuzzyScore(query, text string) (int, bool) {
+	q := []rune(strings.ToLower(strings.ReplaceAll(query, " ", "")))
+	if len(q) == 0 {
+		return 0, true
+	}
+	t := []rune(strings.ToLower(text))
+	score, qi, prev := 0, 0, -2
+	for ti := 0; ti < len(t) && qi < len(q); ti++ {
+		if t[ti] != q[qi] {
+			continue
+		}
+		score++
+		if ti == prev+1 {
+			score += 4
+		}
+		if ti == 0 || strings.ContainsRune(" -_./›", t[ti-1]) {
+			score += 2
+		}
+		prev = ti
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score*100 - len(t), true
+}
+
+func (m *pickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		if msg.Height > 0 {
+			m.height = min(msg.Height, 12)
+		}
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyEnter:
+			if len(m.matches) > 0 {
+				m.chosen = &m.matches[m.cursor]
+			}
+			m.done = true
+			return m, tea.Quit
+		case tea.KeyEsc, tea.KeyCtrlC:
+			m.done = true
+			return m, tea.Quit
+		case tea.KeyUp, tea.KeyCtrlP:
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case tea.KeyDown, tea.KeyCtrlN, tea.KeyTab:
+			if m.cursor < len(m.matches)-1 {
+				m.cursor++
+			}
+		case tea.KeyBackspace:
+			if len(m.query) > 0 {
+				m.query = m.query[:len(m.query)-1]
+				m.filter()
+			}
+		case tea.KeyCtrlU:
+			m.query = nil
+			m.filter()
+		case tea.KeyRunes, tea.KeySpace:
+			m.query = append(m.query, msg.Runes...)
+			m.filter()
+		}
+	}
+	return m, nil
+}
+
+func (m *pickerModel) View() string {
+	// The picker leaves nothing behind once done.
+	if m.done {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(tuiDimStyle.Render(m.title) + "\n")
+	fmt.Fprintf(&b, "%s %s▏\n", tuiTitleStyle.Render(">"), string(m.query))
+	// The list scrolls to keep the cursor in view.
+	rows := max(m.height-3, 1)
+	first := max(m.cursor-rows+1, 0)
+	for i := first; i < len(m.matches) && i < first+rows; i++ {
+		if i == m.cursor {
+			b.WriteString(tuiRunningStyle.Render("▸ "+m.matches[i].String()) + "\n")
+		} else {
+			b.WriteString("  " + m.matches[i].String() + "\n")
+		}
+	}
+	b.WriteString(tuiDimStyle.Render(fmt.Sprintf("%d/%d · type to filter · ↑/↓ select · Enter run · Esc cancel", len(m.matches), len(m.items))))
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyFilter(t *testing.T) {
+	items := []pickerItem{
+		{project: "api"},
+		{project: "api", phase: "build"},
+		{project: "api", phase: "test"},
+		{project: "web"},
+		{project: "web", phase: "build"},
+		{project: "web-admin"},
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"api", "api › build", "api › test", "web", "web › build", "web-admin"}},
+		{"apbu", []string{"api › build"}},
+		{"web", []string{"web", "web-admin", "web › build"}},
+		{"ADM", []string{"web-admin"}},
+		{"build", []string{"api › build", "web › build"}},
+		{"xyz", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, item := range fuzzyFilter(items, tt.query) {
+			got = append(got, item.String())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("fuzzyFilter(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
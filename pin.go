@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// pinCmd records the versions of the tools a project builds with.
+var pinCmd = &cobra.Command{
+	Use:   "pin [project]",
+	Short: "Record the versions of the tools a project uses",
+	Long: `Runs a version probe for each tool the project's commands invoke (cmake,
+ninja, go, node, ...) and records the versions in the project's "pins", so that
+'bild run --check-pins' can tell when the environment changed. Tools that are
+not detected, or report their versions differently, can be probed by adding
+commands to the project:
+
+  "probes": {"protoc": "protoc --version", "sdk": "cat /opt/sdk/VERSION"}
+
+If no project is given, it is deduced from the git repository.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var projectName string
+		if len(args) > 0 {
+			projectName = args[0]
+		} else {
+			name, err := repoProjectName()
+			if err != nil {
+				return errors.New(t("err.no_project_name"))
+			}
+			projectName = name
+		}
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		resolved, err := config.Project(projectName)
+		if err != nil {
+			return err
+		}
+		proj, ok := config.Projects[projectName]
+		if !ok {
+			return &bild.ProjectNotFoundError{Project: projectName}
+		}
+
+		dir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		if root, err := bild.RepoRoot(""); err == nil {
+			dir = root
+		}
+		pins, err := bild.Pin(cmd.Context(), resolved, dir)
+		if err != nil {
+			return err
+		}
+		if len(pins) == 0 {
+			return errors.New(t("pin.none", projectName))
+		}
+
+		fmt.Println(tn("pin.done", len(pins), len(pins), projectName))
+		for _, tool := range slices.Sorted(maps.Keys(pins)) {
+			if old, ok := resolved.Pins[tool]; ok && old != pins[tool] {
+				fmt.Printf("  %-10s %s (was %s)\n", tool, pins[tool], old)
+			} else {
+				fmt.Printf("  %-10s %s\n", tool, pins[tool])
+			}
+		}
+		proj.Pins = pins
+		config.Projects[projectName] = proj
+		if err := saveConfig(config); err != nil {
+			return fmt.Errorf(t("err.save_config"), err)
+		}
+		return nil
+	},
+}
+
+// checkPins compares the tools of proj with its pins for run --check-pins,
+// warning about each difference. In "fail" mode differences are an error.
+func checkPins(ctx context.Context, proj *bild.Project, dir, mode string) error {
+	if len(proj.Pins) == 0 {
+		fmt.Fprintln(os.Stderr, t("pin.unpinned", proj.Name))
+		return nil
+	}
+	drift := bild.CheckPins(ctx, proj, dir)
+	for _, d := range drift {
+		fmt.Fprintln(os.Stderr, t("pin.drift", d))
+	}
+	if len(drift) > 0 && mode == "fail" {
+		return errors.New(tn("pin.drift_failed", len(drift), len(drift), proj.Name))
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(pinCmd)
+}
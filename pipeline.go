@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// pipelineCmd groups the commands working with pipelines.
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Run phases of several projects in a row, handing artifacts along",
+	Long: `Pipelines chain phases of projects, possibly checked out in different
+repositories, and are defined in the "pipelines" of the global config:
+
+  "pipelines": {
+    "release": {"steps": [
+      {"project": "lib", "phase": "package", "dir": "~/src/lib"},
+      {"project": "app", "phase": "integrate", "dir": "~/src/app"}
+    ]}
+  }
+
+The files a phase declares as "artifacts", e.g. {"tarball": "dist/lib.tgz"},
+are handed to the steps after it as $BILD_ARTIFACT_TARBALL and, in phases
+with "template": true, {{ .Artifacts.tarball }}, holding their absolute paths.`,
+}
+
+var pipelineListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the pipelines and their steps",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		if len(config.Pipelines) == 0 {
+			fmt.Println(t("pipeline.none"))
+			return nil
+		}
+		for _, name := range config.PipelineNames() {
+			pl := config.Pipelines[name]
+			fmt.Println(t("pipeline.name", name))
+			if pl.Description != "" {
+				fmt.Printf("   %s\n", pl.Description)
+			}
+			for i, step := range pl.Steps {
+				fmt.Printf("  %d. %-30s %s\n", i+1, step, step.Dir)
+			}
+		}
+		return nil
+	},
+}
+
+var pipelineRunCmd = &cobra.Command{
+	Use:   "run <pipeline>",
+	Short: "Run the steps of a pipeline",
+	Long: `Runs the steps of a pipeline in order, each in its directory, stopping at
+the first that fails. After each step, the artifacts declared by the phases it
+ran must exist; they are then handed to the steps after it. The phases are
+recorded in the history like those of bild run, along with an entry for the
+pipeline as a whole.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePipelines,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPipeline(cmd.Context(), args[0])
+	},
+}
+
+// completePipelines completes the name of a pipeline.
+func completePipelines(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, name := range config.PipelineNames() {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, withDescription(name, config.Pipelines[name].Description))
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// resolveStep returns the project a pipeline step runs and the directory to
+// run it in: the local configs from the step's directory up to the root of
+// its repository are layered over config, as for a run started there.
+func resolveStep(step bild.PipelineStep, config *bild.Config) (*bild.Project, string, error) {
+	dir, err := expandHome(step.Dir)
+	if err != nil {
+		return nil, "", err
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, "", err
+	}
+	stop, _ := bild.RepoRoot(dir)
+	local, _, err := bild.FindLocalConfig(dir, stop, config)
+	if err != nil {
+		return nil, "", err
+	}
+	if local == nil {
+		proj, err := config.Project(step.Project)
+		return proj, dir, err
+	}
+	proj, projDir, err := local.LocalProject(step.Project, "")
+	if err != nil {
+		return nil, "", err
+	}
+	if projDir != "" && projDir != dir {
+		bild.Tracef("pipeline", "%s is defined in the local config in %s; running it there", step.Project, projDir)
+		dir = projDir
+	}
+	return proj, dir, nil
+}
+
+// runPipeline runs the steps of the pipeline name, handing the artifacts of
+// each step to those after it, and records the run in the history.
+func runPipeline(ctx context.Context, name string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf(t("err.load_config"), err)
+	}
+	pl, err := config.Pipeline(name)
+	if err != nil {
+		return err
+	}
+	ctx, stop := bild.NotifyContext(ctx)
+	defer stop()
+
+	runID := bild.NewRunID()
+	start := time.Now()
+	artifacts := make(map[string]string)
+	for i, step := range pl.Steps {
+		var proj *bild.Project
+		var dir string
+		proj, dir, err = resolveStep(step, config)
+		if err != nil {
+			err = fmt.Errorf("step %d (%s): %w", i+1, step, err)
+			break
+		}
+		fmt.Fprintln(infoOut, t("pipeline.step", i+1, len(pl.Steps), step, dir))
+
+		runner := bild.NewRunner()
+		runner.Dir = dir
+		runner.Artifacts = maps.Clone(artifacts)
+		if logDir := config.EffectiveLogDir(); logDir != "" {
+			if runner.LogDir, err = expandHome(logDir); err != nil {
+				break
+			}
+		}
+		history := newHistoryObserver(proj.Name, dir)
+		if h, ok := history.(*historyObserver); ok {
+			h.runID, h.pipeline = runID, name
+		}
+		runner.Observer = bild.MultiObserver(newConsoleObserver(runner), &activeRunObserver{project: proj.Name}, history)
+		if err = runner.Run(ctx, proj, step.Phase); err != nil {
+			break
+		}
+
+		var produced map[string]string
+		if produced, err = proj.Artifacts(step.Phase, dir); err != nil {
+			err = fmt.Errorf("step %d (%s): %w", i+1, step, err)
+			break
+		}
+		for _, artifact := range slices.Sorted(maps.Keys(produced)) {
+			fmt.Fprintln(infoOut, t("pipeline.artifact", artifact, produced[artifact]))
+		}
+		maps.Copy(artifacts, produced)
+	}
+
+	entry := bild.HistoryEntry{
+		RunID:    runID,
+		Pipeline: name,
+		Start:    start,
+		Duration: time.Since(start),
+		User:     runUser(),
+	}
+	if err != nil {
+		entry.ExitCode = exitCode(err)
+	}
+	if e := appendHistory(entry); e != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record run history: %v\n", e)
+	}
+	if e := recordWithSharedAgent(entry); e != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record run with the shared agent: %v\n", e)
+	}
+	if err == nil {
+		fmt.Fprintln(infoOut, t("pipeline.done", name, entry.Duration.Round(time.Millisecond)))
+	}
+	return err
+}
+
+func init() {
+	pipelineCmd.AddCommand(pipelineListCmd)
+	pipelineCmd.AddCommand(pipelineRunCmd)
+	rootCmd.AddCommand(pipelineCmd)
+}
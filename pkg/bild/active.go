@@ -0,0 +1,80 @@
+package bild
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// ActiveRun describes a phase that is currently being executed by some bild process.
+type ActiveRun struct {
+	Project string    `json:"project"`
+	Phase   string    `json:"phase"`
+	PID     int       `json:"pid"`      // process id of the phase's shell
+	BildPID int       `json:"bild_pid"` // process id of the bild process running the phase
+	Started time.Time `json:"started"`
+}
+
+// activeRunsDir returns the directory holding one record per active run,
+// named after the bild process and the phase's shell, as one bild process
+// may run several phases at once.
+func activeRunsDir(stateDir string) string {
+	return filepath.Join(stateDir, "runs")
+}
+
+// RegisterActiveRun records run in stateDir so that other bild processes can find it.
+// The returned function removes the record again.
+func RegisterActiveRun(stateDir string, run ActiveRun) (func(), error) {
+	dir := activeRunsDir(stateDir)
+	if err := ensureDir(dir); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(run)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%d-%d.json", run.BildPID, run.PID))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	return func() { os.Remove(path) }, nil
+}
+
+// ActiveRuns returns the runs recorded in stateDir that are currently in
+// progress, oldest first. Records left behind by bild processes that no
+// longer exist are removed.
+func ActiveRuns(stateDir string) ([]ActiveRun, error) {
+	paths, err := filepath.Glob(filepath.Join(activeRunsDir(stateDir), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []ActiveRun
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var run ActiveRun
+		if err := json.Unmarshal(data, &run); err != nil || !processAlive(run.BildPID) {
+			os.Remove(path)
+			continue
+		}
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Started.Before(runs[j].Started) })
+	return runs, nil
+}
+
+// processAlive reports whether a process with the given id exists.
+func processAlive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return proc.Signal(syscall.Signal(0)) == nil
+}
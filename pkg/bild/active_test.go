@@ -0,0 +1,27 @@
+package bild
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestActiveRunsOfOneProcess(t *testing.T) {
+	state := t.TempDir()
+	// Two variants of a parallel matrix, run by the same bild process.
+	var unregister []func()
+	for i, phase := range []string{"build[gcc]", "build[clang]"} {
+		stop, err := RegisterActiveRun(state, ActiveRun{Project: "lib", Phase: phase, PID: 100 + i, BildPID: os.Getpid(), Started: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		unregister = append(unregister, stop)
+	}
+	if runs, err := ActiveRuns(state); err != nil || len(runs) != 2 {
+		t.Fatalf("runs = %+v, %v; want both variants", runs, err)
+	}
+	unregister[0]()
+	if runs, err := ActiveRuns(state); err != nil || len(runs) != 1 || runs[0].Phase != "build[clang]" {
+		t.Errorf("runs = %+v, %v; want the variant still running", runs, err)
+	}
+}
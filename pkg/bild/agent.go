@@ -0,0 +1,527 @@
+package bild
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AgentSocketEnv names the environment variable pointing clients at the
+// socket of an agent other than their own, such as a shared one.
+const AgentSocketEnv = "BILD_AGENT_SOCKET"
+
+// AgentSocketPath returns the path of the Unix socket the agent listens on:
+// $BILD_AGENT_SOCKET if set, else agent.sock in the state directory.
+func (d Dirs) AgentSocketPath() string {
+	if path := os.Getenv(AgentSocketEnv); path != "" {
+		return path
+	}
+	return filepath.Join(d.State, "agent.sock")
+}
+
+// AgentRequest asks the agent to resolve the project to run from Dir.
+type AgentRequest struct {
+	// Ping only reports the agent's status without resolving anything.
+	Ping bool `json:"ping,omitempty"`
+	// Reload makes the agent read every cached configuration file again and
+	// forget the repository roots it looked up.
+	Reload bool `json:"reload,omitempty"`
+	// Record asks a shared agent to add the entry to its history instead.
+	Record     *HistoryEntry `json:"record,omitempty"`
+	Token      string        `json:"token,omitempty"` // required by shared agents
+	ConfigPath string        `json:"config_path"`
+	Dir        string        `json:"dir"`
+	Project    string        `json:"project,omitempty"` // empty means the repository name
+}
+
+// AgentResponse is the agent's answer to an AgentRequest.
+type AgentResponse struct {
+	Project *Project `json:"project,omitempty"`
+	// ProjectName repeats Project.Name, which is not serialized with the project.
+	ProjectName string `json:"project_name,omitempty"`
+	Dir         string `json:"dir,omitempty"`
+	InRepo      bool   `json:"in_repo,omitempty"` // Dir is the root of a git repository
+	Error       string `json:"error,omitempty"`
+	// Global is set for projects of the global configuration, whose Path
+	// applies; Clone tells that Dir is a clone of the project.
+	Global bool `json:"global,omitempty"`
+	Clone  bool `json:"clone,omitempty"`
+	// LogDir is the log_dir of the global configuration.
+	LogDir string `json:"log_dir,omitempty"`
+	// Terminal is the terminal of the global configuration.
+	Terminal string `json:"terminal,omitempty"`
+	// Shared is set by agents serving several users; User is the user the
+	// request's token belongs to.
+	Shared bool   `json:"shared,omitempty"`
+	User   string `json:"user,omitempty"`
+
+	// Status, filled in for pings.
+	PID      int           `json:"pid,omitempty"`
+	Uptime   time.Duration `json:"uptime,omitempty"`
+	Requests int           `json:"requests,omitempty"`
+	Cached   int           `json:"cached,omitempty"` // number of parsed config files held
+
+	// Reloaded, filled in for reloads, counts the config files read again;
+	// ReloadErrors lists those that failed to parse, which are dropped.
+	Reloaded     int      `json:"reloaded,omitempty"`
+	ReloadErrors []string `json:"reload_errors,omitempty"`
+}
+
+// cachedConfig is a parsed config file together with the file state it was parsed from.
+type cachedConfig struct {
+	modTime time.Time
+	size    int64
+	// files sums up the other files the configuration was read from; see
+	// configFiles.
+	files  string
+	config *Config
+	found  bool
+	local  bool
+}
+
+// configFiles sums up the names and modification times of the files
+// besides path that config, read from path, consists of: its overrides and
+// what it includes, as they are now.
+func configFiles(path string, local bool, config *Config) string {
+	files := []string{filepath.Join(filepath.Dir(path), OverridesName)}
+	if local {
+		files[0] = filepath.Join(filepath.Dir(path), LocalOverridesName)
+	} else if included, err := config.IncludeFiles(filepath.Dir(path)); err == nil {
+		files = append(files, included...)
+	}
+	var sum strings.Builder
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			fmt.Fprintf(&sum, "%s %d %d\n", file, info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return sum.String()
+}
+
+// Agent is a long-running process that keeps configuration files parsed and
+// repository roots looked up, so that resolving a project for a run needs
+// neither file parsing nor spawning git. Cached files are re-read when they
+// change on disk.
+type Agent struct {
+	// IdleTimeout stops Serve after this long without requests; zero means never.
+	// With socket activation the service manager starts the agent again on demand.
+	IdleTimeout time.Duration
+	// WatchInterval is how often Serve checks the cached config files for
+	// changes, reading changed ones right away rather than when a run asks
+	// for them; zero means only when asked.
+	WatchInterval time.Duration
+
+	// UsersPath, if set, makes the agent shared: every request must carry the
+	// token of a user listed in this file (see AgentUsers), projects come
+	// from ConfigPath only and are filtered by what the user may see, and
+	// runs reported by clients are recorded in History under the user's name.
+	UsersPath  string
+	ConfigPath string
+	History    HistoryStore
+
+	mu       sync.Mutex
+	started  time.Time
+	requests int
+	configs  map[string]cachedConfig // by path
+	roots    map[string]string       // repository root by directory
+
+	users        *AgentUsers
+	usersModTime time.Time
+}
+
+// NewAgent returns an agent with empty caches.
+func NewAgent() *Agent {
+	return &Agent{
+		started: time.Now(),
+		configs: make(map[string]cachedConfig),
+		roots:   make(map[string]string),
+	}
+}
+
+// AgentListener returns the listener passed in by systemd socket activation,
+// or else a new listener on the Unix socket at path.
+func AgentListener(path string) (net.Listener, error) {
+	if pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID")); pid == os.Getpid() {
+		if fds, _ := strconv.Atoi(os.Getenv("LISTEN_FDS")); fds >= 1 {
+			// Passed file descriptors start at 3 (SD_LISTEN_FDS_START).
+			f := os.NewFile(3, "systemd-socket")
+			defer f.Close()
+			return net.FileListener(f)
+		}
+	}
+
+	if err := ensureParent(path); err != nil {
+		return nil, err
+	}
+	// A socket left behind by an agent that died is removed; a live one is not.
+	if conn, err := net.DialTimeout("unix", path, 100*time.Millisecond); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("an agent is already listening on %s", path)
+	}
+	os.Remove(path)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Configs may contain secrets; only the owner may ask.
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve answers requests on l until ctx is cancelled or the idle timeout expires.
+func (a *Agent) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	activity := make(chan struct{}, 1)
+	go func() {
+		var idle <-chan time.Time
+		for {
+			if a.IdleTimeout > 0 {
+				idle = time.After(a.IdleTimeout)
+			}
+			select {
+			case <-ctx.Done():
+				l.Close()
+				return
+			case <-activity:
+			case <-idle:
+				cancel()
+			}
+		}
+	}()
+
+	if a.WatchInterval > 0 {
+		go a.watch(ctx)
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		select {
+		case activity <- struct{}{}:
+		default:
+		}
+		go a.handle(conn)
+	}
+}
+
+// watch reloads the cached config files that changed every WatchInterval
+// until ctx is cancelled. A reload holds the agent's lock, so that requests
+// see either the old or the new configuration, never a mix.
+func (a *Agent) watch(ctx context.Context) {
+	ticker := time.NewTicker(a.WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			a.reload(false)
+			a.mu.Unlock()
+		}
+	}
+}
+
+// reload reads the cached config files again that changed on disk, or all
+// of them if force is set, and returns how many it read. Files that are gone
+// or fail to parse are dropped from the cache, so that the next request
+// reports the error; their errors are returned.
+func (a *Agent) reload(force bool) (int, []string) {
+	reloaded := 0
+	var errs []string
+	// Iterate over a copy: reloaded files are added to the cache again.
+	for path, c := range maps.Clone(a.configs) {
+		info, err := os.Stat(path)
+		if err == nil && !force && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+			continue
+		}
+		delete(a.configs, path)
+		if os.IsNotExist(err) {
+			Tracef("agent", "%s is gone; dropped it", path)
+			continue
+		}
+		if _, _, err = a.config(path, c.local); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		Tracef("agent", "reloaded %s", path)
+		reloaded++
+	}
+	if force {
+		clear(a.roots)
+		a.users = nil
+	}
+	return reloaded, errs
+}
+
+// handle answers a single request on conn.
+func (a *Agent) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var req AgentRequest
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		return
+	}
+	resp := a.Resolve(req)
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// Resolve answers req from the agent's caches.
+func (a *Agent) Resolve(req AgentRequest) AgentResponse {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests++
+
+	shared := a.UsersPath != ""
+	if req.Ping {
+		return AgentResponse{
+			PID:      os.Getpid(),
+			Uptime:   time.Since(a.started),
+			Requests: a.requests,
+			Cached:   len(a.configs),
+			Shared:   shared,
+		}
+	}
+
+	var user *AgentUser
+	if shared {
+		users, err := a.loadUsers()
+		if err != nil {
+			return AgentResponse{Error: err.Error()}
+		}
+		var ok bool
+		if user, ok = users.Authenticate(req.Token); !ok {
+			return AgentResponse{Error: "this agent is shared; set $" + AgentTokenEnv + " to a valid token", Shared: true}
+		}
+	}
+
+	if req.Reload {
+		reloaded, errs := a.reload(true)
+		return AgentResponse{Reloaded: reloaded, ReloadErrors: errs, Cached: len(a.configs), Shared: shared}
+	}
+
+	if req.Record != nil {
+		if user == nil || a.History == nil {
+			return AgentResponse{Error: "this agent does not record runs"}
+		}
+		if !user.CanSee(req.Record.Project) {
+			return AgentResponse{Error: (&ProjectNotFoundError{Project: req.Record.Project}).Error()}
+		}
+		entry := *req.Record
+		entry.User = user.Name // attribution comes from the token, not the client
+		if err := a.History.AppendHistory(entry); err != nil {
+			return AgentResponse{Error: err.Error()}
+		}
+		return AgentResponse{Shared: true, User: user.Name}
+	}
+
+	configPath := req.ConfigPath
+	if shared {
+		configPath = a.ConfigPath
+	}
+	global, _, err := a.config(configPath, false)
+	if err != nil {
+		return AgentResponse{Error: err.Error()}
+	}
+	if !global.ExperimentEnabled("daemon") {
+		return AgentResponse{Error: "the daemon experiment is disabled"}
+	}
+
+	resp := AgentResponse{Dir: req.Dir, Shared: shared, LogDir: global.EffectiveLogDir(), Terminal: global.EffectiveTerminal()}
+	if user != nil {
+		resp.User = user.Name
+	}
+	if root := a.repoRoot(req.Dir); root != "" {
+		resp.Dir, resp.InRepo = root, true
+	}
+
+	var proj *Project
+	// A shared agent serves its own configuration only; it does not read
+	// files from the directories of its clients.
+	var local *Config
+	if !shared {
+		stop := ""
+		if resp.InRepo {
+			stop = resp.Dir
+		}
+		local, _, err = findLocalConfig(req.Dir, stop, global, func(dir string) (*Config, bool, error) {
+			return a.config(LocalConfigPath(dir), true)
+		})
+	}
+	configs := global
+	if local != nil {
+		configs = local
+	}
+	var repoName, root string
+	if resp.InRepo && err == nil {
+		// Like a run without the agent, the repository names the project
+		// it is a clone of.
+		root = resp.Dir
+		if repoName, err = configs.RepoProject(root); err != nil {
+			return AgentResponse{Error: err.Error()}
+		}
+	}
+	// Like a run without the agent, a workspace names the project and is
+	// where it runs.
+	workspace := ""
+	if req.Project == "" && err == nil {
+		if name, dir, ok := configs.Workspace(req.Dir, root); ok {
+			req.Project, workspace = name, dir
+		}
+	}
+	switch {
+	case err != nil:
+		return AgentResponse{Error: err.Error()}
+	case local != nil && (req.Project != "" || len(local.Projects) > 0):
+		// Like a run without the agent, run the project where the local
+		// config defining it is.
+		var dir string
+		proj, dir, err = local.LocalProject(req.Project, repoName)
+		if dir != "" {
+			resp.Dir = dir
+		}
+	default:
+		name := req.Project
+		if name == "" {
+			if !resp.InRepo {
+				return AgentResponse{Error: "no project name given outside a git repository"}
+			}
+			name = repoName
+		}
+		proj, err = global.Project(name)
+		resp.Global, resp.Clone = true, resp.InRepo && err == nil && repoName == proj.Name
+	}
+	if workspace != "" {
+		resp.Dir, resp.Global = workspace, false
+	}
+	if err == nil && user != nil && !user.CanSee(proj.Name) {
+		err = &ProjectNotFoundError{Project: proj.Name}
+	}
+	if err != nil {
+		return AgentResponse{Error: err.Error()}
+	}
+	resp.Project, resp.ProjectName = proj, proj.Name
+	return resp
+}
+
+// loadUsers returns the users of a shared agent, re-reading the file when it
+// changes so that tokens can be added and revoked without a restart.
+func (a *Agent) loadUsers() (*AgentUsers, error) {
+	info, err := os.Stat(a.UsersPath)
+	if err != nil {
+		return nil, err
+	}
+	if a.users != nil && a.usersModTime.Equal(info.ModTime()) {
+		return a.users, nil
+	}
+	users, err := LoadAgentUsers(a.UsersPath)
+	if err != nil {
+		return nil, err
+	}
+	a.users, a.usersModTime = users, info.ModTime()
+	return users, nil
+}
+
+// config returns the parsed config file at path, re-reading it if it changed.
+// Local config files are parsed with LoadLocalConfig; a missing local file is
+// reported through the boolean result.
+func (a *Agent) config(path string, local bool) (*Config, bool, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		delete(a.configs, path)
+		if local {
+			return nil, false, nil
+		}
+		return NewConfig(), false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if c, ok := a.configs[path]; ok && c.modTime.Equal(info.ModTime()) && c.size == info.Size() && c.files == configFiles(path, local, c.config) {
+		Tracef("agent", "cache hit for %s", path)
+		return c.config, c.found, nil
+	}
+	Tracef("agent", "cache miss for %s; parsing it", path)
+
+	var config *Config
+	found := true
+	if local {
+		config, found, err = LoadLocalConfig(filepath.Dir(path))
+	} else {
+		config, err = LoadConfig(path)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	a.configs[path] = cachedConfig{modTime: info.ModTime(), size: info.Size(), files: configFiles(path, local, config), config: config, found: found, local: local}
+	return config, found, nil
+}
+
+// repoRoot returns the cached root of the repository containing dir, or "".
+// Only found roots are cached, so a directory turned into a repository later
+// is picked up.
+func (a *Agent) repoRoot(dir string) string {
+	if root, ok := a.roots[dir]; ok {
+		// The repository may have been removed or moved since.
+		if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
+			Tracef("agent", "cached repository root of %s is %s", dir, root)
+			return root
+		}
+		Tracef("agent", "cached repository root %s is gone", root)
+		delete(a.roots, dir)
+	}
+	root, err := RepoRoot(dir)
+	if err != nil {
+		return ""
+	}
+	a.roots[dir] = root
+	return root
+}
+
+// AskAgent sends req to the agent listening on socketPath. If the agent
+// answers with an error, its response is returned along with it.
+func AskAgent(socketPath string, req AgentRequest) (*AgentResponse, error) {
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp AgentResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return &resp, errors.New(resp.Error)
+	}
+	if resp.Project != nil {
+		resp.Project.Name = resp.ProjectName
+	}
+	return &resp, nil
+}
@@ -0,0 +1,122 @@
+package bild
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// AgentTokenEnv names the environment variable holding the token a client
+// presents to a shared agent.
+const AgentTokenEnv = "BILD_TOKEN"
+
+// AgentUser is an engineer allowed to use a shared agent.
+type AgentUser struct {
+	Name string `json:"name"`
+	// TokenHash is the hex SHA-256 of the user's token; the token itself is
+	// only shown once, when it is created.
+	TokenHash string `json:"token_hash"`
+	// Projects lists the projects the user may see; empty means all.
+	Projects []string `json:"projects,omitempty"`
+}
+
+// CanSee reports whether the user may see and run project.
+func (u *AgentUser) CanSee(project string) bool {
+	if len(u.Projects) == 0 {
+		return true
+	}
+	for _, p := range u.Projects {
+		if p == project {
+			return true
+		}
+	}
+	return false
+}
+
+// AgentUsers holds the users of a shared agent.
+type AgentUsers struct {
+	Users []AgentUser `json:"users"`
+}
+
+// AgentUsersPath returns the path of the file listing the users of a shared agent.
+func (d Dirs) AgentUsersPath() string {
+	return filepath.Join(d.Config, "agent-users.json")
+}
+
+// LoadAgentUsers reads the users file at path (no users if it doesn't exist).
+func LoadAgentUsers(path string) (*AgentUsers, error) {
+	users := &AgentUsers{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return users, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Save writes the users file, readable by its owner only.
+func (u *AgentUsers) Save(path string) error {
+	sort.Slice(u.Users, func(i, j int) bool { return u.Users[i].Name < u.Users[j].Name })
+	data, err := marshalConfig(u)
+	if err != nil {
+		return err
+	}
+	if err := ensureParent(path); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Add creates user name with a new token, replacing an existing user of the
+// same name, and returns the token.
+func (u *AgentUsers) Add(name string, projects []string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := "bild_" + hex.EncodeToString(secret)
+
+	u.Remove(name)
+	u.Users = append(u.Users, AgentUser{Name: name, TokenHash: hashToken(token), Projects: projects})
+	return token, nil
+}
+
+// Remove deletes user name and reports whether it existed.
+func (u *AgentUsers) Remove(name string) bool {
+	for i, user := range u.Users {
+		if user.Name == name {
+			u.Users = append(u.Users[:i], u.Users[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate returns the user holding token.
+func (u *AgentUsers) Authenticate(token string) (*AgentUser, bool) {
+	if token == "" {
+		return nil, false
+	}
+	hash := []byte(hashToken(token))
+	for i := range u.Users {
+		if subtle.ConstantTimeCompare(hash, []byte(u.Users[i].TokenHash)) == 1 {
+			return &u.Users[i], true
+		}
+	}
+	return nil, false
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
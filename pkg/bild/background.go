@@ -0,0 +1,189 @@
+package bild
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// BackgroundEnv names the directory of the background run a bild process
+// is, so that it records how the run ended.
+const BackgroundEnv = "BILD_BACKGROUND"
+
+// BackgroundRun is a run started with bild start, detached from the
+// terminal. Each has a directory below the state directory:
+//
+//	<state>/background/<id>/
+//	    run.json     this record
+//	    output.log   what the run printed
+type BackgroundRun struct {
+	ID      string    `json:"id"`
+	Project string    `json:"project"`
+	Args    []string  `json:"args"` // the arguments of the bild command run
+	Dir     string    `json:"dir"`  // the directory it runs in
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	// Finished and ExitCode are set once the run ended.
+	Finished *time.Time `json:"finished,omitempty"`
+	ExitCode int        `json:"exit_code"`
+
+	path string // the run's directory
+}
+
+// BackgroundDir returns the directory holding the background runs.
+func (d Dirs) BackgroundDir() string {
+	return filepath.Join(d.State, "background")
+}
+
+// NewBackgroundRun creates the directory of a new background run of project
+// below dir, named after the project and the time.
+func NewBackgroundRun(dir, project string, args []string) (*BackgroundRun, error) {
+	if err := ensureDir(dir); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	id := fileSafe(project) + "-" + now.Format(logTimeFormat)
+	for n := 2; ; n++ {
+		err := os.Mkdir(filepath.Join(dir, id), 0755)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		id = fmt.Sprintf("%s-%s.%d", fileSafe(project), now.Format(logTimeFormat), n)
+	}
+	return &BackgroundRun{ID: id, Project: project, Args: args, Started: now, path: filepath.Join(dir, id)}, nil
+}
+
+// LoadBackgroundRun reads the background run whose directory is path.
+func LoadBackgroundRun(path string) (*BackgroundRun, error) {
+	data, err := os.ReadFile(filepath.Join(path, "run.json"))
+	if err != nil {
+		return nil, err
+	}
+	var run BackgroundRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	run.path = path
+	return &run, nil
+}
+
+// BackgroundRuns returns the background runs recorded below dir, oldest
+// first.
+func BackgroundRuns(dir string) ([]*BackgroundRun, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "run.json"))
+	if err != nil {
+		return nil, err
+	}
+	var runs []*BackgroundRun
+	for _, path := range paths {
+		run, err := LoadBackgroundRun(filepath.Dir(path))
+		if err != nil {
+			Tracef("background", "skipping %s: %v", path, err)
+			continue
+		}
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Started.Before(runs[j].Started) })
+	return runs, nil
+}
+
+// Reload reads the record of b again, as the run has updated it since.
+func (b *BackgroundRun) Reload() (*BackgroundRun, error) {
+	return LoadBackgroundRun(b.path)
+}
+
+// OutputPath returns the file holding the output of b.
+func (b *BackgroundRun) OutputPath() string {
+	return filepath.Join(b.path, "output.log")
+}
+
+// Remove deletes the directory of b, output and all.
+func (b *BackgroundRun) Remove() error {
+	return os.RemoveAll(b.path)
+}
+
+// save writes the record of b.
+func (b *BackgroundRun) save() error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(b.path, "run.json"), append(data, '\n'))
+}
+
+// Start starts cmd as b in a session of its own, writing its output to the
+// output file of b, and records its process.
+func (b *BackgroundRun) Start(cmd *exec.Cmd) error {
+	out, err := os.OpenFile(b.OutputPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	cmd.Stdin = nil
+	cmd.Stdout, cmd.Stderr = out, out
+	cmd.Env = append(cmd.Environ(), BackgroundEnv+"="+b.path)
+	detach(cmd)
+	b.Dir = cmd.Dir
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	b.PID = cmd.Process.Pid
+	Tracef("background", "started %s as process %d: %q", b.ID, b.PID, cmd.Args)
+	if err := b.save(); err != nil {
+		cmd.Process.Kill()
+		return err
+	}
+	// Reaps the process, should this one outlive it, as bild serve does.
+	go cmd.Wait()
+	return nil
+}
+
+// Finish records that b ended with exitCode, unless it already did.
+func (b *BackgroundRun) Finish(exitCode int) error {
+	if b.Finished != nil {
+		return nil
+	}
+	now := time.Now()
+	b.Finished, b.ExitCode = &now, exitCode
+	return b.save()
+}
+
+// Running reports whether b has not ended yet.
+func (b *BackgroundRun) Running() bool {
+	return b.Finished == nil && processAlive(b.PID)
+}
+
+// Stop interrupts b, as Ctrl-C would, so that its phases and hooks are
+// stopped the usual way, and kills it if it is still running after delay.
+// A run killed is recorded as interrupted.
+func (b *BackgroundRun) Stop(delay time.Duration) error {
+	proc, err := os.FindProcess(b.PID)
+	if err != nil {
+		return err
+	}
+	if err := proc.Signal(os.Interrupt); err != nil {
+		if errors.Is(err, os.ErrProcessDone) {
+			return nil
+		}
+		return err
+	}
+	for deadline := time.Now().Add(delay); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if !processAlive(b.PID) {
+			return nil
+		}
+	}
+	Tracef("background", "killing %s, still running after %s", b.ID, delay)
+	if err := signalProcess(proc, true, syscall.SIGKILL); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return b.Finish((&InterruptedError{Signal: os.Interrupt}).ExitCode())
+}
@@ -0,0 +1,50 @@
+package bild
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackgroundRun(t *testing.T) {
+	dir := t.TempDir()
+	run, err := NewBackgroundRun(dir, "api", []string{"run", "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run.Start(exec.Command("sh", "-c", `echo "started as $`+BackgroundEnv+`"`)); err != nil {
+		t.Fatal(err)
+	}
+	again, err := NewBackgroundRun(dir, "api", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID == run.ID {
+		t.Errorf("two runs started at once share the ID %s", run.ID)
+	}
+
+	var output []byte
+	for deadline := time.Now().Add(5 * time.Second); len(output) == 0 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		output, _ = os.ReadFile(run.OutputPath())
+	}
+	if !strings.Contains(string(output), "started as "+run.path) {
+		t.Errorf("output = %q", output)
+	}
+	loaded, err := run.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Finish(2); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := BackgroundRuns(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second run never started, so it has no record.
+	if len(runs) != 1 || runs[0].ID != run.ID || runs[0].PID == 0 || runs[0].Finished == nil || runs[0].ExitCode != 2 || runs[0].Running() {
+		t.Errorf("BackgroundRuns = %+v", runs)
+	}
+}
@@ -0,0 +1,235 @@
+package bild
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Bundle is a snapshot of the projects of a machine as they resolve there,
+// and of the versions of the tools they use, to be compared with the bundle
+// of another machine by CompareBundles.
+type Bundle struct {
+	Host    string    `json:"host"`
+	OS      string    `json:"os"`
+	Arch    string    `json:"arch"`
+	Created time.Time `json:"created"`
+	// Projects are resolved: extended projects and default phases are merged
+	// in. Secrets are listed by where they come from, never by value.
+	Projects map[string]*Project `json:"projects"`
+	// Tools maps the tools the projects use to their versions on the machine.
+	Tools map[string]string `json:"tools,omitempty"`
+}
+
+// NewBundle returns the bundle of the projects of c and its parents, probing
+// the versions of their tools in dir. Projects that fail to resolve are left
+// out.
+func NewBundle(ctx context.Context, c *Config, dir string) *Bundle {
+	host, _ := os.Hostname()
+	b := &Bundle{
+		Host:     host,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Created:  time.Now().UTC().Truncate(time.Second),
+		Projects: make(map[string]*Project),
+		Tools:    make(map[string]string),
+	}
+	probes := make(map[string]string)
+	for cfg := c; cfg != nil; cfg = cfg.Parent {
+		for _, name := range cfg.ProjectNames() {
+			if _, ok := b.Projects[name]; ok {
+				continue
+			}
+			proj, err := c.Project(name)
+			if err != nil {
+				Tracef("bundle", "leaving out %s: %v", name, err)
+				continue
+			}
+			b.Projects[name] = proj
+			for tool, probe := range proj.PinProbes() {
+				probes[tool] = probe
+			}
+		}
+	}
+	for _, tool := range slices.Sorted(maps.Keys(probes)) {
+		version, err := ProbeVersion(ctx, dir, probes[tool])
+		if err != nil {
+			Tracef("bundle", "no version of %s: %v", tool, err)
+			continue
+		}
+		b.Tools[tool] = version
+	}
+	return b
+}
+
+// ReadBundle reads a bundle written as JSON.
+func ReadBundle(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%s is not a bundle: %v", path, err)
+	}
+	for name, proj := range b.Projects {
+		if proj == nil {
+			delete(b.Projects, name)
+			continue
+		}
+		proj.Name = name
+	}
+	return &b, nil
+}
+
+// ConfigDifference is a setting that differs between two bundles. Mine or
+// Theirs is empty where the setting is missing.
+type ConfigDifference struct {
+	Project string // empty for the tools
+	Phase   string // empty for settings of the project as a whole
+	Setting string // e.g. "commands", "pins.cmake", a tool, or "" if Phase is missing
+	Mine    string
+	Theirs  string
+}
+
+func (d ConfigDifference) String() string {
+	var where []string
+	for _, s := range []string{d.Project, d.Phase, d.Setting} {
+		if s != "" {
+			where = append(where, s)
+		}
+	}
+	return fmt.Sprintf("%s: %s -> %s", strings.Join(where, "/"), orMissing(d.Mine), orMissing(d.Theirs))
+}
+
+// orMissing returns s, or "(missing)" if it is empty.
+func orMissing(s string) string {
+	if s == "" {
+		return "(missing)"
+	}
+	return s
+}
+
+// CompareBundles returns the differences between the projects and tools of
+// mine and theirs, sorted by project, phase and setting. Settings are
+// compared as JSON, so that lists such as commands differ as a whole.
+func CompareBundles(mine, theirs *Bundle) []ConfigDifference {
+	var diffs []ConfigDifference
+	names := slices.Sorted(maps.Keys(mine.Projects))
+	for name := range theirs.Projects {
+		if _, ok := mine.Projects[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		a, b := mine.Projects[name], theirs.Projects[name]
+		if a == nil || b == nil {
+			diffs = append(diffs, ConfigDifference{Project: name, Mine: present(a != nil), Theirs: present(b != nil)})
+			continue
+		}
+		diffs = append(diffs, compareSettings(name, "", projectSettings(a), projectSettings(b))...)
+		phases := make(map[string]bool)
+		for _, ph := range append(slices.Clone(a.Phases), b.Phases...) {
+			phases[ph.Name] = true
+		}
+		samePhases := true
+		for _, phase := range slices.Sorted(maps.Keys(phases)) {
+			pa, errA := a.Phase(phase)
+			pb, errB := b.Phase(phase)
+			if errA != nil || errB != nil {
+				diffs = append(diffs, ConfigDifference{Project: name, Phase: phase, Mine: present(errA == nil), Theirs: present(errB == nil)})
+				samePhases = false
+				continue
+			}
+			diffs = append(diffs, compareSettings(name, phase, settings(pa), settings(pb))...)
+		}
+		// The order only tells something new if both have the same phases.
+		if order := phaseOrder(a); samePhases && order != phaseOrder(b) {
+			diffs = append(diffs, ConfigDifference{Project: name, Setting: "phase order", Mine: order, Theirs: phaseOrder(b)})
+		}
+	}
+	return append(diffs, compareSettings("", "", mine.Tools, theirs.Tools)...)
+}
+
+// present describes whether a project or phase exists in a bundle.
+func present(ok bool) string {
+	if ok {
+		return "present"
+	}
+	return ""
+}
+
+// phaseOrder returns the names of the phases of p in order.
+func phaseOrder(p *Project) string {
+	names := make([]string, len(p.Phases))
+	for i, ph := range p.Phases {
+		names[i] = ph.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// projectSettings returns the settings of p other than its phases.
+func projectSettings(p *Project) map[string]string {
+	c := *p
+	c.Phases = nil
+	s := settings(&c)
+	delete(s, "phases")
+	return s
+}
+
+// settings flattens v, as encoded in JSON, into its settings: the fields of
+// objects are joined with dots, and other values are kept as JSON.
+func settings(v any) map[string]string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+	flat := make(map[string]string)
+	var flatten func(prefix string, v any)
+	flatten = func(prefix string, v any) {
+		if m, ok := v.(map[string]any); ok && len(m) > 0 {
+			for k, e := range m {
+				if prefix != "" {
+					k = prefix + "." + k
+				}
+				flatten(k, e)
+			}
+			return
+		}
+		if v == nil {
+			return
+		}
+		if s, ok := v.(string); ok {
+			flat[prefix] = s
+			return
+		}
+		data, _ := json.Marshal(v)
+		flat[prefix] = string(data)
+	}
+	flatten("", doc)
+	return flat
+}
+
+// compareSettings returns the settings that differ between a and b.
+func compareSettings(project, phase string, a, b map[string]string) []ConfigDifference {
+	keys := maps.Clone(a)
+	maps.Copy(keys, b)
+	var diffs []ConfigDifference
+	for _, key := range slices.Sorted(maps.Keys(keys)) {
+		if a[key] != b[key] {
+			diffs = append(diffs, ConfigDifference{Project: project, Phase: phase, Setting: key, Mine: a[key], Theirs: b[key]})
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,243 @@
+package bild
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ArtifactCache is a directory keeping the outputs of successful runs of
+// phases with Inputs and Outputs, by the hash of their inputs, so that a run
+// with the same inputs, even in another checkout, restores the outputs
+// instead of running the phase:
+//
+//	<dir>/<key>/
+//	    entry.json  the CacheEntry
+//	    files/      the outputs, by their paths relative to the phase's directory
+//
+// Entries are written to a temporary directory and renamed into place, so
+// that concurrent runs never restore half-written ones.
+type ArtifactCache struct {
+	Dir string
+}
+
+// CacheEntry describes the outputs of one run in an ArtifactCache.
+type CacheEntry struct {
+	Key     string    `json:"key"`
+	Project string    `json:"project"`
+	Phase   string    `json:"phase"`
+	Created time.Time `json:"created"`
+	// Files and Size are the number of files and their total size in bytes.
+	Files int   `json:"files"`
+	Size  int64 `json:"size"`
+	// Hits counts the runs that restored the entry, the last at LastUsed.
+	Hits     int       `json:"hits"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// cacheEntryFile is the file in an entry's directory describing it.
+const cacheEntryFile = "entry.json"
+
+// CacheKey returns the key of the outputs of the phase of project whose
+// inputs hash to hash, as returned by InputHash.
+func CacheKey(project, hash string) string {
+	sum := sha256.Sum256([]byte(project + "\x00" + hash))
+	return hex.EncodeToString(sum[:])
+}
+
+// Restore copies the outputs cached under key into dir, reporting whether
+// there were any.
+func (c *ArtifactCache) Restore(key, dir string) (bool, error) {
+	entryDir := filepath.Join(c.Dir, key)
+	var entry CacheEntry
+	data, err := os.ReadFile(filepath.Join(entryDir, cacheEntryFile))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &entry)
+	}
+	if err != nil {
+		return false, fmt.Errorf("cache entry %s: %v", key, err)
+	}
+	files := filepath.Join(entryDir, "files")
+	err = filepath.WalkDir(files, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(files, path)
+		if err != nil {
+			return err
+		}
+		return copyFile(path, filepath.Join(dir, rel))
+	})
+	if err != nil {
+		return false, fmt.Errorf("could not restore cache entry %s: %v", key, err)
+	}
+	// Hits are counted on a best-effort basis; concurrent runs may lose some.
+	entry.Hits++
+	entry.LastUsed = time.Now()
+	if data, err := json.MarshalIndent(entry, "", "  "); err == nil {
+		writeFileAtomic(filepath.Join(entryDir, cacheEntryFile), data)
+	}
+	Tracef("cache", "restored %d files of %s/%s from %s", entry.Files, entry.Project, entry.Phase, key)
+	return true, nil
+}
+
+// Store caches the files of dir, as relative paths, under key, replacing
+// what was cached there. entry describes them; Store sets its Key, Created,
+// Files and Size.
+func (c *ArtifactCache) Store(key string, entry CacheEntry, dir string, files []string) error {
+	if err := ensureDir(c.Dir); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(c.Dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	entry.Key, entry.Created, entry.Files, entry.Size = key, time.Now(), len(files), 0
+	for _, file := range files {
+		if err := copyFile(filepath.Join(dir, file), filepath.Join(tmp, "files", file)); err != nil {
+			return err
+		}
+		if info, err := os.Lstat(filepath.Join(dir, file)); err == nil {
+			entry.Size += info.Size()
+		}
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, cacheEntryFile), data, 0o644); err != nil {
+		return err
+	}
+	// A directory can't be renamed over another; the old entry is moved
+	// aside first.
+	entryDir := filepath.Join(c.Dir, key)
+	old := tmp + "-old"
+	if err := os.Rename(entryDir, old); err == nil {
+		defer os.RemoveAll(old)
+	}
+	if err := os.Rename(tmp, entryDir); err != nil {
+		return err
+	}
+	Tracef("cache", "stored %d files of %s/%s as %s", entry.Files, entry.Project, entry.Phase, key)
+	return nil
+}
+
+// Entries returns the entries of the cache, the newest first.
+func (c *ArtifactCache) Entries() ([]CacheEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(c.Dir, "*", cacheEntryFile))
+	if err != nil {
+		return nil, err
+	}
+	var entries []CacheEntry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			// Removed by a concurrent clear.
+			continue
+		}
+		var entry CacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Created.After(entries[j].Created)
+	})
+	return entries, nil
+}
+
+// Remove deletes the entry with key from the cache.
+func (c *ArtifactCache) Remove(key string) error {
+	if key == "" {
+		return errors.New("no cache key")
+	}
+	return os.RemoveAll(filepath.Join(c.Dir, key))
+}
+
+// restoreOutputs restores the outputs of ph from r.Cache if it has those of
+// a run with the inputs hashing to hash, reporting whether it did.
+func (r *Runner) restoreOutputs(proj *Project, ph *Phase, hash string) bool {
+	if r.Cache == nil || r.Force || len(ph.Outputs) == 0 {
+		return false
+	}
+	hit, err := r.Cache.Restore(CacheKey(proj.Name, hash), r.Dir)
+	if err != nil {
+		fmt.Fprintf(r.Stderr, "Warning: %v; running phase %s\n", err, ph.Name)
+		return false
+	}
+	if !hit {
+		return false
+	}
+	if co, ok := r.Observer.(CacheObserver); ok {
+		co.PhaseRestored(r.shown(ph))
+	}
+	return true
+}
+
+// storeOutputs caches the outputs of ph, which just succeeded with the
+// inputs hashing to hash, in r.Cache, if each of its Outputs matches a file.
+func (r *Runner) storeOutputs(proj *Project, ph *Phase, hash string) {
+	if r.Cache == nil || len(ph.Outputs) == 0 {
+		return
+	}
+	if exist, err := outputsExist(ph, r.Dir); err != nil || !exist {
+		Tracef("cache", "not caching phase %s: its outputs are missing (%v)", ph.Name, err)
+		return
+	}
+	files, err := MatchFiles(r.Dir, ph.Outputs)
+	if err == nil {
+		err = r.Cache.Store(CacheKey(proj.Name, hash), CacheEntry{Project: proj.Name, Phase: ph.Name}, r.Dir, files)
+	}
+	if err != nil {
+		fmt.Fprintf(r.Stderr, "Warning: could not cache the outputs of phase %s: %v\n", ph.Name, err)
+	}
+}
+
+// copyFile copies the file, or symbolic link, src to dst, with its mode,
+// creating the directories of dst.
+func copyFile(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := ensureParent(dst); err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		os.Remove(dst)
+		return os.Symlink(target, dst)
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// A file being replaced is removed first, in case it is read-only.
+	os.Remove(dst)
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,52 @@
+package bild
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunRestoresCachedOutputs(t *testing.T) {
+	proj := &Project{Name: "app", Phases: []Phase{{
+		Name:     "build",
+		Commands: []string{"echo ran; mkdir -p out && cp in.txt out/app"},
+		Inputs:   []string{"in.txt"},
+		Outputs:  []string{"out"},
+	}}}
+	cache := &ArtifactCache{Dir: t.TempDir()}
+	// Two checkouts of the project, each with its own state.
+	run := func(dir string) string {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("v1"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var stdout bytes.Buffer
+		r := &Runner{Stdout: &stdout, Stderr: &stdout, Dir: dir, State: Dirs{State: t.TempDir()}.ProjectState("app"), Cache: cache}
+		if err := r.Run(context.Background(), proj, "build"); err != nil {
+			t.Fatal(err)
+		}
+		return stdout.String()
+	}
+
+	if out := run(t.TempDir()); !strings.Contains(out, "ran") {
+		t.Fatalf("first run: output %q, want the phase run", out)
+	}
+	dir := t.TempDir()
+	if out := run(dir); strings.Contains(out, "ran") {
+		t.Errorf("second checkout: output %q, want the outputs restored", out)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "out", "app")); err != nil || string(data) != "v1" {
+		t.Errorf("restored out/app = %q, %v", data, err)
+	}
+
+	entries, err := cache.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Phase != "build" || entries[0].Files != 1 || entries[0].Size != 2 || entries[0].Hits != 1 {
+		t.Errorf("entries = %+v", entries)
+	}
+}
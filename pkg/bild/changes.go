@@ -0,0 +1,38 @@
+package bild
+
+import "time"
+
+// GreenRun records the last successful run of a phase.
+type GreenRun struct {
+	Commit string    `json:"commit"`
+	RunID  string    `json:"run_id"`
+	Time   time.Time `json:"time"`
+}
+
+// lastGreenFile is the state file holding the last successful run of each phase.
+const lastGreenFile = "last_green.json"
+
+// LastGreen returns the last successful run of each phase of the project
+// that ran in a git repository, by phase name.
+func (s *ProjectState) LastGreen() (map[string]GreenRun, error) {
+	runs := make(map[string]GreenRun)
+	if _, err := s.ReadJSON(lastGreenFile, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// RecordGreen remembers run as the last successful run of phase.
+func (s *ProjectState) RecordGreen(phase string, run GreenRun) error {
+	unlock, err := s.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	runs, err := s.LastGreen()
+	if err != nil {
+		return err
+	}
+	runs[phase] = run
+	return s.WriteJSON(lastGreenFile, runs)
+}
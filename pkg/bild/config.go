@@ -0,0 +1,1144 @@
+package bild
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LocalConfigName is the file name of a repository-local configuration.
+const LocalConfigName = ".bild.json"
+
+// Phase represents an ordered set of commands for one phase (e.g. "configure", "build", "test").
+type Phase struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Commands    []string `json:"commands"`
+	// Steps names and describes the commands written as steps, by index;
+	// see Step. It may be shorter than Commands, or nil, where the last
+	// commands, or all, are bare.
+	Steps []Step `json:"-"`
+	// Template expands the phase's commands and hooks as templates, such
+	// as {{ .Matrix.compiler }}, before they run; see RenderCommand.
+	// Without it they run as written, so that the braces of commands such
+	// as go list -f '{{ .Dir }}' reach them.
+	Template bool `json:"template,omitempty"`
+	// Needs lists phases that must run before this one.
+	Needs []string `json:"needs,omitempty"`
+	// Aliases are other names the phase can be run by, such as "b" for build.
+	Aliases []string `json:"aliases,omitempty"`
+	// DeprecatedNames lists former names of the phase that still resolve to it.
+	DeprecatedNames []DeprecatedName `json:"deprecated_names,omitempty"`
+	// Skip leaves the phase out of runs of all phases, e.g. slow docs or
+	// packaging; it still runs when named.
+	Skip bool `json:"skip,omitempty"`
+	// When, if set, is a condition such as `os == "linux"` the phase only
+	// runs under; it is skipped otherwise. See when.go for the syntax.
+	When string `json:"when,omitempty"`
+	Hooks
+
+	// Umask (octal, e.g. "022"), Locale (setting LANG and LC_ALL) and
+	// Ulimits (e.g. {"core": "unlimited", "nofile": "4096"}) are applied
+	// to the phase's shell before its commands run.
+	Umask   string            `json:"umask,omitempty"`
+	Locale  string            `json:"locale,omitempty"`
+	Ulimits map[string]string `json:"ulimits,omitempty"`
+
+	// Shell runs the phase's script instead of the runner's shell, e.g.
+	// "bash" or "zsh". LoginShell makes it a login shell, so that profile
+	// files setting up nvm, rbenv or environment modules are read first;
+	// without a Shell, login shells are bash.
+	Shell      string `json:"shell,omitempty"`
+	LoginShell bool   `json:"login_shell,omitempty"`
+
+	// Proxy (e.g. "http://proxy:3128") is passed to the phase's commands
+	// in the usual proxy variables, except for the hosts in NoProxy.
+	// Offline instead configures common tools not to use the network and,
+	// where the system allows it, cuts the phase off from the network.
+	Proxy   string `json:"proxy,omitempty"`
+	NoProxy string `json:"no_proxy,omitempty"`
+	Offline bool   `json:"offline,omitempty"`
+
+	// Artifacts maps names to the files the phase produces, relative to the
+	// directory it runs in, which pipelines hand to the steps after it.
+	Artifacts map[string]string `json:"artifacts,omitempty"`
+
+	// Problems names the problem matchers, such as "gcc" or "go", finding
+	// the compiler errors and failing tests the phase reports in its output;
+	// see ProblemMatcher.
+	Problems []string `json:"problems,omitempty"`
+	// Reports are patterns, such as "**/junit*.xml", of the JUnit XML
+	// reports the phase's tests write, relative to the directory it runs
+	// in. Those written by the phase are summed up once it finished; see
+	// TestReport.
+	Reports []string `json:"reports,omitempty"`
+	// Coverage fails the phase if the coverage report its tests write
+	// shows too little coverage; see CoverageGate.
+	Coverage *CoverageGate `json:"coverage,omitempty"`
+
+	// Matrix, e.g. {"compiler": ["gcc", "clang"]}, runs the phase once per
+	// combination of the values, which commands see as {{ .Matrix.compiler }}
+	// and $BILD_MATRIX_COMPILER; see Variants. Parallel runs the variants
+	// concurrently instead of one after the other.
+	Matrix   map[string][]string `json:"matrix,omitempty"`
+	Parallel bool                `json:"parallel,omitempty"`
+	// OnlyVariants, if set, limits the variants run to those of these
+	// names, as VariantName gives them; see Project.Retry.
+	OnlyVariants []string `json:"-"`
+
+	// Env is added to the environment of the phase's commands.
+	Env map[string]string `json:"env,omitempty"`
+
+	// Inputs are patterns, such as "src/**/*.c", of the files the phase
+	// reads, relative to the directory it runs in. A phase with Inputs is
+	// skipped when they, and the phase itself, haven't changed since its
+	// last successful run and each pattern of Outputs still matches a file.
+	Inputs  []string `json:"inputs,omitempty"`
+	Outputs []string `json:"outputs,omitempty"`
+
+	// Container, if set, runs the phase's commands in a container; see
+	// Container.
+	Container *Container `json:"container,omitempty"`
+	// Nix, if set, overrides the Nix setting of the project for the phase;
+	// "off" runs it outside of Nix.
+	Nix string `json:"nix,omitempty"`
+}
+
+// ShellArgs returns the shell running the phase's script, given the shell
+// used by default, and the flags it needs before -c.
+func (ph *Phase) ShellArgs(shell string) (string, []string) {
+	if ph.Shell != "" {
+		shell = ph.Shell
+	}
+	if !ph.LoginShell {
+		if shell == "" {
+			shell = "sh"
+		}
+		return shell, nil
+	}
+	if shell == "" {
+		shell = "bash"
+	}
+	return shell, []string{"-l"}
+}
+
+// Hooks are commands run around a phase's commands, or around a whole run
+// when set on a project.
+type Hooks struct {
+	// Pre runs first. A phase's pre commands run in the phase's shell, so
+	// that variables they export are seen by its commands; if they fail, so
+	// does the phase.
+	Pre []string `json:"pre,omitempty"`
+	// The post hooks run afterwards in a shell of their own, depending on the
+	// outcome, with $BILD_EXIT_CODE set. Always runs last, even when the run
+	// was interrupted.
+	OnSuccess []string `json:"on_success,omitempty"`
+	OnFailure []string `json:"on_failure,omitempty"`
+	Always    []string `json:"always,omitempty"`
+}
+
+// inherit fills the hooks h doesn't set from base.
+func (h Hooks) inherit(base Hooks) Hooks {
+	if h.Pre == nil {
+		h.Pre = base.Pre
+	}
+	if h.OnSuccess == nil {
+		h.OnSuccess = base.OnSuccess
+	}
+	if h.OnFailure == nil {
+		h.OnFailure = base.OnFailure
+	}
+	if h.Always == nil {
+		h.Always = base.Always
+	}
+	return h
+}
+
+// DeprecatedName is a former phase name kept as an alias after a rename, so
+// that scripts and muscle memory keep working for a while.
+type DeprecatedName struct {
+	Name  string     `json:"name"`
+	Until *time.Time `json:"until,omitempty"` // nil means the alias never expires
+}
+
+// Expired reports whether the alias is no longer valid at now.
+func (d DeprecatedName) Expired(now time.Time) bool {
+	return d.Until != nil && now.After(*d.Until)
+}
+
+// Project holds the ordered phases of a project.
+type Project struct {
+	// Name is the key the project is registered under. It is filled in by
+	// Config.Project and is not part of the serialized configuration.
+	Name string `json:"-"`
+	// Extends names a project whose phases this one inherits. Phases defined
+	// here replace inherited phases of the same name in place; the others are
+	// appended after the inherited ones. Hooks not set here are inherited too.
+	Extends string  `json:"extends,omitempty"`
+	Phases  []Phase `json:"phases"`
+	// DefaultPhase, if set, is the phase run when a run of the project
+	// names none, instead of all of its phases.
+	DefaultPhase string `json:"default_phase,omitempty"`
+	// Tags group projects, such as by language or team, for commands
+	// acting on several at once, such as bild run --tag. They are not
+	// inherited from the project extended.
+	Tags []string `json:"tags,omitempty"`
+	// Path is the checkout of a project of the global configuration, such
+	// as ~/src/api, where it runs when named outside of it and where bild
+	// run-all runs it. bild records it on the first run in a repository
+	// named after the project, and by bild dump. A leading ~/ stands for
+	// the home directory.
+	Path string `json:"path,omitempty"`
+	// Remote is the URL of the git repository the project is built from,
+	// such as git@github.com:org/api.git, so that runs in a clone of it
+	// take the project whatever the clone's directory is called; see
+	// RepoProject. SSH and HTTPS URLs of a repository match alike.
+	Remote string `json:"remote,omitempty"`
+	// Encrypted holds the whole project encrypted with the key file or
+	// passphrase, as bild encrypt project writes it, for a project of the
+	// global configuration with sensitive commands. It is decrypted as the
+	// configuration loads and encrypted again as it is saved.
+	Encrypted string `json:"encrypted,omitempty"`
+	// Hooks run around every run of the project, whichever phases it runs.
+	Hooks
+	// Template expands the templates in the project's hooks and in the
+	// commands of all its phases, as Phase.Template does for one phase.
+	Template bool `json:"template,omitempty"`
+	// Secrets are passed to commands as environment variables and masked in
+	// their output.
+	Secrets *Secrets `json:"secrets,omitempty"`
+	// Probes maps tool names to commands printing their versions, adding
+	// to and overriding DefaultProbes; see PinProbes.
+	Probes map[string]string `json:"probes,omitempty"`
+	// Pins records the version of each tool the project was pinned to with
+	// bild pin; see CheckPins.
+	Pins map[string]string `json:"pins,omitempty"`
+	// Vars are values commands use as {{ .Vars.name }}, such as compiler
+	// flags; Env is added to the environment of commands and hooks. Both
+	// are inherited from the project extended, entry by entry.
+	Vars map[string]string `json:"vars,omitempty"`
+	Env  map[string]string `json:"env,omitempty"`
+	// Profiles are named variants of the project, such as debug, release
+	// and asan, overriding its vars, env and phases; see WithProfile.
+	// Profiles of the project extended are inherited unless redefined.
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+	// Profile is the name of the profile applied by WithProfile, if any.
+	Profile string `json:"-"`
+	// Nix runs the shells of the phases in the project's Nix development
+	// environment, so that they get the toolchain it declares: "flake" with
+	// nix develop, "shell" with nix-shell, a flake output such as ".#ci"
+	// with nix develop .#ci, or "auto" with whichever of flake.nix and
+	// shell.nix the phase's directory has. Inherited unless set.
+	Nix string `json:"nix,omitempty"`
+	// Direnv adds what the .envrc of the directory the project runs in
+	// sets to the environment of its commands and hooks, as direnv loads it
+	// into interactive shells; see DirenvEnv. Env takes precedence.
+	Direnv bool `json:"direnv,omitempty"`
+	// ProblemMatchers defines problem matchers by name for the phases'
+	// Problems, besides and over BuiltinProblemMatchers.
+	ProblemMatchers map[string]ProblemMatcher `json:"problem_matchers,omitempty"`
+}
+
+// Phase returns the phase with the given name. Aliases and unexpired
+// deprecated names resolve to their phase; use LookupPhase to detect the use
+// of the latter.
+func (p *Project) Phase(name string) (*Phase, error) {
+	ph, _, err := p.LookupPhase(name)
+	return ph, err
+}
+
+// LookupPhase returns the phase with the given name or alias. If name is a
+// deprecated name of the phase, the matching alias is returned as well.
+// Names take precedence over aliases, and aliases over deprecated names.
+func (p *Project) LookupPhase(name string) (*Phase, *DeprecatedName, error) {
+	for i := range p.Phases {
+		if p.Phases[i].Name == name {
+			return &p.Phases[i], nil, nil
+		}
+	}
+	for i := range p.Phases {
+		if slices.Contains(p.Phases[i].Aliases, name) {
+			Tracef("config", "%s is an alias of phase %s of %s", name, p.Phases[i].Name, p.Name)
+			return &p.Phases[i], nil, nil
+		}
+	}
+
+	now := time.Now()
+	for i := range p.Phases {
+		for j, alias := range p.Phases[i].DeprecatedNames {
+			if alias.Name != name {
+				continue
+			}
+			if alias.Expired(now) {
+				return nil, nil, &PhaseNotFoundError{Phase: name, RenamedTo: p.Phases[i].Name}
+			}
+			return &p.Phases[i], &p.Phases[i].DeprecatedNames[j], nil
+		}
+	}
+	return nil, nil, &PhaseNotFoundError{Phase: name}
+}
+
+// SelectPhases returns the phases of p with the given names or aliases, or
+// all of them but those to Skip if names is empty. They keep the order of p,
+// whatever the order of names, and a phase named twice is returned once.
+func (p *Project) SelectPhases(names []string) ([]*Phase, error) {
+	selected := make(map[*Phase]bool, len(names))
+	for _, name := range names {
+		ph, err := p.Phase(name)
+		if err != nil {
+			return nil, err
+		}
+		selected[ph] = true
+	}
+	var phases []*Phase
+	for i := range p.Phases {
+		if selected[&p.Phases[i]] || (len(names) == 0 && !p.Phases[i].Skip) {
+			phases = append(phases, &p.Phases[i])
+		}
+	}
+	return phases, nil
+}
+
+// WithoutPhases returns phases without those of p named in skip, by name or
+// alias.
+func (p *Project) WithoutPhases(phases []*Phase, skip []string) ([]*Phase, error) {
+	skipped := make(map[*Phase]bool, len(skip))
+	for _, name := range skip {
+		ph, err := p.Phase(name)
+		if err != nil {
+			return nil, err
+		}
+		skipped[ph] = true
+	}
+	var kept []*Phase
+	for _, ph := range phases {
+		if !skipped[ph] {
+			kept = append(kept, ph)
+		}
+	}
+	return kept, nil
+}
+
+// RenamePhase renames the phase oldName to newName, along with the needs of
+// other phases and the default phase naming it. If keepAlias is set, the
+// old name remains usable as a deprecated alias until the given time (a zero
+// time keeps it forever).
+func (p *Project) RenamePhase(oldName, newName string, keepAlias bool, until time.Time) error {
+	var phase *Phase
+	for i := range p.Phases {
+		switch p.Phases[i].Name {
+		case oldName:
+			phase = &p.Phases[i]
+		case newName:
+			return fmt.Errorf("phase %s already exists", newName)
+		}
+	}
+	if phase == nil {
+		return &PhaseNotFoundError{Phase: oldName}
+	}
+
+	// A phase renamed back to one of its former names no longer needs that alias.
+	for i := range p.Phases {
+		aliases := p.Phases[i].DeprecatedNames[:0]
+		for _, alias := range p.Phases[i].DeprecatedNames {
+			if alias.Name != newName {
+				aliases = append(aliases, alias)
+			}
+		}
+		p.Phases[i].DeprecatedNames = aliases
+	}
+
+	phase.Name = newName
+	for i := range p.Phases {
+		for j, need := range p.Phases[i].Needs {
+			if need == oldName {
+				p.Phases[i].Needs[j] = newName
+			}
+		}
+	}
+	if p.DefaultPhase == oldName {
+		p.DefaultPhase = newName
+	}
+	if keepAlias {
+		alias := DeprecatedName{Name: oldName}
+		if !until.IsZero() {
+			alias.Until = &until
+		}
+		phase.DeprecatedNames = append(phase.DeprecatedNames, alias)
+	}
+	return nil
+}
+
+// Config holds a mapping from project names to their configurations.
+type Config struct {
+	// Version is the schema version of the file; see ConfigVersion.
+	Version  int                `json:"version"`
+	Projects map[string]Project `json:"projects"`
+	// Defaults are phases every project inherits unless it defines a phase of
+	// the same name, such as a universal clean or format phase. They run
+	// before the project's own phases.
+	Defaults []Phase `json:"defaults,omitempty"`
+	// Experiments enables unstable features by name; see Experiments.
+	Experiments map[string]bool `json:"experiments,omitempty"`
+	// LogDir, if set, is where the output of every phase run is logged; see
+	// Runner.LogDir. A leading ~/ stands for the home directory.
+	LogDir string `json:"log_dir,omitempty"`
+	// Terminal is the command run --windows opens terminal windows with,
+	// such as "kitty --" or "open -a iTerm"; see Runner.Terminal.
+	Terminal string `json:"terminal,omitempty"`
+	// Worktrees says which project runs in a linked git worktree take
+	// without naming one: "shared", the default, that of the main working
+	// tree, or "own" the one detected from the worktree itself.
+	Worktrees string `json:"worktrees,omitempty"`
+	// Submodules says how runs in a git submodule treat it: "own", the
+	// default, as a repository with a project of its own, or "parent" as a
+	// directory of its superproject, starting at the superproject's root
+	// and taking its project. See ProjectRoot.
+	Submodules string `json:"submodules,omitempty"`
+	// Conventions are checked by Lint.
+	Conventions *Conventions `json:"conventions,omitempty"`
+	// Workspaces map directories to the projects run there when bild is run
+	// in them, or below them, without naming a project; see Workspace.
+	// Relative paths start at the directory of a local config, or at the
+	// git repository root for the global one.
+	Workspaces map[string]string `json:"workspaces,omitempty"`
+	// Pipelines chain phases of projects, possibly of different
+	// repositories; see Pipeline.
+	Pipelines map[string]Pipeline `json:"pipelines,omitempty"`
+	// Includes are glob patterns of files holding more projects and
+	// pipelines, such as "conf.d/*.json", relative to the configuration's
+	// directory; see IncludeFiles. Saving writes them back to their files.
+	Includes []string `json:"includes,omitempty"`
+
+	// Parent, if set, is searched for projects that are extended but not
+	// defined here, such as a global project extended by a .bild.json.
+	Parent *Config `json:"-"`
+	// Dir is the directory of a local configuration found by
+	// FindLocalConfig, where its projects run.
+	Dir string `json:"-"`
+	// Migrated is set if the configuration was upgraded from an older
+	// version as it was loaded.
+	Migrated *Migration `json:"-"`
+	// Overrides, if set, are the settings of this machine merged over those
+	// of the configuration as they are used; see Overrides.
+	Overrides *Overrides `json:"-"`
+
+	// loaded records the file the config was read from and a digest of its
+	// contents, so that Save can tell when someone else wrote it meanwhile.
+	loaded *fileVersion
+	// included records the projects and pipelines read from Includes.
+	included *includes
+	// sealed records the projects kept encrypted, by name.
+	sealed map[string]*sealedProject
+}
+
+// fileVersion identifies the contents of a file at some point in time.
+type fileVersion struct {
+	path string
+	sum  [sha256.Size]byte // of the contents, or zero if the file didn't exist
+}
+
+// readVersion returns the current version of the file at path.
+func readVersion(path string) (*fileVersion, []byte, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &fileVersion{path: path}, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &fileVersion{path: path, sum: sha256.Sum256(data)}, data, nil
+}
+
+// ErrConfigChanged is returned by Config.Save, and by the storage backends,
+// if the config was modified by another process since it was loaded.
+var ErrConfigChanged = errors.New("the config file was changed by another process since it was loaded; run the command again")
+
+// NewConfig returns an empty configuration.
+func NewConfig() *Config {
+	return &Config{
+		Version:  ConfigVersion,
+		Projects: make(map[string]Project),
+	}
+}
+
+// ProjectNames returns the names of all projects in sorted order, so that
+// listings and selections do not depend on Go's map iteration order.
+func (c *Config) ProjectNames() []string {
+	names := make([]string, 0, len(c.Projects))
+	for name := range c.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasTags reports whether p carries every one of tags.
+func (p *Project) HasTags(tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(p.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// TaggedProjects returns the names of the projects of c and its parents
+// carrying every one of tags, in sorted order. A project of c hides one of
+// the same name in its parents.
+func (c *Config) TaggedProjects(tags []string) []string {
+	var names []string
+	for cfg := c; cfg != nil; cfg = cfg.Parent {
+		for _, name := range cfg.ProjectNames() {
+			if proj, owner, _ := c.lookupProject(name); owner == cfg && proj.HasTags(tags) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Project returns the project registered under name with the phases it
+// extends and the default phases merged in; the returned project no longer
+// extends anything. Use Projects directly to edit the project as written.
+func (c *Config) Project(name string) (*Project, error) {
+	proj, err := c.resolveProject(name, make(map[projectKey]bool))
+	if err != nil {
+		return nil, err
+	}
+	// Default phases go first; a project's own phase of the same name keeps
+	// its place among the project's phases.
+	var phases []Phase
+	for _, ph := range c.defaults() {
+		if _, err := proj.Phase(ph.Name); err != nil {
+			Tracef("config", "project %s inherits default phase %s", name, ph.Name)
+			phases = append(phases, ph)
+		}
+	}
+	proj.Phases = append(phases, proj.Phases...)
+	return proj, nil
+}
+
+// defaults returns the default phases of c and its parents; defaults of a
+// config replace those of the same name in its parents.
+func (c *Config) defaults() []Phase {
+	var phases []Phase
+	for cfg := c; cfg != nil; cfg = cfg.Parent {
+		phases = mergePhases(cfg.Defaults, phases)
+	}
+	return phases
+}
+
+// mergePhases returns base with the phases of derived merged in: phases of
+// the same name are replaced in place, the others are appended.
+func mergePhases(base, derived []Phase) []Phase {
+	phases := append([]Phase(nil), base...)
+	for _, ph := range derived {
+		replaced := false
+		for i := range phases {
+			if phases[i].Name == ph.Name {
+				phases[i], replaced = ph, true
+				break
+			}
+		}
+		if !replaced {
+			phases = append(phases, ph)
+		}
+	}
+	return phases
+}
+
+// projectKey identifies a project definition while following extends.
+type projectKey struct {
+	config *Config
+	name   string
+}
+
+// lookupProject finds name in c or its parents and returns the config defining it.
+func (c *Config) lookupProject(name string) (Project, *Config, bool) {
+	for cfg := c; cfg != nil; cfg = cfg.Parent {
+		if proj, ok := cfg.Projects[name]; ok {
+			return proj, cfg, true
+		}
+	}
+	return Project{}, nil, false
+}
+
+// hasProject reports whether c or one of its parents defines name.
+func (c *Config) hasProject(name string) bool {
+	_, _, ok := c.lookupProject(name)
+	return ok
+}
+
+func (c *Config) resolveProject(name string, seen map[projectKey]bool) (*Project, error) {
+	var proj Project
+	var owner *Config
+	ok := false
+	if c != nil {
+		proj, owner, ok = c.lookupProject(name)
+	}
+	if !ok {
+		return nil, &ProjectNotFoundError{Project: name}
+	}
+	if proj.Encrypted != "" {
+		err := ErrNoKey
+		if s := owner.sealed[name]; s != nil && s.err != nil {
+			err = s.err
+		}
+		return nil, fmt.Errorf("project %s is encrypted: %w", name, err)
+	}
+	proj.Name = name
+	proj.Phases = append([]Phase(nil), proj.Phases...)
+	if proj.Extends == "" {
+		return owner.Overrides.apply(name, &proj)
+	}
+
+	key := projectKey{owner, name}
+	if seen[key] {
+		return nil, fmt.Errorf("project %s extends itself", name)
+	}
+	seen[key] = true
+	// A project extending its own name refers to the parent's project of that
+	// name, e.g. a .bild.json adjusting the global project it was dumped from.
+	from := owner
+	if proj.Extends == name {
+		from = owner.Parent
+		Tracef("config", "project %s extends the parent config's %s", name, proj.Extends)
+	} else {
+		Tracef("config", "project %s extends %s", name, proj.Extends)
+	}
+	base, err := from.resolveProject(proj.Extends, seen)
+	if err != nil {
+		return nil, fmt.Errorf("project %s extends %s: %w", name, proj.Extends, err)
+	}
+
+	proj = MergeProject(*base, proj)
+	proj.Extends = ""
+	return owner.Overrides.apply(name, &proj)
+}
+
+// MergeProject returns proj laid over base, as a project extending base is:
+// phases of the same name are replaced and the others appended, maps are
+// merged with proj's entries winning, and settings proj leaves unset are
+// taken from base.
+func MergeProject(base, proj Project) Project {
+	proj.Phases = mergePhases(base.Phases, proj.Phases)
+	proj.Hooks = proj.Hooks.inherit(base.Hooks)
+	if proj.Secrets == nil {
+		proj.Secrets = base.Secrets
+	}
+	if len(base.Probes) > 0 {
+		probes := maps.Clone(base.Probes)
+		maps.Copy(probes, proj.Probes)
+		proj.Probes = probes
+	}
+	if proj.Pins == nil {
+		proj.Pins = base.Pins
+	}
+	proj.Vars = mergeMaps(base.Vars, proj.Vars)
+	proj.Env = mergeMaps(base.Env, proj.Env)
+	if len(base.Profiles) > 0 {
+		profiles := maps.Clone(base.Profiles)
+		maps.Copy(profiles, proj.Profiles)
+		proj.Profiles = profiles
+	}
+	if len(base.ProblemMatchers) > 0 {
+		matchers := maps.Clone(base.ProblemMatchers)
+		maps.Copy(matchers, proj.ProblemMatchers)
+		proj.ProblemMatchers = matchers
+	}
+	if proj.DefaultPhase == "" {
+		proj.DefaultPhase = base.DefaultPhase
+	}
+	if proj.Nix == "" {
+		proj.Nix = base.Nix
+	}
+	proj.Direnv = proj.Direnv || base.Direnv
+	proj.Template = proj.Template || base.Template
+	return proj
+}
+
+// DefaultConfigPath returns the path of the global configuration file in
+// the config directory of DefaultDirs, e.g. ~/.config/bild/bild.json.
+func DefaultConfigPath() (string, error) {
+	dirs, err := DefaultDirs()
+	if err != nil {
+		return "", err
+	}
+	return dirs.ConfigPath(), nil
+}
+
+// LoadConfig reads the configuration at path (or returns an empty config if the file doesn't exist).
+func LoadConfig(path string) (*Config, error) {
+	config := NewConfig()
+
+	version, data, err := readVersion(path)
+	if err != nil {
+		return nil, err
+	}
+	config.loaded = version
+	if data == nil {
+		Tracef("config", "%s does not exist; using an empty config", path)
+		return config.withOverrides(filepath.Join(filepath.Dir(path), OverridesName))
+	}
+	data, migrated, err := migrateConfigFile(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	if config.Projects == nil {
+		config.Projects = make(map[string]Project)
+	}
+	config.decryptProjects()
+	if migrated != nil {
+		if err := config.Save(path); err != nil {
+			return nil, err
+		}
+		config.Migrated = migrated
+	}
+	if err := config.loadIncludes(filepath.Dir(path), path); err != nil {
+		return nil, err
+	}
+	Tracef("config", "loaded %s: %d projects", path, len(config.Projects))
+	return config.withOverrides(filepath.Join(filepath.Dir(path), OverridesName))
+}
+
+// withOverrides sets the overrides of c to those at path, if any, and
+// returns c.
+func (c *Config) withOverrides(path string) (*Config, error) {
+	overrides, err := LoadOverrides(path)
+	if err != nil {
+		return nil, err
+	}
+	c.Overrides = overrides
+	return c, nil
+}
+
+// WithoutOverrides returns a copy of c and its parents without their
+// overrides, which resolves projects as they are shared, e.g. to write them
+// to files others see.
+func (c *Config) WithoutOverrides() *Config {
+	if c == nil {
+		return nil
+	}
+	shared := *c
+	shared.Overrides = nil
+	shared.Parent = c.Parent.WithoutOverrides()
+	return &shared
+}
+
+// EffectiveLogDir returns the log_dir of c, or that of its overrides if
+// they set one.
+func (c *Config) EffectiveLogDir() string {
+	if c.Overrides != nil && c.Overrides.LogDir != "" {
+		return c.Overrides.LogDir
+	}
+	return c.LogDir
+}
+
+// EffectiveTerminal returns the terminal of c, or that of its overrides if
+// they set one.
+func (c *Config) EffectiveTerminal() string {
+	if c.Overrides != nil && c.Overrides.Terminal != "" {
+		return c.Overrides.Terminal
+	}
+	return c.Terminal
+}
+
+// Save writes the configuration to path. Concurrent saves are serialized
+// with a lock on path + ".lock", and the file is replaced atomically so that
+// readers never see it half written. If c was loaded from path and the file
+// changed since, Save leaves it alone and returns ErrConfigChanged.
+func (c *Config) Save(path string) error {
+	data, err := c.marshal(ConfigFormat(path))
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(path+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if c.loaded != nil && c.loaded.path == path {
+		current, _, err := readVersion(path)
+		if err != nil {
+			return err
+		}
+		if current.sum != c.loaded.sum {
+			return fmt.Errorf("%s: %w", path, ErrConfigChanged)
+		}
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+	Tracef("config", "saved %s", path)
+	c.loaded = &fileVersion{path: path, sum: sha256.Sum256(data)}
+	return c.saveIncludes()
+}
+
+// marshal serializes c in format as a configuration of the current version,
+// without what it included.
+func (c *Config) marshal(format string) ([]byte, error) {
+	current := c.withoutIncluded()
+	current.Version = ConfigVersion
+	projects, err := c.sealProjects(current.Projects)
+	if err != nil {
+		return nil, err
+	}
+	current.Projects = projects
+	return encodeConfig(current, format)
+}
+
+// marshalConfig serializes v the same way every time: map keys sorted (as
+// encoding/json does), two-space indentation, shell operators such as "&&"
+// left unescaped, and a trailing newline, so that saved files diff cleanly.
+func marshalConfig(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// LoadLocalConfig attempts to load the local configuration of dir, a
+// .bild.json or .bild.toml file; see LocalConfigPath.
+// The boolean result reports whether a local configuration was found.
+func LoadLocalConfig(dir string) (*Config, bool, error) {
+	path := LocalConfigPath(dir)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		Tracef("config", "no %s in %s", LocalConfigName, dir)
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read local config: %v", err)
+	}
+
+	config := NewConfig()
+	if data, err = toJSON(data, ConfigFormat(path)); err == nil {
+		err = json.Unmarshal(data, &config.Projects)
+	}
+	if err == nil {
+		err = config.takeWorkspaces(data)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse local config %s: %v", filepath.Base(path), err)
+	}
+	if config.Overrides, err = LoadLocalOverrides(dir); err != nil {
+		return nil, false, err
+	}
+	Tracef("config", "loaded %s: %d projects", path, len(config.Projects))
+	return config, true, nil
+}
+
+// LocalWorkspacesKey is the key of the workspaces in a local configuration,
+// which otherwise maps project names to projects; no project can be named so.
+const LocalWorkspacesKey = "workspaces"
+
+// takeWorkspaces moves the workspaces of the local configuration data, which
+// were read as a project, to c.Workspaces.
+func (c *Config) takeWorkspaces(data []byte) error {
+	if _, ok := c.Projects[LocalWorkspacesKey]; !ok {
+		return nil
+	}
+	delete(c.Projects, LocalWorkspacesKey)
+	var doc struct {
+		Workspaces map[string]string `json:"workspaces"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("workspaces: %v", err)
+	}
+	c.Workspaces = doc.Workspaces
+	return nil
+}
+
+// Workspace returns the project that the workspaces of c and its parents map
+// dir to, and the workspace's directory. The deepest workspace holding dir
+// wins. root is the git repository root, or empty outside a repository, in
+// which case relative workspaces of the global configuration don't apply.
+func (c *Config) Workspace(dir, root string) (project, workspace string, ok bool) {
+	for cfg := c; cfg != nil; cfg = cfg.Parent {
+		base := cfg.Dir
+		if base == "" {
+			base = root
+		}
+		for path, name := range cfg.Workspaces {
+			if !filepath.IsAbs(path) {
+				if base == "" {
+					continue
+				}
+				path = filepath.Join(base, path)
+			}
+			if Within(dir, path) && len(path) > len(workspace) {
+				project, workspace, ok = name, path, true
+			}
+		}
+	}
+	if ok {
+		Tracef("resolve", "%s is in the workspace %s of project %s", dir, workspace, project)
+	}
+	return project, workspace, ok
+}
+
+// FindLocalConfig looks for local configurations in dir and its parents, up
+// to and including stop, or the file system root if stop is empty, and
+// returns the nearest one along with the directory holding it. Those further
+// up become its parents, the outermost getting parent, so that projects can
+// extend projects defined further up. It returns nil if there is none.
+func FindLocalConfig(dir, stop string, parent *Config) (*Config, string, error) {
+	return findLocalConfig(dir, stop, parent, LoadLocalConfig)
+}
+
+// findLocalConfig is FindLocalConfig loading the configurations with load.
+func findLocalConfig(dir, stop string, parent *Config, load func(dir string) (*Config, bool, error)) (*Config, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	if stop != "" && !Within(dir, stop) {
+		// E.g. dir is reached through a symlink: look in stop alone rather
+		// than in directories outside of it.
+		dir = stop
+	}
+	var found []*Config
+	var dirs []string
+	for {
+		config, ok, err := load(dir)
+		if err != nil {
+			return nil, "", err
+		}
+		if ok {
+			found = append(found, config)
+			dirs = append(dirs, dir)
+		}
+		next := filepath.Dir(dir)
+		if dir == stop || next == dir {
+			break
+		}
+		dir = next
+	}
+	if found == nil {
+		return nil, "", nil
+	}
+	for i, config := range found {
+		config.Parent = parent
+		config.Dir = dirs[i]
+		if i+1 < len(found) {
+			config.Parent = found[i+1]
+		}
+	}
+	Tracef("config", "nearest local config is in %s, below %d more", dirs[0], len(found)-1)
+	return found[0], dirs[0], nil
+}
+
+// LocalProject returns the project to run from c, a local configuration
+// found by FindLocalConfig, and the directory to run it in: that of the
+// configuration defining it, or "" if it comes from a parent that is not
+// local. A project named name is looked up in c and its parents. Without a
+// name, c must define a project: its only one, or else the one named
+// fallback, such as the repository, is taken.
+func (c *Config) LocalProject(name, fallback string) (*Project, string, error) {
+	if name == "" {
+		names := c.ProjectNames()
+		if _, ok := c.Projects[fallback]; ok {
+			name = fallback
+		} else if len(names) == 1 {
+			name = names[0]
+		} else {
+			return nil, "", &AmbiguousProjectError{Dir: c.Dir, Projects: names}
+		}
+		Tracef("resolve", "taking project %s of %d in the local config in %s", name, len(names), c.Dir)
+	}
+	proj, err := c.Project(name)
+	if err != nil {
+		return nil, "", err
+	}
+	_, owner, _ := c.lookupProject(name)
+	return proj, owner.Dir, nil
+}
+
+// WriteLocalConfig writes proj to a .bild.json file in dir under the given name
+// and returns the path of the written file.
+func WriteLocalConfig(dir string, name string, proj Project) (string, error) {
+	return WriteLocalConfigAs(dir, name, proj, FormatJSON)
+}
+
+// WriteLocalConfigAs writes proj to the local configuration file of format
+// in dir under the given name, replacing a local configuration in the other
+// format, and returns the path of the written file. The other projects of
+// the local configuration are kept.
+func WriteLocalConfigAs(dir string, name string, proj Project, format string) (string, error) {
+	if name == LocalWorkspacesKey {
+		return "", fmt.Errorf("a project in a local config cannot be named %s", name)
+	}
+	localConfig := map[string]any{}
+	if existing, ok, err := LoadLocalConfig(dir); err != nil {
+		return "", err
+	} else if ok {
+		for n, p := range existing.Projects {
+			localConfig[n] = p
+		}
+		if len(existing.Workspaces) > 0 {
+			localConfig[LocalWorkspacesKey] = existing.Workspaces
+		}
+	}
+	localConfig[name] = proj
+
+	data, err := encodeConfig(localConfig, format)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config: %v", err)
+	}
+
+	path, other := filepath.Join(dir, LocalConfigName), filepath.Join(dir, LocalConfigTOMLName)
+	if format == FormatTOML {
+		path, other = other, path
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write config: %v", err)
+	}
+	if err := os.Remove(other); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	return path, nil
+}
+
+// OrderByNeeds returns phases reordered so that every phase comes after the
+// phases it needs, keeping the original order wherever the needs allow.
+// Needs referring to unknown phases are ignored; cycles are reported as errors.
+func OrderByNeeds(phases []Phase) ([]Phase, error) {
+	index := make(map[string]int, len(phases))
+	for i, ph := range phases {
+		index[ph.Name] = i
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(phases))
+	ordered := make([]Phase, 0, len(phases))
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("phase %s has a dependency cycle", phases[i].Name)
+		case done:
+			return nil
+		}
+		state[i] = visiting
+		for _, need := range phases[i].Needs {
+			if j, ok := index[need]; ok {
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = done
+		ordered = append(ordered, phases[i])
+		return nil
+	}
+
+	for i := range phases {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// OrderByRisk returns phases reordered so that, of the phases whose needs
+// have run, the one most likely to reveal a failure soonest runs next,
+// according to the run history entries: one that failed in the last run (see
+// LastFailures), else the one with the highest historical failure rate per
+// second of run time. Phases without recorded failures keep their relative
+// order after the others. Like OrderByNeeds, it only follows declared needs,
+// so phases that rely on their predecessors without saying so may be moved
+// ahead of them.
+func OrderByRisk(phases []Phase, entries []HistoryEntry) ([]Phase, error) {
+	ordered, err := OrderByNeeds(phases)
+	if err != nil {
+		return nil, err
+	}
+	byPhase := make(map[string]PhaseStats)
+	for _, s := range ComputeStats(entries) {
+		byPhase[s.Phase] = s
+	}
+	failedLast := make(map[string]bool)
+	for _, e := range LastFailures(entries) {
+		phase, _, _ := strings.Cut(e.Phase, "[")
+		failedLast[phase] = true
+	}
+	// risk is the failure rate per second, or +Inf for a phase that has
+	// failed but never succeeded, so its duration is unknown.
+	risk := func(ph Phase) float64 {
+		s := byPhase[ph.Name]
+		if s.Failures == 0 {
+			return 0
+		}
+		rate := float64(s.Failures) / float64(s.Runs)
+		if s.Mean <= 0 {
+			return math.Inf(1)
+		}
+		return rate / s.Mean.Seconds()
+	}
+	riskier := func(a, b Phase) bool {
+		if failedLast[a.Name] != failedLast[b.Name] {
+			return failedLast[a.Name]
+		}
+		return risk(a) > risk(b)
+	}
+
+	known := make(map[string]bool, len(ordered))
+	for _, ph := range ordered {
+		known[ph.Name] = true
+	}
+	ran := make(map[string]bool, len(ordered))
+	ready := func(ph Phase) bool {
+		for _, need := range ph.Needs {
+			if known[need] && !ran[need] {
+				return false
+			}
+		}
+		return true
+	}
+	result := make([]Phase, 0, len(ordered))
+	for len(ordered) > 0 {
+		// ordered keeps needs before their dependents, so its first phase
+		// is always ready.
+		next := 0
+		for i, ph := range ordered {
+			if ready(ph) && riskier(ph, ordered[next]) {
+				next = i
+			}
+		}
+		ran[ordered[next].Name] = true
+		result = append(result, ordered[next])
+		ordered = append(ordered[:next], ordered[next+1:]...)
+	}
+	return result, nil
+}
+
+// Within reports whether path is dir or inside it.
+func Within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,302 @@
+package bild
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bild.json")
+	mine, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mine.Projects["api"] = Project{Phases: []Phase{{Name: "build", Commands: []string{"make"}}}}
+	if err := mine.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	// Saving again what it saved itself is fine.
+	if err := mine.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	theirs.Projects["web"] = Project{}
+	if err := theirs.Save(path); !errors.Is(err, ErrConfigChanged) {
+		t.Fatalf("Save of a config changed meanwhile = %v, want ErrConfigChanged", err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := config.Projects["web"]; ok {
+		t.Error("the conflicting save was written")
+	}
+	// Saving elsewhere is not a conflict.
+	if err := theirs.Save(filepath.Join(t.TempDir(), "bild.json")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSaveThroughSymlink(t *testing.T) {
+	dotfiles := filepath.Join(t.TempDir(), "bild.json")
+	if err := os.WriteFile(dotfiles, []byte(`{"projects": {}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bild.json")
+	if err := os.Symlink(dotfiles, path); err != nil {
+		t.Skip(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Projects["api"] = Project{Phases: []Phase{{Name: "build", Commands: []string{"make"}}}}
+	if err := config.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(path); err != nil || target != dotfiles {
+		t.Errorf("the link was replaced: %q, %v", target, err)
+	}
+	config, err = LoadConfig(dotfiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := config.Projects["api"]; !ok {
+		t.Error("the file the link points to was not written")
+	}
+}
+
+func TestSelectPhasesSkip(t *testing.T) {
+	proj := &Project{Phases: []Phase{
+		{Name: "build"},
+		{Name: "docs", Skip: true},
+		{Name: "test", Aliases: []string{"t"}},
+	}}
+	names := func(phases []*Phase) string {
+		var s []string
+		for _, ph := range phases {
+			s = append(s, ph.Name)
+		}
+		return strings.Join(s, ",")
+	}
+
+	all, err := proj.SelectPhases(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(all); got != "build,test" {
+		t.Errorf("SelectPhases() = %s, want build,test", got)
+	}
+	named, err := proj.SelectPhases([]string{"docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(named); got != "docs" {
+		t.Errorf("SelectPhases(docs) = %s, want docs", got)
+	}
+	kept, err := proj.WithoutPhases(all, []string{"t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(kept); got != "build" {
+		t.Errorf("WithoutPhases(t) = %s, want build", got)
+	}
+	if _, err := proj.WithoutPhases(all, []string{"lint"}); err == nil {
+		t.Error("WithoutPhases accepted an unknown phase")
+	}
+}
+
+func TestRenamePhase(t *testing.T) {
+	proj := &Project{DefaultPhase: "compile", Phases: []Phase{
+		{Name: "compile"},
+		{Name: "test", Needs: []string{"compile"}},
+		{Name: "package", Needs: []string{"test", "compile"}},
+	}}
+	if err := proj.RenamePhase("compile", "build", false, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if proj.DefaultPhase != "build" || proj.Phases[1].Needs[0] != "build" || proj.Phases[2].Needs[1] != "build" {
+		t.Errorf("after the rename: default phase %s, needs %v and %v; want build", proj.DefaultPhase, proj.Phases[1].Needs, proj.Phases[2].Needs)
+	}
+	ordered, err := OrderByNeeds([]Phase{proj.Phases[2], proj.Phases[1], proj.Phases[0]})
+	if err != nil || ordered[0].Name != "build" || ordered[2].Name != "package" {
+		t.Errorf("order after the rename: %v, %v", ordered, err)
+	}
+	if err := proj.RenamePhase("test", "build", false, time.Time{}); err == nil {
+		t.Error("renamed a phase to the name of another")
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bild.json")
+	config := `{"version": 1, "log_dir": "/shared/logs", "projects": {"app": {"phases": [
+		{"name": "build", "commands": ["make"], "umask": "022"},
+		{"name": "test", "commands": ["make test"]}
+	]}}}`
+	overrides := `{"log_dir": "/scratch/logs", "projects": {"app": {
+		"env": {"TOKEN": "t0p"},
+		"phases": [{"name": "build", "commands": ["make -j64"], "umask": null}, {"name": "bench", "commands": ["make bench"]}]
+	}}}`
+	for name, data := range map[string]string{"bild.json": config, OverridesName: overrides} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proj, err := c.Project("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ph := range proj.Phases {
+		got = append(got, ph.Name+": "+strings.Join(ph.Commands, "; ")+" "+ph.Umask)
+	}
+	if want := "build: make -j64 |test: make test |bench: make bench "; strings.Join(got, "|") != want {
+		t.Errorf("phases = %q, want %q", strings.Join(got, "|"), want)
+	}
+	if proj.Env["TOKEN"] != "t0p" || c.EffectiveLogDir() != "/scratch/logs" {
+		t.Errorf("env = %v, log dir = %s: want the overrides", proj.Env, c.EffectiveLogDir())
+	}
+
+	if shared, err := c.WithoutOverrides().Project("app"); err != nil || len(shared.Phases) != 2 || shared.Env != nil {
+		t.Errorf("shared project = %+v, %v: want no overrides", shared, err)
+	}
+
+	// Saving leaves the overrides out of the configuration.
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "t0p") || strings.Contains(string(data), "-j64") || strings.Contains(string(data), "scratch") {
+		t.Errorf("saved config has the overrides:\n%s", data)
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bild.json")
+	a := `{"projects": {"api": {"phases": [{"name": "build", "commands": ["go build"]}]}}}`
+	files := map[string]string{
+		"bild.json":     `{"version": 1, "includes": ["conf.d/*.json"], "projects": {"tools": {"phases": []}}}`,
+		"conf.d/a.json": a,
+		"conf.d/b.json": `{"projects": {"web": {"phases": [{"name": "build", "commands": ["npm run build"]}]}}}`,
+	}
+	for name, data := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(c.ProjectNames(), ","); names != "api,tools,web" {
+		t.Fatalf("projects = %s, want api,tools,web", names)
+	}
+	if from := c.IncludedFrom("web"); from != filepath.Join(dir, "conf.d", "b.json") {
+		t.Errorf("web included from %q", from)
+	}
+
+	// Saving writes included projects back to their files, leaving
+	// unchanged ones alone.
+	web := c.Projects["web"]
+	web.Phases[0].Commands = []string{"npm ci", "npm run build"}
+	c.Projects["web"] = web
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"bild.json": "tools", "conf.d/a.json": a, "conf.d/b.json": "npm ci"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), want) || name == "bild.json" && strings.Contains(string(data), "web") {
+			t.Errorf("%s after saving:\n%s", name, data)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "conf.d", "c.json"), []byte(a), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var conflict *IncludeConflictError
+	if _, err := LoadConfig(path); !errors.As(err, &conflict) || conflict.Name != "api" || filepath.Base(conflict.Files[1]) != "c.json" {
+		t.Errorf("LoadConfig with api defined twice: %v", err)
+	}
+}
+
+func TestTaggedProjects(t *testing.T) {
+	global := &Config{Projects: map[string]Project{
+		"api":  {Tags: []string{"go", "work"}},
+		"blog": {Tags: []string{"go"}},
+		"lib":  {Tags: []string{"cpp", "work"}},
+	}}
+	local := &Config{Parent: global, Projects: map[string]Project{
+		"blog": {Extends: "blog"},
+		"tool": {Tags: []string{"go", "work"}},
+	}}
+	tests := []struct {
+		tags []string
+		want string
+	}{
+		{[]string{"go"}, "api,tool"},
+		{[]string{"work"}, "api,lib,tool"},
+		{[]string{"go", "work"}, "api,tool"},
+		{[]string{"rust"}, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(local.TaggedProjects(tt.tags), ","); got != tt.want {
+			t.Errorf("TaggedProjects(%q) = %s, want %s", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestOrderByRiskWithoutNeeds(t *testing.T) {
+	phases := []Phase{{Name: "lint"}, {Name: "unit"}, {Name: "integration"}, {Name: "docs"}}
+	run := func(id string, failed ...string) []HistoryEntry {
+		var entries []HistoryEntry
+		for _, ph := range phases {
+			e := HistoryEntry{RunID: id, Project: "api", Phase: ph.Name, Duration: time.Second}
+			if slices.Contains(failed, ph.Name) {
+				e.ExitCode = 1
+			}
+			entries = append(entries, e)
+		}
+		return entries
+	}
+	names := func(phases []Phase) []string {
+		var names []string
+		for _, ph := range phases {
+			names = append(names, ph.Name)
+		}
+		return names
+	}
+
+	ordered, err := OrderByRisk(phases, nil)
+	if want := []string{"lint", "unit", "integration", "docs"}; err != nil || !slices.Equal(names(ordered), want) {
+		t.Errorf("without history: %q, %v; want %q", names(ordered), err, want)
+	}
+	// integration failed in the last run; unit fails more often.
+	history := slices.Concat(run("1", "unit"), run("2", "unit"), run("3", "integration"))
+	ordered, err = OrderByRisk(phases, history)
+	if want := []string{"integration", "unit", "lint", "docs"}; err != nil || !slices.Equal(names(ordered), want) {
+		t.Errorf("with history: %q, %v; want %q", names(ordered), err, want)
+	}
+}
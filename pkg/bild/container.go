@@ -0,0 +1,131 @@
+package bild
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Container is a container image a phase's commands run in, through docker
+// run or podman run, for a toolchain that doesn't depend on what the host
+// has installed. The directory the phase runs in is mounted at the same
+// path, so that the commands need not change. The phase's hooks run on the
+// host, and so do phases with a container when run in windows.
+type Container struct {
+	Image string `json:"image"`
+	// Engine is the program running the container, docker or podman; by
+	// default docker if it is installed, podman otherwise.
+	Engine string `json:"engine,omitempty"`
+	// Mounts are more volumes, as source:target[:options]. Sources starting
+	// with ./ or ../ are relative to the phase's directory, those starting
+	// with ~/ to the home directory; other names are named volumes.
+	Mounts []string `json:"mounts,omitempty"`
+	// Workdir is where the commands run in the container (default: the
+	// phase's directory, at the path it is mounted at).
+	Workdir string `json:"workdir,omitempty"`
+	// User is who the commands run as, e.g. "root" or "1000:1000". By
+	// default it is the user running bild, so that the files the commands
+	// write to the mounts belong to them.
+	User string `json:"user,omitempty"`
+}
+
+// containerEngine returns the engine running c.
+func (c *Container) containerEngine() string {
+	if c.Engine != "" {
+		return c.Engine
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		if _, err := exec.LookPath("podman"); err == nil {
+			return "podman"
+		}
+	}
+	return "docker"
+}
+
+// runArgs returns the arguments of the engine running the command args of
+// ph in its container, in dir, with the variables named by env passed on.
+func (c *Container) runArgs(ph *Phase, dir string, env []string, args []string) ([]string, error) {
+	if c.Image == "" {
+		return nil, fmt.Errorf("phase %s: container has no image", ph.Name)
+	}
+	engine := c.containerEngine()
+	workdir := c.Workdir
+	if workdir == "" {
+		workdir = dir
+	}
+	run := []string{"run", "--rm", "-i", "-v", dir + ":" + dir, "-w", workdir}
+	for _, mount := range c.Mounts {
+		source, target, ok := strings.Cut(mount, ":")
+		if !ok || source == "" || target == "" {
+			return nil, fmt.Errorf("phase %s: invalid container mount %q (want source:target)", ph.Name, mount)
+		}
+		if rest, ok := strings.CutPrefix(source, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			source = filepath.Join(home, rest)
+		} else if strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") {
+			source = filepath.Join(dir, source)
+		}
+		run = append(run, "-v", source+":"+target)
+	}
+	switch {
+	case c.User != "":
+		run = append(run, "--user", c.User)
+	case filepath.Base(engine) == "podman":
+		// Rootless podman maps the user to root in the container unless told
+		// to keep their ID.
+		run = append(run, "--userns=keep-id")
+	case runtime.GOOS != "windows":
+		run = append(run, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+	if ph.Offline {
+		run = append(run, "--network=none")
+	}
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		// The values come from the environment of the engine, so that
+		// secrets don't show up in its arguments.
+		run = append(run, "-e", name)
+	}
+	run = append(run, c.Image)
+	return append(run, args...), nil
+}
+
+// inContainer changes cmd, running the shell of ph with the variables env
+// added to the environment of bild, to run that shell in the container of ph
+// instead, with those variables.
+func (r *Runner) inContainer(cmd *exec.Cmd, ph *Phase, env []string) error {
+	dir := r.Dir
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	args, err := ph.Container.runArgs(ph, dir, env, cmd.Args)
+	if err != nil {
+		return err
+	}
+	engine := ph.Container.containerEngine()
+	path, err := exec.LookPath(engine)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("phase %s runs in a container, but %s is not installed", ph.Name, engine)
+		}
+		return err
+	}
+	// The shell only has to exist in the container.
+	cmd.Path, cmd.Args, cmd.Err = path, append([]string{engine}, args...), nil
+	Tracef("runner", "phase %s: running in container %s with %s", ph.Name, ph.Container.Image, engine)
+	return nil
+}
@@ -0,0 +1,55 @@
+package bild
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunInContainer(t *testing.T) {
+	dir := t.TempDir()
+	// The engine logs its arguments and runs the command after the image on
+	// the host.
+	engine := filepath.Join(t.TempDir(), "docker")
+	script := `#!/bin/sh
+echo "$@" >"$(dirname "$0")/args"
+while [ "$1" != alpine ]; do shift; done
+shift
+exec "$@"
+`
+	if err := os.WriteFile(engine, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	proj := &Project{Name: "app", Phases: []Phase{{
+		Name:      "build",
+		Commands:  []string{`echo "built with $CC"`},
+		Env:       map[string]string{"CC": "clang"},
+		Offline:   true,
+		Container: &Container{Image: "alpine", Engine: engine, Mounts: []string{"./cache:/cache", "gomod:/go/pkg/mod"}},
+	}}}
+	var stdout bytes.Buffer
+	r := &Runner{Stdout: &stdout, Stderr: &stdout, Dir: dir}
+	if err := r.Run(context.Background(), proj, "build"); err != nil {
+		t.Fatalf("%v: %s", err, stdout.String())
+	}
+	if stdout.String() != "built with clang\n" {
+		t.Errorf("output = %q", stdout.String())
+	}
+	args, err := os.ReadFile(filepath.Join(filepath.Dir(engine), "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("run --rm -i -v %s:%s -w %s -v %s:/cache -v gomod:/go/pkg/mod --user %d:%d --network=none", dir, dir, dir, filepath.Join(dir, "cache"), os.Getuid(), os.Getgid())
+	if !strings.HasPrefix(string(args), want) || !strings.Contains(string(args), " -e CC ") {
+		t.Errorf("engine arguments = %q, want them to start with %q and pass CC", args, want)
+	}
+
+	proj.Phases[0].Container = &Container{Engine: engine}
+	if err := r.Run(context.Background(), proj, "build"); err == nil {
+		t.Error("a container without an image ran")
+	}
+}
@@ -0,0 +1,278 @@
+package bild
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// coverageFile holds the coverage of the last run of each phase with a
+// coverage gate.
+const coverageFile = "coverage.json"
+
+// CoverageGate fails a phase whose tests cover too little of the code: less
+// than Min, or less than in its previous run.
+type CoverageGate struct {
+	// Report is the coverage report the phase writes, relative to the
+	// directory it runs in, such as "coverage.out" or "lcov.info".
+	Report string `json:"report"`
+	// Format is that of the report: "go" for Go cover profiles, "lcov" or
+	// "cobertura". It is told from the report's contents if unset.
+	Format string `json:"format,omitempty"`
+	// Min is the least percentage of the lines or statements the tests
+	// must cover, such as 80.
+	Min float64 `json:"min,omitempty"`
+	// MaxDrop, if set, is how many percentage points coverage may fall
+	// below that of the phase's previous run; 0 allows no drop at all.
+	MaxDrop *float64 `json:"max_drop,omitempty"`
+}
+
+// CoverageResult is the coverage a phase with a CoverageGate measured.
+type CoverageResult struct {
+	Percent float64 `json:"percent"`
+	// Previous is the coverage of the phase's previous run, if recorded.
+	Previous *float64 `json:"previous,omitempty"`
+}
+
+// CoverageError reports coverage below a phase's CoverageGate.
+type CoverageError struct {
+	Percent float64
+	// Min is the threshold missed; Previous is set instead if coverage
+	// dropped by more than the gate allows.
+	Min      float64
+	Previous *float64
+}
+
+func (e *CoverageError) Error() string {
+	if e.Previous != nil {
+		return fmt.Sprintf("coverage dropped to %.1f%%, from %.1f%% in the previous run", e.Percent, *e.Previous)
+	}
+	return fmt.Sprintf("coverage is %.1f%%, below the minimum of %.1f%%", e.Percent, e.Min)
+}
+
+// CoverageRun records the coverage of a run of a phase.
+type CoverageRun struct {
+	Percent float64   `json:"percent"`
+	Time    time.Time `json:"time"`
+}
+
+// Coverage returns the coverage of the last run of each phase with a
+// coverage gate, by phase name.
+func (s *ProjectState) Coverage() (map[string]CoverageRun, error) {
+	runs := make(map[string]CoverageRun)
+	if _, err := s.ReadJSON(coverageFile, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// RecordCoverage remembers run as the coverage of the last run of phase.
+func (s *ProjectState) RecordCoverage(phase string, run CoverageRun) error {
+	unlock, err := s.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	runs, err := s.Coverage()
+	if err != nil {
+		return err
+	}
+	runs[phase] = run
+	return s.WriteJSON(coverageFile, runs)
+}
+
+// ReadCoverage returns the percentage of lines or statements the coverage
+// report at path covers. An empty format is told from the report.
+func ReadCoverage(path, format string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if format == "" {
+		format = coverageFormat(data)
+	}
+	var covered, total float64
+	switch format {
+	case "go":
+		covered, total, err = parseGoCover(data)
+	case "lcov":
+		covered, total, err = parseLcov(data)
+	case "cobertura":
+		covered, total, err = parseCobertura(data)
+	case "":
+		return 0, fmt.Errorf("%s: not a Go cover profile, lcov or Cobertura report; set its format", path)
+	default:
+		return 0, fmt.Errorf("unknown coverage format %q (want go, lcov or cobertura)", format)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", path, err)
+	}
+	if total == 0 {
+		// Nothing to cover is all covered.
+		return 100, nil
+	}
+	return 100 * covered / total, nil
+}
+
+// coverageFormat tells the format of a coverage report from its start.
+func coverageFormat(data []byte) string {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte("mode:")):
+		return "go"
+	case bytes.HasPrefix(data, []byte("<")):
+		return "cobertura"
+	case bytes.HasPrefix(data, []byte("TN:")), bytes.HasPrefix(data, []byte("SF:")):
+		return "lcov"
+	}
+	return ""
+}
+
+// parseGoCover counts the statements of a Go cover profile. Blocks listed
+// more than once, as with -coverpkg, count once, covered if any run did.
+func parseGoCover(data []byte) (covered, total float64, err error) {
+	blocks := make(map[string]bool)
+	stmts := make(map[string]float64)
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		// file.go:12.34,15.2 3 1
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return 0, 0, fmt.Errorf("bad line %q", line)
+		}
+		n, err1 := strconv.ParseFloat(fields[1], 64)
+		count, err2 := strconv.Atoi(fields[2])
+		if err := errors.Join(err1, err2); err != nil {
+			return 0, 0, fmt.Errorf("bad line %q", line)
+		}
+		stmts[fields[0]] = n
+		blocks[fields[0]] = blocks[fields[0]] || count > 0
+	}
+	for block, n := range stmts {
+		total += n
+		if blocks[block] {
+			covered += n
+		}
+	}
+	return covered, total, lines.Err()
+}
+
+// parseLcov counts the lines of an lcov tracefile, from the LF and LH
+// totals of its files, or from their DA lines if those are missing.
+func parseLcov(data []byte) (covered, total float64, err error) {
+	var found, hit, daFound, daHit float64
+	var hasTotals bool
+	flush := func() {
+		if hasTotals {
+			covered, total = covered+hit, total+found
+		} else {
+			covered, total = covered+daHit, total+daFound
+		}
+		found, hit, daFound, daHit, hasTotals = 0, 0, 0, 0, false
+	}
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	for lines.Scan() {
+		key, value, _ := strings.Cut(strings.TrimSpace(lines.Text()), ":")
+		switch key {
+		case "LF", "LH":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("bad %s:%s", key, value)
+			}
+			if key == "LF" {
+				found = n
+			} else {
+				hit = n
+			}
+			hasTotals = true
+		case "DA":
+			// DA:<line>,<count>[,<checksum>]
+			parts := strings.Split(value, ",")
+			if len(parts) < 2 {
+				return 0, 0, fmt.Errorf("bad DA:%s", value)
+			}
+			daFound++
+			if count, _ := strconv.ParseFloat(parts[1], 64); count > 0 {
+				daHit++
+			}
+		case "end_of_record":
+			flush()
+		}
+	}
+	flush()
+	return covered, total, lines.Err()
+}
+
+// parseCobertura reads the lines covered and valid of a Cobertura report,
+// or its line rate if it lacks them.
+func parseCobertura(data []byte) (covered, total float64, err error) {
+	var report struct {
+		XMLName      xml.Name `xml:"coverage"`
+		LineRate     *float64 `xml:"line-rate,attr"`
+		LinesCovered *float64 `xml:"lines-covered,attr"`
+		LinesValid   *float64 `xml:"lines-valid,attr"`
+	}
+	if err := xml.Unmarshal(data, &report); err != nil {
+		return 0, 0, err
+	}
+	switch {
+	case report.LinesCovered != nil && report.LinesValid != nil:
+		return *report.LinesCovered, *report.LinesValid, nil
+	case report.LineRate != nil:
+		return *report.LineRate, 1, nil
+	}
+	return 0, 0, errors.New("the coverage element has neither lines-covered and lines-valid nor line-rate")
+}
+
+// checkCoverage reads the coverage report ph wrote since start, in r.Dir,
+// and checks it against the phase's gate and its previous run. Coverage
+// passing the gate is recorded as that of the phase's last run.
+func (r *Runner) checkCoverage(ph *Phase, start time.Time) (*CoverageResult, error) {
+	gate := ph.Coverage
+	path := filepath.Join(r.Dir, gate.Report)
+	// A report left over from an earlier run would pass the gate unseen.
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if info.ModTime().Before(start.Truncate(time.Second)) {
+		return nil, fmt.Errorf("%s was not written by the phase", gate.Report)
+	}
+	percent, err := ReadCoverage(path, gate.Format)
+	if err != nil {
+		return nil, err
+	}
+	result := &CoverageResult{Percent: percent}
+	if r.State != nil {
+		runs, err := r.State.Coverage()
+		if err != nil {
+			return nil, err
+		}
+		if last, ok := runs[ph.Name]; ok {
+			result.Previous = &last.Percent
+		}
+	}
+	Tracef("coverage", "phase %s: %.2f%% covered (gate %+v)", ph.Name, percent, *gate)
+	if percent < gate.Min {
+		return result, &CoverageError{Percent: percent, Min: gate.Min}
+	}
+	if gate.MaxDrop != nil && result.Previous != nil && percent < *result.Previous-*gate.MaxDrop {
+		return result, &CoverageError{Percent: percent, Previous: result.Previous}
+	}
+	// Only coverage that passed the gate is the one to keep up with.
+	if r.State != nil {
+		if err := r.State.RecordCoverage(ph.Name, CoverageRun{Percent: percent, Time: time.Now()}); err != nil {
+			fmt.Fprintf(r.Stderr, "Warning: could not record the coverage of phase %s: %v\n", ph.Name, err)
+		}
+	}
+	return result, nil
+}
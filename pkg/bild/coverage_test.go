@@ -0,0 +1,83 @@
+package bild
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadCoverage(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name, format, data string
+		want               float64
+	}{
+		// The block of a.go listed twice, as -coverpkg does, counts once.
+		{"go", "", "mode: set\na.go:1.1,3.2 3 1\nb.go:1.1,2.2 1 0\na.go:1.1,3.2 3 0\n", 75},
+		{"lcov", "", "TN:\nSF:a.c\nDA:1,1\nDA:2,0\nLF:2\nLH:1\nend_of_record\nSF:b.c\nDA:1,4\nDA:2,3\nend_of_record\n", 75},
+		{"cobertura", "", `<?xml version="1.0"?><coverage line-rate="0.5" lines-covered="3" lines-valid="4"></coverage>`, 75},
+		{"rate", "cobertura", `<coverage line-rate="0.75"/>`, 75},
+		{"empty", "go", "mode: atomic\n", 100},
+	} {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadCoverage(path, tt.format)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: coverage = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	path := filepath.Join(dir, "unknown")
+	os.WriteFile(path, []byte("covered: lots\n"), 0644)
+	if _, err := ReadCoverage(path, ""); err == nil {
+		t.Error("a report of no known format was read")
+	}
+}
+
+func TestCheckCoverage(t *testing.T) {
+	dir := t.TempDir()
+	write := func(covered int) {
+		data := "mode: set\na.go:1.1,2.2 100 0\n"
+		if covered > 0 {
+			data += fmt.Sprintf("b.go:1.1,2.2 %d 1\n", covered)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "coverage.out"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	maxDrop := 1.0
+	ph := &Phase{Name: "test", Coverage: &CoverageGate{Report: "coverage.out", Min: 40, MaxDrop: &maxDrop}}
+	r := &Runner{Stderr: io.Discard, Dir: dir, State: Dirs{State: t.TempDir()}.ProjectState("app")}
+	start := time.Now()
+
+	write(100) // 50%
+	result, err := r.checkCoverage(ph, start)
+	if err != nil || result.Percent != 50 || result.Previous != nil {
+		t.Fatalf("first run: %+v, %v", result, err)
+	}
+	write(300) // 75%
+	if result, err = r.checkCoverage(ph, start); err != nil || *result.Previous != 50 {
+		t.Fatalf("second run: %+v, %v", result, err)
+	}
+	write(150) // 60%, more than a point below the 75% recorded
+	var coverageErr *CoverageError
+	if _, err = r.checkCoverage(ph, start); !errors.As(err, &coverageErr) || coverageErr.Previous == nil {
+		t.Errorf("drop: err = %v", err)
+	}
+	write(50) // 33%
+	if _, err = r.checkCoverage(ph, start); !errors.As(err, &coverageErr) || coverageErr.Min != 40 {
+		t.Errorf("below the minimum: err = %v", err)
+	}
+	// A report older than the phase is not its own.
+	if _, err = r.checkCoverage(ph, start.Add(time.Hour)); err == nil {
+		t.Error("a stale report passed the gate")
+	}
+}
@@ -0,0 +1,362 @@
+package bild
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// KeyFileEnv names the environment variable pointing at the key file the
+// encrypted parts of the config are decrypted with, in place of KeyFile.
+const KeyFileEnv = "BILD_KEY_FILE"
+
+// PassphraseEnv names the environment variable holding a passphrase to
+// encrypt and decrypt with instead of a key file.
+const PassphraseEnv = "BILD_PASSPHRASE"
+
+// KeyFile, if set, is the key file used when neither $BILD_KEY_FILE nor
+// $BILD_PASSPHRASE is set.
+var KeyFile string
+
+// ErrNoKey is returned when something is to be encrypted or decrypted but
+// there is neither a key file nor a passphrase.
+var ErrNoKey = errors.New("no key to encrypt or decrypt with; create one with bild encrypt key, or set $" + PassphraseEnv)
+
+// KeyPath returns the path of the key file used by default, next to the
+// global configuration. Unlike the configuration, it is never synced.
+func (d Dirs) KeyPath() string {
+	return filepath.Join(d.Config, "bild.key")
+}
+
+// Encrypted values look like "v1.k.<data>" for values encrypted with a key
+// file and "v1.p.<data>" for those encrypted with a passphrase, the data
+// being the salt, the nonce and the AES-256-GCM ciphertext in unpadded
+// base64url.
+const (
+	encryptedVersion = "v1"
+	saltSize         = 16
+	// passphraseIterations is the PBKDF2-HMAC-SHA256 work factor for
+	// passphrases; key files are random and need none.
+	passphraseIterations = 600000
+)
+
+var (
+	keyMu sync.Mutex
+	// derived caches keys derived from a passphrase, which is slow on
+	// purpose, by passphrase and salt.
+	derived = make(map[[sha256.Size]byte][]byte)
+	// sessionSalt is the salt of the values a process encrypts with a
+	// passphrase, so that it derives the key once.
+	sessionSalt []byte
+)
+
+// secretKey is the key material of a key file or a passphrase.
+type secretKey struct {
+	material   []byte
+	passphrase bool
+}
+
+// loadKey returns the passphrase of $BILD_PASSPHRASE, or else the key of
+// the key file.
+func loadKey() (*secretKey, error) {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return &secretKey{material: []byte(p), passphrase: true}, nil
+	}
+	path := os.Getenv(KeyFileEnv)
+	if path == "" {
+		path = KeyFile
+	}
+	if path == "" {
+		return nil, ErrNoKey
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	material, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(material) < 32 {
+		return nil, fmt.Errorf("%s is not a key file written by bild encrypt key", path)
+	}
+	return &secretKey{material: material}, nil
+}
+
+// mode returns the letter marking values encrypted with k.
+func (k *secretKey) mode() string {
+	if k.passphrase {
+		return "p"
+	}
+	return "k"
+}
+
+// derive returns the AES-256 key of k for salt.
+func (k *secretKey) derive(salt []byte) []byte {
+	if !k.passphrase {
+		mac := hmac.New(sha256.New, k.material)
+		mac.Write(salt)
+		return mac.Sum(nil)
+	}
+	id := sha256.Sum256(append(append([]byte(nil), k.material...), salt...))
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if key, ok := derived[id]; ok {
+		return key
+	}
+	Tracef("crypt", "deriving the key of the passphrase")
+	key := pbkdf2SHA256(k.material, salt, passphraseIterations, 32)
+	derived[id] = key
+	return key
+}
+
+// pbkdf2SHA256 derives a key of keyLen bytes from password and salt with
+// PBKDF2-HMAC-SHA256 (RFC 8018).
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// GenerateKey writes a new random key file to path, readable only by its
+// owner. An existing file is left alone and is an error.
+func GenerateKey(path string) error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	if err := ensureParent(path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, base64.StdEncoding.EncodeToString(key)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Encrypt encrypts plaintext with the key file or passphrase, for
+// Decrypt.
+func Encrypt(plaintext []byte) (string, error) {
+	key, err := loadKey()
+	if err != nil {
+		return "", err
+	}
+	salt := make([]byte, saltSize)
+	if key.passphrase {
+		keyMu.Lock()
+		if sessionSalt == nil {
+			sessionSalt = make([]byte, saltSize)
+			_, err = rand.Read(sessionSalt)
+		}
+		salt = sessionSalt
+		keyMu.Unlock()
+	} else {
+		_, err = rand.Read(salt)
+	}
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key.derive(salt))
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	data := append(append(append([]byte(nil), salt...), nonce...), aead.Seal(nil, nonce, plaintext, nil)...)
+	return encryptedVersion + "." + key.mode() + "." + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Decrypt decrypts value, as returned by Encrypt.
+func Decrypt(value string) ([]byte, error) {
+	parts := strings.SplitN(value, ".", 3)
+	if len(parts) != 3 || parts[0] != encryptedVersion || (parts[1] != "k" && parts[1] != "p") {
+		return nil, errors.New("not a value encrypted by bild")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("not a value encrypted by bild: %v", err)
+	}
+	key, err := loadKey()
+	if err != nil {
+		return nil, err
+	}
+	if key.mode() != parts[1] {
+		if key.passphrase {
+			return nil, errors.New("encrypted with a key file, not a passphrase")
+		}
+		return nil, errors.New("encrypted with a passphrase; set $" + PassphraseEnv)
+	}
+	if len(data) < saltSize {
+		return nil, errors.New("encrypted value too short")
+	}
+	aead, err := newAEAD(key.derive(data[:saltSize]))
+	if err != nil {
+		return nil, err
+	}
+	data = data[saltSize:]
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("encrypted value too short")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("cannot decrypt: wrong key or passphrase")
+	}
+	return plaintext, nil
+}
+
+// newAEAD returns AES-GCM with key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealedProject is a project of the global configuration kept encrypted:
+// the value it was read from, and the project it decrypted to in JSON, or
+// why it could not be decrypted.
+type sealedProject struct {
+	value string
+	plain []byte
+	err   error
+}
+
+// decryptProjects replaces the encrypted projects of c with the projects
+// they decrypt to. Those that cannot be decrypted, say for lack of the key
+// on this machine, are kept as they are and fail to resolve.
+func (c *Config) decryptProjects() {
+	for name, proj := range c.Projects {
+		if proj.Encrypted == "" {
+			continue
+		}
+		if c.sealed == nil {
+			c.sealed = make(map[string]*sealedProject)
+		}
+		sealed := &sealedProject{value: proj.Encrypted}
+		c.sealed[name] = sealed
+		data, err := Decrypt(proj.Encrypted)
+		if err != nil {
+			Tracef("crypt", "cannot decrypt project %s: %v", name, err)
+			sealed.err = err
+			continue
+		}
+		var plain Project
+		if err := json.Unmarshal(data, &plain); err != nil {
+			sealed.err = err
+			continue
+		}
+		Tracef("crypt", "decrypted project %s", name)
+		c.Projects[name] = plain
+		sealed.plain, sealed.err = json.Marshal(plain)
+	}
+}
+
+// sealProjects returns projects with those kept encrypted encrypted: as
+// they were read if unchanged, so that saving does not rewrite them, or
+// else anew.
+func (c *Config) sealProjects(projects map[string]Project) (map[string]Project, error) {
+	if len(c.sealed) == 0 {
+		return projects, nil
+	}
+	sealed := make(map[string]Project, len(projects))
+	for name, proj := range projects {
+		s, ok := c.sealed[name]
+		if !ok || s.err != nil {
+			sealed[name] = proj
+			continue
+		}
+		data, err := json.Marshal(proj)
+		if err != nil {
+			return nil, err
+		}
+		if s.value != "" && bytes.Equal(data, s.plain) {
+			sealed[name] = Project{Encrypted: s.value}
+			continue
+		}
+		value, err := Encrypt(data)
+		if err != nil {
+			return nil, fmt.Errorf("encrypting project %s: %w", name, err)
+		}
+		s.value, s.plain = value, data
+		sealed[name] = Project{Encrypted: value}
+	}
+	return sealed, nil
+}
+
+// Seal has project name of c kept encrypted from its next save on.
+func (c *Config) Seal(name string) error {
+	if _, ok := c.Projects[name]; !ok {
+		return &ProjectNotFoundError{Project: name}
+	}
+	if _, ok := c.sealed[name]; ok {
+		return fmt.Errorf("project %s is already encrypted", name)
+	}
+	if c.included != nil {
+		if _, ok := c.included.projects[name]; ok {
+			return fmt.Errorf("project %s comes from an included file; only projects of the config itself can be encrypted", name)
+		}
+	}
+	if _, err := loadKey(); err != nil {
+		return err
+	}
+	if c.sealed == nil {
+		c.sealed = make(map[string]*sealedProject)
+	}
+	c.sealed[name] = &sealedProject{}
+	return nil
+}
+
+// Unseal has project name of c saved in plain text again.
+func (c *Config) Unseal(name string) error {
+	s, ok := c.sealed[name]
+	if !ok {
+		return fmt.Errorf("project %s is not encrypted", name)
+	}
+	if s.err != nil {
+		return fmt.Errorf("project %s: %w", name, s.err)
+	}
+	delete(c.sealed, name)
+	return nil
+}
+
+// Sealed reports whether project name of c is kept encrypted.
+func (c *Config) Sealed(name string) bool {
+	_, ok := c.sealed[name]
+	return ok
+}
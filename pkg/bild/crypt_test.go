@@ -0,0 +1,92 @@
+package bild
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	for iterations, want := range map[int]string{
+		1: "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b",
+		2: "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43",
+	} {
+		if got := hex.EncodeToString(pbkdf2SHA256([]byte("password"), []byte("salt"), iterations, 32)); got != want {
+			t.Errorf("%d iterations: got %s, want %s", iterations, got, want)
+		}
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	key := filepath.Join(t.TempDir(), "bild.key")
+	t.Setenv(KeyFileEnv, key)
+	t.Setenv(PassphraseEnv, "")
+	if _, err := Encrypt([]byte("token")); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("Encrypt without a key = %v, want ErrNoKey", err)
+	}
+	if err := GenerateKey(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateKey(key); !errors.Is(err, os.ErrExist) {
+		t.Errorf("GenerateKey over a key file = %v, want os.ErrExist", err)
+	}
+	value, err := Encrypt([]byte("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(value, "v1.k.") || strings.Contains(value, "token") {
+		t.Errorf("Encrypt = %q", value)
+	}
+	if plain, err := Decrypt(value); err != nil || string(plain) != "token" {
+		t.Errorf("Decrypt = %q, %v", plain, err)
+	}
+	if _, err := Decrypt(value[:len(value)-2] + "AA"); err == nil {
+		t.Error("Decrypt of a tampered value succeeded")
+	}
+}
+
+func TestSealedProject(t *testing.T) {
+	key := filepath.Join(t.TempDir(), "bild.key")
+	t.Setenv(KeyFileEnv, key)
+	t.Setenv(PassphraseEnv, "")
+	if err := GenerateKey(key); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bild.json")
+	config := &Config{Projects: map[string]Project{
+		"deploy": {Phases: []Phase{{Name: "deploy", Commands: []string{"curl -H 'Token: s3cr3t' example.com"}}}},
+	}}
+	if err := config.Seal("deploy"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cr3t") || !strings.Contains(string(data), `"encrypted"`) {
+		t.Fatalf("saved config holds the project in plain text:\n%s", data)
+	}
+
+	loaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Sealed("deploy") || loaded.Projects["deploy"].Phases[0].Commands[0] != "curl -H 'Token: s3cr3t' example.com" {
+		t.Errorf("loaded project = %+v", loaded.Projects["deploy"])
+	}
+
+	t.Setenv(KeyFileEnv, filepath.Join(t.TempDir(), "missing.key"))
+	loaded, err = LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loaded.Project("deploy"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("resolving without the key = %v, want ErrNoKey", err)
+	}
+}
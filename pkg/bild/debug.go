@@ -0,0 +1,64 @@
+package bild
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DebugEnv names the environment variable enabling the trace of bild's own
+// decisions: "1" traces to stderr, any other value except "0" names a file
+// the trace is appended to.
+const DebugEnv = "BILD_DEBUG"
+
+// Trace, if non-nil, receives one line for every decision bild makes while
+// resolving and running a project: which config files are read, whether a
+// git repository was found, local versus global projects, agent cache hits
+// and how phases are executed. Programs embedding bild may set it directly.
+var Trace io.Writer
+
+var (
+	traceMu    sync.Mutex
+	traceStart = time.Now()
+)
+
+// OpenTrace returns the writer selected by a BILD_DEBUG-style value, or nil
+// if tracing is off. The returned function closes a trace file.
+func OpenTrace(dest string) (io.Writer, func() error, error) {
+	switch strings.ToLower(dest) {
+	case "", "0", "false":
+		return nil, func() error { return nil }, nil
+	case "1", "true", "stderr":
+		return os.Stderr, func() error { return nil }, nil
+	}
+	if err := ensureParent(dest); err != nil {
+		return nil, nil, err
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// traceDir names dir in trace lines, where "" means the current directory.
+func traceDir(dir string) string {
+	if dir == "" {
+		return "the current directory"
+	}
+	return dir
+}
+
+// Tracef writes a line about area (such as "config" or "git") to Trace, if set.
+func Tracef(area, format string, args ...any) {
+	if Trace == nil {
+		return
+	}
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	elapsed := time.Since(traceStart).Milliseconds()
+	fmt.Fprintf(Trace, "bild[%d] +%dms %s: %s\n", os.Getpid(), elapsed, area, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,81 @@
+package bild
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// findEnvrc returns the .envrc direnv would load in dir: the one in dir or
+// in the nearest of its parents, or "" if there is none.
+func findEnvrc(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if path := filepath.Join(dir, ".envrc"); exists(path) {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// DirenvEnv returns the variables the .envrc of dir sets, as NAME=value
+// sorted by name, the way direnv export json computes them for the
+// environment of bild: nothing if bild runs in a shell that loaded it
+// already, or if there is no .envrc. A .envrc that was not allowed with
+// direnv allow is an error. Variables the .envrc unsets are left alone.
+func DirenvEnv(ctx context.Context, dir string) ([]string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	envrc := findEnvrc(dir)
+	if envrc == "" {
+		Tracef("direnv", "no .envrc in %s or above", dir)
+		return nil, nil
+	}
+	if _, err := exec.LookPath("direnv"); err != nil {
+		return nil, fmt.Errorf("%s asks for direnv, but it is not installed", envrc)
+	}
+	cmd := exec.CommandContext(ctx, "direnv", "export", "json")
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	// direnv reports a blocked .envrc, but exits successfully.
+	if msg := strings.TrimSpace(stderr.String()); err != nil || strings.Contains(msg, "is blocked") {
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("direnv could not load %s: %s", envrc, msg)
+	}
+	if stdout.Len() == 0 {
+		Tracef("direnv", "%s is loaded already", envrc)
+		return nil, nil
+	}
+	var vars map[string]*string
+	if err := json.Unmarshal(stdout.Bytes(), &vars); err != nil {
+		return nil, fmt.Errorf("direnv export json: %v", err)
+	}
+	var env []string
+	for _, name := range slices.Sorted(maps.Keys(vars)) {
+		// direnv's own bookkeeping only concerns shells.
+		if vars[name] == nil || strings.HasPrefix(name, "DIRENV_") {
+			continue
+		}
+		env = append(env, name+"="+*vars[name])
+	}
+	Tracef("direnv", "%s sets %d variables", envrc, len(env))
+	return env, nil
+}
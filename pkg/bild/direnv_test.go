@@ -0,0 +1,54 @@
+package bild
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunWithDirenv(t *testing.T) {
+	// The fake direnv exports what loading a .envrc would, bookkeeping and
+	// unset variables included.
+	bin := t.TempDir()
+	script := `#!/bin/sh
+echo '{"DB": "dev", "GREETING": "hello", "DIRENV_DIFF": "x", "UNSET_ME": null}'
+`
+	if err := os.WriteFile(filepath.Join(bin, "direnv"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	repo := t.TempDir()
+	dir := filepath.Join(repo, "sub")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	proj := &Project{
+		Name:   "app",
+		Direnv: true,
+		Env:    map[string]string{"GREETING": "hi"},
+		Phases: []Phase{{Name: "build", Commands: []string{`echo "$DB $GREETING ${DIRENV_DIFF:-none}"`}}},
+	}
+	run := func() string {
+		t.Helper()
+		var stdout bytes.Buffer
+		r := &Runner{Stdout: &stdout, Stderr: &stdout, Dir: dir}
+		if err := r.Run(context.Background(), proj, "build"); err != nil {
+			t.Fatalf("%v: %s", err, stdout.String())
+		}
+		return strings.TrimSpace(stdout.String())
+	}
+
+	if out := run(); out != "hi none" {
+		t.Errorf("without .envrc: output %q", out)
+	}
+	if err := os.WriteFile(filepath.Join(repo, ".envrc"), []byte("export DB=dev\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out := run(); out != "dev hi none" {
+		t.Errorf("with .envrc in a parent: output %q, want its DB and the project's GREETING", out)
+	}
+}
@@ -0,0 +1,24 @@
+// Package bild loads bild configurations and executes the phases of a project.
+//
+// It is the engine behind the bild command-line tool and can be embedded by
+// other Go programs and editor plugins:
+//
+//	path, err := bild.DefaultConfigPath()
+//	if err != nil {
+//		return err
+//	}
+//	cfg, err := bild.LoadConfig(path)
+//	if err != nil {
+//		return err
+//	}
+//	proj, err := cfg.Project("my_project")
+//	if err != nil {
+//		return err
+//	}
+//	return bild.NewRunner().Run(ctx, proj, "build")
+//
+// Failures are reported as typed errors (*PhaseError, *HookError,
+// *InterruptedError, *ProjectNotFoundError, *PhaseNotFoundError) so callers
+// can decide how to present them. Run the phases under NotifyContext to have
+// Ctrl-C stop them cleanly.
+package bild
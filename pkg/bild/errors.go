@@ -0,0 +1,142 @@
+package bild
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// PhaseError reports a phase whose commands did not complete successfully.
+type PhaseError struct {
+	Phase    string
+	ExitCode int
+	Duration time.Duration
+	Err      error
+	// Step names the step that failed, if it was written as a named one.
+	Step string
+	// Tail holds the last lines the phase printed if Runner.TailLines is set.
+	Tail []string
+}
+
+func (e *PhaseError) Error() string {
+	return fmt.Sprintf("phase %s failed after %s (exit code %d): %v", e.Phase, e.Duration.Round(time.Millisecond), e.ExitCode, e.Err)
+}
+
+func (e *PhaseError) Unwrap() error {
+	return e.Err
+}
+
+// ProjectNotFoundError reports a project missing from the configuration.
+type ProjectNotFoundError struct {
+	Project string
+}
+
+func (e *ProjectNotFoundError) Error() string {
+	return fmt.Sprintf("project %s not found", e.Project)
+}
+
+// PipelineNotFoundError reports a pipeline missing from the configuration.
+type PipelineNotFoundError struct {
+	Pipeline string
+}
+
+func (e *PipelineNotFoundError) Error() string {
+	return fmt.Sprintf("pipeline %s not found", e.Pipeline)
+}
+
+// IncludeConflictError reports a project or pipeline defined by two of the
+// files making up a configuration, such as two included files.
+type IncludeConflictError struct {
+	Kind  string // "project" or "pipeline"
+	Name  string
+	Files [2]string // where it was defined first and again
+}
+
+func (e *IncludeConflictError) Error() string {
+	return fmt.Sprintf("%s %s is defined in both %s and %s", e.Kind, e.Name, e.Files[0], e.Files[1])
+}
+
+// AmbiguousProjectError reports a local configuration with several projects
+// when none of them was named.
+type AmbiguousProjectError struct {
+	Dir      string // the directory of the configuration
+	Projects []string
+}
+
+func (e *AmbiguousProjectError) Error() string {
+	return fmt.Sprintf("the local config in %s has %d projects (%s); name the one to run", e.Dir, len(e.Projects), strings.Join(e.Projects, ", "))
+}
+
+// PhaseNotFoundError reports a phase missing from a project.
+type PhaseNotFoundError struct {
+	Phase string
+	// RenamedTo is set when Phase is an expired deprecated name of another phase.
+	RenamedTo string
+}
+
+func (e *PhaseNotFoundError) Error() string {
+	if e.RenamedTo != "" {
+		return fmt.Sprintf("phase %s not found (it was renamed to %s)", e.Phase, e.RenamedTo)
+	}
+	return fmt.Sprintf("phase %s not found", e.Phase)
+}
+
+// HookError reports a hook whose commands did not complete successfully.
+type HookError struct {
+	Hook     string // pre, on_success, on_failure or always
+	Phase    string // empty for the project's hooks
+	ExitCode int
+	Err      error
+}
+
+func (e *HookError) Error() string {
+	if e.Phase == "" {
+		return fmt.Sprintf("%s hook failed (exit code %d): %v", e.Hook, e.ExitCode, e.Err)
+	}
+	return fmt.Sprintf("%s hook of phase %s failed (exit code %d): %v", e.Hook, e.Phase, e.ExitCode, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// DeadlineError reports a run stopped at Runner.Deadline, before the
+// phases in Skipped were started.
+type DeadlineError struct {
+	Deadline time.Time
+	Skipped  []string
+}
+
+func (e *DeadlineError) Error() string {
+	return fmt.Sprintf("deadline %s reached; did not start %s", e.Deadline.Format(time.DateTime), strings.Join(e.Skipped, ", "))
+}
+
+// ExitCode returns 124, the status timeout(1) exits with.
+func (e *DeadlineError) ExitCode() int {
+	return 124
+}
+
+// InterruptedError reports a run stopped by a signal such as Ctrl-C (see
+// NotifyContext), or canceled otherwise if Signal is nil.
+type InterruptedError struct {
+	Phase  string // the phase that was running, if any
+	Signal os.Signal
+}
+
+func (e *InterruptedError) Error() string {
+	if e.Phase == "" {
+		return fmt.Sprintf("interrupted (%s)", signalName(e.Signal))
+	}
+	return fmt.Sprintf("phase %s was interrupted (%s)", e.Phase, signalName(e.Signal))
+}
+
+// ExitCode returns the exit status shells use for commands ended by the
+// signal: 128 plus its number, so 130 for Ctrl-C.
+func (e *InterruptedError) ExitCode() int {
+	if s, ok := e.Signal.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 130
+}
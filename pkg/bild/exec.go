@@ -0,0 +1,90 @@
+package bild
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Exec runs args, a program and its arguments, the way the commands of a
+// phase of proj run: in r.Dir, with the project's secrets in the environment
+// and, if the project or phase is templated, templates such as {{ .Dir }}
+// in args expanded. If phase is not empty, that phase's shell, umask,
+// locale, limits and network settings apply too.
+// Unlike Run, Exec runs no hooks, tells no observer and masks no output, so
+// that interactive programs such as debuggers and shells work.
+func (r *Runner) Exec(ctx context.Context, proj *Project, phase string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("no command given")
+	}
+	ph := &Phase{Name: "exec"}
+	if phase != "" {
+		found, err := r.lookupPhase(proj, phase)
+		if err != nil {
+			return err
+		}
+		ph = found.clone()
+		ph.Commands, ph.Steps, ph.Hooks = nil, nil, Hooks{}
+	}
+
+	var secrets []string
+	if proj.Secrets != nil && !proj.Secrets.Empty() {
+		var err error
+		if secrets, err = LoadSecrets(*proj.Secrets, r.Dir); err != nil {
+			return err
+		}
+	}
+	settings, env, err := ph.processSettings()
+	if err != nil {
+		return err
+	}
+	if proj.templated(ph) {
+		data := r.templateData(proj, ph)
+		if phase == "" {
+			data.Phase = ""
+		}
+		if args, err = renderCommands(args, data); err != nil {
+			return err
+		}
+	}
+
+	// The shell applies the settings, then replaces itself with the program.
+	cmd := r.shellCommand(ctx, ph, settings+`exec "$@"`)
+	cmd.Args = append(append(cmd.Args, "bild-exec"), args...)
+	cmd.Dir = r.Dir
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = r.Stdin, r.Stdout, r.Stderr
+	cmd.Env = r.environ(append(secrets, env...)...)
+	if ph.Container != nil {
+		if err := r.inContainer(cmd, ph, r.addedEnv(append(secrets, env...)...)); err != nil {
+			return err
+		}
+	} else if err := r.inNix(cmd, proj, ph); err != nil {
+		return err
+	} else if ph.Offline {
+		if err := isolateNetwork(cmd); err != nil {
+			fmt.Fprintf(r.Stderr, "Warning: phase %s is offline, but its network can't be cut off (%v); only its tools are told to stay offline\n", ph.Name, err)
+		}
+	}
+	Tracef("runner", "exec %s via %s in %q", strings.Join(args, " "), cmd.Path, r.Dir)
+
+	start := time.Now()
+	err = cmd.Run()
+	r.commandExited(ctx, cmd)
+	if err != nil && ctx.Err() != nil {
+		sig := interruptSignal(ctx)
+		reapGroup(cmd, sig, r.killDelay())
+		return &InterruptedError{Phase: ph.Name, Signal: sig}
+	}
+	if err != nil {
+		exitCode := 1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			exitCode = exitErr.ExitCode()
+		}
+		return &PhaseError{Phase: ph.Name, ExitCode: exitCode, Duration: time.Since(start), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package bild
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ExperimentsEnv names the environment variable that enables experiments for a
+// single invocation, as a comma-separated list of names.
+const ExperimentsEnv = "BILD_EXPERIMENTS"
+
+// Experiment is an unstable feature that users opt into via the config.
+type Experiment struct {
+	Name        string
+	Description string
+}
+
+// experiments lists every known experiment. Features graduate by being
+// removed from this list and enabled unconditionally.
+var experiments = []Experiment{
+	{Name: "daemon", Description: "Keep a background agent to serve runs with less startup latency"},
+}
+
+// Experiments returns all known experiments.
+func Experiments() []Experiment {
+	return append([]Experiment(nil), experiments...)
+}
+
+// LookupExperiment returns the known experiment with the given name.
+func LookupExperiment(name string) (Experiment, bool) {
+	for _, e := range experiments {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Experiment{}, false
+}
+
+// ExperimentEnabled reports whether the named experiment is enabled, either in
+// the config or via $BILD_EXPERIMENTS.
+func (c *Config) ExperimentEnabled(name string) bool {
+	for _, e := range strings.Split(os.Getenv(ExperimentsEnv), ",") {
+		if strings.TrimSpace(e) == name {
+			return true
+		}
+	}
+	if enabled, ok := c.Overrides.experiment(name); ok {
+		return enabled
+	}
+	return c.Experiments[name]
+}
+
+// SetExperiment enables or disables a known experiment in the config.
+// Experiments no longer known, having graduated or been dropped, can
+// still be disabled, which removes them from the config.
+func (c *Config) SetExperiment(name string, enabled bool) error {
+	if !enabled {
+		delete(c.Experiments, name)
+		return nil
+	}
+	if _, ok := LookupExperiment(name); !ok {
+		return fmt.Errorf("unknown experiment %s", name)
+	}
+	if c.Experiments == nil {
+		c.Experiments = make(map[string]bool)
+	}
+	c.Experiments[name] = true
+	return nil
+}
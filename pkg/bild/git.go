@@ -0,0 +1,446 @@
+package bild
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// NoGitEnv names the environment variable that, set to 1, has the effect
+// of NoGit.
+const NoGitEnv = "BILD_NO_GIT"
+
+// ErrNoGit is returned by the functions probing git repositories while
+// NoGit is set.
+var ErrNoGit = errors.New("git is disabled (--no-git)")
+
+// NoGit, if set, makes bild treat every directory as outside of a git
+// repository without running git at all, for file systems where git is
+// slow.
+var NoGit bool
+
+// RepoCache, if set, is a file in which RepoRoot remembers the roots it
+// found for later processes. Remembered roots are checked with a few stat
+// calls, which is much cheaper than starting git on network file systems.
+var RepoCache string
+
+var (
+	repoMu    sync.Mutex
+	repoRoots = make(map[string]repoRoot) // of this process, by directory
+)
+
+// repoRoot is the repository root found for a directory; Root is empty if
+// the directory is not in a repository.
+type repoRoot struct {
+	Root string `json:"root"`
+}
+
+// RepoRoot returns the top-level directory of the git repository containing dir.
+// An empty dir means the current working directory.
+func RepoRoot(dir string) (string, error) {
+	if NoGit {
+		Tracef("git", "not probing %s: git is disabled", traceDir(dir))
+		return "", ErrNoGit
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	repoMu.Lock()
+	defer repoMu.Unlock()
+	cached, ok := repoRoots[abs]
+	if !ok {
+		cached, ok = readRepoCache(abs)
+	}
+	if !ok {
+		cached = repoRoot{}
+		cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+		cmd.Dir = dir
+		output, err := cmd.Output()
+		if err != nil {
+			Tracef("git", "%s is not in a git repository: %v", traceDir(dir), err)
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				// Without git, there is nothing worth remembering.
+				return "", err
+			}
+		} else {
+			cached.Root = strings.TrimSpace(string(output))
+		}
+		writeRepoCache(abs, cached)
+	}
+	repoRoots[abs] = cached
+	if cached.Root == "" {
+		return "", fmt.Errorf("%s is not in a git repository", abs)
+	}
+	Tracef("git", "repository root of %s is %s", traceDir(dir), cached.Root)
+	return cached.Root, nil
+}
+
+// readRepoCache returns the root remembered in RepoCache for dir, if it is
+// still right: dir must be inside the root, with no repository in between,
+// and a remembered absence of a repository must have no .git above dir.
+func readRepoCache(dir string) (repoRoot, bool) {
+	// git's environment can point anywhere; don't second-guess it.
+	if RepoCache == "" || os.Getenv("GIT_DIR") != "" || os.Getenv("GIT_WORK_TREE") != "" {
+		return repoRoot{}, false
+	}
+	entries := loadRepoCache()
+	cached, ok := entries[dir]
+	if !ok {
+		return repoRoot{}, false
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		if d == cached.Root {
+			// The root must still be a repository.
+			if _, err := os.Stat(filepath.Join(d, ".git")); err != nil {
+				break
+			}
+			Tracef("git", "repository root of %s remembered in %s", dir, RepoCache)
+			return cached, true
+		}
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			break // a repository inside the remembered one, or a new one
+		}
+		if parent := filepath.Dir(d); parent == d {
+			if cached.Root == "" {
+				Tracef("git", "%s remembered as outside of any repository", dir)
+				return cached, true
+			}
+			break // dir moved out of the remembered root
+		}
+	}
+	Tracef("git", "remembered repository root of %s is stale", dir)
+	return repoRoot{}, false
+}
+
+// maxRepoCache bounds the number of directories in RepoCache.
+const maxRepoCache = 256
+
+// loadRepoCache reads RepoCache; a missing or malformed file is empty.
+func loadRepoCache() map[string]repoRoot {
+	entries := make(map[string]repoRoot)
+	if data, err := os.ReadFile(RepoCache); err == nil {
+		json.Unmarshal(data, &entries)
+	}
+	return entries
+}
+
+// writeRepoCache remembers root for dir in RepoCache. The cache is only a
+// shortcut, so failing to write it is not an error.
+func writeRepoCache(dir string, root repoRoot) {
+	if RepoCache == "" {
+		return
+	}
+	entries := loadRepoCache()
+	if len(entries) >= maxRepoCache {
+		entries = make(map[string]repoRoot)
+	}
+	entries[dir] = root
+	data, err := json.Marshal(entries)
+	if err == nil {
+		if err = ensureParent(RepoCache); err == nil {
+			err = writeFileAtomic(RepoCache, data)
+		}
+	}
+	if err != nil {
+		Tracef("git", "could not remember the repository root of %s: %v", dir, err)
+	}
+}
+
+// RepoName determines the repository name as the basename of RepoRoot.
+// Use Config.RepoProject to find the project of a repository, which also
+// considers its remote.
+func RepoName(dir string) (string, error) {
+	root, err := RepoRoot(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(root), nil
+}
+
+// RemoteURL returns the URL of the origin remote of the repository
+// containing dir, or "" if it has none.
+func RemoteURL(dir string) (string, error) {
+	if NoGit {
+		return "", ErrNoGit
+	}
+	cmd := exec.Command("git", "remote", "get-url", "origin")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 2 {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// NormalizeRemote returns a git remote URL in a form that is the same for
+// the SSH and HTTPS URLs of a repository, and ignores case, user names,
+// ports and a .git suffix: git@github.com:org/api.git,
+// ssh://git@github.com:22/org/api and https://github.com/Org/api all become
+// github.com/org/api. Paths of local repositories are only cleaned.
+func NormalizeRemote(remote string) string {
+	remote = strings.TrimSpace(remote)
+	var host, path string
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if i := strings.Index(remote, ":"); i > 0 && !strings.Contains(remote[:i], "/") {
+		// The scp-like syntax of SSH: [user@]host:path
+		host, path = remote[:i], remote[i+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	} else {
+		return filepath.Clean(strings.TrimPrefix(remote, "file://"))
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return strings.ToLower(host + "/" + path)
+}
+
+// MainWorktree returns the main working tree of the repository whose
+// linked worktree, added with git worktree add, contains dir, or "" if dir
+// is not in a linked worktree or the repository is bare.
+func MainWorktree(dir string) (string, error) {
+	if NoGit {
+		return "", ErrNoGit
+	}
+	cmd := exec.Command("git", "rev-parse", "--git-dir", "--git-common-dir")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	paths := strings.Fields(string(output))
+	if len(paths) != 2 {
+		return "", fmt.Errorf("git rev-parse: unexpected output %q", output)
+	}
+	for i, path := range paths {
+		if !filepath.IsAbs(path) {
+			paths[i] = filepath.Join(dir, path)
+		}
+	}
+	gitDir, commonDir := filepath.Clean(paths[0]), filepath.Clean(paths[1])
+	if gitDir == commonDir || filepath.Base(commonDir) != ".git" {
+		return "", nil
+	}
+	return filepath.Dir(commonDir), nil
+}
+
+// Superproject returns the working tree of the outermost repository that
+// the repository containing dir is a submodule of, or "" if it is not a
+// submodule.
+func Superproject(dir string) (string, error) {
+	if NoGit {
+		return "", ErrNoGit
+	}
+	super := ""
+	for {
+		cmd := exec.Command("git", "rev-parse", "--show-superproject-working-tree")
+		cmd.Dir = dir
+		output, err := cmd.Output()
+		if err != nil {
+			return "", err
+		}
+		parent := strings.TrimSpace(string(output))
+		if parent == "" {
+			return super, nil
+		}
+		super, dir = parent, parent
+	}
+}
+
+// global returns the outermost of c and its parents: the global
+// configuration if c is a local one layered over it.
+func (c *Config) global() *Config {
+	for c.Parent != nil {
+		c = c.Parent
+	}
+	return c
+}
+
+// ProjectRoot returns the root of the git repository containing dir, where
+// runs there start: that of the repository itself, or, if the global
+// configuration sets submodules to "parent", that of the outermost
+// superproject of a submodule.
+func (c *Config) ProjectRoot(dir string) (string, error) {
+	root, err := RepoRoot(dir)
+	if err != nil {
+		return "", err
+	}
+	switch submodules := c.global().Submodules; submodules {
+	case "", "own":
+		return root, nil
+	case "parent":
+		super, err := Superproject(root)
+		if err != nil || super == "" {
+			return root, err
+		}
+		Tracef("git", "%s is a submodule of %s, which it runs as", root, super)
+		return super, nil
+	default:
+		return "", fmt.Errorf("unknown submodules setting %q (want own or parent)", submodules)
+	}
+}
+
+// RepoProject returns the name of the project of c, or of its parents, that
+// the git repository containing dir is a clone of: the project whose
+// Remote is the repository's origin, or else the project named like the
+// origin's repository, or else the name of the repository's directory,
+// whether a project is named so or not. The repository is the one of
+// ProjectRoot, and, for a linked worktree, its main working tree unless
+// the global configuration sets worktrees to "own".
+func (c *Config) RepoProject(dir string) (string, error) {
+	root, err := c.ProjectRoot(dir)
+	if err != nil {
+		return "", err
+	}
+	switch worktrees := c.global().Worktrees; worktrees {
+	case "", "shared":
+		main, err := MainWorktree(root)
+		if err != nil {
+			return "", err
+		}
+		if main != "" {
+			Tracef("git", "%s is a worktree of %s, whose project it shares", root, main)
+			root = main
+		}
+	case "own":
+	default:
+		return "", fmt.Errorf("unknown worktrees setting %q (want shared or own)", worktrees)
+	}
+	dirName := filepath.Base(root)
+	remote, err := RemoteURL(root)
+	if err != nil || remote == "" {
+		Tracef("git", "no origin remote to find the project by: %v", err)
+		return dirName, nil
+	}
+	normalized := NormalizeRemote(remote)
+	var names []string
+	for cfg := c; cfg != nil; cfg = cfg.Parent {
+		names = append(names, cfg.ProjectNames()...)
+	}
+	for _, name := range names {
+		if proj, _, _ := c.lookupProject(name); proj.Remote != "" && NormalizeRemote(proj.Remote) == normalized {
+			Tracef("git", "project %s has the remote %s", name, remote)
+			return name, nil
+		}
+	}
+	if name := normalized[strings.LastIndex(normalized, "/")+1:]; name != dirName && c.hasProject(name) {
+		Tracef("git", "project %s is named like the repository of the remote %s", name, remote)
+		return name, nil
+	}
+	return dirName, nil
+}
+
+// HeadCommit returns the commit hash checked out in the repository containing dir.
+func HeadCommit(dir string) (string, error) {
+	if NoGit {
+		return "", ErrNoGit
+	}
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Branch returns the branch checked out in the repository containing dir,
+// or "" if HEAD is detached.
+func Branch(dir string) (string, error) {
+	if NoGit {
+		return "", ErrNoGit
+	}
+	cmd := exec.Command("git", "symbolic-ref", "--quiet", "--short", "HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Dirty reports whether the repository containing dir has uncommitted
+// changes or untracked files.
+func Dirty(dir string) (bool, error) {
+	if NoGit {
+		return false, ErrNoGit
+	}
+	cmd := exec.Command("git", "status", "--porcelain")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return false, err
+	}
+	return len(strings.TrimSpace(string(output))) > 0, nil
+}
+
+// CloneAt clones the repository at src (including submodules) into dest and
+// checks out commit. Only committed content ends up in dest.
+func CloneAt(src, dest, commit string) error {
+	steps := [][]string{
+		{"git", "clone", "--quiet", "--no-checkout", "--no-hardlinks", src, dest},
+		{"git", "-C", dest, "checkout", "--quiet", "--detach", commit},
+		{"git", "-C", dest, "submodule", "update", "--quiet", "--init", "--recursive"},
+	}
+	for _, args := range steps {
+		if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// AddWorktree checks out ref of the repository at repo into a new detached
+// worktree at dest, including submodules, and returns the commit checked out.
+func AddWorktree(repo, dest, ref string) (string, error) {
+	steps := [][]string{
+		{"git", "-C", repo, "worktree", "add", "--quiet", "--detach", dest, ref},
+		{"git", "-C", dest, "submodule", "update", "--quiet", "--init", "--recursive"},
+	}
+	for _, args := range steps {
+		if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("%s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+		}
+	}
+	return HeadCommit(dest)
+}
+
+// RemoveWorktree removes the worktree at dest from the repository at repo,
+// discarding anything written to it.
+func RemoveWorktree(repo, dest string) error {
+	output, err := exec.Command("git", "-C", repo, "worktree", "remove", "--force", dest).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git worktree remove: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Log returns `git log --oneline` of the commits reachable from until but
+// not from since, newest first, in the repository containing dir.
+func Log(dir, since, until string) ([]string, error) {
+	cmd := exec.Command("git", "log", "--oneline", "--no-decorate", since+".."+until)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("git log: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	text := strings.TrimSpace(string(output))
+	if text == "" {
+		return nil, nil
+	}
+	return strings.Split(text, "\n"), nil
+}
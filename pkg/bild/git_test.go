@@ -0,0 +1,92 @@
+package bild
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeRemote(t *testing.T) {
+	for _, remote := range []string{
+		"git@github.com:org/api.git",
+		"ssh://git@github.com:22/org/api",
+		"https://github.com/Org/api.git",
+		"https://user@github.com/org/api/",
+	} {
+		if got := NormalizeRemote(remote); got != "github.com/org/api" {
+			t.Errorf("NormalizeRemote(%q) = %q", remote, got)
+		}
+	}
+	if got := NormalizeRemote("/srv/git/api/"); got != "/srv/git/api" {
+		t.Errorf("local path: %q", got)
+	}
+}
+
+func TestRepoProject(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := filepath.Join(t.TempDir(), "api-fork")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = filepath.Dir(dir)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "-q", dir)
+
+	c := &Config{Projects: map[string]Project{"api": {}, "api-fork": {}, "service": {}}}
+	if name, err := c.RepoProject(dir); err != nil || name != "api-fork" {
+		t.Errorf("without a remote: %q, %v; want the directory's name", name, err)
+	}
+	git("-C", dir, "remote", "add", "origin", "git@example.com:org/api.git")
+	if name, _ := c.RepoProject(dir); name != "api" {
+		t.Errorf("with a remote: %q, want the project named like its repository", name)
+	}
+	c.Projects["service"] = Project{Remote: "https://example.com/org/api"}
+	if name, _ := c.RepoProject(dir); name != "service" {
+		t.Errorf("with a project of the remote: %q, want service", name)
+	}
+}
+
+func TestRepoProjectWorktreesAndSubmodules(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	base := t.TempDir()
+	git := func(dir string, args ...string) {
+		t.Helper()
+		args = append([]string{"-c", "user.name=bild", "-c", "user.email=bild@example.com", "-c", "protocol.file.allow=always"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	for _, name := range []string{"api", "lib"} {
+		git(base, "init", "-q", name)
+		git(filepath.Join(base, name), "commit", "-q", "--allow-empty", "-m", "init")
+	}
+	api := filepath.Join(base, "api")
+	git(api, "worktree", "add", "-q", filepath.Join(base, "api-feature"))
+	git(api, "submodule", "add", "-q", filepath.Join(base, "lib"), "lib")
+
+	c := &Config{Projects: map[string]Project{"api": {}, "lib": {}}}
+	tests := []struct {
+		worktrees, submodules string
+		dir, want             string
+	}{
+		{"", "", "api-feature", "api"},
+		{"own", "", "api-feature", "api-feature"},
+		{"", "", "api/lib", "lib"},
+		{"", "parent", "api/lib", "api"},
+	}
+	for _, tt := range tests {
+		c.Worktrees, c.Submodules = tt.worktrees, tt.submodules
+		if got, err := c.RepoProject(filepath.Join(base, tt.dir)); err != nil || got != tt.want {
+			t.Errorf("worktrees %q, submodules %q in %s: %q, %v; want %s", tt.worktrees, tt.submodules, tt.dir, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package bild
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// GitHookNames are the git hooks bild can run phases from, in the order
+// git runs them.
+var GitHookNames = []string{"pre-commit", "commit-msg", "post-commit", "post-checkout", "post-merge", "pre-rebase", "pre-push"}
+
+// gitHookMarker starts the line of a hook script written by InstallGitHook
+// that records what it runs.
+const gitHookMarker = "# bild-hook:"
+
+// GitHook is a hook script in the hooks directory of a repository.
+type GitHook struct {
+	Name string
+	Path string
+	// Project and Phases are what a hook installed by bild runs; Phases is
+	// empty if it runs the project's default phases. Foreign is set for a
+	// hook bild did not install.
+	Project string
+	Phases  []string
+	Foreign bool
+}
+
+// GitHooksDir returns the directory git runs the hooks of the repository
+// containing dir from, honoring core.hooksPath and shared by worktrees.
+func GitHooksDir(dir string) (string, error) {
+	if NoGit {
+		return "", ErrNoGit
+	}
+	cmd := exec.Command("git", "rev-parse", "--git-path", "hooks")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository: %w", err)
+	}
+	hooks := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(hooks) {
+		hooks = filepath.Join(dir, hooks)
+	}
+	return filepath.Abs(hooks)
+}
+
+// checkGitHook returns an error if name is not one of GitHookNames.
+func checkGitHook(name string) error {
+	if !slices.Contains(GitHookNames, name) {
+		return fmt.Errorf("unknown git hook %q (want one of %s)", name, strings.Join(GitHookNames, ", "))
+	}
+	return nil
+}
+
+// gitHookScript returns the script of a hook running phases of project
+// with bild, or with the bild binary at fallback if there is none
+// on the PATH.
+func gitHookScript(project string, phases []string, fallback string) []byte {
+	var b bytes.Buffer
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString("# Installed by bild hooks install; bild hooks uninstall removes it.\n")
+	fmt.Fprintf(&b, "%s project=%s phases=%s\n", gitHookMarker, project, strings.Join(phases, ","))
+	fmt.Fprintf(&b, "bild=$(command -v bild || echo %s)\n", quoteShell(fallback))
+	args := []string{quoteShell(project)}
+	if len(phases) > 0 {
+		args = append(args, quoteShell(strings.Join(phases, ",")))
+	}
+	// Git passes some hooks input on stdin, which is not for the phases.
+	fmt.Fprintf(&b, "exec \"$bild\" run %s </dev/null\n", strings.Join(args, " "))
+	return b.Bytes()
+}
+
+// InstallGitHook writes the hook name of the repository containing dir,
+// running phases of project, or its default phases if there are none, and
+// returns its path. A hook bild did not install is an error unless force
+// is set; it is then kept next to the new one with the suffix .orig, which
+// UninstallGitHook puts back.
+func InstallGitHook(dir, name, project string, phases []string, force bool) (string, error) {
+	if err := checkGitHook(name); err != nil {
+		return "", err
+	}
+	hooksDir, err := GitHooksDir(dir)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(hooksDir, name)
+	existing, err := readGitHook(name, path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if existing != nil && existing.Foreign {
+		if !force {
+			return "", fmt.Errorf("%s exists and was not installed by bild; use --force to replace it (it is kept as %s.orig)", path, name)
+		}
+		if err := os.Rename(path, path+".orig"); err != nil {
+			return "", err
+		}
+	}
+	self, err := os.Executable()
+	if err != nil {
+		self = "bild"
+	}
+	if err := ensureDir(hooksDir); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(path, gitHookScript(project, phases, self)); err != nil {
+		return "", err
+	}
+	Tracef("git", "installed the %s hook at %s", name, path)
+	return path, os.Chmod(path, 0o755)
+}
+
+// UninstallGitHook removes the hook name of the repository containing dir
+// if bild installed it, putting back the hook it replaced, if any. It
+// reports whether there was a hook of bild to remove.
+func UninstallGitHook(dir, name string) (bool, error) {
+	if err := checkGitHook(name); err != nil {
+		return false, err
+	}
+	hooksDir, err := GitHooksDir(dir)
+	if err != nil {
+		return false, err
+	}
+	path := filepath.Join(hooksDir, name)
+	hook, err := readGitHook(name, path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && hook.Foreign) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := os.Remove(path); err != nil {
+		return false, err
+	}
+	if exists(path + ".orig") {
+		Tracef("git", "putting back the %s hook bild replaced", name)
+		return true, os.Rename(path+".orig", path)
+	}
+	return true, nil
+}
+
+// GitHooks returns the hooks of GitHookNames the repository containing dir
+// has, installed by bild or not.
+func GitHooks(dir string) ([]GitHook, error) {
+	hooksDir, err := GitHooksDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var hooks []GitHook
+	for _, name := range GitHookNames {
+		hook, err := readGitHook(name, filepath.Join(hooksDir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, *hook)
+	}
+	return hooks, nil
+}
+
+// readGitHook reads the hook name at path.
+func readGitHook(name, path string) (*GitHook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	hook := &GitHook{Name: name, Path: path, Foreign: true}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), gitHookMarker)
+		if !ok {
+			continue
+		}
+		hook.Foreign = false
+		for _, field := range strings.Fields(line) {
+			key, value, _ := strings.Cut(field, "=")
+			switch key {
+			case "project":
+				hook.Project = value
+			case "phases":
+				if value != "" {
+					hook.Phases = strings.Split(value, ",")
+				}
+			}
+		}
+		break
+	}
+	return hook, nil
+}
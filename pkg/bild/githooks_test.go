@@ -0,0 +1,55 @@
+package bild
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestInstallGitHook(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	hooksDir, err := GitHooksDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign := []byte("#!/bin/sh\nexit 0\n")
+	path := filepath.Join(hooksDir, "pre-push")
+	if err := os.WriteFile(path, foreign, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := InstallGitHook(dir, "pre-push", "api", []string{"test"}, false); err == nil {
+		t.Fatal("replaced a hook bild did not install without --force")
+	}
+	if _, err := InstallGitHook(dir, "pre-push", "api", []string{"lint", "test"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InstallGitHook(dir, "pre-commit", "api", nil, false); err != nil {
+		t.Fatal(err)
+	}
+	hooks, err := GitHooks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 2 || hooks[0].Name != "pre-commit" || hooks[0].Phases != nil || hooks[1].Project != "api" || !slices.Equal(hooks[1].Phases, []string{"lint", "test"}) {
+		t.Errorf("hooks %+v, want pre-commit with the default phases and pre-push with lint and test of api", hooks)
+	}
+
+	if ok, err := UninstallGitHook(dir, "pre-push"); err != nil || !ok {
+		t.Fatalf("uninstall: %v, %v", ok, err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != string(foreign) {
+		t.Errorf("after uninstall the hook is %q, %v; want the one it replaced", data, err)
+	}
+	if ok, _ := UninstallGitHook(dir, "pre-push"); ok {
+		t.Error("removed a hook bild did not install")
+	}
+}
@@ -0,0 +1,263 @@
+package bild
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryEntry records the outcome of one phase execution.
+type HistoryEntry struct {
+	RunID    string        `json:"run_id"`
+	Project  string        `json:"project"`
+	Phase    string        `json:"phase"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	ExitCode int           `json:"exit_code"`
+	Commit   string        `json:"commit,omitempty"`
+	// Since is the commit of the previous successful run of the phase, so
+	// that Since..Commit is what this run built anew.
+	Since string `json:"since,omitempty"`
+	// Command is set for ad-hoc commands run outside of any phase.
+	Command string `json:"command,omitempty"`
+	// User is the name of whoever started the run.
+	User string `json:"user,omitempty"`
+	// Pipeline is set for the phases run by a pipeline, which share its run
+	// ID, and for the entry recording the run of the pipeline as a whole,
+	// which has no project or phase.
+	Pipeline string `json:"pipeline,omitempty"`
+	// Step names the step of the phase that failed, if it has a name.
+	Step string `json:"step,omitempty"`
+	// Tests sums up the test reports of the phase, if it has any.
+	Tests *TestReport `json:"tests,omitempty"`
+	// Coverage is the percentage the coverage gate of the phase measured.
+	Coverage *float64 `json:"coverage,omitempty"`
+}
+
+// AdHoc reports whether the entry records an ad-hoc command rather than a phase.
+func (e HistoryEntry) AdHoc() bool {
+	return e.Command != ""
+}
+
+// lastRun returns the entries of the last run of phases recorded in
+// entries, oldest first.
+func lastRun(entries []HistoryEntry) []HistoryEntry {
+	var runID string
+	var run []HistoryEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.AdHoc() || e.PipelineRun() {
+			continue
+		}
+		if runID == "" {
+			runID = e.RunID
+		}
+		if e.RunID == runID {
+			run = append(run, e)
+		}
+	}
+	slices.Reverse(run)
+	return run
+}
+
+// LastFailure returns the entry of the phase that failed in the last run of
+// phases recorded in entries, oldest first, or nil if that run succeeded.
+func LastFailure(entries []HistoryEntry) *HistoryEntry {
+	failed := LastFailures(entries)
+	if len(failed) == 0 {
+		return nil
+	}
+	return &failed[len(failed)-1]
+}
+
+// LastFailures returns the entries of the phases, and variants of matrix
+// phases, that failed in the last run of phases recorded in entries.
+func LastFailures(entries []HistoryEntry) []HistoryEntry {
+	var failed []HistoryEntry
+	for _, e := range lastRun(entries) {
+		if !e.Succeeded() {
+			failed = append(failed, e)
+		}
+	}
+	return failed
+}
+
+// Retry narrows p to the phases and matrix variants named in failed, as
+// the history records them, and returns the names of the phases to run, in
+// the order their needs call for.
+func (p *Project) Retry(failed []string) ([]string, error) {
+	phases, err := OrderByNeeds(p.Phases)
+	if err != nil {
+		return nil, err
+	}
+	p.Phases = phases
+	variants := make(map[*Phase][]string)
+	for _, name := range failed {
+		phase, _, isVariant := strings.Cut(name, "[")
+		ph, err := p.Phase(phase)
+		if err != nil {
+			return nil, err
+		}
+		if !isVariant || len(ph.Matrix) == 0 {
+			variants[ph] = nil
+		} else if list, ok := variants[ph]; !ok || list != nil {
+			variants[ph] = append(list, name)
+		}
+	}
+	var names []string
+	for i := range p.Phases {
+		ph := &p.Phases[i]
+		only, ok := variants[ph]
+		if !ok {
+			continue
+		}
+		names = append(names, ph.Name)
+		if only == nil {
+			continue
+		}
+		// A matrix changed since runs the variants it has now.
+		ph.OnlyVariants = only
+		if len(ph.Variants()) == 0 {
+			ph.OnlyVariants = nil
+		}
+		Tracef("run", "retrying variants %s of phase %s", strings.Join(ph.OnlyVariants, ", "), ph.Name)
+	}
+	return names, nil
+}
+
+// PipelineRun reports whether the entry records the run of a pipeline as a
+// whole rather than a phase.
+func (e HistoryEntry) PipelineRun() bool {
+	return e.Pipeline != "" && e.Phase == "" && e.Command == ""
+}
+
+// Succeeded reports whether the phase completed successfully.
+func (e HistoryEntry) Succeeded() bool {
+	return e.ExitCode == 0
+}
+
+// NewRunID returns an identifier grouping the phases executed by one invocation.
+func NewRunID() string {
+	return time.Now().UTC().Format("20060102T150405.000000000Z")
+}
+
+// AppendHistory appends entry to the history file at path.
+func AppendHistory(path string, entry HistoryEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := ensureParent(path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadHistory returns all entries of the history file at path, oldest first.
+// A missing file yields no entries; malformed lines are skipped.
+func ReadHistory(path string) ([]HistoryEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// PhaseStats summarizes the recorded executions of one project phase.
+type PhaseStats struct {
+	Project  string
+	Phase    string
+	Runs     int
+	Failures int
+	Mean     time.Duration
+	P50      time.Duration
+	P90      time.Duration
+	Max      time.Duration
+}
+
+// ComputeStats aggregates entries per project and phase, sorted by project
+// and phase name. Durations are computed over successful runs only; ad-hoc
+// commands and the runs of whole pipelines are ignored.
+func ComputeStats(entries []HistoryEntry) []PhaseStats {
+	type key struct{ project, phase string }
+	durations := make(map[key][]time.Duration)
+	stats := make(map[key]*PhaseStats)
+	for _, e := range entries {
+		if e.AdHoc() || e.PipelineRun() {
+			continue
+		}
+		k := key{e.Project, e.Phase}
+		s, ok := stats[k]
+		if !ok {
+			s = &PhaseStats{Project: e.Project, Phase: e.Phase}
+			stats[k] = s
+		}
+		s.Runs++
+		if !e.Succeeded() {
+			s.Failures++
+			continue
+		}
+		durations[k] = append(durations[k], e.Duration)
+	}
+
+	result := make([]PhaseStats, 0, len(stats))
+	for k, s := range stats {
+		ds := durations[k]
+		if len(ds) > 0 {
+			sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+			var total time.Duration
+			for _, d := range ds {
+				total += d
+			}
+			s.Mean = total / time.Duration(len(ds))
+			s.P50 = percentile(ds, 50)
+			s.P90 = percentile(ds, 90)
+			s.Max = ds[len(ds)-1]
+		}
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Project != result[j].Project {
+			return result[i].Project < result[j].Project
+		}
+		return result[i].Phase < result[j].Phase
+	})
+	return result
+}
+
+// percentile returns the nearest-rank percentile p of the sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
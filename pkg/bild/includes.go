@@ -0,0 +1,213 @@
+package bild
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// includedFile is what a file listed in the includes of a configuration
+// adds to it. Like the configuration, it may be JSON or TOML.
+type includedFile struct {
+	Projects  map[string]Project  `json:"projects,omitempty"`
+	Pipelines map[string]Pipeline `json:"pipelines,omitempty"`
+}
+
+// includes records which files the projects and pipelines of a
+// configuration were included from, so that saving writes them back there.
+type includes struct {
+	files     []string // in the order they were merged
+	sums      map[string][sha256.Size]byte
+	projects  map[string]string // project names to files
+	pipelines map[string]string
+}
+
+// IncludeFiles returns the files the patterns of c.Includes match, relative
+// to dir unless absolute or starting with ~/: the files of each pattern in
+// the order of their names, the patterns in the order listed, each file
+// once. A pattern without wildcards must name an existing file.
+func (c *Config) IncludeFiles(dir string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, pattern := range c.Includes {
+		if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			pattern = filepath.Join(home, rest)
+		} else if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("includes: %v", err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, `*?[\`) {
+			return nil, fmt.Errorf("includes: %s does not exist", pattern)
+		}
+		for _, file := range matches {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+// loadIncludes adds the projects and pipelines of the files included by c,
+// as found by IncludeFiles, to c. source names the configuration itself in
+// errors. A project or pipeline defined twice is an *IncludeConflictError.
+func (c *Config) loadIncludes(dir, source string) error {
+	files, err := c.IncludeFiles(dir)
+	if err != nil || len(files) == 0 {
+		return err
+	}
+	inc := &includes{
+		sums:      make(map[string][sha256.Size]byte, len(files)),
+		projects:  make(map[string]string),
+		pipelines: make(map[string]string),
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("includes: %v", err)
+		}
+		inc.files = append(inc.files, file)
+		inc.sums[file] = sha256.Sum256(data)
+		var included includedFile
+		if data, err = toJSON(data, ConfigFormat(file)); err == nil {
+			err = json.Unmarshal(data, &included)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse included %s: %v", file, err)
+		}
+		for name, proj := range included.Projects {
+			if _, ok := c.Projects[name]; ok {
+				return &IncludeConflictError{Kind: "project", Name: name, Files: [2]string{origin(inc.projects, name, source), file}}
+			}
+			c.Projects[name] = proj
+			inc.projects[name] = file
+		}
+		for name, pl := range included.Pipelines {
+			if _, ok := c.Pipelines[name]; ok {
+				return &IncludeConflictError{Kind: "pipeline", Name: name, Files: [2]string{origin(inc.pipelines, name, source), file}}
+			}
+			if c.Pipelines == nil {
+				c.Pipelines = make(map[string]Pipeline)
+			}
+			c.Pipelines[name] = pl
+			inc.pipelines[name] = file
+		}
+		Tracef("config", "included %s: %d projects, %d pipelines", file, len(included.Projects), len(included.Pipelines))
+	}
+	c.included = inc
+	return nil
+}
+
+// origin returns the file files maps name to, or source if none.
+func origin(files map[string]string, name, source string) string {
+	if file, ok := files[name]; ok {
+		return file
+	}
+	return source
+}
+
+// IncludedFrom returns the included file project name was read from, or ""
+// if it is defined in the configuration itself.
+func (c *Config) IncludedFrom(name string) string {
+	if c.included == nil {
+		return ""
+	}
+	return c.included.projects[name]
+}
+
+// withoutIncluded returns a copy of c without the projects and pipelines
+// read from included files, as the configuration file itself holds it.
+func (c *Config) withoutIncluded() *Config {
+	own := *c
+	if c.included == nil {
+		return &own
+	}
+	own.Projects = make(map[string]Project, len(c.Projects))
+	for name, proj := range c.Projects {
+		if _, ok := c.included.projects[name]; !ok {
+			own.Projects[name] = proj
+		}
+	}
+	if c.Pipelines != nil {
+		own.Pipelines = make(map[string]Pipeline, len(c.Pipelines))
+		for name, pl := range c.Pipelines {
+			if _, ok := c.included.pipelines[name]; !ok {
+				own.Pipelines[name] = pl
+			}
+		}
+	}
+	return &own
+}
+
+// saveIncludes writes the projects and pipelines of c read from included
+// files back to those that changed: edited, renamed or removed ones.
+func (c *Config) saveIncludes() error {
+	if c.included == nil {
+		return nil
+	}
+	for _, file := range c.included.files {
+		var included includedFile
+		for name, f := range c.included.projects {
+			if proj, ok := c.Projects[name]; ok && f == file {
+				if included.Projects == nil {
+					included.Projects = make(map[string]Project)
+				}
+				included.Projects[name] = proj
+			}
+		}
+		for name, f := range c.included.pipelines {
+			if pl, ok := c.Pipelines[name]; ok && f == file {
+				if included.Pipelines == nil {
+					included.Pipelines = make(map[string]Pipeline)
+				}
+				included.Pipelines[name] = pl
+			}
+		}
+		data, err := encodeConfig(&included, ConfigFormat(file))
+		if err != nil {
+			return err
+		}
+		// A file is left alone unless what it holds changed, keeping its
+		// formatting.
+		current, err := encodeIncluded(file)
+		if err == nil && string(current) == string(data) {
+			continue
+		}
+		if sum, err := os.ReadFile(file); err == nil && sha256.Sum256(sum) != c.included.sums[file] {
+			return fmt.Errorf("%s: %w", file, ErrConfigChanged)
+		}
+		if err := writeFileAtomic(file, data); err != nil {
+			return err
+		}
+		c.included.sums[file] = sha256.Sum256(data)
+		Tracef("config", "saved included %s", file)
+	}
+	return nil
+}
+
+// encodeIncluded returns the included file as saveIncludes would write it.
+func encodeIncluded(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = toJSON(data, ConfigFormat(file)); err != nil {
+		return nil, err
+	}
+	var included includedFile
+	if err := json.Unmarshal(data, &included); err != nil {
+		return nil, err
+	}
+	return encodeConfig(&included, ConfigFormat(file))
+}
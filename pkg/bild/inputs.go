@@ -0,0 +1,229 @@
+package bild
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// inputsFile is the state file holding the input hashes of phases.
+const inputsFile = "inputs.json"
+
+// InputsRun records the inputs of the last successful run of a phase.
+type InputsRun struct {
+	Hash string    `json:"hash"`
+	Time time.Time `json:"time"`
+}
+
+// Inputs returns the inputs of the last successful run of each phase with
+// Inputs, by phase name.
+func (s *ProjectState) Inputs() (map[string]InputsRun, error) {
+	runs := make(map[string]InputsRun)
+	if _, err := s.ReadJSON(inputsFile, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// RecordInputs remembers run as the inputs of the last successful run of phase.
+func (s *ProjectState) RecordInputs(phase string, run InputsRun) error {
+	unlock, err := s.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	runs, err := s.Inputs()
+	if err != nil {
+		return err
+	}
+	runs[phase] = run
+	return s.WriteJSON(inputsFile, runs)
+}
+
+// InputHash returns a hash of what a run of ph in dir depends on: the files
+// its Inputs match, by name and content, and its definition along with the
+// vars, env and profile of proj, so that changing a command reruns it too.
+func InputHash(proj *Project, ph *Phase, dir string) (string, error) {
+	files, err := MatchFiles(dir, ph.Inputs)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	definition, err := json.Marshal(struct {
+		Phase   *Phase
+		Vars    map[string]string
+		Env     map[string]string
+		Profile string
+	}{ph, proj.Vars, proj.Env, proj.Profile})
+	if err != nil {
+		return "", err
+	}
+	h.Write(definition)
+	for _, file := range files {
+		f, err := os.Open(filepath.Join(dir, file))
+		if err != nil {
+			return "", err
+		}
+		content := sha256.New()
+		_, err = io.Copy(content, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "\x00%s\x00%x", filepath.ToSlash(file), content.Sum(nil))
+	}
+	Tracef("inputs", "phase %s: %d input files", ph.Name, len(files))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkInputs returns the hash of the inputs of ph, if it has any and r
+// remembers them, and whether the phase is up to date: its inputs are those
+// of its last successful run, its outputs exist and r.Force is not set.
+// Inputs that can't be read make the phase run, as if they had changed.
+func (r *Runner) checkInputs(proj *Project, ph *Phase) (string, bool) {
+	if r.State == nil || len(ph.Inputs) == 0 {
+		return "", false
+	}
+	hash, err := InputHash(proj, ph, r.Dir)
+	if err != nil {
+		fmt.Fprintf(r.Stderr, "Warning: could not hash the inputs of phase %s: %v\n", ph.Name, err)
+		return "", false
+	}
+	if r.Force {
+		return hash, false
+	}
+	runs, err := r.State.Inputs()
+	if err != nil {
+		fmt.Fprintf(r.Stderr, "Warning: could not read the inputs of earlier runs: %v\n", err)
+		return hash, false
+	}
+	if runs[ph.Name].Hash != hash {
+		return hash, false
+	}
+	exist, err := outputsExist(ph, r.Dir)
+	if err != nil {
+		Tracef("inputs", "phase %s: %v", ph.Name, err)
+	}
+	return hash, exist
+}
+
+// recordInputs remembers hash as the inputs of the last successful run of ph.
+func (r *Runner) recordInputs(ph *Phase, hash string) {
+	if err := r.State.RecordInputs(ph.Name, InputsRun{Hash: hash, Time: time.Now()}); err != nil {
+		fmt.Fprintf(r.Stderr, "Warning: could not record the inputs of phase %s: %v\n", ph.Name, err)
+	}
+}
+
+// outputsExist reports whether every pattern of ph.Outputs matches a file
+// in dir.
+func outputsExist(ph *Phase, dir string) (bool, error) {
+	for _, pattern := range ph.Outputs {
+		files, err := MatchFiles(dir, []string{pattern})
+		if err != nil || len(files) == 0 {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// MatchFiles returns the files below dir that patterns match, relative to
+// dir and sorted. Patterns use the syntax of path.Match, relative to dir and
+// with slashes, plus ** matching any number of directories, as in
+// "src/**/*.c". A directory matched stands for all the files below it.
+// .git directories are left out. An empty dir is the current directory.
+func MatchFiles(dir string, patterns []string) ([]string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		pattern = path.Clean(filepath.ToSlash(pattern))
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		parts := strings.Split(pattern, "/")
+		// Walk from the directory before the first wildcard.
+		base := 0
+		for base < len(parts)-1 && !strings.ContainsAny(parts[base], `*?[\`) {
+			base++
+		}
+		root := filepath.Join(dir, filepath.FromSlash(path.Join(parts[:base]...)))
+		err := filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && file == root {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() && d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			rel, err := filepath.Rel(dir, file)
+			if err != nil {
+				return err
+			}
+			if !matchPath(parts, strings.Split(filepath.ToSlash(rel), "/")) {
+				return nil
+			}
+			if !d.IsDir() {
+				seen[rel] = true
+				return nil
+			}
+			return filepath.WalkDir(file, func(file string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() && d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				if !d.IsDir() {
+					rel, err := filepath.Rel(dir, file)
+					if err != nil {
+						return err
+					}
+					seen[rel] = true
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	files := make([]string, 0, len(seen))
+	for file := range seen {
+		files = append(files, file)
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// matchPath reports whether the path with the elements name matches the
+// pattern with the elements pattern, where ** matches any number of them.
+func matchPath(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchPath(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], name[0])
+	return ok && matchPath(pattern[1:], name[1:])
+}
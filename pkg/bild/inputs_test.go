@@ -0,0 +1,82 @@
+package bild
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"main.c", "src/a.c", "src/lib/b.c", "src/lib/b.h", "docs/x.md", ".git/HEAD"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(file)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := MatchFiles(dir, []string{"**/*.c", "docs", "missing/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"docs/x.md", "main.c", "src/a.c", "src/lib/b.c"}
+	if !slices.Equal(files, want) {
+		t.Errorf("MatchFiles = %q, want %q", files, want)
+	}
+}
+
+func TestRunSkipsUpToDatePhase(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	proj := &Project{Name: "app", Phases: []Phase{{
+		Name:     "build",
+		Commands: []string{"echo ran; cp in.txt out.txt"},
+		Inputs:   []string{"in.txt"},
+		Outputs:  []string{"out.txt"},
+	}}}
+	var stdout bytes.Buffer
+	r := &Runner{Stdout: &stdout, Stderr: &stdout, Dir: dir, State: Dirs{State: t.TempDir()}.ProjectState("app")}
+	run := func() int {
+		t.Helper()
+		stdout.Reset()
+		if err := r.Run(context.Background(), proj, "build"); err != nil {
+			t.Fatal(err)
+		}
+		return strings.Count(stdout.String(), "ran\n")
+	}
+
+	if n := run(); n != 1 {
+		t.Errorf("first run ran the phase %d times", n)
+	}
+	if n := run(); n != 0 {
+		t.Error("unchanged inputs: the phase ran again")
+	}
+	r.Force = true
+	if n := run(); n != 1 {
+		t.Error("Force: the phase was skipped")
+	}
+	r.Force = false
+	if err := os.Remove(filepath.Join(dir, "out.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if n := run(); n != 1 {
+		t.Error("missing output: the phase was skipped")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if n := run(); n != 1 {
+		t.Error("changed input: the phase was skipped")
+	}
+	proj.Phases[0].Commands = []string{"echo ran; cp in.txt out.txt; true"}
+	if n := run(); n != 1 {
+		t.Error("changed commands: the phase was skipped")
+	}
+}
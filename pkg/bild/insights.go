@@ -0,0 +1,166 @@
+package bild
+
+import (
+	"sort"
+	"time"
+)
+
+// Insights summarizes someone's own runs from the local history: which
+// phases they run most, how long they wait on builds each week, and which
+// phases fail most often.
+type Insights struct {
+	Since time.Time
+	Runs  int // invocations, each counted once however many phases it ran
+	// Waited is the wall time of all runs.
+	Waited time.Duration
+	// TopPhases are the phases run most often, most first.
+	TopPhases []PhaseUsage
+	// Weeks lists the time waited per week, oldest first, including weeks
+	// without runs.
+	Weeks []WeekUsage
+	// Hotspots are the phases that failed most often, most first.
+	Hotspots []PhaseUsage
+}
+
+// PhaseUsage counts the runs of one project phase.
+type PhaseUsage struct {
+	Project  string
+	Phase    string
+	Runs     int
+	Failures int
+	Total    time.Duration
+}
+
+// FailureRate returns the fraction of the runs that failed.
+func (u PhaseUsage) FailureRate() float64 {
+	if u.Runs == 0 {
+		return 0
+	}
+	return float64(u.Failures) / float64(u.Runs)
+}
+
+// WeekUsage is the time spent waiting on runs in the week starting Start, a
+// Monday at midnight local time.
+type WeekUsage struct {
+	Start  time.Time
+	Runs   int
+	Waited time.Duration
+}
+
+// ComputeInsights analyzes the entries started at or after since by user, or
+// by anyone if user is empty; entries recorded before runs were attributed
+// count as user's. top limits the phases listed (0 for all). The time waited
+// on a run is its wall time, from its first phase's start to its last
+// phase's end, so that phases run concurrently count once.
+func ComputeInsights(entries []HistoryEntry, user string, since time.Time, top int) Insights {
+	type key struct{ project, phase string }
+	type span struct{ start, end time.Time }
+	usage := make(map[key]*PhaseUsage)
+	runs := make(map[string]*span)
+	for _, e := range entries {
+		if e.Start.Before(since) || (user != "" && e.User != "" && e.User != user) {
+			continue
+		}
+		end := e.Start.Add(e.Duration)
+		if s, ok := runs[e.RunID]; !ok {
+			runs[e.RunID] = &span{e.Start, end}
+		} else {
+			if e.Start.Before(s.start) {
+				s.start = e.Start
+			}
+			if end.After(s.end) {
+				s.end = end
+			}
+		}
+		if e.AdHoc() || e.PipelineRun() {
+			continue
+		}
+		k := key{e.Project, e.Phase}
+		u, ok := usage[k]
+		if !ok {
+			u = &PhaseUsage{Project: e.Project, Phase: e.Phase}
+			usage[k] = u
+		}
+		u.Runs++
+		u.Total += e.Duration
+		if !e.Succeeded() {
+			u.Failures++
+		}
+	}
+
+	in := Insights{Since: since, Runs: len(runs)}
+	weeks := make(map[time.Time]*WeekUsage)
+	for _, s := range runs {
+		in.Waited += s.end.Sub(s.start)
+		start := weekStart(s.start)
+		w, ok := weeks[start]
+		if !ok {
+			w = &WeekUsage{Start: start}
+			weeks[start] = w
+		}
+		w.Runs++
+		w.Waited += s.end.Sub(s.start)
+	}
+	if len(weeks) > 0 {
+		first := weekStart(since)
+		if since.IsZero() {
+			for start := range weeks {
+				if first.IsZero() || start.Before(first) {
+					first = start
+				}
+			}
+		}
+		last := weekStart(time.Now())
+		for start := first; !start.After(last); start = start.AddDate(0, 0, 7) {
+			if w, ok := weeks[start]; ok {
+				in.Weeks = append(in.Weeks, *w)
+			} else {
+				in.Weeks = append(in.Weeks, WeekUsage{Start: start})
+			}
+		}
+	}
+
+	for _, u := range usage {
+		in.TopPhases = append(in.TopPhases, *u)
+		if u.Failures > 0 {
+			in.Hotspots = append(in.Hotspots, *u)
+		}
+	}
+	sort.Slice(in.TopPhases, func(i, j int) bool {
+		a, b := in.TopPhases[i], in.TopPhases[j]
+		if a.Runs != b.Runs {
+			return a.Runs > b.Runs
+		}
+		return usageLess(a, b)
+	})
+	sort.Slice(in.Hotspots, func(i, j int) bool {
+		a, b := in.Hotspots[i], in.Hotspots[j]
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+		if a.FailureRate() != b.FailureRate() {
+			return a.FailureRate() > b.FailureRate()
+		}
+		return usageLess(a, b)
+	})
+	if top > 0 {
+		in.TopPhases = in.TopPhases[:min(top, len(in.TopPhases))]
+		in.Hotspots = in.Hotspots[:min(top, len(in.Hotspots))]
+	}
+	return in
+}
+
+// usageLess orders phase usages by project and phase name.
+func usageLess(a, b PhaseUsage) bool {
+	if a.Project != b.Project {
+		return a.Project < b.Project
+	}
+	return a.Phase < b.Phase
+}
+
+// weekStart returns the Monday at midnight, local time, starting the week of t.
+func weekStart(t time.Time) time.Time {
+	t = t.Local()
+	days := (int(t.Weekday()) + 6) % 7 // days since Monday
+	return time.Date(t.Year(), t.Month(), t.Day()-days, 0, 0, 0, 0, time.Local)
+}
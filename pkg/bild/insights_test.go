@@ -0,0 +1,36 @@
+package bild
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeInsights(t *testing.T) {
+	monday := weekStart(time.Now()).AddDate(0, 0, -7)
+	at := func(days int, hour int) time.Time {
+		return monday.AddDate(0, 0, days).Add(time.Duration(hour) * time.Hour)
+	}
+	entries := []HistoryEntry{
+		// Two phases of one run, the second overlapping the first.
+		{RunID: "1", Project: "api", Phase: "build", Start: at(0, 9), Duration: 10 * time.Minute, User: "ann"},
+		{RunID: "1", Project: "api", Phase: "test", Start: at(0, 9), Duration: 20 * time.Minute, ExitCode: 1, User: "ann"},
+		{RunID: "2", Project: "api", Phase: "build", Start: at(8, 9), Duration: 5 * time.Minute, User: "ann"},
+		{RunID: "3", Project: "api", Phase: "build", Start: at(8, 10), Duration: time.Hour, User: "bob"},
+		// Too old.
+		{RunID: "0", Project: "api", Phase: "build", Start: at(-30, 0), Duration: time.Hour, User: "ann"},
+	}
+	in := ComputeInsights(entries, "ann", monday, 0)
+
+	if in.Runs != 2 || in.Waited != 25*time.Minute {
+		t.Errorf("Runs, Waited = %d, %s; want 2, 25m", in.Runs, in.Waited)
+	}
+	if len(in.TopPhases) != 2 || in.TopPhases[0].Phase != "build" || in.TopPhases[0].Runs != 2 {
+		t.Errorf("TopPhases = %+v, want build twice first", in.TopPhases)
+	}
+	if len(in.Hotspots) != 1 || in.Hotspots[0].Phase != "test" || in.Hotspots[0].FailureRate() != 1 {
+		t.Errorf("Hotspots = %+v, want test always failing", in.Hotspots)
+	}
+	if len(in.Weeks) != 2 || in.Weeks[0].Waited != 20*time.Minute || in.Weeks[1].Waited != 5*time.Minute {
+		t.Errorf("Weeks = %+v, want 20m and 5m", in.Weeks)
+	}
+}
@@ -0,0 +1,242 @@
+package bild
+
+import (
+	"errors"
+	"iter"
+	"maps"
+	"slices"
+)
+
+// The functions in this file let tooling such as documentation generators
+// and auditors read configurations without running anything. They yield
+// copies in a stable order, so that what they are given can't change the
+// configuration and their output doesn't depend on map iteration.
+
+// Command is one command of a phase, or of a project's hooks.
+type Command struct {
+	// Hook is "pre", "on_success", "on_failure" or "always" for commands of
+	// hooks, and empty for the commands of the phase itself.
+	Hook string
+	// Index is the command's position among those of its hook or phase.
+	Index int
+	Text  string
+	// Step and Description are the name and description of a command of
+	// the phase written as a step.
+	Step        string
+	Description string
+}
+
+// Projects yields the projects of c sorted by name, resolved like
+// Config.Project does. Projects that fail to resolve, e.g. because they
+// extend a missing project, are yielded as nil with the error.
+func Projects(c *Config) iter.Seq2[*Project, error] {
+	return func(yield func(*Project, error) bool) {
+		for _, name := range c.ProjectNames() {
+			proj, err := c.Project(name)
+			if err == nil {
+				proj = proj.clone()
+			}
+			if !yield(proj, err) {
+				return
+			}
+		}
+	}
+}
+
+// Phases yields the phases of p in the order they are declared.
+func Phases(p *Project) iter.Seq[*Phase] {
+	return func(yield func(*Phase) bool) {
+		for i := range p.Phases {
+			if !yield(p.Phases[i].clone()) {
+				return
+			}
+		}
+	}
+}
+
+// Commands yields the commands of ph in the order a successful run executes
+// them: the pre hook, the phase's commands, then the on_success and always
+// hooks; the on_failure hook comes last.
+func Commands(ph *Phase) iter.Seq[Command] {
+	return func(yield func(Command) bool) {
+		for c := range hookCommands(ph.Hooks, ph.Commands) {
+			if c.Hook == "" {
+				step := ph.Step(c.Index)
+				c.Step, c.Description = step.Name, step.Description
+			}
+			if !yield(c) {
+				return
+			}
+		}
+	}
+}
+
+// hookCommands yields commands surrounded by hooks in execution order.
+func hookCommands(h Hooks, commands []string) iter.Seq[Command] {
+	return func(yield func(Command) bool) {
+		groups := []struct {
+			hook     string
+			commands []string
+		}{
+			{"pre", h.Pre},
+			{"", commands},
+			{"on_success", h.OnSuccess},
+			{"always", h.Always},
+			{"on_failure", h.OnFailure},
+		}
+		for _, g := range groups {
+			for i, text := range g.commands {
+				if !yield(Command{Hook: g.hook, Index: i, Text: text}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// SkipProject can be returned by a WalkFunc visiting a project, or one of
+// its phases or commands, to skip the rest of that project.
+var SkipProject = errors.New("skip this project")
+
+// WalkFunc is called by Walk for each project (with ph and cmd nil), each
+// command of the project's hooks (with ph nil), each phase (with cmd nil)
+// and each of the phase's commands. A project that fails to resolve is
+// visited once with its error and a project holding only its name.
+type WalkFunc func(proj *Project, ph *Phase, cmd *Command, err error) error
+
+// Walk visits the projects of c sorted by name, and their phases and
+// commands in order, stopping at the first error fn returns other than
+// SkipProject.
+func Walk(c *Config, fn WalkFunc) error {
+	for _, name := range c.ProjectNames() {
+		var err error
+		if proj, resolveErr := c.Project(name); resolveErr != nil {
+			err = fn(&Project{Name: name}, nil, nil, resolveErr)
+		} else {
+			err = walkProject(proj.clone(), fn)
+		}
+		if err != nil && err != SkipProject {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkProject visits proj for Walk.
+func walkProject(proj *Project, fn WalkFunc) error {
+	if err := fn(proj, nil, nil, nil); err != nil {
+		return err
+	}
+	for cmd := range hookCommands(proj.Hooks, nil) {
+		if err := fn(proj, nil, &cmd, nil); err != nil {
+			return err
+		}
+	}
+	for ph := range Phases(proj) {
+		if err := fn(proj, ph, nil, nil); err != nil {
+			return err
+		}
+		for cmd := range Commands(ph) {
+			if err := fn(proj, ph, &cmd, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// clone returns a deep copy of p.
+func (p *Project) clone() *Project {
+	c := *p
+	c.Phases = make([]Phase, len(p.Phases))
+	for i := range p.Phases {
+		c.Phases[i] = *p.Phases[i].clone()
+	}
+	c.Tags = slices.Clone(p.Tags)
+	c.Hooks = p.Hooks.clone()
+	c.Probes = maps.Clone(p.Probes)
+	c.Pins = maps.Clone(p.Pins)
+	c.Vars = maps.Clone(p.Vars)
+	c.Env = maps.Clone(p.Env)
+	if p.Profiles != nil {
+		c.Profiles = make(map[string]Profile, len(p.Profiles))
+		for name, profile := range p.Profiles {
+			c.Profiles[name] = profile.clone()
+		}
+	}
+	if p.ProblemMatchers != nil {
+		c.ProblemMatchers = make(map[string]ProblemMatcher, len(p.ProblemMatchers))
+		for name, m := range p.ProblemMatchers {
+			m.Next = slices.Clone(m.Next)
+			c.ProblemMatchers[name] = m
+		}
+	}
+	if p.Secrets != nil {
+		s := Secrets{
+			EnvFiles:  slices.Clone(p.Secrets.EnvFiles),
+			Keyring:   maps.Clone(p.Secrets.Keyring),
+			Env:       maps.Clone(p.Secrets.Env),
+			Resolvers: maps.Clone(p.Secrets.Resolvers),
+		}
+		c.Secrets = &s
+	}
+	return &c
+}
+
+// clone returns a deep copy of ph.
+func (ph *Phase) clone() *Phase {
+	c := *ph
+	c.Commands = slices.Clone(ph.Commands)
+	c.Steps = slices.Clone(ph.Steps)
+	c.OnlyVariants = slices.Clone(ph.OnlyVariants)
+	c.Needs = slices.Clone(ph.Needs)
+	c.Aliases = slices.Clone(ph.Aliases)
+	c.Hooks = ph.Hooks.clone()
+	c.Ulimits = maps.Clone(ph.Ulimits)
+	c.Artifacts = maps.Clone(ph.Artifacts)
+	c.Problems = slices.Clone(ph.Problems)
+	c.Reports = slices.Clone(ph.Reports)
+	if ph.Coverage != nil {
+		gate := *ph.Coverage
+		if gate.MaxDrop != nil {
+			maxDrop := *gate.MaxDrop
+			gate.MaxDrop = &maxDrop
+		}
+		c.Coverage = &gate
+	}
+	c.Env = maps.Clone(ph.Env)
+	c.Inputs = slices.Clone(ph.Inputs)
+	c.Outputs = slices.Clone(ph.Outputs)
+	if ph.Container != nil {
+		container := *ph.Container
+		container.Mounts = slices.Clone(ph.Container.Mounts)
+		c.Container = &container
+	}
+	if ph.Matrix != nil {
+		c.Matrix = make(map[string][]string, len(ph.Matrix))
+		for key, values := range ph.Matrix {
+			c.Matrix[key] = slices.Clone(values)
+		}
+	}
+	if ph.DeprecatedNames != nil {
+		c.DeprecatedNames = make([]DeprecatedName, len(ph.DeprecatedNames))
+		for i, d := range ph.DeprecatedNames {
+			if d.Until != nil {
+				until := *d.Until
+				d.Until = &until
+			}
+			c.DeprecatedNames[i] = d
+		}
+	}
+	return &c
+}
+
+// clone returns a deep copy of h.
+func (h Hooks) clone() Hooks {
+	return Hooks{
+		Pre:       slices.Clone(h.Pre),
+		OnSuccess: slices.Clone(h.OnSuccess),
+		OnFailure: slices.Clone(h.OnFailure),
+		Always:    slices.Clone(h.Always),
+	}
+}
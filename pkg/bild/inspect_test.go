@@ -0,0 +1,120 @@
+package bild
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fullProject returns a project with every map, slice and pointer set, so
+// that TestClone notices any of them the clone shares.
+func fullProject() *Project {
+	until := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxDrop := 0.5
+	hooks := Hooks{Pre: []string{"pre"}, OnSuccess: []string{"ok"}, OnFailure: []string{"fail"}, Always: []string{"always"}}
+	return &Project{
+		Name: "api",
+		Phases: []Phase{{
+			Name:            "build",
+			Commands:        []string{"make"},
+			Steps:           []Step{{Name: "compile"}},
+			Needs:           []string{"deps"},
+			Aliases:         []string{"b"},
+			DeprecatedNames: []DeprecatedName{{Name: "compile", Until: &until}},
+			Hooks:           hooks,
+			Ulimits:         map[string]string{"nofile": "1024"},
+			Artifacts:       map[string]string{"binary": "bin/api"},
+			Problems:        []string{"go"},
+			Reports:         []string{"**/junit*.xml"},
+			Coverage:        &CoverageGate{Report: "coverage.out", Min: 80, MaxDrop: &maxDrop},
+			Matrix:          map[string][]string{"compiler": {"gcc", "clang"}},
+			OnlyVariants:    []string{"build[clang]"},
+			Env:             map[string]string{"CGO_ENABLED": "0"},
+			Inputs:          []string{"**/*.go"},
+			Outputs:         []string{"bin/app"},
+			Container:       &Container{Image: "golang:1.23", Mounts: []string{"gomod:/go/pkg/mod"}},
+		}},
+		Hooks: hooks,
+		Secrets: &Secrets{
+			EnvFiles:  []string{".env"},
+			Keyring:   map[string]string{"TOKEN": "api-token"},
+			Env:       map[string]string{"DB_PASSWORD": "vault:secret/db#password"},
+			Resolvers: map[string]string{"pass": "pass show \"$BILD_SECRET_REF\""},
+		},
+		Probes: map[string]string{"go": "go version"},
+		Pins:   map[string]string{"go": "go1.23.5"},
+		ProblemMatchers: map[string]ProblemMatcher{"rustc": {
+			Pattern: `^(?P<severity>error|warning): (?P<message>.+)$`,
+			Next:    []string{`^\s*--> (?P<file>[^:]+):(?P<line>\d+)`},
+		}},
+		Tags: []string{"go"},
+		Vars: map[string]string{"tags": "netgo"},
+		Env:  map[string]string{"GOFLAGS": "-mod=mod"},
+		Profiles: map[string]Profile{"race": {
+			Vars:   map[string]string{"tags": "race"},
+			Env:    map[string]string{"GORACE": "halt_on_error=1"},
+			Phases: map[string]PhaseProfile{"build": {Commands: []string{"go build -race"}, Env: map[string]string{"CGO_ENABLED": "1"}}},
+		}},
+	}
+}
+
+// checkDeepCopy reports the maps, slices and pointers below path that orig
+// and copy share, and those orig leaves empty, which would prove nothing.
+func checkDeepCopy(t *testing.T, path string, orig, copy reflect.Value) {
+	t.Helper()
+	switch orig.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Pointer:
+		if orig.IsNil() || (orig.Kind() != reflect.Pointer && orig.Len() == 0) {
+			t.Errorf("%s is empty in fullProject", path)
+			return
+		}
+		if orig.Pointer() == copy.Pointer() {
+			t.Errorf("%s is shared with the clone", path)
+		}
+	}
+	switch orig.Kind() {
+	case reflect.Pointer:
+		checkDeepCopy(t, path, orig.Elem(), copy.Elem())
+	case reflect.Struct:
+		// Types of other packages, such as time.Time, are copied as values.
+		if orig.Type().PkgPath() != reflect.TypeFor[Project]().PkgPath() {
+			return
+		}
+		for i := range orig.NumField() {
+			checkDeepCopy(t, path+"."+orig.Type().Field(i).Name, orig.Field(i), copy.Field(i))
+		}
+	case reflect.Slice:
+		for i := range orig.Len() {
+			checkDeepCopy(t, fmt.Sprintf("%s[%d]", path, i), orig.Index(i), copy.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range orig.MapKeys() {
+			checkDeepCopy(t, fmt.Sprintf("%s[%v]", path, key), orig.MapIndex(key), copy.MapIndex(key))
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	proj := fullProject()
+	c := proj.clone()
+	if !reflect.DeepEqual(c, proj) {
+		t.Fatalf("clone = %+v, want %+v", c, proj)
+	}
+	checkDeepCopy(t, "Project", reflect.ValueOf(proj), reflect.ValueOf(c))
+}
+
+func TestProjectsYieldCopies(t *testing.T) {
+	config := NewConfig()
+	config.Projects["api"] = *fullProject()
+	for proj, err := range Projects(config) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		proj.Phases[0].Commands[0] = "rm -rf /"
+		proj.Secrets.Keyring["TOKEN"] = "stolen"
+	}
+	if got := config.Projects["api"]; !reflect.DeepEqual(got.Phases, fullProject().Phases) || got.Secrets.Keyring["TOKEN"] != "api-token" {
+		t.Errorf("changes to a yielded project reached the config: %+v", got)
+	}
+}
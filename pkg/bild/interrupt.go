@@ -0,0 +1,99 @@
+package bild
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// defaultKillDelay is how long commands get to exit after being signaled
+// before they are killed, unless Runner.KillDelay says otherwise.
+const defaultKillDelay = 10 * time.Second
+
+// NotifyContext returns a copy of parent that is canceled when the process
+// receives SIGINT (Ctrl-C) or SIGTERM. Runners forward the signal to the
+// running phase's commands and return an *InterruptedError. Signals after
+// the first are no longer caught, so pressing Ctrl-C again stops bild at
+// once. stop releases the signal handling.
+func NotifyContext(parent context.Context) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			Tracef("runner", "received %s; stopping the run", signalName(sig))
+			cancel(&InterruptedError{Signal: sig})
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(nil)
+	}
+}
+
+// interruptSignal returns the signal ctx was canceled by (see NotifyContext),
+// or nil if it was canceled otherwise.
+func interruptSignal(ctx context.Context) os.Signal {
+	if e, ok := context.Cause(ctx).(*InterruptedError); ok {
+		return e.Signal
+	}
+	return nil
+}
+
+// killDelay returns how long commands get to exit after being signaled.
+func (r *Runner) killDelay() time.Duration {
+	if r.KillDelay > 0 {
+		return r.KillDelay
+	}
+	return defaultKillDelay
+}
+
+// handleInterrupts makes canceling ctx signal the shell of cmd and the
+// commands it started, rather than kill the shell alone and leave them
+// running. The signal received by bild is passed on (SIGTERM if the run was
+// stopped otherwise); whatever is still running after the kill delay is
+// killed. Once cmd exited, commandExited must be called.
+func (r *Runner) handleInterrupts(ctx context.Context, cmd *exec.Cmd) {
+	group := startInGroup(cmd, r.Stdin)
+	cmd.WaitDelay = r.killDelay()
+	cmd.Cancel = func() error {
+		sig := interruptSignal(ctx)
+		if sig == nil {
+			sig = syscall.SIGTERM
+		}
+		Tracef("runner", "sending %s to pid %d (process group: %t)", signalName(sig), cmd.Process.Pid, group)
+		return signalProcess(cmd.Process, group, sig)
+	}
+}
+
+// commandExited takes back the terminal handleInterrupts gave cmd. If cmd
+// was stopped by a Ctrl-C only it got, it waits for the Ctrl-C to cancel
+// ctx, as NotifyContext does, so that the run stops as it would otherwise.
+func (r *Runner) commandExited(ctx context.Context, cmd *exec.Cmd) {
+	if !takeTerminal(cmd) || ctx.Done() == nil {
+		return
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+	}
+}
+
+// signalName returns the conventional name of sig.
+func signalName(sig os.Signal) string {
+	switch sig {
+	case os.Interrupt:
+		return "SIGINT"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	case nil:
+		return "cancellation"
+	}
+	return sig.String()
+}
@@ -0,0 +1,96 @@
+//go:build linux
+
+package bild
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openTerminal returns the terminal end of a new pseudo-terminal.
+func openTerminal(t *testing.T) *os.File {
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { ptmx.Close() })
+	if err := unix.IoctlSetPointerInt(int(ptmx.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatal(err)
+	}
+	n, err := unix.IoctlGetInt(int(ptmx.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pts, err := os.OpenFile("/dev/pts/"+strconv.Itoa(n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pts.Close() })
+	return pts
+}
+
+func TestInterruptKillsChildren(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		sig      os.Signal
+		terminal bool
+	}{
+		{"SIGINT", os.Interrupt, false},
+		{"SIGTERM", syscall.SIGTERM, false},
+		{"SIGINT on a terminal", os.Interrupt, true},
+		{"SIGTERM on a terminal", syscall.SIGTERM, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// Background jobs of a script ignore SIGINT, and the shell
+			// exits without waiting for them.
+			proj := &Project{Name: "app", Phases: []Phase{{
+				Name:     "serve",
+				Commands: []string{"sleep 30 &", "sleep 30 &", "echo $! > pid", "wait"},
+			}}}
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+			r := &Runner{Dir: dir, KillDelay: time.Second}
+			if tt.terminal {
+				r.Stdin = openTerminal(t)
+			}
+			done := make(chan error, 1)
+			go func() { done <- r.Run(ctx, proj, "serve") }()
+
+			var pid int
+			for deadline := time.Now().Add(5 * time.Second); pid == 0; time.Sleep(10 * time.Millisecond) {
+				if time.Now().After(deadline) {
+					t.Fatal("the phase did not start its commands")
+				}
+				data, _ := os.ReadFile(filepath.Join(dir, "pid"))
+				pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+			}
+			cancel(&InterruptedError{Signal: tt.sig})
+
+			var interrupted *InterruptedError
+			if err := <-done; !errors.As(err, &interrupted) {
+				t.Fatalf("Run = %v, want an *InterruptedError", err)
+			}
+			// The job is gone, or a zombie until its new parent reaps it.
+			for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+				stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+				if syscall.Kill(pid, 0) != nil || err == nil && strings.Contains(string(stat), ") Z ") {
+					break
+				}
+				if time.Now().After(deadline) {
+					syscall.Kill(pid, syscall.SIGKILL)
+					t.Fatalf("background job %d still runs after the run was stopped", pid)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package bild
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ulimitFlags maps the resource names accepted in a phase's ulimits to the
+// ulimit flags that sh, dash and bash agree on.
+var ulimitFlags = map[string]string{
+	"core":    "-c", // core file size, in blocks
+	"cpu":     "-t", // CPU time, in seconds
+	"data":    "-d", // data segment size, in kilobytes
+	"fsize":   "-f", // size of files written, in blocks
+	"memlock": "-l", // locked memory, in kilobytes
+	"nofile":  "-n", // open files
+	"stack":   "-s", // stack size, in kilobytes
+	"as":      "-v", // virtual memory, in kilobytes
+}
+
+// processSettings returns the script lines applying the phase's umask and
+// ulimits in its shell, before any of its commands run, and the environment
+// variables selecting its locale and network settings, followed by its env.
+func (ph *Phase) processSettings() (string, []string, error) {
+	var script string
+	if ph.Umask != "" {
+		mask, err := strconv.ParseUint(ph.Umask, 8, 32)
+		if err != nil || mask > 0777 {
+			return "", nil, fmt.Errorf("phase %s: invalid umask %q (want an octal mode such as 022)", ph.Name, ph.Umask)
+		}
+		script += fmt.Sprintf("umask %04o\n", mask)
+	}
+
+	names := make([]string, 0, len(ph.Ulimits))
+	for name := range ph.Ulimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flag, ok := ulimitFlags[name]
+		if !ok {
+			return "", nil, fmt.Errorf("phase %s: unknown ulimit %q", ph.Name, name)
+		}
+		value := ph.Ulimits[name]
+		if _, err := strconv.ParseUint(value, 10, 64); err != nil && value != "unlimited" {
+			return "", nil, fmt.Errorf("phase %s: invalid value %q for ulimit %s (want a number or unlimited)", ph.Name, value, name)
+		}
+		script += fmt.Sprintf("ulimit %s %s\n", flag, value)
+	}
+
+	var env []string
+	if strings.ContainsFunc(ph.Locale, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_.@-", r))
+	}) {
+		return "", nil, fmt.Errorf("phase %s: invalid locale %q", ph.Name, ph.Locale)
+	}
+	if ph.Locale != "" {
+		env = []string{"LANG=" + ph.Locale, "LC_ALL=" + ph.Locale}
+	}
+	network, err := ph.networkEnv()
+	if err != nil {
+		return "", nil, err
+	}
+	return script, slices.Concat(env, network, envList(ph.Env)), nil
+}
+
+// offlineEnv makes common tools work without the network, or fail at once
+// instead of timing out. The proxy points at the discard port, which
+// refuses connections.
+var offlineEnv = []string{
+	"BILD_OFFLINE=1",
+	"GOPROXY=off",
+	"CARGO_NET_OFFLINE=true",
+	"npm_config_offline=true",
+	"YARN_ENABLE_OFFLINE_MODE=1",
+	"PIP_NO_INDEX=1",
+	"MAVEN_ARGS=--offline",
+	"HTTP_PROXY=http://127.0.0.1:9",
+	"HTTPS_PROXY=http://127.0.0.1:9",
+	"http_proxy=http://127.0.0.1:9",
+	"https_proxy=http://127.0.0.1:9",
+	"NO_PROXY=",
+	"no_proxy=",
+}
+
+// networkEnv returns the environment variables setting the phase's proxy or
+// making it offline.
+func (ph *Phase) networkEnv() ([]string, error) {
+	switch {
+	case ph.Offline && ph.Proxy != "":
+		return nil, fmt.Errorf("phase %s: offline and proxy cannot be combined", ph.Name)
+	case ph.Offline:
+		return offlineEnv, nil
+	case ph.Proxy == "":
+		if ph.NoProxy != "" {
+			return nil, fmt.Errorf("phase %s: no_proxy needs a proxy", ph.Name)
+		}
+		return nil, nil
+	}
+	if u, err := url.Parse(ph.Proxy); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("phase %s: invalid proxy %q (want a URL such as http://proxy:3128)", ph.Name, ph.Proxy)
+	}
+	env := []string{
+		"HTTP_PROXY=" + ph.Proxy, "HTTPS_PROXY=" + ph.Proxy,
+		"http_proxy=" + ph.Proxy, "https_proxy=" + ph.Proxy,
+	}
+	if ph.NoProxy != "" {
+		env = append(env, "NO_PROXY="+ph.NoProxy, "no_proxy="+ph.NoProxy)
+	}
+	return env, nil
+}
+
+// ShellPreamble returns the phase's umask, ulimits, locale and network
+// settings as shell commands, for renderings of the phase that run without
+// bild.
+func (ph *Phase) ShellPreamble() ([]string, error) {
+	script, env, err := ph.processSettings()
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(script, "\n"), "\n")
+	if script == "" {
+		lines = nil
+	}
+	for _, v := range env {
+		lines = append(lines, "export "+v)
+	}
+	return lines, nil
+}
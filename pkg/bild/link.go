@@ -0,0 +1,113 @@
+package bild
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// LocalLink records that a project of the global configuration is kept in
+// sync with the project of the same name in the local configuration of its
+// repository, and both sides as last synced, as SHA-256 sums in hex of the
+// project (see ProjectSum), to tell which side changed since.
+type LocalLink struct {
+	Dir    string    `json:"dir"` // the repository holding the local configuration
+	Global string    `json:"global"`
+	Local  string    `json:"local"`
+	Synced time.Time `json:"synced"`
+}
+
+// linkFile is the state file holding the link of a project.
+const linkFile = "link.json"
+
+// Link returns the link of the project to a local configuration, or nil if
+// it is not linked.
+func (s *ProjectState) Link() (*LocalLink, error) {
+	var link LocalLink
+	ok, err := s.ReadJSON(linkFile, &link)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// SetLink records link as the project's link to a local configuration.
+func (s *ProjectState) SetLink(link *LocalLink) error {
+	unlock, err := s.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.WriteJSON(linkFile, link)
+}
+
+// Unlink forgets the project's link to a local configuration.
+func (s *ProjectState) Unlink() error {
+	if !s.Exists() {
+		return nil
+	}
+	unlock, err := s.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.Remove(linkFile)
+}
+
+// Changed reports which sides of l changed since the last sync, global and
+// local being the projects as ProjectSum sums them.
+func (l *LocalLink) Changed(global, local *Project) (globalChanged, localChanged bool) {
+	return ProjectSum(global) != l.Global, ProjectSum(local) != l.Local
+}
+
+// ProjectSum returns the SHA-256 sum in hex of p as saved, leaving out its
+// path, which only holds on one machine.
+func ProjectSum(p *Project) string {
+	c := *p
+	c.Path = ""
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// SharedProject returns project name of c as it is shared in a local
+// configuration: resolved, without the overrides of the machine and
+// without its path.
+func (c *Config) SharedProject(name string) (*Project, error) {
+	proj, err := c.WithoutOverrides().Project(name)
+	if err != nil {
+		return nil, err
+	}
+	proj.Path = ""
+	return proj, nil
+}
+
+// LinkedProject returns project name of the local configuration in dir as
+// written.
+func LinkedProject(dir, name string) (*Project, error) {
+	local, ok, err := LoadLocalConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no %s in %s", LocalConfigName, dir)
+	}
+	proj, ok := local.Projects[name]
+	if !ok {
+		return nil, fmt.Errorf("%s no longer holds project %s", LocalConfigPath(dir), name)
+	}
+	return &proj, nil
+}
+
+// CompareProjects returns the differences between a and b, two versions of
+// project name, as CompareBundles does.
+func CompareProjects(name string, a, b *Project) []ConfigDifference {
+	mine := &Bundle{Projects: map[string]*Project{name: a}}
+	theirs := &Bundle{Projects: map[string]*Project{name: b}}
+	return CompareBundles(mine, theirs)
+}
@@ -0,0 +1,18 @@
+package bild
+
+import "testing"
+
+func TestLocalLinkChanged(t *testing.T) {
+	proj := &Project{Phases: []Phase{{Name: "build", Commands: []string{"make"}}}, Path: "/src/api"}
+	link := &LocalLink{Global: ProjectSum(proj), Local: ProjectSum(proj)}
+
+	moved := *proj
+	moved.Path = "/home/me/api"
+	if g, l := link.Changed(&moved, proj); g || l {
+		t.Errorf("a project checked out elsewhere counts as changed: %v, %v", g, l)
+	}
+	edited := &Project{Phases: []Phase{{Name: "build", Commands: []string{"make -j8"}}}}
+	if g, l := link.Changed(proj, edited); g || !l {
+		t.Errorf("Changed after editing the local project = %v, %v, want false, true", g, l)
+	}
+}
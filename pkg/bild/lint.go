@@ -0,0 +1,232 @@
+package bild
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Conventions describe how a team names and orders phases, so that many
+// project configurations stay consistent. Lint reports where projects
+// deviate from them.
+type Conventions struct {
+	// PhaseOrder lists phase names in the order projects must declare them,
+	// e.g. ["configure", "build", "test"]. Projects need not have all of
+	// them, and may put other phases anywhere.
+	PhaseOrder []string `json:"phase_order,omitempty"`
+	// AllowedPhases, if set, are the only phase names projects may use,
+	// besides those in PhaseOrder and RequiredPhases.
+	AllowedPhases []string `json:"allowed_phases,omitempty"`
+	// RequiredPhases are phases every project must have.
+	RequiredPhases []string `json:"required_phases,omitempty"`
+	// NamePattern is a regular expression phase names must match, such as
+	// "^[a-z][a-z0-9-]*$".
+	NamePattern string `json:"name_pattern,omitempty"`
+}
+
+// LintIssue is a problem Lint found in a project. Phase is empty for
+// problems of the project as a whole.
+type LintIssue struct {
+	Project string `json:"project"`
+	Phase   string `json:"phase,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i LintIssue) String() string {
+	if i.Phase == "" {
+		return fmt.Sprintf("%s: %s", i.Project, i.Message)
+	}
+	return fmt.Sprintf("%s/%s: %s", i.Project, i.Phase, i.Message)
+}
+
+// Lint checks the named projects of c (all of them if names is empty), with
+// the phases they extend and the default phases merged in, against c's
+// conventions and for mistakes such as duplicate phase names and needs of
+// phases that don't exist. Conventions set in c's parents apply if c has
+// none. An error is returned if the conventions themselves are invalid.
+func (c *Config) Lint(names ...string) ([]LintIssue, error) {
+	conv := c.conventions()
+	var pattern *regexp.Regexp
+	if conv.NamePattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(conv.NamePattern); err != nil {
+			return nil, fmt.Errorf("conventions: invalid name_pattern: %v", err)
+		}
+	}
+	allowed := make(map[string]bool)
+	var allowedNames []string
+	for _, list := range [][]string{conv.AllowedPhases, conv.PhaseOrder, conv.RequiredPhases} {
+		for _, name := range list {
+			if !allowed[name] {
+				allowed[name] = true
+				allowedNames = append(allowedNames, name)
+			}
+		}
+	}
+	sort.Strings(allowedNames)
+	rank := make(map[string]int, len(conv.PhaseOrder))
+	for i, name := range conv.PhaseOrder {
+		rank[name] = i
+	}
+
+	if len(names) == 0 {
+		names = c.ProjectNames()
+	}
+	var issues []LintIssue
+	for _, name := range names {
+		proj, err := c.Project(name)
+		if err != nil {
+			issues = append(issues, LintIssue{Project: name, Message: err.Error()})
+			continue
+		}
+		report := func(phase, format string, args ...any) {
+			issues = append(issues, LintIssue{Project: name, Phase: phase, Message: fmt.Sprintf(format, args...)})
+		}
+
+		for _, matcher := range slices.Sorted(maps.Keys(proj.ProblemMatchers)) {
+			if _, err := proj.ProblemMatchers[matcher].compile(matcher); err != nil {
+				report("", "%v", err)
+			}
+		}
+
+		seen := make(map[string]bool, len(proj.Phases))
+		last := "" // the last phase with a place in PhaseOrder
+		for _, ph := range proj.Phases {
+			if seen[ph.Name] {
+				report(ph.Name, "defined more than once")
+			}
+			seen[ph.Name] = true
+			if len(ph.Commands) == 0 && len(ph.Pre) == 0 {
+				report(ph.Name, "has no commands")
+			}
+			steps := make(map[string]bool, len(ph.Steps))
+			for _, step := range ph.Steps {
+				if step.Name != "" && steps[step.Name] {
+					report(ph.Name, "has more than one step named %s", step.Name)
+				}
+				steps[step.Name] = true
+			}
+			if _, err := ph.ShellPreamble(); err != nil {
+				report(ph.Name, "%v", err)
+			}
+			for _, key := range slices.Sorted(maps.Keys(ph.Matrix)) {
+				if len(ph.Matrix[key]) == 0 {
+					report(ph.Name, "matrix %s has no values, so the phase never runs", key)
+				}
+			}
+			if _, err := proj.compileProblemMatchers(ph.Problems); err != nil {
+				report(ph.Name, "%v", err)
+			}
+			if gate := ph.Coverage; gate != nil {
+				switch {
+				case gate.Report == "":
+					report(ph.Name, "coverage gate names no report")
+				case gate.Format != "" && gate.Format != "go" && gate.Format != "lcov" && gate.Format != "cobertura":
+					report(ph.Name, "unknown coverage format %q (want go, lcov or cobertura)", gate.Format)
+				case gate.Min < 0 || gate.Min > 100:
+					report(ph.Name, "coverage minimum %g is not a percentage", gate.Min)
+				case gate.Min == 0 && gate.MaxDrop == nil:
+					report(ph.Name, "coverage gate has neither a minimum nor a max_drop, so it never fails")
+				}
+			}
+			if ph.Container != nil && ph.Container.Image == "" {
+				report(ph.Name, "container has no image")
+			}
+			if err := checkNix(ph.Nix); err != nil {
+				report(ph.Name, "%v", err)
+			} else if ph.Container != nil && nixMode(proj, &ph) != "" && nixMode(proj, &ph) != "off" {
+				report(ph.Name, "runs in a container, so its nix setting has no effect")
+			}
+			if ph.Parallel && len(ph.Matrix) == 0 {
+				report(ph.Name, "parallel has no effect without a matrix")
+			}
+			if ph.When != "" {
+				if _, err := parseCondition(ph.When); err != nil {
+					report(ph.Name, "%v", err)
+				}
+			}
+			if pattern != nil && !pattern.MatchString(ph.Name) {
+				report(ph.Name, "name does not match %s", conv.NamePattern)
+			}
+			if len(conv.AllowedPhases) > 0 && !allowed[ph.Name] {
+				report(ph.Name, "not an allowed phase name (allowed: %s)", strings.Join(allowedNames, ", "))
+			}
+			if r, ok := rank[ph.Name]; ok {
+				if last != "" && r < rank[last] {
+					report(ph.Name, "comes after %s; phases go in the order %s", last, strings.Join(conv.PhaseOrder, ", "))
+				} else {
+					last = ph.Name
+				}
+			}
+		}
+		aliased := make(map[string]string)
+		for _, ph := range proj.Phases {
+			for _, need := range ph.Needs {
+				if !seen[need] {
+					report(ph.Name, "needs unknown phase %s", need)
+				}
+			}
+			for _, alias := range ph.Aliases {
+				switch {
+				case seen[alias]:
+					report(ph.Name, "alias %s is the name of a phase, which takes precedence", alias)
+				case aliased[alias] != "":
+					report(ph.Name, "alias %s is already an alias of %s", alias, aliased[alias])
+				default:
+					aliased[alias] = ph.Name
+				}
+			}
+		}
+		if proj.DefaultPhase != "" {
+			if _, err := proj.Phase(proj.DefaultPhase); err != nil {
+				report("", "default phase %s does not exist", proj.DefaultPhase)
+			}
+		}
+		for _, profile := range slices.Sorted(maps.Keys(proj.Profiles)) {
+			for _, phase := range slices.Sorted(maps.Keys(proj.Profiles[profile].Phases)) {
+				if _, err := proj.Phase(phase); err != nil {
+					report("", "profile %s changes unknown phase %s", profile, phase)
+				}
+			}
+		}
+		if _, err := OrderByNeeds(proj.Phases); err != nil {
+			report("", "%v", err)
+		}
+		if err := checkNix(proj.Nix); err != nil {
+			report("", "%v", err)
+		}
+		if s := proj.Secrets; s != nil {
+			for _, env := range slices.Sorted(maps.Keys(s.Env)) {
+				for _, backend := range SecretRefs(s.Env[env]) {
+					if !s.HasResolver(backend) {
+						report("", "secret %s refers to %s, which has no resolver", env, backend)
+					}
+				}
+			}
+		}
+		for _, required := range conv.RequiredPhases {
+			if !seen[required] {
+				report("", "missing required phase %s", required)
+			}
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(c.Workspaces)) {
+		if name := c.Workspaces[path]; !c.hasProject(name) {
+			issues = append(issues, LintIssue{Project: name, Message: fmt.Sprintf("not defined, but workspace %s maps to it", path)})
+		}
+	}
+	return issues, nil
+}
+
+// conventions returns the conventions of c or, if unset, of its parents.
+func (c *Config) conventions() Conventions {
+	for cfg := c; cfg != nil; cfg = cfg.Parent {
+		if cfg.Conventions != nil {
+			return *cfg.Conventions
+		}
+	}
+	return Conventions{}
+}
@@ -0,0 +1,86 @@
+//go:build !unix
+
+package bild
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockWait is how long lockFile waits for a lock before giving up.
+const lockWait = 30 * time.Second
+
+// lockFile creates path exclusively, recording the process holding it, and
+// waits for it to disappear if wait is set. Without flock, a lock left
+// behind by a process that is gone is broken, and one held for longer than
+// lockWait is reported as an error naming the file.
+func lockFile(path string, wait bool) (func(), error) {
+	deadline := time.Now().Add(lockWait)
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d %s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+			f.Close()
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		pid, since, known := lockOwner(path)
+		if known && !processRuns(pid) || !known && time.Since(since) > lockWait {
+			Tracef("state", "breaking the stale lock %s of process %d", path, pid)
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			continue
+		}
+		if !wait {
+			return nil, ErrStateLocked
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is held by process %d since %s; delete it if that process is gone", path, pid, since.Format(time.DateTime))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// lockOwner returns the process holding the lock at path and since when,
+// and whether the lock records them; if not, since is the file's time.
+func lockOwner(path string) (pid int, since time.Time, known bool) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 {
+			owner, err1 := strconv.Atoi(fields[0])
+			locked, err2 := time.Parse(time.RFC3339, fields[1])
+			if err1 == nil && err2 == nil {
+				return owner, locked, true
+			}
+		}
+	}
+	// A lock just created has no owner written yet.
+	if info, err := os.Stat(path); err == nil {
+		since = info.ModTime()
+	} else {
+		since = time.Now()
+	}
+	return 0, since, false
+}
+
+// processRuns reports whether the process pid still runs, as far as the
+// system tells: on Windows, finding a process fails once it exited.
+func processRuns(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,36 @@
+//go:build windows
+
+package bild
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFileStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	// A lock of this process is held; one of a process that is gone is not.
+	held := fmt.Sprintf("%d %s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+	if err := os.WriteFile(path, []byte(held), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockFile(path, false); !errors.Is(err, ErrStateLocked) {
+		t.Fatalf("lockFile of a held lock = %v, want ErrStateLocked", err)
+	}
+	stale := fmt.Sprintf("%d %s\n", 1<<30, time.Now().Add(-time.Hour).Format(time.RFC3339))
+	if err := os.WriteFile(path, []byte(stale), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockFile(path, false)
+	if err != nil {
+		t.Fatalf("lockFile of a stale lock: %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("lock left after unlocking: %v", err)
+	}
+}
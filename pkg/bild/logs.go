@@ -0,0 +1,130 @@
+package bild
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logTimeFormat is the timestamp in log file names; it sorts chronologically.
+const logTimeFormat = "20060102-150405"
+
+// LogsDir returns the suggested directory for phase logs.
+func (d Dirs) LogsDir() string {
+	return filepath.Join(d.Data, "logs")
+}
+
+// LogPath returns the path of the log of phase of project started at start,
+// below dir: <dir>/<project>/<phase>-<timestamp>.log.
+func LogPath(dir, project, phase string, start time.Time) string {
+	name := fmt.Sprintf("%s-%s.log", fileSafe(phase), start.Format(logTimeFormat))
+	return filepath.Join(dir, fileSafe(project), name)
+}
+
+// PhaseLogs returns the logs of project below dir, newest first, optionally
+// restricted to one phase.
+func PhaseLogs(dir, project, phase string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, fileSafe(project), "*.log"))
+	if err != nil {
+		return nil, err
+	}
+	type logFile struct {
+		path  string
+		start time.Time
+		seq   int
+	}
+	var found []logFile
+	for _, path := range paths {
+		name, start, seq, ok := parseLogName(filepath.Base(path))
+		if ok && (phase == "" || name == fileSafe(phase)) {
+			found = append(found, logFile{path, start, seq})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if !a.start.Equal(b.start) {
+			return a.start.After(b.start)
+		}
+		if a.seq != b.seq {
+			return a.seq > b.seq
+		}
+		// Different phases started within the same second.
+		return modTime(a.path).After(modTime(b.path))
+	})
+	logs := make([]string, len(found))
+	for i, f := range found {
+		logs[i] = f.path
+	}
+	return logs, nil
+}
+
+// parseLogName splits the name of a log file into the phase, the start time
+// and the sequence number of logs started within the same second (1 for the
+// first, then 2, 3, ... as a ".N" suffix).
+func parseLogName(base string) (string, time.Time, int, bool) {
+	name := strings.TrimSuffix(base, ".log")
+	seq := 1
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		if n, err := strconv.Atoi(name[i+1:]); err == nil {
+			name, seq = name[:i], n
+		}
+	}
+	n := len(logTimeFormat)
+	if len(name) < n+2 || name[len(name)-n-1] != '-' {
+		return "", time.Time{}, 0, false
+	}
+	start, err := time.ParseInLocation(logTimeFormat, name[len(name)-n:], time.Local)
+	if err != nil {
+		return "", time.Time{}, 0, false
+	}
+	return name[:len(name)-n-1], start, seq, true
+}
+
+// modTime returns the modification time of path, or the zero time.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// openPhaseLog creates the log file of a phase and writes its header. Logs
+// started within the same second get a numeric suffix.
+func (r *Runner) openPhaseLog(proj *Project, ph *Phase, start time.Time) (*os.File, error) {
+	path := LogPath(r.LogDir, proj.Name, ph.Name, start)
+	if err := ensureParent(path); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	for n := 2; os.IsExist(err); n++ {
+		f, err = os.OpenFile(fmt.Sprintf("%s.%d.log", strings.TrimSuffix(path, ".log"), n), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	Tracef("runner", "logging phase %s to %s", ph.Name, f.Name())
+	fmt.Fprintf(f, "# bild: project %s, phase %s, started %s\n", proj.Name, ph.Name, start.Format(time.RFC3339))
+	for i, cmd := range ph.Commands {
+		if label := ph.Step(i).Label(); label != "" {
+			fmt.Fprintf(f, "# $ %s  # %s\n", cmd, label)
+		} else {
+			fmt.Fprintf(f, "# $ %s\n", cmd)
+		}
+	}
+	return f, nil
+}
+
+// closePhaseLog writes the outcome of the phase to its log and closes it.
+func closePhaseLog(f *os.File, err error, elapsed time.Duration) {
+	if err != nil {
+		fmt.Fprintf(f, "# bild: failed after %s: %v\n", elapsed.Round(time.Millisecond), err)
+	} else {
+		fmt.Fprintf(f, "# bild: passed after %s\n", elapsed.Round(time.Millisecond))
+	}
+	f.Close()
+}
@@ -0,0 +1,251 @@
+package bild
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Variant is one combination of the values of a phase's Matrix, such as
+// {"compiler": "gcc", "type": "Debug"}.
+type Variant map[string]string
+
+// Variants returns every combination of the values of ph.Matrix, or nil if
+// ph has no matrix. The values of the key first in alphabetical order vary
+// slowest, each in the order listed. If ph.OnlyVariants is set, only the
+// variants it names are returned.
+func (ph *Phase) Variants() []Variant {
+	if len(ph.Matrix) == 0 {
+		return nil
+	}
+	variants := []Variant{{}}
+	for _, key := range slices.Sorted(maps.Keys(ph.Matrix)) {
+		var next []Variant
+		for _, v := range variants {
+			for _, value := range ph.Matrix[key] {
+				combined := maps.Clone(v)
+				combined[key] = value
+				next = append(next, combined)
+			}
+		}
+		variants = next
+	}
+	if ph.OnlyVariants != nil {
+		variants = slices.DeleteFunc(variants, func(v Variant) bool {
+			return !slices.Contains(ph.OnlyVariants, ph.VariantName(v))
+		})
+	}
+	return variants
+}
+
+// VariantName returns the name the variant v of ph runs as: the phase's
+// name followed by the values of v in the order of their keys, as in
+// "build[gcc,Debug]".
+func (ph *Phase) VariantName(v Variant) string {
+	values := make([]string, 0, len(v))
+	for _, key := range slices.Sorted(maps.Keys(v)) {
+		values = append(values, v[key])
+	}
+	return ph.Name + "[" + strings.Join(values, ",") + "]"
+}
+
+// MatrixEnv returns the environment variables passing the values of v to
+// commands, such as BILD_MATRIX_COMPILER=gcc, sorted.
+func MatrixEnv(v Variant) []string {
+	env := make([]string, 0, len(v))
+	for key, value := range v {
+		env = append(env, MatrixVar(key)+"="+value)
+	}
+	slices.Sort(env)
+	return env
+}
+
+// MatrixVar returns the name of the environment variable holding the value
+// of the matrix key.
+func MatrixVar(key string) string {
+	return "BILD_MATRIX_" + envName(key)
+}
+
+// runMatrix runs every variant of ph as a phase of its own, one after the
+// other until one fails, or all at once if ph.Parallel is set. Output of
+// parallel variants is prefixed with their values, line by line, unless
+// they run in windows of r.Terminal; the error is that of the first variant
+// failing.
+func (r *Runner) runMatrix(ctx context.Context, proj *Project, ph *Phase) error {
+	variants := ph.Variants()
+	phases := make([]*Phase, len(variants))
+	for i, v := range variants {
+		vph := *ph
+		vph.Name, vph.Matrix = ph.VariantName(v), nil
+		phases[i] = &vph
+	}
+	Tracef("runner", "phase %s: %d variants (parallel: %t)", ph.Name, len(variants), ph.Parallel)
+
+	if !ph.Parallel {
+		for i, v := range variants {
+			run := *r
+			run.matrix = v
+			if err := run.runPhase(ctx, proj, phases[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	r.flushOutput()
+	errs := make([]error, len(variants))
+	var lines sync.Mutex // lets one variant at a time write a line
+	var observer Observer
+	if r.Observer != nil {
+		observer = &syncObserver{o: r.Observer}
+	}
+	var wg sync.WaitGroup
+	for i, v := range variants {
+		run := *r
+		run.matrix = v
+		run.Observer = observer
+		run.Stdin = nil
+		run.window = len(r.Terminal) > 0
+		prefix := strings.TrimPrefix(phases[i].Name, ph.Name) + " "
+		stdout := &prefixWriter{w: r.Stdout, mu: &lines, prefix: prefix}
+		stderr := &prefixWriter{w: r.Stderr, mu: &lines, prefix: prefix}
+		if w := phaseOutput(r.Observer, phases[i]); w != nil {
+			stdout = &prefixWriter{w: w, mu: new(sync.Mutex)}
+			stderr = &prefixWriter{w: w, mu: stdout.mu}
+		}
+		// Each variant has its own output, so that its log gets only its lines.
+		run.output = NewMux()
+		run.output.Add(StreamSink(stdout, stderr), SinkOptions{})
+		run.Stdout, run.Stderr = run.output.Writer(Stdout), run.output.Writer(Stderr)
+		if len(r.masked) > 0 {
+			// Secrets are masked before the variant's log and tail see them.
+			run.Stdout = &maskWriter{w: run.Stdout, values: r.masked}
+			run.Stderr = &maskWriter{w: run.Stderr, values: r.masked}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = run.runPhase(ctx, proj, phases[i])
+			run.flushOutput()
+			run.output.Close()
+			stdout.finish()
+			stderr.finish()
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefixWriter writes the lines written to it to w, each with a prefix,
+// holding mu while writing one. A last line without a newline is written by
+// finish.
+type prefixWriter struct {
+	w       io.Writer
+	mu      *sync.Mutex
+	prefix  string
+	partial []byte
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.partial = append(p.partial, data...)
+	for {
+		i := bytes.IndexByte(p.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.writeLine(p.partial[:i+1]); err != nil {
+			return 0, err
+		}
+		p.partial = p.partial[i+1:]
+	}
+	return len(data), nil
+}
+
+// finish writes a last line without a newline, adding one.
+func (p *prefixWriter) finish() error {
+	if len(p.partial) == 0 {
+		return nil
+	}
+	line := append(p.partial, '\n')
+	p.partial = nil
+	return p.writeLine(line)
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	if p.w == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := io.WriteString(p.w, p.prefix+string(line))
+	return err
+}
+
+// syncObserver passes the notifications of concurrent variants on to o one
+// at a time.
+type syncObserver struct {
+	mu sync.Mutex
+	o  Observer
+}
+
+func (s *syncObserver) PhaseStarted(phase *Phase) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.o.PhaseStarted(phase)
+}
+
+func (s *syncObserver) PhaseFinished(phase *Phase, err error, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.o.PhaseFinished(phase, err, elapsed)
+}
+
+func (s *syncObserver) ProcessStarted(phase *Phase, pid int) {
+	if po, ok := s.o.(ProcessObserver); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		po.ProcessStarted(phase, pid)
+	}
+}
+
+func (s *syncObserver) CommandStarted(phase *Phase, index int, command string) {
+	if co, ok := s.o.(CommandObserver); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		co.CommandStarted(phase, index, command)
+	}
+}
+
+func (s *syncObserver) ShellStarting(phase *Phase, args []string, dir string, env []string) {
+	if so, ok := s.o.(ShellObserver); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		so.ShellStarting(phase, args, dir, env)
+	}
+}
+
+func (s *syncObserver) TestsReported(phase *Phase, report *TestReport) {
+	if ro, ok := s.o.(ReportObserver); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		ro.TestsReported(phase, report)
+	}
+}
+
+func (s *syncObserver) CoverageReported(phase *Phase, result CoverageResult) {
+	if co, ok := s.o.(CoverageObserver); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		co.CoverageReported(phase, result)
+	}
+}
@@ -0,0 +1,191 @@
+package bild
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVariants(t *testing.T) {
+	ph := &Phase{Name: "build", Matrix: map[string][]string{
+		"type":     {"Debug", "Release"},
+		"compiler": {"gcc", "clang"},
+	}}
+	var names []string
+	for _, v := range ph.Variants() {
+		names = append(names, ph.VariantName(v))
+	}
+	want := []string{"build[gcc,Debug]", "build[gcc,Release]", "build[clang,Debug]", "build[clang,Release]"}
+	if !slices.Equal(names, want) {
+		t.Errorf("variants = %q, want %q", names, want)
+	}
+	env := MatrixEnv(Variant{"compiler": "gcc", "build-type": "Debug"})
+	if want := []string{"BILD_MATRIX_BUILD_TYPE=Debug", "BILD_MATRIX_COMPILER=gcc"}; !slices.Equal(env, want) {
+		t.Errorf("MatrixEnv = %q, want %q", env, want)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	entries := []HistoryEntry{
+		{RunID: "1", Phase: "lint", ExitCode: 1},
+		{RunID: "2", Phase: "build[gcc]"},
+		{RunID: "2", Phase: "build[clang]", ExitCode: 2},
+		{RunID: "2", Phase: "test", ExitCode: 1},
+	}
+	var failed []string
+	for _, e := range LastFailures(entries) {
+		failed = append(failed, e.Phase)
+	}
+	if !slices.Equal(failed, []string{"build[clang]", "test"}) {
+		t.Fatalf("LastFailures = %q", failed)
+	}
+
+	proj := &Project{Phases: []Phase{
+		{Name: "test", Needs: []string{"build"}},
+		{Name: "lint"},
+		{Name: "build", Matrix: map[string][]string{"compiler": {"gcc", "clang"}}},
+	}}
+	names, err := proj.Retry(failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names, []string{"build", "test"}) {
+		t.Errorf("Retry = %q, want build before test", names)
+	}
+	build, _ := proj.Phase("build")
+	if variants := build.Variants(); len(variants) != 1 || variants[0]["compiler"] != "clang" {
+		t.Errorf("build retries %v", variants)
+	}
+}
+
+func TestRunMatrixParallel(t *testing.T) {
+	proj := &Project{Name: "lib", Phases: []Phase{{
+		Name:     "build",
+		Commands: []string{`echo "{{ .Matrix.cc }} $BILD_MATRIX_OPT"`},
+		Template: true,
+		Matrix:   map[string][]string{"cc": {"gcc", "clang"}, "opt": {"O0", "O2"}},
+		Parallel: true,
+	}}}
+	var stdout bytes.Buffer
+	r := &Runner{Stdout: &stdout, Dir: t.TempDir()}
+	if err := r.RunPhases(context.Background(), proj, nil); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	slices.Sort(lines)
+	want := []string{"[clang,O0] clang O0", "[clang,O2] clang O2", "[gcc,O0] gcc O0", "[gcc,O2] gcc O2"}
+	if !slices.Equal(lines, want) {
+		t.Errorf("output = %q, want %q", lines, want)
+	}
+}
+
+// variantOutputs keeps the output of each variant apart.
+type variantOutputs struct {
+	mu      sync.Mutex
+	outputs map[string]*bytes.Buffer
+}
+
+func (v *variantOutputs) PhaseStarted(phase *Phase)                                    {}
+func (v *variantOutputs) PhaseFinished(phase *Phase, err error, elapsed time.Duration) {}
+
+func (v *variantOutputs) PhaseOutput(phase *Phase) io.Writer {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.outputs[phase.Name] = new(bytes.Buffer)
+	return v.outputs[phase.Name]
+}
+
+func TestRunMatrixParallelPhaseOutput(t *testing.T) {
+	proj := &Project{Name: "lib", Phases: []Phase{{
+		Name:     "build",
+		Commands: []string{`echo "cc=$BILD_MATRIX_CC"`, `echo "$BILD_MATRIX_CC done" >&2`},
+		Matrix:   map[string][]string{"cc": {"gcc", "clang"}},
+		Parallel: true,
+	}}}
+	var stdout bytes.Buffer
+	observer := &variantOutputs{outputs: make(map[string]*bytes.Buffer)}
+	r := &Runner{Stdout: &stdout, Stderr: &stdout, Dir: t.TempDir(), Observer: MultiObserver(observer)}
+	if err := r.RunPhases(context.Background(), proj, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, cc := range []string{"gcc", "clang"} {
+		got := observer.outputs["build["+cc+"]"]
+		if got == nil {
+			t.Fatalf("no output of build[%s]", cc)
+		}
+		lines := strings.Split(strings.TrimSpace(got.String()), "\n")
+		slices.Sort(lines)
+		if want := []string{"cc=" + cc, cc + " done"}; !slices.Equal(lines, want) {
+			t.Errorf("output of build[%s] = %q, want %q", cc, lines, want)
+		}
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("stdout = %q, want the output kept by the observer", stdout.String())
+	}
+}
+
+func TestRunMatrixParallelMasksSecrets(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+	proj := &Project{Name: "lib", Secrets: &Secrets{Env: map[string]string{"TOKEN": "s3cr3t-value"}}, Phases: []Phase{{
+		Name:     "build",
+		Commands: []string{`echo "token=$TOKEN"`, `printf "$TOKEN" >&2`},
+		Matrix:   map[string][]string{"cc": {"gcc", "clang"}},
+		Parallel: true,
+	}}}
+	var stdout bytes.Buffer
+	r := &Runner{Stdout: &stdout, Stderr: &stdout, Dir: dir, LogDir: logDir}
+	if err := r.RunPhases(context.Background(), proj, nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stdout.String(), "s3cr3t") {
+		t.Errorf("output = %q, want the secret masked", stdout.String())
+	}
+	logs, _ := filepath.Glob(filepath.Join(logDir, "*", "*"))
+	if len(logs) != 2 {
+		t.Fatalf("logs = %q, want one per variant", logs)
+	}
+	for _, log := range logs {
+		data, err := os.ReadFile(log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "s3cr3t") || !strings.Contains(string(data), "token=") {
+			t.Errorf("%s = %q, want the secret masked", log, data)
+		}
+	}
+}
+
+func TestRunMatrixInWindows(t *testing.T) {
+	dir := t.TempDir()
+	proj := &Project{Name: "lib", Phases: []Phase{{
+		Name:     "build",
+		Commands: []string{`echo "$BILD_MATRIX_CC" > "$BILD_MATRIX_CC.out"`, `[ "$BILD_MATRIX_CC" = gcc ] || exit 3`},
+		Matrix:   map[string][]string{"cc": {"gcc", "clang"}},
+		Parallel: true,
+	}}}
+	var stdout bytes.Buffer
+	// sh stands in for a terminal, running the window's script.
+	r := &Runner{Stdout: &stdout, Dir: dir, Terminal: []string{"sh"}}
+	err := r.RunPhases(context.Background(), proj, nil)
+	var phaseErr *PhaseError
+	if !errors.As(err, &phaseErr) || phaseErr.Phase != "build[clang]" || phaseErr.ExitCode != 3 {
+		t.Fatalf("err = %v, want build[clang] failing with exit status 3", err)
+	}
+	for _, cc := range []string{"gcc", "clang"} {
+		if data, err := os.ReadFile(filepath.Join(dir, cc+".out")); err != nil || string(data) != cc+"\n" {
+			t.Errorf("%s.out = %q, %v", cc, data, err)
+		}
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("stdout = %q, want the output in the windows", stdout.String())
+	}
+}
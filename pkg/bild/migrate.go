@@ -0,0 +1,92 @@
+package bild
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ConfigVersion is the version of the configuration schema this bild reads
+// and writes. Files of older versions are upgraded as they are loaded.
+const ConfigVersion = 1
+
+// migration upgrades the decoded JSON of a configuration of version from to
+// version from+1. It works on the raw document, so that it can read fields
+// the Config type no longer has.
+type migration struct {
+	from        int
+	description string
+	apply       func(doc map[string]any) error
+}
+
+// migrations lists every schema change in order: migrations[i] upgrades
+// version i. A change to the schema appends an entry and bumps ConfigVersion.
+var migrations = []migration{
+	{0, "record the schema version", func(map[string]any) error { return nil }},
+}
+
+// migrateConfig upgrades data, a configuration of any version up to
+// ConfigVersion, and returns the version it had. data is returned unchanged
+// if it is current.
+func migrateConfig(data []byte) ([]byte, int, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, 0, err
+	}
+	version := 0
+	if v, ok := doc["version"].(float64); ok {
+		version = int(v)
+	}
+	switch {
+	case version == ConfigVersion:
+		return data, version, nil
+	case version > ConfigVersion || version < 0:
+		return nil, version, fmt.Errorf("config version %d is not supported by this bild, which knows versions up to %d; upgrade bild", version, ConfigVersion)
+	}
+	for _, m := range migrations[version:] {
+		Tracef("config", "migrating from version %d: %s", m.from, m.description)
+		if err := m.apply(doc); err != nil {
+			return nil, version, fmt.Errorf("migrating config from version %d (%s): %v", m.from, m.description, err)
+		}
+		doc["version"] = m.from + 1
+	}
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, version, err
+	}
+	return migrated, version, nil
+}
+
+// BackupPath returns where the configuration at path is saved before it is
+// migrated from version.
+func BackupPath(path string, version int) string {
+	return fmt.Sprintf("%s.v%d.bak", path, version)
+}
+
+// Migration describes the upgrade of a configuration as it was loaded.
+type Migration struct {
+	From   int    // the version the configuration had
+	Backup string // where the file was copied before it was rewritten
+}
+
+// migrateConfigFile upgrades the configuration file at path, holding data,
+// and returns its new contents as JSON, and how it was upgraded if it wasn't
+// current. The file as it was is first copied to BackupPath.
+func migrateConfigFile(path string, data []byte) ([]byte, *Migration, error) {
+	doc, err := toJSON(data, ConfigFormat(path))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	migrated, version, err := migrateConfig(doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if version == ConfigVersion {
+		return doc, nil, nil
+	}
+	backup := BackupPath(path, version)
+	if err := writeFileAtomic(backup, data); err != nil {
+		return nil, nil, fmt.Errorf("backing up %s before migrating it: %w", path, err)
+	}
+	Tracef("config", "backed up %s to %s", path, backup)
+	return migrated, &Migration{From: version, Backup: backup}, nil
+}
@@ -0,0 +1,49 @@
+package bild
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigMigrates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bild.json")
+	const old = `{"projects": {"api": {"phases": [{"name": "build", "commands": ["make"]}]}}}`
+	if err := os.WriteFile(path, []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Migrated == nil || config.Migrated.From != 0 || config.Migrated.Backup != BackupPath(path, 0) {
+		t.Fatalf("Migrated = %+v", config.Migrated)
+	}
+	if backup, err := os.ReadFile(config.Migrated.Backup); err != nil || string(backup) != old {
+		t.Errorf("backup = %q, %v, want the file as it was", backup, err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !strings.Contains(string(data), `"version": 1`) {
+		t.Errorf("the file was not upgraded:\n%s", data)
+	}
+	if _, err := config.Project("api"); err != nil {
+		t.Error(err)
+	}
+	// The migrated config saves without a conflict.
+	if err := config.Save(path); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadConfigFromTheFuture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bild.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "projects": {}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Errorf("LoadConfig of a config from the future = %v", err)
+	}
+	if _, err := os.Stat(BackupPath(path, 99)); err == nil {
+		t.Error("a config from the future was backed up")
+	}
+}
@@ -0,0 +1,332 @@
+package bild
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+// Stream identifies the standard stream output was written to.
+type Stream int
+
+const (
+	Stdout Stream = iota
+	Stderr
+)
+
+func (s Stream) String() string {
+	if s == Stderr {
+		return "stderr"
+	}
+	return "stdout"
+}
+
+// Sink receives the output of a run, tagged with the stream it was written
+// to; see Mux.
+type Sink interface {
+	WriteStream(stream Stream, p []byte) error
+}
+
+// StreamSink returns a sink writing each stream to a writer of its own, such
+// as os.Stdout and os.Stderr. Output of a stream without a writer is dropped.
+func StreamSink(stdout, stderr io.Writer) Sink {
+	return streamSink{stdout, stderr}
+}
+
+type streamSink [2]io.Writer
+
+func (s streamSink) WriteStream(stream Stream, p []byte) error {
+	if w := s[stream]; w != nil {
+		_, err := w.Write(p)
+		return err
+	}
+	return nil
+}
+
+// WriterSink returns a sink writing both streams to w, interleaved as they
+// were written, such as a log file.
+func WriterSink(w io.Writer) Sink {
+	return writerSink{w}
+}
+
+type writerSink struct{ w io.Writer }
+
+func (s writerSink) WriteStream(stream Stream, p []byte) error {
+	_, err := s.w.Write(p)
+	return err
+}
+
+// DefaultSinkBuffer is how many bytes may be queued for a sink by default.
+const DefaultSinkBuffer = 256 << 10
+
+// SinkOptions set how a Mux feeds a sink.
+type SinkOptions struct {
+	// Buffer is how many bytes may be queued for the sink before writes
+	// wait for it (default DefaultSinkBuffer).
+	Buffer int
+	// Lossy sinks, such as live views, never hold up the output: what does
+	// not fit in their buffer is dropped instead of waited for.
+	Lossy bool
+}
+
+// Mux copies output to several sinks, such as the terminal, a log file and
+// an event stream. Each sink is fed from a bounded queue by a goroutine of
+// its own, so that a slow sink holds up the commands writing only once its
+// queue is full, and a lossy one never does. A sink that fails is dropped;
+// the others keep getting the output. Output written before Flush or Close
+// returns is never lost, not even when the run is canceled, so that the last
+// lines of a failing command always arrive.
+type Mux struct {
+	mu     sync.Mutex
+	sinks  []*muxSink
+	closed bool
+}
+
+// NewMux returns a Mux without sinks.
+func NewMux() *Mux {
+	return &Mux{}
+}
+
+// Add starts feeding s with what is written to m from now on. The returned
+// function stops it again, once s has been given everything written so far,
+// and returns the error s failed with, if any.
+func (m *Mux) Add(s Sink, opts SinkOptions) (remove func() error) {
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultSinkBuffer
+	}
+	ms := &muxSink{sink: s, opts: opts, done: make(chan struct{})}
+	ms.cond = sync.NewCond(&ms.mu)
+	go ms.run()
+
+	m.mu.Lock()
+	m.sinks = append(m.sinks, ms)
+	m.mu.Unlock()
+	return func() error {
+		m.mu.Lock()
+		for i, other := range m.sinks {
+			if other == ms {
+				m.sinks = append(m.sinks[:i:i], m.sinks[i+1:]...)
+				break
+			}
+		}
+		m.mu.Unlock()
+		return ms.close()
+	}
+}
+
+// Writer returns a writer whose output goes to the sinks of m as stream.
+// It may be used concurrently with the writers of other streams.
+func (m *Mux) Writer(stream Stream) io.Writer {
+	return muxWriter{m, stream}
+}
+
+type muxWriter struct {
+	m      *Mux
+	stream Stream
+}
+
+func (w muxWriter) Write(p []byte) (int, error) {
+	w.m.write(w.stream, p)
+	return len(p), nil
+}
+
+// flush lets Runner.flushOutput wait for the output to reach the sinks.
+func (w muxWriter) flush() error {
+	return w.m.Flush()
+}
+
+// write queues p for every sink, waiting for those whose queue is full.
+func (m *Mux) write(stream Stream, p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	m.mu.Lock()
+	sinks := m.sinks
+	closed := m.closed
+	m.mu.Unlock()
+	if closed {
+		return
+	}
+	// The sinks get a copy, as callers may reuse p once Write returns.
+	data := append([]byte(nil), p...)
+	for _, s := range sinks {
+		s.push(muxChunk{stream, data})
+	}
+}
+
+// Flush waits until the sinks have been given everything written so far,
+// and returns the first error a sink failed with.
+func (m *Mux) Flush() error {
+	m.mu.Lock()
+	sinks := m.sinks
+	m.mu.Unlock()
+	var first error
+	for _, s := range sinks {
+		if err := s.flush(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Close gives the sinks what is still queued for them and stops feeding
+// them; later writes are discarded. It returns the first error a sink
+// failed with.
+func (m *Mux) Close() error {
+	m.mu.Lock()
+	sinks := m.sinks
+	m.sinks, m.closed = nil, true
+	m.mu.Unlock()
+	var first error
+	for _, s := range sinks {
+		if err := s.close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// muxChunk is one write to a Mux.
+type muxChunk struct {
+	stream Stream
+	data   []byte
+}
+
+// muxSink is the queue of a sink of a Mux and the state of the goroutine
+// draining it.
+type muxSink struct {
+	sink Sink
+	opts SinkOptions
+	done chan struct{} // closed when the goroutine has returned
+
+	mu      sync.Mutex
+	cond    *sync.Cond // signaled whenever any of the below changes
+	queue   []muxChunk
+	queued  int  // bytes in queue
+	busy    bool // a chunk is being written
+	closed  bool
+	err     error
+	dropped int // bytes a lossy sink had no room for
+}
+
+// run writes the queued chunks to the sink until it is closed.
+func (s *muxSink) run() {
+	defer close(s.done)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if len(s.queue) == 0 {
+			return
+		}
+		chunk := s.queue[0]
+		s.queue = s.queue[1:]
+		s.busy = true
+		s.mu.Unlock()
+		err := s.sink.WriteStream(chunk.stream, chunk.data)
+		s.mu.Lock()
+		s.busy = false
+		s.queued -= len(chunk.data)
+		if err != nil && s.err == nil {
+			Tracef("output", "dropping a sink that failed: %v", err)
+			s.err = err
+			s.queue, s.queued = nil, 0
+		}
+		s.cond.Broadcast()
+	}
+}
+
+// push queues chunk, first waiting for room unless the sink is lossy. A
+// chunk larger than the buffer is queued once the queue is empty.
+func (s *muxSink) push(chunk muxChunk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(chunk.data)
+	full := func() bool { return s.queued > 0 && s.queued+n > s.opts.Buffer }
+	if s.opts.Lossy && full() {
+		s.dropped += n
+		return
+	}
+	for full() && s.err == nil && !s.closed {
+		s.cond.Wait()
+	}
+	if s.err != nil || s.closed {
+		return
+	}
+	s.queue = append(s.queue, chunk)
+	s.queued += n
+	s.cond.Broadcast()
+}
+
+// flush waits until the queue has been written.
+func (s *muxSink) flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for (len(s.queue) > 0 || s.busy) && s.err == nil {
+		s.cond.Wait()
+	}
+	return s.err
+}
+
+// close writes what is queued and stops the goroutine.
+func (s *muxSink) close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	<-s.done
+	if s.dropped > 0 {
+		Tracef("output", "a lossy sink dropped %d bytes it had no room for", s.dropped)
+	}
+	return s.err
+}
+
+// TailBuffer is a writer keeping the last lines written to it, such as the
+// output shown with a failure.
+type TailBuffer struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	partial string
+}
+
+// NewTailBuffer returns a buffer keeping the last n lines.
+func NewTailBuffer(n int) *TailBuffer {
+	return &TailBuffer{max: n}
+}
+
+func (t *TailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	text := t.partial + string(p)
+	for {
+		line, rest, found := strings.Cut(text, "\n")
+		if !found {
+			break
+		}
+		t.lines = append(t.lines, strings.TrimSuffix(line, "\r"))
+		text = rest
+	}
+	t.partial = text
+	if extra := len(t.lines) - t.max; extra > 0 {
+		t.lines = append([]string(nil), t.lines[extra:]...)
+	}
+	return len(p), nil
+}
+
+// Lines returns the last lines written, including a last line without a
+// newline.
+func (t *TailBuffer) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := append([]string(nil), t.lines...)
+	if t.partial != "" {
+		lines = append(lines, t.partial)
+		if len(lines) > t.max {
+			lines = lines[1:]
+		}
+	}
+	return lines
+}
@@ -0,0 +1,73 @@
+package bild
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowSink collects what it is given, taking a while for each write.
+type slowSink struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *slowSink) WriteStream(stream Stream, p []byte) error {
+	time.Sleep(time.Millisecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf.Write(p)
+	return nil
+}
+
+type failingSink struct{}
+
+func (failingSink) WriteStream(Stream, []byte) error { return errors.New("disk full") }
+
+func TestMuxDeliversEverything(t *testing.T) {
+	mux := NewMux()
+	slow := &slowSink{}
+	var fast bytes.Buffer
+	mux.Add(slow, SinkOptions{Buffer: 16})
+	mux.Add(WriterSink(&fast), SinkOptions{})
+	failing := mux.Add(failingSink{}, SinkOptions{})
+
+	var want strings.Builder
+	w := mux.Writer(Stdout)
+	for i := range 50 {
+		line := fmt.Sprintf("line %d\n", i)
+		want.WriteString(line)
+		fmt.Fprint(w, line)
+	}
+	if err := failing(); err == nil {
+		t.Error("removing the failing sink returned no error")
+	}
+	if err := mux.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+	if got := slow.buf.String(); got != want.String() {
+		t.Errorf("slow sink got %q, want %q", got, want.String())
+	}
+	if got := fast.String(); got != want.String() {
+		t.Errorf("writer sink got %q, want %q", got, want.String())
+	}
+	// Writes after Close are discarded.
+	fmt.Fprint(w, "late\n")
+	if strings.Contains(fast.String(), "late") {
+		t.Error("writer sink got output written after Close")
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	tail := NewTailBuffer(2)
+	fmt.Fprint(tail, "one\ntwo\r\nthr")
+	fmt.Fprint(tail, "ee\nfour")
+	want := []string{"three", "four"}
+	if got := tail.Lines(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Lines = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package bild
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// isolateNetwork makes cmd start in network and user namespaces of its own,
+// where it has no network interface but a down loopback. The user namespace
+// maps the user to itself, so files are accessed as usual. It fails if the
+// system does not allow unprivileged user namespaces.
+func isolateNetwork(cmd *exec.Cmd) error {
+	if err := netnsSupported(); err != nil {
+		return err
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	setNetns(cmd.SysProcAttr)
+	return nil
+}
+
+// setNetns sets attr up to start a process in new namespaces.
+func setNetns(attr *syscall.SysProcAttr) {
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+}
+
+// netnsSupported reports, once, whether a process can be started in new
+// namespaces, by trying it.
+var netnsSupported = sync.OnceValue(func() error {
+	cmd := exec.Command("/bin/sh", "-c", ":")
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	setNetns(cmd.SysProcAttr)
+	err := cmd.Run()
+	Tracef("runner", "network namespaces: %v", err)
+	return err
+})
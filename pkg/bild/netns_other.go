@@ -0,0 +1,13 @@
+//go:build !linux
+
+package bild
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// isolateNetwork is only implemented on Linux.
+func isolateNetwork(cmd *exec.Cmd) error {
+	return errors.New("not supported on this system")
+}
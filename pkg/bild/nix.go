@@ -0,0 +1,98 @@
+package bild
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// inNixShellEnv is set by nix develop and nix-shell in the environments they
+// start, where wrapping the phases again would only slow them down.
+const inNixShellEnv = "IN_NIX_SHELL"
+
+// checkNix returns an error if mode is not a valid value of the nix setting
+// of a project or phase.
+func checkNix(mode string) error {
+	switch mode {
+	case "", "off", "auto", "flake", "shell":
+		return nil
+	}
+	if strings.Contains(mode, "#") {
+		return nil
+	}
+	return fmt.Errorf("unknown nix mode %q (want auto, flake, shell, off or a flake output such as .#ci)", mode)
+}
+
+// nixMode returns the nix setting that applies to ph of proj: its own, or
+// else the project's.
+func nixMode(proj *Project, ph *Phase) string {
+	if ph.Nix != "" {
+		return ph.Nix
+	}
+	return proj.Nix
+}
+
+// inNix changes cmd, running the shell of ph of proj, to run that shell in
+// the Nix development environment the phase asks for, if any: that of the
+// flake with nix develop, or that of shell.nix with nix-shell. Auto picks
+// flake.nix or shell.nix, whichever the phase's directory has, in that
+// order. Nothing changes in a Nix environment already.
+func (r *Runner) inNix(cmd *exec.Cmd, proj *Project, ph *Phase) error {
+	mode := nixMode(proj, ph)
+	if err := checkNix(mode); err != nil {
+		return fmt.Errorf("phase %s: %w", ph.Name, err)
+	}
+	if mode == "" || mode == "off" {
+		return nil
+	}
+	if os.Getenv(inNixShellEnv) != "" {
+		Tracef("runner", "phase %s: already in a Nix environment ($%s is set)", ph.Name, inNixShellEnv)
+		return nil
+	}
+	if mode == "auto" {
+		dir := r.Dir
+		if dir == "" {
+			dir = "."
+		}
+		switch {
+		case exists(filepath.Join(dir, "flake.nix")):
+			mode = "flake"
+		case exists(filepath.Join(dir, "shell.nix")):
+			mode = "shell"
+		default:
+			Tracef("runner", "phase %s: no flake.nix or shell.nix in %s", ph.Name, dir)
+			return nil
+		}
+	}
+
+	var args []string
+	switch mode {
+	case "shell":
+		quoted := make([]string, len(cmd.Args))
+		for i, arg := range cmd.Args {
+			quoted[i] = quoteShell(arg)
+		}
+		args = []string{"nix-shell", "--run", strings.Join(quoted, " ")}
+	default:
+		// Flakes still count as experimental in many installations.
+		args = []string{"nix", "--extra-experimental-features", "nix-command flakes", "develop"}
+		if mode != "flake" {
+			args = append(args, mode)
+		}
+		args = append(append(args, "--command"), cmd.Args...)
+	}
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("phase %s runs in a Nix environment, but %s is not installed", ph.Name, args[0])
+		}
+		return err
+	}
+	// The shell only has to exist in the Nix environment.
+	cmd.Path, cmd.Args, cmd.Err = path, args, nil
+	Tracef("runner", "phase %s: running in the Nix environment of %s", ph.Name, mode)
+	return nil
+}
@@ -0,0 +1,70 @@
+package bild
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunInNix(t *testing.T) {
+	// The fake nix and nix-shell say where they are and run the command
+	// they are given on the host.
+	bin := t.TempDir()
+	fakes := map[string]string{
+		"nix": `#!/bin/sh
+echo "in nix develop $4"
+while [ "$1" != --command ]; do shift; done
+shift
+exec "$@"
+`,
+		"nix-shell": `#!/bin/sh
+echo "in nix-shell"
+exec sh -c "$2"
+`,
+	}
+	for name, script := range fakes {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv(inNixShellEnv, "")
+
+	dir := t.TempDir()
+	proj := &Project{Name: "app", Nix: "auto", Phases: []Phase{{Name: "build", Commands: []string{"echo 'it'\"'\"'s built'"}}}}
+	run := func() string {
+		t.Helper()
+		var stdout bytes.Buffer
+		r := &Runner{Stdout: &stdout, Stderr: &stdout, Dir: dir}
+		if err := r.Run(context.Background(), proj, "build"); err != nil {
+			t.Fatalf("%v: %s", err, stdout.String())
+		}
+		return stdout.String()
+	}
+
+	if out := run(); out != "it's built\n" {
+		t.Errorf("auto without Nix files: output %q", out)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "shell.nix"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out := run(); out != "in nix-shell\nit's built\n" {
+		t.Errorf("auto with shell.nix: output %q", out)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "flake.nix"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out := run(); out != "in nix develop --command\nit's built\n" {
+		t.Errorf("auto with flake.nix: output %q", out)
+	}
+	proj.Phases[0].Nix = ".#ci"
+	if out := run(); out != "in nix develop .#ci\nit's built\n" {
+		t.Errorf("flake output: output %q", out)
+	}
+	t.Setenv(inNixShellEnv, "impure")
+	if out := run(); out != "it's built\n" {
+		t.Errorf("in a Nix environment already: output %q", out)
+	}
+}
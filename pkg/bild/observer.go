@@ -0,0 +1,203 @@
+package bild
+
+import (
+	"io"
+	"time"
+)
+
+// Observer receives progress notifications from a Runner.
+type Observer interface {
+	// PhaseStarted is called before the commands of a phase are executed.
+	PhaseStarted(phase *Phase)
+	// PhaseFinished is called once a phase has completed; err is nil on success.
+	PhaseFinished(phase *Phase, err error, elapsed time.Duration)
+}
+
+// ProcessObserver is implemented by observers that also want to know the
+// process id of the shell executing each phase.
+type ProcessObserver interface {
+	ProcessStarted(phase *Phase, pid int)
+}
+
+// CommandObserver is implemented by observers that also want to know when
+// each command of a phase starts. Since all commands of a phase share one
+// shell, the runner only instruments the phase's script for observers that
+// implement it.
+type CommandObserver interface {
+	// CommandStarted is called as the command at index of phase.Commands starts.
+	CommandStarted(phase *Phase, index int, command string)
+}
+
+// ShellObserver is implemented by observers that also want to know how the
+// shell executing each phase is started: its arguments up to the script, its
+// working directory and the environment variables bild adds to those it
+// inherits, with secret values masked.
+type ShellObserver interface {
+	ShellStarting(phase *Phase, args []string, dir string, env []string)
+}
+
+// SkipObserver is implemented by observers that also want to know about
+// phases skipped because their When condition does not hold.
+type SkipObserver interface {
+	PhaseSkipped(phase *Phase, when string)
+}
+
+// UpToDateObserver is implemented by observers that also want to know about
+// phases skipped because their inputs haven't changed since they last
+// succeeded.
+type UpToDateObserver interface {
+	PhaseUpToDate(phase *Phase)
+}
+
+// CacheObserver is implemented by observers that also want to know about
+// phases whose outputs were restored from the artifact cache instead of
+// running them.
+type CacheObserver interface {
+	PhaseRestored(phase *Phase)
+}
+
+// ReportObserver is implemented by observers that also want the test
+// results of phases with Reports, just before the phase finishes.
+type ReportObserver interface {
+	TestsReported(phase *Phase, report *TestReport)
+}
+
+// CoverageObserver is implemented by observers that also want the coverage
+// phases with a CoverageGate measured, just before the phase finishes.
+type CoverageObserver interface {
+	CoverageReported(phase *Phase, result CoverageResult)
+}
+
+// OutputObserver is implemented by observers that keep the output of the
+// variants of a matrix phase running in parallel apart, such as a dashboard
+// showing each variant's log. PhaseOutput returns where the output of
+// phase, one of those variants, goes instead of, unprefixed, to the
+// runner's Stdout and Stderr; nil leaves it there.
+type OutputObserver interface {
+	PhaseOutput(phase *Phase) io.Writer
+}
+
+// phaseOutput returns where o, or the first observer it forwards to that
+// implements OutputObserver, wants the output of phase to go, or nil.
+func phaseOutput(o Observer, phase *Phase) io.Writer {
+	if m, ok := o.(multiObserver); ok {
+		for _, o := range m {
+			if w := phaseOutput(o, phase); w != nil {
+				return w
+			}
+		}
+		return nil
+	}
+	if oo, ok := o.(OutputObserver); ok {
+		return oo.PhaseOutput(phase)
+	}
+	return nil
+}
+
+// wantsCommands reports whether o, or any observer it forwards to,
+// implements CommandObserver.
+func wantsCommands(o Observer) bool {
+	if s, ok := o.(*syncObserver); ok {
+		return wantsCommands(s.o)
+	}
+	if m, ok := o.(multiObserver); ok {
+		for _, o := range m {
+			if wantsCommands(o) {
+				return true
+			}
+		}
+		return false
+	}
+	_, ok := o.(CommandObserver)
+	return ok
+}
+
+// MultiObserver returns an Observer that forwards every notification to each
+// of observers in order. Nil observers are skipped.
+func MultiObserver(observers ...Observer) Observer {
+	var m multiObserver
+	for _, o := range observers {
+		if o != nil {
+			m = append(m, o)
+		}
+	}
+	return m
+}
+
+type multiObserver []Observer
+
+func (m multiObserver) PhaseStarted(phase *Phase) {
+	for _, o := range m {
+		o.PhaseStarted(phase)
+	}
+}
+
+func (m multiObserver) PhaseFinished(phase *Phase, err error, elapsed time.Duration) {
+	for _, o := range m {
+		o.PhaseFinished(phase, err, elapsed)
+	}
+}
+
+func (m multiObserver) ProcessStarted(phase *Phase, pid int) {
+	for _, o := range m {
+		if po, ok := o.(ProcessObserver); ok {
+			po.ProcessStarted(phase, pid)
+		}
+	}
+}
+
+func (m multiObserver) CommandStarted(phase *Phase, index int, command string) {
+	for _, o := range m {
+		if co, ok := o.(CommandObserver); ok {
+			co.CommandStarted(phase, index, command)
+		}
+	}
+}
+
+func (m multiObserver) ShellStarting(phase *Phase, args []string, dir string, env []string) {
+	for _, o := range m {
+		if so, ok := o.(ShellObserver); ok {
+			so.ShellStarting(phase, args, dir, env)
+		}
+	}
+}
+
+func (m multiObserver) PhaseSkipped(phase *Phase, when string) {
+	for _, o := range m {
+		if so, ok := o.(SkipObserver); ok {
+			so.PhaseSkipped(phase, when)
+		}
+	}
+}
+
+func (m multiObserver) PhaseUpToDate(phase *Phase) {
+	for _, o := range m {
+		if uo, ok := o.(UpToDateObserver); ok {
+			uo.PhaseUpToDate(phase)
+		}
+	}
+}
+
+func (m multiObserver) PhaseRestored(phase *Phase) {
+	for _, o := range m {
+		if co, ok := o.(CacheObserver); ok {
+			co.PhaseRestored(phase)
+		}
+	}
+}
+
+func (m multiObserver) TestsReported(phase *Phase, report *TestReport) {
+	for _, o := range m {
+		if ro, ok := o.(ReportObserver); ok {
+			ro.TestsReported(phase, report)
+		}
+	}
+}
+
+func (m multiObserver) CoverageReported(phase *Phase, result CoverageResult) {
+	for _, o := range m {
+		if co, ok := o.(CoverageObserver); ok {
+			co.CoverageReported(phase, result)
+		}
+	}
+}
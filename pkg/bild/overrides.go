@@ -0,0 +1,164 @@
+package bild
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// OverridesName is the name of the file next to the global configuration
+// holding the overrides of this machine; see Overrides.
+const OverridesName = "overrides.json"
+
+// LocalOverridesName is the name of the file next to a local configuration
+// holding the overrides of this machine, which is meant to be gitignored.
+// Like the local configuration, it maps project names to projects.
+const LocalOverridesName = ".bild.local.json"
+
+// Overrides are settings of one machine merged over a configuration as it is
+// used, but never saved into it, such as paths, core counts or credentials
+// that are not to be shared. Projects are merged as JSON: objects key by key,
+// phases by name, anything else replaced; null removes a setting.
+type Overrides struct {
+	// LogDir and Terminal replace those of the configuration if set;
+	// Experiments are merged over its.
+	LogDir      string          `json:"log_dir,omitempty"`
+	Terminal    string          `json:"terminal,omitempty"`
+	Experiments map[string]bool `json:"experiments,omitempty"`
+	// Projects maps project names to what is merged over them.
+	Projects map[string]json.RawMessage `json:"projects,omitempty"`
+
+	// Path is the file the overrides were read from.
+	Path string `json:"-"`
+}
+
+// LoadOverrides reads the overrides at path, or returns nil if there is no
+// such file.
+func LoadOverrides(path string) (*Overrides, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	o := &Overrides{Path: path}
+	if err := json.Unmarshal(data, o); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	Tracef("config", "loaded %s: overrides for %d projects", path, len(o.Projects))
+	return o, nil
+}
+
+// LoadLocalOverrides reads the .bild.local.json in dir, or returns nil if
+// there is none.
+func LoadLocalOverrides(dir string) (*Overrides, error) {
+	path := filepath.Join(dir, LocalOverridesName)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	o := &Overrides{Path: path}
+	if err := json.Unmarshal(data, &o.Projects); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	Tracef("config", "loaded %s: overrides for %d projects", path, len(o.Projects))
+	return o, nil
+}
+
+// experiment reports whether o enables or disables the experiment name, if
+// it mentions it. o may be nil.
+func (o *Overrides) experiment(name string) (enabled, ok bool) {
+	if o == nil {
+		return false, false
+	}
+	enabled, ok = o.Experiments[name]
+	return enabled, ok
+}
+
+// apply returns proj, named name, with its overrides merged in. o may be nil.
+func (o *Overrides) apply(name string, proj *Project) (*Project, error) {
+	if o == nil || o.Projects[name] == nil {
+		return proj, nil
+	}
+	data, err := json.Marshal(proj)
+	if err != nil {
+		return nil, err
+	}
+	var base, over any
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(o.Projects[name], &over); err != nil {
+		return nil, fmt.Errorf("%s: project %s: %v", o.Path, name, err)
+	}
+	if data, err = json.Marshal(mergeJSON(base, over)); err != nil {
+		return nil, err
+	}
+	var merged Project
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, fmt.Errorf("%s: project %s: %v", o.Path, name, err)
+	}
+	merged.Name, merged.Profile = proj.Name, proj.Profile
+	Tracef("config", "applied the overrides of %s to project %s", o.Path, name)
+	return &merged, nil
+}
+
+// mergeJSON returns the decoded JSON value over merged over base: objects
+// are merged key by key, with null removing a key, and arrays of objects
+// with names, such as phases, name by name. Anything else over replaces.
+func mergeJSON(base, over any) any {
+	switch over := over.(type) {
+	case map[string]any:
+		b, ok := base.(map[string]any)
+		if !ok {
+			b = make(map[string]any, len(over))
+		}
+		for key, value := range over {
+			if value == nil {
+				delete(b, key)
+			} else {
+				b[key] = mergeJSON(b[key], value)
+			}
+		}
+		return b
+	case []any:
+		b, ok := base.([]any)
+		if !ok || !named(b) || !named(over) {
+			return over
+		}
+		for _, o := range over {
+			name := o.(map[string]any)["name"]
+			found := false
+			for i, e := range b {
+				if e.(map[string]any)["name"] == name {
+					b[i], found = mergeJSON(e, o), true
+					break
+				}
+			}
+			if !found {
+				b = append(b, o)
+			}
+		}
+		return b
+	}
+	return over
+}
+
+// named reports whether values are all objects with a string name.
+func named(values []any) bool {
+	for _, v := range values {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return false
+		}
+		if _, ok := obj["name"].(string); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package bild
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// ConfigDirEnv names the environment variable that relocates all of bild's
+// files (config, data, state and cache) below a single directory.
+const ConfigDirEnv = "BILD_CONFIG_DIR"
+
+// Dirs locates the directories bild reads from and writes to.
+type Dirs struct {
+	Config string // holds bild.json
+	Data   string // holds long-lived data such as run history
+	State  string // holds transient state such as the records of in-progress runs
+	Cache  string // holds what can be rebuilt at any time, such as cached artifacts
+}
+
+// DefaultDirs returns the directories below $BILD_CONFIG_DIR if it is set.
+// Otherwise they follow the conventions of the platform: $XDG_CONFIG_HOME/bild
+// (~/.config/bild), $XDG_DATA_HOME/bild (~/.local/share/bild),
+// $XDG_STATE_HOME/bild (~/.local/state/bild) and $XDG_CACHE_HOME/bild
+// (~/.cache/bild) on Linux and other Unix systems, ~/Library/Application
+// Support/bild on macOS and %APPDATA%\bild and %LOCALAPPDATA%\bild on
+// Windows. The XDG variables are honored everywhere.
+// Directories used by earlier versions of bild, which always used the Linux
+// defaults, are kept as long as the new ones don't exist.
+func DefaultDirs() (Dirs, error) {
+	if base := os.Getenv(ConfigDirEnv); base != "" {
+		return DirsUnder(base), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Dirs{}, err
+	}
+	legacy := Dirs{
+		Config: filepath.Join(home, ".config", "bild"),
+		Data:   filepath.Join(home, ".local", "share", "bild"),
+		State:  filepath.Join(home, ".local", "state", "bild"),
+		Cache:  filepath.Join(home, ".cache", "bild"),
+	}
+	dirs := platformDirs(home, legacy)
+	for _, d := range []struct {
+		env       string
+		dir, prev *string
+	}{
+		{"XDG_CONFIG_HOME", &dirs.Config, &legacy.Config},
+		{"XDG_DATA_HOME", &dirs.Data, &legacy.Data},
+		{"XDG_STATE_HOME", &dirs.State, &legacy.State},
+		{"XDG_CACHE_HOME", &dirs.Cache, &legacy.Cache},
+	} {
+		// The XDG specification has relative paths ignored.
+		if base := os.Getenv(d.env); filepath.IsAbs(base) {
+			*d.dir = filepath.Join(base, "bild")
+		}
+		if *d.dir != *d.prev && !exists(*d.dir) && exists(*d.prev) {
+			Tracef("dirs", "%s does not exist; still using %s", *d.dir, *d.prev)
+			*d.dir = *d.prev
+		}
+	}
+	return dirs, nil
+}
+
+// platformDirs returns the default directories of the platform bild runs on.
+func platformDirs(home string, unix Dirs) Dirs {
+	switch runtime.GOOS {
+	case "darwin":
+		return DirsUnder(filepath.Join(home, "Library", "Application Support", "bild"))
+	case "windows":
+		roaming := os.Getenv("APPDATA")
+		if roaming == "" {
+			roaming = filepath.Join(home, "AppData", "Roaming")
+		}
+		local := os.Getenv("LOCALAPPDATA")
+		if local == "" {
+			local = filepath.Join(home, "AppData", "Local")
+		}
+		// Only the config roams with the user's profile.
+		return Dirs{
+			Config: filepath.Join(roaming, "bild"),
+			Data:   filepath.Join(local, "bild", "data"),
+			State:  filepath.Join(local, "bild", "state"),
+			Cache:  filepath.Join(local, "bild", "cache"),
+		}
+	}
+	return unix
+}
+
+// exists reports whether path exists.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// DirsUnder returns an isolated set of directories below base, which is
+// handy for trying out configuration changes without touching the real setup.
+func DirsUnder(base string) Dirs {
+	return Dirs{
+		Config: base,
+		Data:   filepath.Join(base, "data"),
+		State:  filepath.Join(base, "state"),
+		Cache:  filepath.Join(base, "cache"),
+	}
+}
+
+// ConfigPath returns the path of the global configuration file: bild.json,
+// or bild.toml if only that exists.
+func (d Dirs) ConfigPath() string {
+	return preferExisting(filepath.Join(d.Config, "bild.json"), filepath.Join(d.Config, "bild.toml"))
+}
+
+// RepoCachePath returns the path of the file remembering the repository
+// roots of directories; see RepoCache.
+func (d Dirs) RepoCachePath() string {
+	return filepath.Join(d.State, "repos.json")
+}
+
+// HistoryPath returns the path of the run history file.
+func (d Dirs) HistoryPath() string {
+	return filepath.Join(d.Data, "history.jsonl")
+}
+
+// DatabasePath returns the path of the SQLite database used by the sqlite
+// storage backend.
+func (d Dirs) DatabasePath() string {
+	return filepath.Join(d.Data, "bild.db")
+}
+
+// ArtifactCache returns the cache of the outputs of phases; see ArtifactCache.
+func (d Dirs) ArtifactCache() *ArtifactCache {
+	return &ArtifactCache{Dir: filepath.Join(d.Cache, "artifacts")}
+}
+
+// ensureDir creates dir (and its parents) if it does not exist yet.
+func ensureDir(dir string) error {
+	return os.MkdirAll(dir, 0755)
+}
+
+// ensureParent creates the directory containing path if it does not exist yet.
+func ensureParent(path string) error {
+	return ensureDir(filepath.Dir(path))
+}
@@ -0,0 +1,165 @@
+package bild
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultProbes are the commands printing the versions of common build
+// tools. Project.Probes adds to and overrides them.
+var DefaultProbes = map[string]string{
+	"cc":      "cc --version",
+	"c++":     "c++ --version",
+	"gcc":     "gcc --version",
+	"g++":     "g++ --version",
+	"clang":   "clang --version",
+	"clang++": "clang++ --version",
+	"cmake":   "cmake --version",
+	"ninja":   "ninja --version",
+	"make":    "make --version",
+	"meson":   "meson --version",
+	"go":      "go version",
+	"cargo":   "cargo --version",
+	"rustc":   "rustc --version",
+	"node":    "node --version",
+	"npm":     "npm --version",
+	"pnpm":    "pnpm --version",
+	"yarn":    "yarn --version",
+	"python":  "python --version",
+	"python3": "python3 --version",
+	"java":    "java -version",
+	"mvn":     "mvn --version",
+	"gradle":  "gradle --version",
+	"docker":  "docker --version",
+}
+
+// probeTimeout bounds how long a probe may take, so that a tool waiting for
+// input can't hang a run.
+const probeTimeout = 10 * time.Second
+
+// versionPattern matches the first version number in a probe's output.
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)+[-+.\w]*`)
+
+// PinDrift is a pinned tool whose version has changed.
+type PinDrift struct {
+	Tool   string
+	Pinned string
+	Actual string // empty if the tool could not be found
+}
+
+func (d PinDrift) String() string {
+	if d.Actual == "" {
+		return fmt.Sprintf("%s: pinned %s, but it is missing", d.Tool, d.Pinned)
+	}
+	return fmt.Sprintf("%s: pinned %s, found %s", d.Tool, d.Pinned, d.Actual)
+}
+
+// PinProbes returns the probes bild pin runs for p: its own probes, and the
+// default probes of the tools its commands invoke.
+func (p *Project) PinProbes() map[string]string {
+	probes := maps.Clone(p.Probes)
+	if probes == nil {
+		probes = make(map[string]string)
+	}
+	used := p.commandWords()
+	for tool, probe := range DefaultProbes {
+		if _, ok := probes[tool]; !ok && used[tool] {
+			probes[tool] = probe
+		}
+	}
+	return probes
+}
+
+// commandWords returns the words of the commands of p, and of their hooks,
+// that may name programs.
+func (p *Project) commandWords() map[string]bool {
+	words := make(map[string]bool)
+	add := func(command string) {
+		for _, w := range strings.FieldsFunc(command, func(r rune) bool {
+			return strings.ContainsRune(" \t\n;&|()`$\"'", r)
+		}) {
+			words[w] = true
+			if i := strings.LastIndexByte(w, '/'); i >= 0 {
+				words[w[i+1:]] = true
+			}
+		}
+	}
+	for cmd := range hookCommands(p.Hooks, nil) {
+		add(cmd.Text)
+	}
+	for i := range p.Phases {
+		for cmd := range Commands(&p.Phases[i]) {
+			add(cmd.Text)
+		}
+	}
+	return words
+}
+
+// Pin runs the probes of p in dir and returns the version of each tool that
+// was found. Tools of the default probes that are not installed are left
+// out; a failing probe of the project's own is an error.
+func Pin(ctx context.Context, p *Project, dir string) (map[string]string, error) {
+	pins := make(map[string]string)
+	for tool, probe := range p.PinProbes() {
+		version, err := ProbeVersion(ctx, dir, probe)
+		if err != nil {
+			if _, own := p.Probes[tool]; own {
+				return nil, fmt.Errorf("probe of %s: %w", tool, err)
+			}
+			Tracef("pin", "skipping %s: %v", tool, err)
+			continue
+		}
+		pins[tool] = version
+	}
+	return pins, nil
+}
+
+// CheckPins probes the tools pinned by p in dir and returns those whose
+// version differs from the pinned one, sorted by tool.
+func CheckPins(ctx context.Context, p *Project, dir string) []PinDrift {
+	probes := p.PinProbes()
+	var drift []PinDrift
+	for _, tool := range slices.Sorted(maps.Keys(p.Pins)) {
+		probe, ok := probes[tool]
+		if !ok {
+			if probe, ok = DefaultProbes[tool]; !ok {
+				probe = tool + " --version"
+			}
+		}
+		actual, err := ProbeVersion(ctx, dir, probe)
+		if err != nil {
+			Tracef("pin", "probe of %s failed: %v", tool, err)
+		}
+		if actual != p.Pins[tool] {
+			drift = append(drift, PinDrift{Tool: tool, Pinned: p.Pins[tool], Actual: actual})
+		}
+	}
+	return drift
+}
+
+// ProbeVersion runs the shell command probe in dir and returns the version
+// it prints: the first version number in its output, or else its first line.
+func ProbeVersion(ctx context.Context, dir, probe string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", probe)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+	if version := versionPattern.Find(out); version != nil {
+		return string(version), nil
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if line == "" {
+		return "", fmt.Errorf("%q printed nothing", probe)
+	}
+	return line, nil
+}
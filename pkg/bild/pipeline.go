@@ -0,0 +1,122 @@
+package bild
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Pipeline runs phases of several projects one after another, such as
+// packaging a library in its repository and then running the integration
+// tests of an application against the package in another. The artifacts
+// declared by the phases of each step are handed to the steps after it.
+type Pipeline struct {
+	Description string         `json:"description,omitempty"`
+	Steps       []PipelineStep `json:"steps"`
+}
+
+// PipelineStep runs a phase of a project, or all of its phases if Phase is
+// empty, in Dir: a checkout of the project's repository, whose local
+// configs are consulted as by a run started there. A leading ~/ in Dir
+// stands for the home directory.
+type PipelineStep struct {
+	Project string `json:"project"`
+	Phase   string `json:"phase,omitempty"`
+	Dir     string `json:"dir"`
+}
+
+// String describes the step for messages, e.g. "lib package".
+func (s PipelineStep) String() string {
+	if s.Phase == "" {
+		return s.Project
+	}
+	return s.Project + " " + s.Phase
+}
+
+// PipelineNames returns the names of all pipelines in sorted order.
+func (c *Config) PipelineNames() []string {
+	names := make([]string, 0, len(c.Pipelines))
+	for name := range c.Pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Pipeline returns the pipeline registered under name.
+func (c *Config) Pipeline(name string) (*Pipeline, error) {
+	pl, ok := c.Pipelines[name]
+	if !ok {
+		return nil, &PipelineNotFoundError{Pipeline: name}
+	}
+	if len(pl.Steps) == 0 {
+		return nil, fmt.Errorf("pipeline %s has no steps", name)
+	}
+	for i, step := range pl.Steps {
+		if step.Project == "" || step.Dir == "" {
+			return nil, fmt.Errorf("pipeline %s: step %d needs a project and a dir", name, i+1)
+		}
+	}
+	return &pl, nil
+}
+
+// Artifacts returns the artifacts that the phases of p a run of phase (all
+// phases if empty) in dir produced, keyed by name, as absolute paths. A
+// phase declaring an artifact its run did not produce is an error.
+func (p *Project) Artifacts(phase, dir string) (map[string]string, error) {
+	phases := p.Phases
+	if phase != "" {
+		ph, err := p.Phase(phase)
+		if err != nil {
+			return nil, err
+		}
+		phases = []Phase{*ph}
+	}
+	artifacts := make(map[string]string)
+	for _, ph := range phases {
+		for name, path := range ph.Artifacts {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			if _, err := os.Stat(path); err != nil {
+				return nil, fmt.Errorf("phase %s of %s did not produce its artifact %s: %v", ph.Name, p.Name, name, err)
+			}
+			artifacts[name] = path
+		}
+	}
+	return artifacts, nil
+}
+
+// ArtifactEnv returns the environment variables handing artifacts to
+// commands, sorted by name: the artifact "app-tarball" is passed as
+// $BILD_ARTIFACT_APP_TARBALL.
+func ArtifactEnv(artifacts map[string]string) []string {
+	env := make([]string, 0, len(artifacts))
+	for name, path := range artifacts {
+		env = append(env, ArtifactVar(name)+"="+path)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// ArtifactVar returns the name of the environment variable holding the
+// artifact name.
+func ArtifactVar(name string) string {
+	return "BILD_ARTIFACT_" + envName(name)
+}
+
+// envName returns name in upper case with characters other than letters
+// and digits replaced by underscores, as part of an environment variable.
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,38 @@
+package bild
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProjectArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "lib.tgz"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	proj := &Project{Name: "lib", Phases: []Phase{
+		{Name: "package", Artifacts: map[string]string{"tarball": "lib.tgz"}},
+		{Name: "docs", Artifacts: map[string]string{"site": "site/index.html"}},
+	}}
+
+	artifacts, err := proj.Artifacts("package", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "lib.tgz"); len(artifacts) != 1 || artifacts["tarball"] != want {
+		t.Errorf("Artifacts(package) = %v, want tarball at %s", artifacts, want)
+	}
+	// Running all phases, docs should have produced its site too.
+	if _, err := proj.Artifacts("", dir); err == nil {
+		t.Error("Artifacts of all phases succeeded without the site")
+	}
+}
+
+func TestArtifactEnv(t *testing.T) {
+	env := ArtifactEnv(map[string]string{"app-tarball": "/a.tgz", "docs": "/site"})
+	want := []string{"BILD_ARTIFACT_APP_TARBALL=/a.tgz", "BILD_ARTIFACT_DOCS=/site"}
+	if len(env) != len(want) || env[0] != want[0] || env[1] != want[1] {
+		t.Errorf("ArtifactEnv = %q, want %q", env, want)
+	}
+}
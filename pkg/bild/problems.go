@@ -0,0 +1,368 @@
+package bild
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ProblemMatcher finds the problems, such as compiler errors and failing
+// tests, a phase reports in its output. Phases name the matchers they use
+// in Phase.Problems: those of BuiltinProblemMatchers, or those a project
+// defines in Project.ProblemMatchers.
+type ProblemMatcher struct {
+	// Pattern is a regular expression matching a line reporting a problem.
+	// Its named groups make up the problem: file, line, column, severity,
+	// code and message, which is required.
+	Pattern string `json:"pattern"`
+	// Next, if set, are patterns the lines right after it must match in
+	// turn, for problems reported over several lines as rustc does. Their
+	// groups add to those of Pattern.
+	Next []string `json:"next,omitempty"`
+	// Severity is that of problems without a severity group: "error", the
+	// default, "warning" or "note".
+	Severity string `json:"severity,omitempty"`
+}
+
+// BuiltinProblemMatchers are the problem matchers phases can name without
+// defining them.
+var BuiltinProblemMatchers = map[string][]ProblemMatcher{
+	"gcc": {{
+		Pattern: `^(?P<file>[^\s:]+):(?P<line>\d+):(?:(?P<column>\d+):)?\s+(?P<severity>fatal error|error|warning|note):\s+(?P<message>.*?)(?:\s+\[(?P<code>-W[^\]]+)\])?$`,
+	}},
+	"go": {{
+		// Compiler and vet errors, and the failures of tests, which are
+		// indented below their test.
+		Pattern: `^\s*(?P<file>[^\s:]+\.go):(?P<line>\d+)(?::(?P<column>\d+))?:\s+(?P<message>.+)$`,
+	}},
+	"rustc": {{
+		Pattern: `^(?P<severity>error|warning)(?:\[(?P<code>\w+)\])?:\s+(?P<message>.+)$`,
+		Next:    []string{`^\s*--> (?P<file>[^:]+):(?P<line>\d+):(?P<column>\d+)$`},
+	}},
+	"pytest": {{
+		Pattern: `^(?P<file>[^\s:]+\.py):(?P<line>\d+): (?P<message>(?:\w+\.)*\w*(?:Error|Exception|Failed)\b.*)$`,
+	}, {
+		Pattern: `^FAILED (?P<file>[^\s:]+\.py)::(?P<code>\S+?)(?: - (?P<message>.+))?$`,
+	}},
+}
+
+func init() {
+	BuiltinProblemMatchers["clang"] = BuiltinProblemMatchers["gcc"]
+}
+
+// Problem is a problem a phase reported in its output.
+type Problem struct {
+	Phase    string `json:"phase"`
+	Matcher  string `json:"matcher"`
+	File     string `json:"file,omitempty"` // as printed by the phase
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"` // error, warning or note
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+}
+
+// String returns p as compilers print problems.
+func (p Problem) String() string {
+	var b strings.Builder
+	if p.File != "" {
+		b.WriteString(p.File + ":")
+		if p.Line > 0 {
+			fmt.Fprintf(&b, "%d:", p.Line)
+			if p.Column > 0 {
+				fmt.Fprintf(&b, "%d:", p.Column)
+			}
+		}
+		b.WriteString(" ")
+	}
+	b.WriteString(p.Severity + ": " + p.Message)
+	if p.Code != "" {
+		b.WriteString(" [" + p.Code + "]")
+	}
+	return b.String()
+}
+
+// problemMatcher is a ProblemMatcher ready to match.
+type problemMatcher struct {
+	name     string
+	patterns []*regexp.Regexp // Pattern, then Next
+	severity string
+}
+
+// compileProblemMatchers returns the matchers of the given names, those of
+// p before the built-in ones.
+func (p *Project) compileProblemMatchers(names []string) ([]*problemMatcher, error) {
+	var compiled []*problemMatcher
+	for _, name := range names {
+		matchers := BuiltinProblemMatchers[name]
+		if m, ok := p.ProblemMatchers[name]; ok {
+			matchers = []ProblemMatcher{m}
+		}
+		if matchers == nil {
+			return nil, fmt.Errorf("no problem matcher %s; define it in problem_matchers or use one of %s", name, strings.Join(slices.Sorted(maps.Keys(BuiltinProblemMatchers)), ", "))
+		}
+		for _, m := range matchers {
+			c, err := m.compile(name)
+			if err != nil {
+				return nil, err
+			}
+			compiled = append(compiled, c)
+		}
+	}
+	return compiled, nil
+}
+
+// compile checks m and compiles its patterns.
+func (m ProblemMatcher) compile(name string) (*problemMatcher, error) {
+	c := &problemMatcher{name: name, severity: m.Severity}
+	switch c.severity {
+	case "":
+		c.severity = "error"
+	case "error", "warning", "note":
+	default:
+		return nil, fmt.Errorf("problem matcher %s: severity %q is none of error, warning and note", name, m.Severity)
+	}
+	hasMessage := false
+	for _, pattern := range append([]string{m.Pattern}, m.Next...) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("problem matcher %s: %v", name, err)
+		}
+		hasMessage = hasMessage || re.SubexpIndex("message") >= 0
+		c.patterns = append(c.patterns, re)
+	}
+	if !hasMessage {
+		return nil, fmt.Errorf("problem matcher %s: a pattern needs a message group", name)
+	}
+	return c, nil
+}
+
+// Problems collects the problems the phases of a run report; see
+// Runner.Problems.
+type Problems struct {
+	mu   sync.Mutex
+	list []Problem
+	seen map[Problem]bool
+}
+
+// NewProblems returns an empty collection of problems.
+func NewProblems() *Problems {
+	return &Problems{seen: make(map[Problem]bool)}
+}
+
+// List returns the problems found, in the order they were reported; a
+// problem reported twice is listed once.
+func (p *Problems) List() []Problem {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.list)
+}
+
+// add records problem, unless it was reported before.
+func (p *Problems) add(problem Problem) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.seen[problem] {
+		p.seen[problem] = true
+		p.list = append(p.list, problem)
+	}
+}
+
+// scanner returns the sink matching the output of phase against matchers.
+func (p *Problems) scanner(phase string, matchers []*problemMatcher) *problemScanner {
+	return &problemScanner{problems: p, phase: phase, matchers: matchers}
+}
+
+// problemScanner is a Sink turning the output of a phase into problems.
+// Each stream is matched on its own, as their lines interleave.
+type problemScanner struct {
+	problems *Problems
+	phase    string
+	matchers []*problemMatcher
+
+	partial [2][]byte // unterminated output per stream
+	pending [2]*pendingProblem
+}
+
+// pendingProblem is a problem whose first lines matched, waiting for the
+// line matching the next pattern of its matcher.
+type pendingProblem struct {
+	matcher *problemMatcher
+	next    int // index of the pattern the next line must match
+	groups  map[string]string
+}
+
+func (s *problemScanner) WriteStream(stream Stream, p []byte) error {
+	s.partial[stream] = append(s.partial[stream], p...)
+	for {
+		i := bytes.IndexByte(s.partial[stream], '\n')
+		if i < 0 {
+			return nil
+		}
+		s.scan(stream, strings.TrimRight(string(s.partial[stream][:i]), "\r"))
+		s.partial[stream] = s.partial[stream][i+1:]
+	}
+}
+
+// flush matches the last lines of the streams, lacking a newline.
+func (s *problemScanner) flush() {
+	for stream, rest := range s.partial {
+		if len(rest) > 0 {
+			s.scan(Stream(stream), string(rest))
+			s.partial[stream] = nil
+		}
+	}
+}
+
+// scan matches one line of stream.
+func (s *problemScanner) scan(stream Stream, line string) {
+	line = ansi.Strip(line)
+	if pending := s.pending[stream]; pending != nil {
+		s.pending[stream] = nil
+		re := pending.matcher.patterns[pending.next]
+		if m := re.FindStringSubmatch(line); m != nil {
+			addGroups(pending.groups, re, m)
+			if pending.next++; pending.next < len(pending.matcher.patterns) {
+				s.pending[stream] = pending
+			} else {
+				s.report(pending.matcher, pending.groups)
+			}
+			return
+		}
+	}
+	for _, matcher := range s.matchers {
+		m := matcher.patterns[0].FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		groups := make(map[string]string)
+		addGroups(groups, matcher.patterns[0], m)
+		if len(matcher.patterns) > 1 {
+			s.pending[stream] = &pendingProblem{matcher: matcher, next: 1, groups: groups}
+		} else {
+			s.report(matcher, groups)
+		}
+		return
+	}
+}
+
+// addGroups adds the named groups re matched in m to groups.
+func addGroups(groups map[string]string, re *regexp.Regexp, m []string) {
+	for i, name := range re.SubexpNames() {
+		if name != "" && m[i] != "" {
+			groups[name] = m[i]
+		}
+	}
+}
+
+// report records the problem of groups found by matcher.
+func (s *problemScanner) report(matcher *problemMatcher, groups map[string]string) {
+	problem := Problem{
+		Phase:    s.phase,
+		Matcher:  matcher.name,
+		File:     groups["file"],
+		Severity: matcher.severity,
+		Code:     groups["code"],
+		Message:  strings.TrimSpace(groups["message"]),
+	}
+	problem.Line, _ = strconv.Atoi(groups["line"])
+	problem.Column, _ = strconv.Atoi(groups["column"])
+	switch severity := strings.ToLower(groups["severity"]); {
+	case strings.Contains(severity, "error"):
+		problem.Severity = "error"
+	case strings.HasPrefix(severity, "warn"):
+		problem.Severity = "warning"
+	case severity == "note" || severity == "info":
+		problem.Severity = "note"
+	}
+	if problem.Message == "" {
+		problem.Message = problem.Code
+	}
+	if problem.Message == "" {
+		return
+	}
+	s.problems.add(problem)
+}
+
+// WriteSARIF writes problems as a SARIF 2.1.0 log, the format code scanning
+// tools such as GitHub's read. Relative paths are taken to start at dir,
+// and made relative to root, if they are below it.
+func WriteSARIF(w io.Writer, problems []Problem, dir, root string) error {
+	type (
+		sarifRegion struct {
+			StartLine   int `json:"startLine,omitempty"`
+			StartColumn int `json:"startColumn,omitempty"`
+		}
+		sarifArtifact struct {
+			URI string `json:"uri"`
+		}
+		sarifPhysical struct {
+			ArtifactLocation sarifArtifact `json:"artifactLocation"`
+			Region           *sarifRegion  `json:"region,omitempty"`
+		}
+		sarifLocation struct {
+			PhysicalLocation sarifPhysical `json:"physicalLocation"`
+		}
+		sarifMessage struct {
+			Text string `json:"text"`
+		}
+		sarifResult struct {
+			RuleID    string          `json:"ruleId,omitempty"`
+			Level     string          `json:"level"`
+			Message   sarifMessage    `json:"message"`
+			Locations []sarifLocation `json:"locations,omitempty"`
+			// Properties tell which phase and matcher found the problem.
+			Properties map[string]string `json:"properties"`
+		}
+	)
+	results := []sarifResult{}
+	for _, p := range problems {
+		result := sarifResult{
+			RuleID:     p.Code,
+			Level:      p.Severity,
+			Message:    sarifMessage{p.Message},
+			Properties: map[string]string{"phase": p.Phase, "matcher": p.Matcher},
+		}
+		if p.File != "" {
+			loc := sarifPhysical{ArtifactLocation: sarifArtifact{sarifURI(p.File, dir, root)}}
+			if p.Line > 0 {
+				loc.Region = &sarifRegion{StartLine: p.Line, StartColumn: p.Column}
+			}
+			result.Locations = []sarifLocation{{loc}}
+		}
+		results = append(results, result)
+	}
+	log := map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []any{map[string]any{
+			"tool":    map[string]any{"driver": map[string]any{"name": "bild", "informationUri": "https://github.com/rkabrick/bild"}},
+			"results": results,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// sarifURI returns the URI of file, printed by a phase running in dir,
+// relative to root if it is below it.
+func sarifURI(file, dir, root string) string {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	if rel, err := filepath.Rel(root, file); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(rel)
+	}
+	return "file://" + filepath.ToSlash(file)
+}
@@ -0,0 +1,86 @@
+package bild
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestProblemScanner(t *testing.T) {
+	proj := &Project{ProblemMatchers: map[string]ProblemMatcher{
+		"todo": {Pattern: `^TODO\((?P<file>[^)]+)\): (?P<message>.+)$`, Severity: "note"},
+	}}
+	matchers, err := proj.compileProblemMatchers([]string{"gcc", "rustc", "pytest", "todo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := NewProblems()
+	s := problems.scanner("build", matchers)
+	s.WriteStream(Stdout, []byte("src/a.c:3:7: error: expected ';' [-Werror]\nerror[E0425]: cannot find x\n"))
+	s.WriteStream(Stderr, []byte("\x1b[33msrc/a.c:9: warning: unused\x1b[0m\n"))
+	s.WriteStream(Stdout, []byte("  --> src/main.rs:2:5\nerror: aborting due to 1 previous error\n\n"))
+	s.WriteStream(Stdout, []byte("src/a.c:3:7: error: expected ';' [-Werror]\n"))
+	s.WriteStream(Stdout, []byte("FAILED tests/test_x.py::test_sum - assert 1 == 2\nTODO(lib.c): fix"))
+	s.flush()
+
+	want := []Problem{
+		{Phase: "build", Matcher: "gcc", File: "src/a.c", Line: 3, Column: 7, Severity: "error", Code: "-Werror", Message: "expected ';'"},
+		{Phase: "build", Matcher: "gcc", File: "src/a.c", Line: 9, Severity: "warning", Message: "unused"},
+		{Phase: "build", Matcher: "rustc", File: "src/main.rs", Line: 2, Column: 5, Severity: "error", Code: "E0425", Message: "cannot find x"},
+		{Phase: "build", Matcher: "pytest", File: "tests/test_x.py", Severity: "error", Code: "test_sum", Message: "assert 1 == 2"},
+		{Phase: "build", Matcher: "todo", File: "lib.c", Severity: "note", Message: "fix"},
+	}
+	if got := problems.List(); !reflect.DeepEqual(got, want) {
+		t.Errorf("problems:\n%+v\nwant:\n%+v", got, want)
+	}
+}
+
+func TestCompileProblemMatchers(t *testing.T) {
+	proj := &Project{ProblemMatchers: map[string]ProblemMatcher{
+		"nomessage": {Pattern: `^(?P<file>\S+):`},
+		"severity":  {Pattern: `^(?P<message>.+)$`, Severity: "fatal"},
+		"broken":    {Pattern: `(`},
+	}}
+	for _, name := range []string{"nomessage", "severity", "broken", "missing"} {
+		if _, err := proj.compileProblemMatchers([]string{name}); err == nil {
+			t.Errorf("matcher %s compiled", name)
+		}
+	}
+	if _, err := proj.compileProblemMatchers([]string{"go", "clang"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	problems := []Problem{
+		{Phase: "build", Matcher: "gcc", File: "a.c", Line: 3, Severity: "error", Message: "boom"},
+		{Phase: "build", Matcher: "gcc", File: "/usr/include/x.h", Line: 1, Severity: "warning", Message: "meh"},
+	}
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, problems, "/repo/sub", "/repo"); err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Runs []struct {
+			Results []struct {
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string } `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	var uris []string
+	for _, r := range log.Runs[0].Results {
+		uris = append(uris, r.Level+" "+r.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	}
+	if want := []string{"error sub/a.c", "warning file:///usr/include/x.h"}; !reflect.DeepEqual(uris, want) {
+		t.Errorf("results = %q, want %q", uris, want)
+	}
+}
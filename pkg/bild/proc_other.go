@@ -0,0 +1,34 @@
+//go:build !unix
+
+package bild
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// startInGroup reports that cmd was not put into a process group of its
+// own; without process groups, only the shell itself can be signaled.
+func startInGroup(cmd *exec.Cmd, stdin io.Reader) bool {
+	return false
+}
+
+// takeTerminal does nothing: startInGroup hands no terminal over.
+func takeTerminal(cmd *exec.Cmd) bool {
+	return false
+}
+
+// signalProcess stops p. Windows cannot deliver signals other than Kill to
+// other processes.
+func signalProcess(p *os.Process, group bool, sig os.Signal) error {
+	return p.Kill()
+}
+
+// reapGroup does nothing without process groups.
+func reapGroup(cmd *exec.Cmd, sig os.Signal, delay time.Duration) {}
+
+// detach leaves cmd as it is; without sessions, it keeps running on its own
+// once started.
+func detach(cmd *exec.Cmd) {}
@@ -0,0 +1,101 @@
+//go:build unix
+
+package bild
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"golang.org/x/sys/unix"
+)
+
+// startInGroup makes cmd start in a process group of its own, so that the
+// commands it starts can be signaled together, and reports whether it did.
+// If stdin is the terminal bild runs in the foreground of, the group is
+// given the terminal for as long as it runs, so that its commands can read
+// from it and get Ctrl-C; see takeTerminal.
+func startInGroup(cmd *exec.Cmd, stdin io.Reader) bool {
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	if f, ok := stdin.(*os.File); ok && isatty.IsTerminal(f.Fd()) {
+		if pgrp, err := unix.IoctlGetInt(int(f.Fd()), unix.TIOCGPGRP); err == nil && pgrp == syscall.Getpgrp() {
+			attr.Foreground, attr.Ctty = true, int(f.Fd())
+		}
+	}
+	cmd.SysProcAttr = attr
+	return true
+}
+
+// takeTerminal gives bild back the terminal startInGroup gave the process
+// group of cmd, once cmd exited, and reports whether it did. The commands
+// had the terminal to themselves, so a Ctrl-C stopping them reached them
+// alone; it is then passed on to bild, as if bild had received it too.
+func takeTerminal(cmd *exec.Cmd) (interrupted bool) {
+	attr := cmd.SysProcAttr
+	if attr == nil || !attr.Foreground || cmd.ProcessState == nil {
+		return false
+	}
+	// bild is in the background until it is done, and would be stopped
+	// for changing the foreground group.
+	signal.Ignore(syscall.SIGTTOU)
+	err := unix.IoctlSetPointerInt(attr.Ctty, unix.TIOCSPGRP, syscall.Getpgrp())
+	signal.Reset(syscall.SIGTTOU)
+	Tracef("runner", "took the terminal back from process group %d: %v", cmd.Process.Pid, err)
+	status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() || status.Signal() != syscall.SIGINT {
+		return false
+	}
+	syscall.Kill(os.Getpid(), syscall.SIGINT)
+	return true
+}
+
+// signalProcess sends sig to p, or to its whole process group if group is set.
+func signalProcess(p *os.Process, group bool, sig os.Signal) error {
+	if !group {
+		return p.Signal(sig)
+	}
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return p.Signal(sig)
+	}
+	err := syscall.Kill(-p.Pid, s)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
+
+// reapGroup stops the commands left in the process group of cmd's exited
+// shell after it was sent sig. Background jobs ignore Ctrl-C, so after a
+// SIGINT they are sent SIGTERM; all are killed if still running after delay.
+func reapGroup(cmd *exec.Cmd, sig os.Signal, delay time.Duration) {
+	if cmd.Process == nil || cmd.SysProcAttr == nil || !cmd.SysProcAttr.Setpgid {
+		return
+	}
+	pgid := cmd.Process.Pid
+	if sig == os.Interrupt {
+		syscall.Kill(-pgid, syscall.SIGTERM)
+	}
+	if syscall.Kill(-pgid, 0) != nil {
+		return
+	}
+	Tracef("runner", "waiting for the rest of process group %d to exit", pgid)
+	for deadline := time.Now().Add(delay); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if syscall.Kill(-pgid, 0) != nil {
+			return
+		}
+	}
+	Tracef("runner", "killing what is left of process group %d", pgid)
+	syscall.Kill(-pgid, syscall.SIGKILL)
+}
+
+// detach makes cmd start in a session of its own, so that it keeps running
+// when the terminal it was started from is closed.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
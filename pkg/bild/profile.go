@@ -0,0 +1,109 @@
+package bild
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ProfileEnv is the environment variable naming the profile a run uses.
+const ProfileEnv = "BILD_PROFILE"
+
+// Profile is a named variant of a project, such as release or asan, that a
+// run can choose; see Project.WithProfile.
+type Profile struct {
+	Description string `json:"description,omitempty"`
+	// Vars and Env are merged over those of the project.
+	Vars map[string]string `json:"vars,omitempty"`
+	Env  map[string]string `json:"env,omitempty"`
+	// Phases maps names of phases to what the profile changes about them.
+	Phases map[string]PhaseProfile `json:"phases,omitempty"`
+}
+
+// PhaseProfile is what a profile changes about a phase.
+type PhaseProfile struct {
+	// Commands, if set, replace the phase's commands.
+	Commands []string `json:"commands,omitempty"`
+	// Env is merged over the phase's.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// WithProfile returns a copy of p with the profile named name applied to its
+// vars, env and phases. Commands learn the profile as {{ .Profile }} and
+// $BILD_PROFILE.
+func (p *Project) WithProfile(name string) (*Project, error) {
+	profile, ok := p.Profiles[name]
+	if !ok {
+		if len(p.Profiles) == 0 {
+			return nil, fmt.Errorf("project %s has no profiles", p.Name)
+		}
+		return nil, fmt.Errorf("project %s has no profile %s (profiles: %s)", p.Name, name, strings.Join(slices.Sorted(maps.Keys(p.Profiles)), ", "))
+	}
+	c := p.clone()
+	c.Profile = name
+	c.Vars = mergeMaps(p.Vars, profile.Vars)
+	c.Env = mergeMaps(p.Env, profile.Env)
+	for _, phase := range slices.Sorted(maps.Keys(profile.Phases)) {
+		ph, err := c.Phase(phase)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		change := profile.Phases[phase]
+		if change.Commands != nil {
+			ph.SetCommands(slices.Clone(change.Commands))
+		}
+		ph.Env = mergeMaps(ph.Env, change.Env)
+	}
+	Tracef("config", "project %s with profile %s", p.Name, name)
+	return c, nil
+}
+
+// clone returns a deep copy of p.
+func (p Profile) clone() Profile {
+	c := p
+	c.Vars = maps.Clone(p.Vars)
+	c.Env = maps.Clone(p.Env)
+	if p.Phases != nil {
+		c.Phases = make(map[string]PhaseProfile, len(p.Phases))
+		for name, ph := range p.Phases {
+			ph.Commands = slices.Clone(ph.Commands)
+			ph.Env = maps.Clone(ph.Env)
+			c.Phases[name] = ph
+		}
+	}
+	return c
+}
+
+// mergeMaps returns the entries of base and over, those of over replacing
+// those of base, or nil if there are none.
+func mergeMaps(base, over map[string]string) map[string]string {
+	if len(base)+len(over) == 0 {
+		return nil
+	}
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = make(map[string]string, len(over))
+	}
+	maps.Copy(merged, over)
+	return merged
+}
+
+// envList returns the variables of env as NAME=value, sorted by name.
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		list = append(list, name+"="+env[name])
+	}
+	return list
+}
+
+// environ returns the variables the project adds to the environment of its
+// commands and hooks: its Env and, with a profile, ProfileEnv.
+func (p *Project) environ() []string {
+	env := envList(p.Env)
+	if p.Profile != "" {
+		env = append(env, ProfileEnv+"="+p.Profile)
+	}
+	return env
+}
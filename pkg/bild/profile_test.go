@@ -0,0 +1,52 @@
+package bild
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestWithProfile(t *testing.T) {
+	proj := &Project{
+		Name: "app",
+		Phases: []Phase{
+			{Name: "build", Aliases: []string{"b"}, Commands: []string{"cmake --build build"}, Env: map[string]string{"VERBOSE": "1"}},
+			{Name: "test", Commands: []string{`echo "{{ .Profile }} {{ .Vars.type }} $CFLAGS $ASAN_OPTIONS $BILD_PROFILE"`}},
+		},
+		Template: true,
+		Vars:     map[string]string{"type": "Debug"},
+		Env:      map[string]string{"CFLAGS": "-O0", "CC": "gcc"},
+		Profiles: map[string]Profile{"asan": {
+			Vars: map[string]string{"type": "RelWithDebInfo"},
+			Env:  map[string]string{"CFLAGS": "-fsanitize=address"},
+			Phases: map[string]PhaseProfile{
+				"b":    {Commands: []string{"cmake --build build-asan"}},
+				"test": {Env: map[string]string{"ASAN_OPTIONS": "detect_leaks=1"}},
+			},
+		}},
+	}
+	asan, err := proj.WithProfile("asan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := asan.Phases[0].Commands; !slices.Equal(got, []string{"cmake --build build-asan"}) {
+		t.Errorf("build commands = %q", got)
+	}
+	if asan.Env["CC"] != "gcc" || proj.Env["CFLAGS"] != "-O0" || proj.Phases[0].Commands[0] != "cmake --build build" {
+		t.Errorf("profile env = %v, project env = %v: want the profile merged into a copy", asan.Env, proj.Env)
+	}
+
+	var stdout bytes.Buffer
+	r := &Runner{Stdout: &stdout, Dir: t.TempDir()}
+	if err := r.Run(context.Background(), asan, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "asan RelWithDebInfo -fsanitize=address detect_leaks=1 asan\n"; stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+
+	if _, err := proj.WithProfile("release"); err == nil {
+		t.Error("WithProfile(release) succeeded")
+	}
+}
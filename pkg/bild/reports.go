@@ -0,0 +1,148 @@
+package bild
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxTestFailures bounds the failed tests a TestReport names, so that a
+// broken build failing thousands of tests doesn't bloat the history.
+const maxTestFailures = 50
+
+// TestReport sums up the JUnit XML reports a phase wrote; see Phase.Reports.
+type TestReport struct {
+	Tests   int `json:"tests"`
+	Failed  int `json:"failed"` // failures and errors
+	Skipped int `json:"skipped"`
+	// Failures are the first of the tests that failed.
+	Failures []TestFailure `json:"failures,omitempty"`
+}
+
+// Passed returns how many tests passed.
+func (r *TestReport) Passed() int {
+	return r.Tests - r.Failed - r.Skipped
+}
+
+// TestFailure is a test that failed.
+type TestFailure struct {
+	Suite   string `json:"suite,omitempty"`
+	Name    string `json:"name"`
+	Message string `json:"message,omitempty"`
+}
+
+func (f TestFailure) String() string {
+	name := f.Name
+	if f.Suite != "" {
+		name = f.Suite + " › " + name
+	}
+	if f.Message != "" {
+		return name + ": " + f.Message
+	}
+	return name
+}
+
+// JUnit XML, as written by most test runners: a <testsuites> root holding
+// <testsuite> elements, or a single <testsuite>, which may nest further.
+type (
+	junitSuite struct {
+		Name   string       `xml:"name,attr"`
+		Suites []junitSuite `xml:"testsuite"`
+		Cases  []junitCase  `xml:"testcase"`
+	}
+	junitCase struct {
+		Name      string        `xml:"name,attr"`
+		Classname string        `xml:"classname,attr"`
+		Failure   *junitProblem `xml:"failure"`
+		Error     *junitProblem `xml:"error"`
+		Skipped   *struct{}     `xml:"skipped"`
+	}
+	junitProblem struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+// ParseJUnit adds the test cases of the JUnit XML report read from r to
+// report.
+func ParseJUnit(r io.Reader, report *TestReport) error {
+	var root junitSuite
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return err
+	}
+	report.add(root)
+	return nil
+}
+
+// add counts the test cases of suite and of the suites it holds.
+func (r *TestReport) add(suite junitSuite) {
+	for _, c := range suite.Cases {
+		r.Tests++
+		problem := c.Failure
+		if problem == nil {
+			problem = c.Error
+		}
+		switch {
+		case problem != nil:
+			r.Failed++
+			if len(r.Failures) < maxTestFailures {
+				name := c.Classname
+				if name == "" {
+					name = suite.Name
+				}
+				message := problem.Message
+				if message == "" {
+					// The first line of the output stands in for a message.
+					message, _, _ = strings.Cut(strings.TrimSpace(problem.Text), "\n")
+				}
+				r.Failures = append(r.Failures, TestFailure{Suite: name, Name: c.Name, Message: message})
+			}
+		case c.Skipped != nil:
+			r.Skipped++
+		}
+	}
+	for _, s := range suite.Suites {
+		r.add(s)
+	}
+}
+
+// CollectTestReports sums up the JUnit XML reports matching patterns below
+// dir that were written since the given time, so that those left over from
+// earlier runs don't count. It returns nil if there are none. Reports that
+// can't be read are left out, and reported in the error.
+func CollectTestReports(dir string, patterns []string, since time.Time) (*TestReport, error) {
+	files, err := MatchFiles(dir, patterns)
+	if err != nil {
+		return nil, err
+	}
+	// File systems may keep modification times in whole seconds.
+	since = since.Truncate(time.Second)
+	var report *TestReport
+	var errs []error
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Before(since) {
+			Tracef("reports", "leaving out %s, written before the phase", file)
+			continue
+		}
+		if report == nil {
+			report = &TestReport{}
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := ParseJUnit(f, report); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", file, err))
+		}
+		f.Close()
+	}
+	return report, errors.Join(errs...)
+}
@@ -0,0 +1,105 @@
+package bild
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const junitReport = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="api">
+    <testcase classname="api.Users" name="create"/>
+    <testcase classname="api.Users" name="delete">
+      <failure message="expected 204, got 500">stack</failure>
+    </testcase>
+    <testsuite name="nested">
+      <testcase name="flaky"><skipped/></testcase>
+      <testcase name="panics"><error>panic: nil map
+goroutine 1</error></testcase>
+    </testsuite>
+  </testsuite>
+</testsuites>`
+
+func TestParseJUnit(t *testing.T) {
+	var report TestReport
+	if err := ParseJUnit(strings.NewReader(junitReport), &report); err != nil {
+		t.Fatal(err)
+	}
+	// A report with a single suite as its root adds to the same sums.
+	if err := ParseJUnit(strings.NewReader(`<testsuite name="cli"><testcase name="help"/></testsuite>`), &report); err != nil {
+		t.Fatal(err)
+	}
+	want := TestReport{Tests: 5, Failed: 2, Skipped: 1, Failures: []TestFailure{
+		{Suite: "api.Users", Name: "delete", Message: "expected 204, got 500"},
+		{Suite: "nested", Name: "panics", Message: "panic: nil map"},
+	}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+	if report.Passed() != 2 {
+		t.Errorf("passed = %d, want 2", report.Passed())
+	}
+}
+
+func TestCollectTestReports(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string, modified time.Time) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	write("out/junit-api.xml", junitReport, start)
+	write("old/junit-stale.xml", `<testsuite><testcase name="old"/></testsuite>`, start.Add(-time.Hour))
+
+	report, err := CollectTestReports(dir, []string{"**/junit*.xml"}, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report == nil || report.Tests != 4 {
+		t.Fatalf("report = %+v, want the 4 tests of the report written since the start", report)
+	}
+
+	if report, err := CollectTestReports(dir, []string{"none/*.xml"}, start); report != nil || err != nil {
+		t.Errorf("without reports: %+v, %v; want nil", report, err)
+	}
+	write("out/junit-broken.xml", "<testsuite>", start)
+	if _, err := CollectTestReports(dir, []string{"**/junit*.xml"}, start); err == nil || !strings.Contains(err.Error(), "junit-broken.xml") {
+		t.Errorf("broken report: err = %v", err)
+	}
+}
+
+func TestSQLiteHistoryTests(t *testing.T) {
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "bild.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	tests := &TestReport{Tests: 3, Failed: 1, Failures: []TestFailure{{Name: "delete"}}}
+	for _, e := range []HistoryEntry{
+		{RunID: "1", Project: "api", Phase: "build"},
+		{RunID: "1", Project: "api", Phase: "test", ExitCode: 1, Tests: tests},
+	} {
+		if err := s.AppendHistory(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := s.History("api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Tests != nil || !reflect.DeepEqual(entries[1].Tests, tests) {
+		t.Errorf("tests = %+v, %+v; want none, then %+v", entries[0].Tests, entries[1].Tests, tests)
+	}
+}
@@ -0,0 +1,766 @@
+package bild
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Runner executes project phases, streaming their output to the configured writers.
+type Runner struct {
+	// Dir is the working directory for commands; empty means the current directory.
+	Dir string
+	// Shell is the shell used to execute a phase's script (default "sh").
+	Shell string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Env is added to the environment of commands and hooks.
+	Env []string
+
+	// Artifacts are handed to commands and hooks by name, such as those of
+	// the earlier steps of a pipeline, as $BILD_ARTIFACT_<NAME> (see
+	// ArtifactVar) and in templates as {{ .Artifacts.name }}.
+	Artifacts map[string]string
+
+	// LogDir, if set, receives a copy of each phase's output, including its
+	// hooks, in <LogDir>/<project>/<phase>-<timestamp>.log.
+	LogDir string
+
+	// KillDelay is how long commands get to exit after the context of a run
+	// is canceled and they have been signaled, before they are killed
+	// (default 10s).
+	KillDelay time.Duration
+
+	// Deadline, if set, is when Run stops starting phases. A phase running
+	// at the deadline is left to finish; Run then returns a *DeadlineError
+	// naming the phases it skipped.
+	Deadline time.Time
+
+	// Observer, if non-nil, is notified as phases start and finish.
+	Observer Observer
+
+	// Sinks receive the output of the phases and hooks, with secrets
+	// masked, besides Stdout and Stderr, such as an event stream; see Mux.
+	Sinks []Sink
+
+	// TailLines, if positive, is how many of the last lines a failing phase
+	// printed are kept in its PhaseError, e.g. to show in notifications.
+	TailLines int
+
+	// Problems, if set, collects the problems the phases report in their
+	// output, as their Problems name matchers for; see ProblemMatcher.
+	Problems *Problems
+
+	// Terminal, if set, is the command opening a terminal window or tab that
+	// runs the script given as its last argument, such as
+	// ["x-terminal-emulator", "-e"]. The variants of parallel matrix phases
+	// then run in windows of their own, each with its live output, instead of
+	// sharing Stdout and Stderr; their hooks don't. See DefaultTerminal.
+	Terminal []string
+
+	// State, if set, remembers the inputs of phases with Inputs, so that
+	// those whose inputs haven't changed since they last succeeded are
+	// skipped, unless Force is set.
+	State *ProjectState
+	Force bool
+	// Cache, if set, keeps the Outputs of such phases after they succeed,
+	// and restores them instead of running a phase whose inputs it has
+	// seen, unless Force is set.
+	Cache *ArtifactCache
+
+	// masked lists secret values replaced in output and in the phases
+	// shown to the observer.
+	masked []string
+
+	// output feeds Stdout, Stderr and Sinks during a run; see withOutput.
+	output *Mux
+
+	// matrix holds the values of the variant of a matrix phase running.
+	matrix Variant
+
+	// window is set for a phase whose commands run in a window of Terminal.
+	window bool
+}
+
+// NewRunner returns a Runner wired to the process's standard streams.
+func NewRunner() *Runner {
+	return &Runner{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// Run executes the phases of proj. If phase is empty, all phases are run in order.
+// Otherwise, only the specified phase is executed.
+// The project's hooks run around the phases.
+func (r *Runner) Run(ctx context.Context, proj *Project, phase string) error {
+	if phase == "" {
+		return r.RunPhases(ctx, proj, nil)
+	}
+	return r.RunPhases(ctx, proj, []string{phase})
+}
+
+// RunPhases executes the named phases of proj, or all of them if names is
+// empty, in the order of the project; see Project.SelectPhases. The
+// project's hooks run around the phases.
+func (r *Runner) RunPhases(ctx context.Context, proj *Project, names []string) error {
+	r, closeOutput := r.withOutput()
+	defer closeOutput()
+	for _, name := range names {
+		if _, err := r.lookupPhase(proj, name); err != nil {
+			return err
+		}
+	}
+	phases, err := proj.SelectPhases(names)
+	if err != nil {
+		return err
+	}
+	conditions := make([]condition, len(phases))
+	for i, ph := range phases {
+		if ph.When == "" {
+			continue
+		}
+		if conditions[i], err = parseCondition(ph.When); err != nil {
+			return fmt.Errorf("phase %s: %w", ph.Name, err)
+		}
+	}
+	env := proj.environ()
+	if proj.Direnv {
+		envrc, err := DirenvEnv(ctx, r.Dir)
+		if err != nil {
+			return err
+		}
+		env = append(envrc, env...)
+	}
+	if len(env) > 0 {
+		withEnv := *r
+		withEnv.Env = slices.Concat(r.Env, env)
+		r = &withEnv
+	}
+	when := &whenEnv{dir: r.Dir, env: r.Env}
+
+	if proj.Secrets != nil && !proj.Secrets.Empty() {
+		secrets, err := LoadSecrets(*proj.Secrets, r.Dir)
+		if err != nil {
+			return err
+		}
+		r = r.withSecrets(secrets)
+		defer r.flushOutput()
+	}
+
+	hooks, err := r.renderHooks(proj, nil, proj.Hooks)
+	if err != nil {
+		return err
+	}
+	err = r.runHook(ctx, proj, nil, "pre", hooks.Pre, 0)
+	for i, ph := range phases {
+		if err != nil {
+			break
+		}
+		if !r.Deadline.IsZero() && !time.Now().Before(r.Deadline) {
+			err = &DeadlineError{Deadline: r.Deadline, Skipped: phaseNames(phases[i:])}
+			break
+		}
+		if conditions[i] != nil && !conditions[i].eval(when) {
+			Tracef("runner", "skipping phase %s: %s does not hold", ph.Name, ph.When)
+			if so, ok := r.Observer.(SkipObserver); ok {
+				so.PhaseSkipped(r.shown(ph), ph.When)
+			}
+			continue
+		}
+		hash, upToDate := r.checkInputs(proj, ph)
+		if upToDate {
+			Tracef("runner", "skipping phase %s: its inputs haven't changed", ph.Name)
+			if uo, ok := r.Observer.(UpToDateObserver); ok {
+				uo.PhaseUpToDate(r.shown(ph))
+			}
+			continue
+		}
+		if hash != "" && r.restoreOutputs(proj, ph, hash) {
+			r.recordInputs(ph, hash)
+			continue
+		}
+		if len(ph.Matrix) > 0 {
+			err = r.runMatrix(ctx, proj, ph)
+		} else {
+			err = r.runPhase(ctx, proj, ph)
+		}
+		if err == nil && hash != "" {
+			r.recordInputs(ph, hash)
+			r.storeOutputs(proj, ph, hash)
+		}
+	}
+	return r.postHooks(ctx, proj, nil, hooks, err)
+}
+
+// phaseNames returns the names of phases.
+func phaseNames(phases []*Phase) []string {
+	names := make([]string, len(phases))
+	for i, ph := range phases {
+		names[i] = ph.Name
+	}
+	return names
+}
+
+// withOutput returns a copy of r writing to a Mux that feeds the Stdout,
+// Stderr and Sinks of r, and the function closing it at the end of the run,
+// if there is more to feed than Stdout and Stderr. Otherwise commands keep
+// writing to those directly, so that they can tell when they are terminals.
+func (r *Runner) withOutput() (*Runner, func()) {
+	if len(r.Sinks) == 0 && r.LogDir == "" && r.TailLines <= 0 && r.Problems == nil {
+		return r, func() {}
+	}
+	mux := NewMux()
+	if r.Stdout != nil || r.Stderr != nil {
+		mux.Add(StreamSink(r.Stdout, r.Stderr), SinkOptions{})
+	}
+	for _, sink := range r.Sinks {
+		mux.Add(sink, SinkOptions{})
+	}
+	muxed := *r
+	muxed.output = mux
+	muxed.Stdout, muxed.Stderr = mux.Writer(Stdout), mux.Writer(Stderr)
+	Tracef("output", "writing to %d sinks", len(r.Sinks)+1)
+	return &muxed, func() {
+		if err := mux.Close(); err != nil {
+			Tracef("output", "a sink failed: %v", err)
+		}
+	}
+}
+
+// withSecrets returns a copy of r passing secrets to commands and masking
+// their values in the output.
+func (r *Runner) withSecrets(secrets []string) *Runner {
+	masked := *r
+	masked.Env = append(append([]string(nil), r.Env...), secrets...)
+	masked.masked = append(append([]string(nil), r.masked...), secretValues(secrets)...)
+	if len(masked.masked) > 0 {
+		if r.Stdout != nil {
+			masked.Stdout = &maskWriter{w: r.Stdout, values: masked.masked}
+		}
+		if r.Stderr != nil {
+			masked.Stderr = &maskWriter{w: r.Stderr, values: masked.masked}
+		}
+	}
+	return &masked
+}
+
+// flushOutput writes output held back while masking secrets, and waits for
+// the output to reach the sinks.
+func (r *Runner) flushOutput() {
+	for _, w := range []io.Writer{r.Stdout, r.Stderr} {
+		if f, ok := w.(interface{ flush() error }); ok {
+			f.flush()
+		}
+	}
+	if r.output != nil {
+		r.output.Flush()
+	}
+}
+
+// shown returns ph as observers may see it, with secret values masked.
+func (r *Runner) shown(ph *Phase) *Phase {
+	if len(r.masked) == 0 {
+		return ph
+	}
+	shown := *ph
+	mask := func(commands []string) []string {
+		if commands == nil {
+			return nil
+		}
+		out := make([]string, len(commands))
+		for i, c := range commands {
+			out[i] = maskString(c, r.masked)
+		}
+		return out
+	}
+	shown.Commands = mask(ph.Commands)
+	shown.Pre, shown.OnSuccess, shown.OnFailure, shown.Always = mask(ph.Pre), mask(ph.OnSuccess), mask(ph.OnFailure), mask(ph.Always)
+	return &shown
+}
+
+// environ returns the environment of commands, with extra added.
+func (r *Runner) environ(extra ...string) []string {
+	added := r.addedEnv(extra...)
+	if len(added) == 0 {
+		return nil
+	}
+	return append(os.Environ(), added...)
+}
+
+// addedEnv returns what environ adds to the environment of bild.
+func (r *Runner) addedEnv(extra ...string) []string {
+	return slices.Concat(r.Env, ArtifactEnv(r.Artifacts), MatrixEnv(r.matrix), extra)
+}
+
+// lookupPhase returns the phase of proj named phase, warning about the use
+// of a deprecated name.
+func (r *Runner) lookupPhase(proj *Project, phase string) (*Phase, error) {
+	ph, alias, err := proj.LookupPhase(phase)
+	if err != nil {
+		return nil, err
+	}
+	if alias != nil {
+		fmt.Fprintf(r.Stderr, "Warning: phase %s has been renamed to %s; the old name is deprecated", alias.Name, ph.Name)
+		if alias.Until != nil {
+			fmt.Fprintf(r.Stderr, " and stops working after %s", alias.Until.Local().Format("2006-01-02"))
+		}
+		fmt.Fprintln(r.Stderr)
+	}
+	return ph, nil
+}
+
+// templateData returns the data command templates of ph (nil for the
+// project's hooks) are rendered with.
+func (r *Runner) templateData(proj *Project, ph *Phase) TemplateData {
+	dir := r.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	data := TemplateData{
+		Project:   proj.Name,
+		Dir:       dir,
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Artifacts: r.Artifacts,
+		Matrix:    r.matrix,
+		Vars:      proj.Vars,
+		Profile:   proj.Profile,
+	}
+	if ph != nil {
+		data.Phase = ph.Name
+	}
+	return data
+}
+
+// renderCommands returns commands with their templates expanded.
+func renderCommands(commands []string, data TemplateData) ([]string, error) {
+	if commands == nil {
+		return nil, nil
+	}
+	rendered := make([]string, len(commands))
+	for i, cmd := range commands {
+		out, err := RenderCommand(cmd, data)
+		if err != nil {
+			if data.Phase == "" {
+				return nil, fmt.Errorf("invalid command template %q: %v", cmd, err)
+			}
+			return nil, fmt.Errorf("phase %s: invalid command template %q: %v", data.Phase, cmd, err)
+		}
+		rendered[i] = out
+	}
+	return rendered, nil
+}
+
+// templated reports whether the commands of ph (nil for the project's hooks)
+// are templates to expand.
+func (p *Project) templated(ph *Phase) bool {
+	return p.Template || ph != nil && ph.Template
+}
+
+// renderHooks returns hooks with their templates expanded, if ph (nil for
+// the project's hooks) is templated.
+func (r *Runner) renderHooks(proj *Project, ph *Phase, hooks Hooks) (Hooks, error) {
+	if !proj.templated(ph) {
+		return hooks, nil
+	}
+	data := r.templateData(proj, ph)
+	var err error
+	for _, list := range []*[]string{&hooks.Pre, &hooks.OnSuccess, &hooks.OnFailure, &hooks.Always} {
+		if *list, err = renderCommands(*list, data); err != nil {
+			return Hooks{}, err
+		}
+	}
+	return hooks, nil
+}
+
+// renderPhase returns a copy of ph with the templates in its commands and
+// hooks expanded, or ph itself if it is not templated.
+func (r *Runner) renderPhase(proj *Project, ph *Phase) (*Phase, error) {
+	if !proj.templated(ph) {
+		return ph, nil
+	}
+	rendered := *ph
+	var err error
+	if rendered.Commands, err = renderCommands(ph.Commands, r.templateData(proj, ph)); err != nil {
+		return nil, err
+	}
+	if rendered.Commands == nil {
+		rendered.Commands = []string{}
+	}
+	if rendered.Hooks, err = r.renderHooks(proj, ph, ph.Hooks); err != nil {
+		return nil, err
+	}
+	return &rendered, nil
+}
+
+// shellCommand returns the command running script in the shell of ph.
+func (r *Runner) shellCommand(ctx context.Context, ph *Phase, script string) *exec.Cmd {
+	shell, flags := ph.ShellArgs(r.Shell)
+	cmd := exec.CommandContext(ctx, shell, append(flags, "-c", script)...)
+	r.handleInterrupts(ctx, cmd)
+	return cmd
+}
+
+// runHook runs the commands of hook name in a shell of their own. ph is nil
+// for the project's hooks; exitCode is the outcome the post hooks react to.
+func (r *Runner) runHook(ctx context.Context, proj *Project, ph *Phase, name string, commands []string, exitCode int) error {
+	if len(commands) == 0 {
+		return nil
+	}
+	phaseName := ""
+	if ph != nil {
+		phaseName = ph.Name
+	}
+	Tracef("runner", "running %s hook of %q (phase %q)", name, proj.Name, phaseName)
+
+	// A phase's hooks run in the phase's shell.
+	shellPhase := &Phase{}
+	if ph != nil {
+		shellPhase = ph
+	}
+	cmd := r.shellCommand(ctx, shellPhase, "set -e\n"+strings.Join(commands, "\n")+"\n")
+	cmd.Dir = r.Dir
+	cmd.Stdin = r.Stdin
+	cmd.Stdout = r.Stdout
+	cmd.Stderr = r.Stderr
+	cmd.Env = r.environ(
+		"BILD_PROJECT="+proj.Name,
+		"BILD_PHASE="+phaseName,
+		"BILD_EXIT_CODE="+strconv.Itoa(exitCode),
+	)
+	err := cmd.Run()
+	r.commandExited(ctx, cmd)
+	r.flushOutput()
+	if err != nil {
+		hookErr := &HookError{Hook: name, Phase: phaseName, ExitCode: 1, Err: err}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			hookErr.ExitCode = exitErr.ExitCode()
+		}
+		return hookErr
+	}
+	return nil
+}
+
+// postHooks runs the post hooks for the outcome err of a phase (or of the
+// run if ph is nil) and returns the outcome including them. A failing hook
+// fails a successful phase or run; after a failure it is only reported,
+// so that it doesn't hide the original error.
+func (r *Runner) postHooks(ctx context.Context, proj *Project, ph *Phase, hooks Hooks, err error) error {
+	exitCode := 0
+	if err != nil {
+		exitCode = 1
+		var phaseErr *PhaseError
+		var hookErr *HookError
+		if errors.As(err, &phaseErr) {
+			exitCode = phaseErr.ExitCode
+		} else if errors.As(err, &hookErr) {
+			exitCode = hookErr.ExitCode
+		}
+	}
+
+	var hookErr error
+	run := func(ctx context.Context, name string, commands []string) {
+		if e := r.runHook(ctx, proj, ph, name, commands, exitCode); e != nil && hookErr == nil {
+			hookErr = e
+		}
+	}
+	if err == nil {
+		run(ctx, "on_success", hooks.OnSuccess)
+	} else {
+		run(ctx, "on_failure", hooks.OnFailure)
+	}
+	// Cleanup still happens after Ctrl-C.
+	run(context.WithoutCancel(ctx), "always", hooks.Always)
+
+	if err != nil {
+		if hookErr != nil {
+			fmt.Fprintf(r.Stderr, "Warning: %v\n", hookErr)
+		}
+		return err
+	}
+	return hookErr
+}
+
+// runPhase executes all commands of a phase in a single shell process.
+func (r *Runner) runPhase(ctx context.Context, proj *Project, ph *Phase) error {
+	ph, err := r.renderPhase(proj, ph)
+	if err != nil {
+		return err
+	}
+	settings, env, err := ph.processSettings()
+	if err != nil {
+		return err
+	}
+	matchers, err := proj.compileProblemMatchers(ph.Problems)
+	if err != nil {
+		return fmt.Errorf("phase %s: %v", ph.Name, err)
+	}
+	shown := r.shown(ph)
+
+	if r.Observer != nil {
+		r.Observer.PhaseStarted(shown)
+	}
+
+	// The phase's output, hooks included, is copied to its log file, and
+	// its last lines are kept for a failure.
+	if r.LogDir != "" && r.output != nil {
+		logFile, logErr := r.openPhaseLog(proj, shown, time.Now())
+		if logErr != nil {
+			fmt.Fprintf(r.Stderr, "Warning: could not log phase %s: %v\n", ph.Name, logErr)
+		} else {
+			start := time.Now()
+			r.flushOutput()
+			stopLog := r.output.Add(WriterSink(logFile), SinkOptions{})
+			defer func() {
+				r.flushOutput()
+				if logErr := stopLog(); logErr != nil {
+					fmt.Fprintf(r.Stderr, "Warning: could not log phase %s: %v\n", ph.Name, logErr)
+				}
+				closePhaseLog(logFile, err, time.Since(start))
+			}()
+		}
+	}
+	var tail *TailBuffer
+	if r.TailLines > 0 && r.output != nil {
+		tail = NewTailBuffer(r.TailLines)
+		r.flushOutput()
+		defer r.output.Add(WriterSink(tail), SinkOptions{})()
+	}
+	if r.Problems != nil && len(matchers) > 0 && r.output != nil {
+		scanner := r.Problems.scanner(shown.Name, matchers)
+		r.flushOutput()
+		stop := r.output.Add(scanner, SinkOptions{})
+		defer func() {
+			r.flushOutput()
+			stop()
+			scanner.flush()
+		}()
+	}
+
+	// Observers of individual commands learn about them through markers the
+	// script writes to stdout; see commandMarker. Named steps are tracked
+	// the same way, so that a failure can tell which of them failed.
+	stdout := r.Stdout
+	var markers *markerWriter
+	current := -1
+	co, ok := r.Observer.(CommandObserver)
+	if ok = ok && wantsCommands(r.Observer); (ok || ph.hasSteps()) && !r.window {
+		w := r.Stdout
+		if w == nil {
+			w = io.Discard
+		}
+		markers = &markerWriter{w: w, mark: func(i int) {
+			if i >= 0 && i < len(shown.Commands) {
+				current = i
+				if ok {
+					r.flushOutput()
+					co.CommandStarted(shown, i, shown.Commands[i])
+				}
+			}
+		}}
+		stdout = markers
+	}
+
+	// Create a shell script that combines all commands in the phase
+	var script strings.Builder
+	script.WriteString("set -e\n") // Exit on any error
+	script.WriteString(settings)
+	for _, cmd := range ph.Pre {
+		script.WriteString(cmd + "\n")
+	}
+	for i, cmd := range ph.Commands {
+		if markers != nil {
+			script.WriteString(markerScript(i))
+		}
+		script.WriteString(cmd + "\n")
+	}
+
+	// Execute all commands in a single shell process
+	cmd := r.shellCommand(ctx, ph, script.String())
+	Tracef("runner", "phase %s: %d commands via %s in %q (command markers: %t)", ph.Name, len(ph.Commands), strings.Join(cmd.Args[:len(cmd.Args)-1], " "), r.Dir, markers != nil)
+	cmd.Dir = r.Dir
+	cmd.Stdin = r.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = r.Stderr
+	cmd.Env = r.environ(env...)
+	// A container of its own is the phase's sandbox, and cuts it off from
+	// the network itself. Hooks keep the network, e.g. to send notifications.
+	if ph.Container != nil {
+		err = r.inContainer(cmd, ph, r.addedEnv(env...))
+	} else if err = r.inNix(cmd, proj, ph); err == nil && ph.Offline {
+		if err := isolateNetwork(cmd); err != nil {
+			fmt.Fprintf(r.Stderr, "Warning: phase %s is offline, but its network can't be cut off (%v); only its tools are told to stay offline\n", ph.Name, err)
+		}
+	}
+	if so, ok := r.Observer.(ShellObserver); ok && err == nil {
+		dir := r.Dir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		added := append(append([]string(nil), r.Env...), env...)
+		for i, kv := range added {
+			added[i] = maskString(kv, r.masked)
+		}
+		so.ShellStarting(shown, cmd.Args[:len(cmd.Args)-1], dir, added)
+	}
+
+	start := time.Now()
+	if err != nil {
+		// The phase's container or Nix environment could not be set up.
+	} else if r.window && ph.Container == nil {
+		err = r.runInWindow(ctx, ph, cmd.Args[:len(cmd.Args)-2], script.String(), r.addedEnv(env...))
+	} else if err = cmd.Start(); err == nil {
+		if po, ok := r.Observer.(ProcessObserver); ok {
+			po.ProcessStarted(shown, cmd.Process.Pid)
+		}
+		err = cmd.Wait()
+		r.commandExited(ctx, cmd)
+		if markers != nil {
+			markers.flush()
+		}
+		r.flushOutput()
+	}
+	elapsed := time.Since(start)
+	if err != nil && ctx.Err() != nil {
+		sig := interruptSignal(ctx)
+		reapGroup(cmd, sig, r.killDelay())
+		err = &InterruptedError{Phase: ph.Name, Signal: sig}
+	} else if err != nil {
+		exitCode := 1
+		var exitErr interface{ ExitCode() int } // also a windowExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			exitCode = exitErr.ExitCode()
+		}
+		phaseErr := &PhaseError{
+			Phase:    ph.Name,
+			ExitCode: exitCode,
+			Duration: elapsed,
+			Err:      err,
+		}
+		if tail != nil {
+			// The output was flushed once the command exited.
+			phaseErr.Tail = tail.Lines()
+		}
+		if current >= 0 {
+			phaseErr.Step = ph.Step(current).Name
+		}
+		err = phaseErr
+	}
+	if err == nil && ph.Coverage != nil {
+		result, coverageErr := r.checkCoverage(ph, start)
+		if co, ok := r.Observer.(CoverageObserver); ok && result != nil {
+			co.CoverageReported(shown, *result)
+		}
+		if coverageErr != nil {
+			err = &PhaseError{Phase: ph.Name, ExitCode: 1, Duration: elapsed, Err: fmt.Errorf("coverage gate: %w", coverageErr)}
+		}
+	}
+	if err = r.postHooks(ctx, proj, ph, ph.Hooks, err); err != nil {
+		// A failing post hook fails the phase like a failing command.
+		var hookErr *HookError
+		if errors.As(err, &hookErr) {
+			err = &PhaseError{Phase: ph.Name, ExitCode: hookErr.ExitCode, Duration: elapsed, Err: hookErr}
+		}
+	}
+	if len(ph.Reports) > 0 {
+		report, reportErr := CollectTestReports(r.Dir, ph.Reports, start)
+		if reportErr != nil {
+			fmt.Fprintf(r.Stderr, "Warning: could not read the test reports of phase %s: %v\n", ph.Name, reportErr)
+		}
+		if ro, ok := r.Observer.(ReportObserver); ok && report != nil {
+			ro.TestsReported(shown, report)
+		}
+	}
+	if r.Observer != nil {
+		r.Observer.PhaseFinished(shown, err, elapsed)
+	}
+	return err
+}
+
+// commandMarker is written to the phase's stdout before each command, followed
+// by the command's index and a NUL byte. Sharing the stream with the output
+// keeps the notifications in order with what the commands print.
+const commandMarker = "\x00bild-command:"
+
+// markerWriter passes output through to w, removing command markers and
+// reporting them to mark.
+type markerWriter struct {
+	w       io.Writer
+	mark    func(index int)
+	pending []byte // the start of what may be a marker split across writes
+}
+
+// markerScript returns the script line writing the marker of the command at index.
+func markerScript(index int) string {
+	return fmt.Sprintf("printf '\\000bild-command:%d\\000'\n", index)
+}
+
+func (m *markerWriter) Write(p []byte) (int, error) {
+	buf := append(m.pending, p...)
+	m.pending = nil
+	for len(buf) > 0 {
+		i := bytes.IndexByte(buf, 0)
+		if i < 0 {
+			break
+		}
+		rest := buf[i:]
+		n := len(commandMarker)
+		if len(rest) < n && strings.HasPrefix(commandMarker, string(rest)) {
+			// Possibly a marker cut short; hold it back until the next write.
+			m.pending = append([]byte(nil), rest...)
+			buf = buf[:i]
+			break
+		}
+		if !bytes.HasPrefix(rest, []byte(commandMarker)) {
+			if _, err := m.w.Write(buf[:i+1]); err != nil {
+				return 0, err
+			}
+			buf = buf[i+1:]
+			continue
+		}
+		end := bytes.IndexByte(rest[n:], 0)
+		if end < 0 {
+			m.pending = append([]byte(nil), rest...)
+			buf = buf[:i]
+			break
+		}
+		if _, err := m.w.Write(buf[:i]); err != nil {
+			return 0, err
+		}
+		if index, err := strconv.Atoi(string(rest[n : n+end])); err == nil {
+			m.mark(index)
+		}
+		buf = rest[n+end+1:]
+	}
+	if len(buf) > 0 {
+		if _, err := m.w.Write(buf); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes output held back as a possible marker.
+func (m *markerWriter) flush() error {
+	if len(m.pending) == 0 {
+		return nil
+	}
+	_, err := m.w.Write(m.pending)
+	m.pending = nil
+	return err
+}
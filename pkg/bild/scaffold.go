@@ -0,0 +1,197 @@
+package bild
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProjectTemplate is a starting set of phases for a kind of project.
+type ProjectTemplate struct {
+	Name        string  `json:"-"`
+	Description string  `json:"description,omitempty"`
+	Phases      []Phase `json:"phases"`
+	// Markers are the files, or glob patterns, telling a repository is of
+	// the template's kind, as go.mod for Go.
+	Markers []string `json:"markers,omitempty"`
+	// Path is the file a user template was read from; empty for built-in ones.
+	Path string `json:"-"`
+}
+
+// builtinTemplates are the templates available without any setup, in the
+// order DetectTemplates prefers them.
+var builtinTemplates = []ProjectTemplate{
+	{
+		Name:        "cmake",
+		Description: "CMake project built out of tree in build/",
+		Markers:     []string{"CMakeLists.txt"},
+		Phases: []Phase{
+			{Name: "configure", Commands: []string{"cmake -S . -B build -DCMAKE_BUILD_TYPE=RelWithDebInfo"}},
+			{Name: "build", Commands: []string{"cmake --build build --parallel {{numCPU}}"}, Template: true},
+			{Name: "test", Commands: []string{"ctest --test-dir build --output-on-failure"}},
+		},
+	},
+	{
+		Name:        "meson",
+		Description: "Meson project built in build/",
+		Markers:     []string{"meson.build"},
+		Phases: []Phase{
+			{Name: "configure", Commands: []string{"meson setup build"}},
+			{Name: "build", Commands: []string{"meson compile -C build"}},
+			{Name: "test", Commands: []string{"meson test -C build"}},
+		},
+	},
+	{
+		Name:        "go",
+		Description: "Go module",
+		Markers:     []string{"go.mod"},
+		Phases: []Phase{
+			{Name: "configure", Commands: []string{"go mod download"}},
+			{Name: "build", Commands: []string{"go build ./..."}},
+			{Name: "test", Commands: []string{"go vet ./...", "go test ./..."}},
+		},
+	},
+	{
+		Name:        "rust",
+		Description: "Cargo package or workspace",
+		Markers:     []string{"Cargo.toml"},
+		Phases: []Phase{
+			{Name: "configure", Commands: []string{"cargo fetch"}},
+			{Name: "build", Commands: []string{"cargo build"}},
+			{Name: "test", Commands: []string{"cargo test"}},
+		},
+	},
+	{
+		Name:        "node",
+		Description: "npm package",
+		Markers:     []string{"package.json"},
+		Phases: []Phase{
+			{Name: "configure", Commands: []string{"npm ci"}},
+			{Name: "build", Commands: []string{"npm run build --if-present"}},
+			{Name: "test", Commands: []string{"npm test"}},
+		},
+	},
+	{
+		Name:        "make",
+		Description: "Makefile",
+		Markers:     []string{"GNUmakefile", "makefile", "Makefile"},
+		Phases: []Phase{
+			{Name: "build", Commands: []string{"make -j{{numCPU}}"}, Template: true},
+			{Name: "test", Commands: []string{"make test"}},
+		},
+	},
+}
+
+// TemplatesDir returns the directory holding user-defined project templates,
+// one <name>.json file each.
+func (d Dirs) TemplatesDir() string {
+	return filepath.Join(d.Config, "templates")
+}
+
+// ProjectTemplates returns the built-in templates together with the user
+// templates in dir, sorted by name. A user template replaces a built-in one
+// of the same name.
+func ProjectTemplates(dir string) ([]ProjectTemplate, error) {
+	byName := make(map[string]ProjectTemplate)
+	for _, tmpl := range builtinTemplates {
+		byName[tmpl.Name] = tmpl
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		tmpl, err := loadProjectTemplate(path)
+		if err != nil {
+			return nil, err
+		}
+		byName[tmpl.Name] = tmpl
+	}
+
+	templates := make([]ProjectTemplate, 0, len(byName))
+	for _, tmpl := range byName {
+		templates = append(templates, tmpl)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// LookupProjectTemplate returns the template called name, preferring a user
+// template in dir over a built-in one.
+func LookupProjectTemplate(dir, name string) (ProjectTemplate, error) {
+	templates, err := ProjectTemplates(dir)
+	if err != nil {
+		return ProjectTemplate{}, err
+	}
+	names := make([]string, len(templates))
+	for i, tmpl := range templates {
+		if tmpl.Name == name {
+			return tmpl, nil
+		}
+		names[i] = tmpl.Name
+	}
+	return ProjectTemplate{}, fmt.Errorf("unknown template %s (available: %s)", name, strings.Join(names, ", "))
+}
+
+// Detection is a template whose marker was found in a repository.
+type Detection struct {
+	Template ProjectTemplate
+	// Marker is the file that was found.
+	Marker string
+}
+
+// DetectTemplates returns the templates, user templates in templatesDir
+// included, whose markers dir holds. Built-in kinds come first, in the
+// order of builtinTemplates, so that a CMake project with a generated
+// Makefile is taken for CMake; user templates of other kinds follow by
+// name.
+func DetectTemplates(dir, templatesDir string) ([]Detection, error) {
+	templates, err := ProjectTemplates(templatesDir)
+	if err != nil {
+		return nil, err
+	}
+	rank := make(map[string]int, len(builtinTemplates))
+	for i, tmpl := range builtinTemplates {
+		rank[tmpl.Name] = i + 1
+	}
+	sort.SliceStable(templates, func(i, j int) bool {
+		ri, rj := rank[templates[i].Name], rank[templates[j].Name]
+		return ri != 0 && (rj == 0 || ri < rj)
+	})
+
+	var detections []Detection
+	for _, tmpl := range templates {
+		for _, marker := range tmpl.Markers {
+			matches, err := filepath.Glob(filepath.Join(dir, marker))
+			if err != nil {
+				return nil, fmt.Errorf("template %s: marker %s: %v", tmpl.Name, marker, err)
+			}
+			if len(matches) > 0 {
+				Tracef("detect", "%s has %s", tmpl.Name, filepath.Base(matches[0]))
+				detections = append(detections, Detection{Template: tmpl, Marker: filepath.Base(matches[0])})
+				break
+			}
+		}
+	}
+	return detections, nil
+}
+
+// loadProjectTemplate reads a user template; its name is the file name
+// without the .json extension.
+func loadProjectTemplate(path string) (ProjectTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ProjectTemplate{}, err
+	}
+	var tmpl ProjectTemplate
+	if err := json.Unmarshal(data, &tmpl); err != nil {
+		return ProjectTemplate{}, fmt.Errorf("template %s: %v", path, err)
+	}
+	tmpl.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+	tmpl.Path = path
+	return tmpl, nil
+}
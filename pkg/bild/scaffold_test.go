@@ -0,0 +1,39 @@
+package bild
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectTemplates(t *testing.T) {
+	repo, templates := t.TempDir(), t.TempDir()
+	for _, name := range []string{"Makefile", "CMakeLists.txt", "app.csproj"} {
+		if err := os.WriteFile(filepath.Join(repo, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dotnet := `{"markers": ["*.csproj"], "phases": [{"name": "build", "commands": ["dotnet build"]}]}`
+	if err := os.WriteFile(filepath.Join(templates, "dotnet.json"), []byte(dotnet), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	detections, err := DetectTemplates(repo, templates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range detections {
+		got = append(got, d.Template.Name+":"+d.Marker)
+	}
+	want := []string{"cmake:CMakeLists.txt", "make:Makefile", "dotnet:app.csproj"}
+	if len(got) != len(want) {
+		t.Fatalf("detected %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("detected %v, want %v: built-in kinds in their order, then user templates", got, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package bild
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Secrets declares where a project's secrets come from. They are passed to
+// commands and hooks as environment variables and masked in what bild shows.
+type Secrets struct {
+	// EnvFiles are dotenv files, relative to the directory the project runs
+	// in. Files that don't exist are skipped.
+	EnvFiles []string `json:"env_files,omitempty"`
+	// Keyring maps environment variable names to entries of the OS keyring:
+	// generic passwords with that service name in the macOS keychain, or
+	// items with that "service" attribute in the Secret Service (secret-tool).
+	Keyring map[string]string `json:"keyring,omitempty"`
+	// Env maps environment variable names to values holding secret
+	// references such as "${secret:vault:kv/build/token}", which are
+	// resolved as a run starts. Values in EnvFiles may hold them too.
+	Env map[string]string `json:"env,omitempty"`
+	// Resolvers maps the backend names of secret references to shell
+	// commands printing the secret named by $BILD_SECRET_REF, adding to and
+	// overriding DefaultResolvers.
+	Resolvers map[string]string `json:"resolvers,omitempty"`
+}
+
+// Empty reports whether no secrets are declared.
+func (s Secrets) Empty() bool {
+	return len(s.EnvFiles) == 0 && len(s.Keyring) == 0 && len(s.Env) == 0
+}
+
+// SecretRefEnv names the environment variable passing the reference to the
+// command of a secret resolver.
+const SecretRefEnv = "BILD_SECRET_REF"
+
+// DefaultResolvers are the commands resolving secret references of common
+// backends: ${secret:vault:kv/build/token} reads the field token of the
+// secret kv/build from Vault, ${secret:ssm:/build/token} an AWS SSM
+// parameter, and ${secret:op:Build/GitHub/token} a 1Password item field.
+// ${secret:keyring:entry} reads an entry of the OS keyring like Keyring,
+// and ${secret:encrypted:v1.k....} decrypts a value bild encrypt env wrote.
+var DefaultResolvers = map[string]string{
+	"vault": `vault kv get -field="${BILD_SECRET_REF##*/}" "${BILD_SECRET_REF%/*}"`,
+	"ssm":   `aws ssm get-parameter --name "$BILD_SECRET_REF" --with-decryption --query Parameter.Value --output text`,
+	"op":    `op read "op://$BILD_SECRET_REF"`,
+}
+
+// keyringResolver is the backend of references to the OS keyring, which
+// needs no command.
+const keyringResolver = "keyring"
+
+// encryptedResolver is the backend of references holding a value encrypted
+// with the key file or passphrase; see Encrypt.
+const encryptedResolver = "encrypted"
+
+// resolverTimeout bounds how long a resolver may take, so that a backend that
+// can't be reached doesn't hang a run.
+const resolverTimeout = time.Minute
+
+// secretRefPattern matches a secret reference, capturing its backend and the
+// reference passed to the backend's resolver.
+var secretRefPattern = regexp.MustCompile(`\$\{secret:([\w-]+):([^}]+)\}`)
+
+// SecretRefs returns the backends of the secret references in value.
+func SecretRefs(value string) []string {
+	var backends []string
+	for _, m := range secretRefPattern.FindAllStringSubmatch(value, -1) {
+		backends = append(backends, m[1])
+	}
+	return backends
+}
+
+// HasResolver reports whether s can resolve references to backend.
+func (s Secrets) HasResolver(backend string) bool {
+	_, ok := s.Resolvers[backend]
+	_, builtin := DefaultResolvers[backend]
+	return ok || builtin || backend == keyringResolver || backend == encryptedResolver
+}
+
+// secretResolver resolves the secret references of a run, looking each one
+// up only once.
+type secretResolver struct {
+	commands map[string]string
+	resolved map[string]string
+}
+
+func newSecretResolver(s Secrets) *secretResolver {
+	commands := maps.Clone(DefaultResolvers)
+	maps.Copy(commands, s.Resolvers)
+	return &secretResolver{commands: commands, resolved: make(map[string]string)}
+}
+
+// expand replaces the secret references in value with the secrets.
+func (r *secretResolver) expand(value string) (string, error) {
+	var err error
+	expanded := secretRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		if secret, ok := r.resolved[ref]; ok {
+			return secret
+		}
+		m := secretRefPattern.FindStringSubmatch(ref)
+		var secret string
+		if secret, err = r.resolve(m[1], m[2]); err != nil {
+			err = fmt.Errorf("resolving %s: %v", ref, err)
+			return ""
+		}
+		r.resolved[ref] = secret
+		return secret
+	})
+	return expanded, err
+}
+
+// resolve runs the resolver of backend for ref.
+func (r *secretResolver) resolve(backend, ref string) (string, error) {
+	command, ok := r.commands[backend]
+	if !ok {
+		switch backend {
+		case keyringResolver:
+			return keyringLookup(ref)
+		case encryptedResolver:
+			secret, err := Decrypt(ref)
+			return string(secret), err
+		}
+		return "", fmt.Errorf("no resolver for %s; add one to the project's secrets.resolvers", backend)
+	}
+	Tracef("secrets", "resolving a %s reference with: %s", backend, command)
+	ctx, cancel := context.WithTimeout(context.Background(), resolverTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), SecretRefEnv+"="+ref)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+	secret := strings.TrimSuffix(string(out), "\n")
+	if secret == "" {
+		return "", errors.New("the resolver printed nothing")
+	}
+	return secret, nil
+}
+
+// secretMask replaces secret values in output.
+const secretMask = "***"
+
+// minMaskedLength is the length below which values are not masked, since
+// masking every "1" or "on" would garble the output.
+const minMaskedLength = 4
+
+// LoadSecrets reads the secrets declared by s for a project run in dir and
+// returns them as environment variables, sorted by name.
+func LoadSecrets(s Secrets, dir string) ([]string, error) {
+	values := make(map[string]string)
+	resolver := newSecretResolver(s)
+	for _, name := range s.EnvFiles {
+		path := name
+		if !filepath.IsAbs(path) && dir != "" {
+			path = filepath.Join(dir, path)
+		}
+		vars, err := readEnvFile(path)
+		if os.IsNotExist(err) {
+			Tracef("secrets", "skipping %s: it does not exist", path)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("secrets: %v", err)
+		}
+		Tracef("secrets", "loaded %d variables from %s", len(vars), path)
+		for k, v := range vars {
+			if values[k], err = resolver.expand(v); err != nil {
+				return nil, fmt.Errorf("secrets: %s from %s: %v", k, path, err)
+			}
+		}
+	}
+	for name, entry := range s.Keyring {
+		value, err := keyringLookup(entry)
+		if err != nil {
+			return nil, fmt.Errorf("secrets: %s from keyring entry %q: %v", name, entry, err)
+		}
+		Tracef("secrets", "loaded %s from keyring entry %q", name, entry)
+		values[name] = value
+	}
+	for name, value := range s.Env {
+		expanded, err := resolver.expand(value)
+		if err != nil {
+			return nil, fmt.Errorf("secrets: %s: %v", name, err)
+		}
+		values[name] = expanded
+	}
+
+	env := make([]string, 0, len(values))
+	for k, v := range values {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+// readEnvFile parses a dotenv file: KEY=VALUE lines, optionally prefixed with
+// "export", with single-quoted values taken literally and double-quoted ones
+// understanding \n, \" and \\. Blank lines and # comments are ignored.
+func readEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+		default:
+			// An unquoted value ends at a comment.
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		vars[key] = value
+	}
+	return vars, scanner.Err()
+}
+
+// keyringLookup returns the secret stored in the OS keyring under entry.
+func keyringLookup(entry string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", entry, "-w")
+	case "windows":
+		return "", errors.New("the OS keyring is not supported on Windows; use an env file")
+	default:
+		cmd = exec.Command("secret-tool", "lookup", "service", entry)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+	value := strings.TrimSuffix(string(out), "\n")
+	if value == "" {
+		return "", errors.New("no such entry")
+	}
+	return value, nil
+}
+
+// secretValues returns the values of env worth masking.
+func secretValues(env []string) []string {
+	var values []string
+	for _, kv := range env {
+		_, v, _ := strings.Cut(kv, "=")
+		if len(v) >= minMaskedLength {
+			values = append(values, v)
+		}
+	}
+	// Longer values first, so that a value containing another is masked whole.
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	return values
+}
+
+// maskString replaces the values in s.
+func maskString(s string, values []string) string {
+	for _, v := range values {
+		s = strings.ReplaceAll(s, v, secretMask)
+	}
+	return s
+}
+
+// maskWriter passes output through to w with secret values replaced. Output
+// that could be the start of a value split across writes is held back.
+type maskWriter struct {
+	w       io.Writer
+	values  []string
+	pending []byte
+}
+
+func (m *maskWriter) Write(p []byte) (int, error) {
+	buf := maskString(string(m.pending)+string(p), m.values)
+	m.pending = nil
+	// Hold back the longest tail that a value starts with.
+	hold := 0
+	for _, v := range m.values {
+		for n := min(len(v)-1, len(buf)); n > hold; n-- {
+			if strings.HasSuffix(buf, v[:n]) {
+				hold = n
+				break
+			}
+		}
+	}
+	if hold > 0 {
+		m.pending = []byte(buf[len(buf)-hold:])
+		buf = buf[:len(buf)-hold]
+	}
+	if _, err := io.WriteString(m.w, buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// flush writes output held back as a possible secret.
+func (m *maskWriter) flush() error {
+	if len(m.pending) == 0 {
+		return nil
+	}
+	_, err := m.w.Write(m.pending)
+	m.pending = nil
+	return err
+}
@@ -0,0 +1,275 @@
+package bild
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the pure-Go "sqlite" driver
+)
+
+// sqliteSchema creates the tables of the SQLite storage backend. The
+// configuration is kept as one JSON document, exactly as in bild.json; the
+// history gets a row per entry so it can be queried without reading it all.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS config (
+	id   INTEGER PRIMARY KEY CHECK (id = 1),
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS history (
+	id        INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id    TEXT NOT NULL,
+	project   TEXT NOT NULL,
+	phase     TEXT NOT NULL,
+	start     INTEGER NOT NULL, -- Unix nanoseconds
+	duration  INTEGER NOT NULL, -- nanoseconds
+	exit_code INTEGER NOT NULL,
+	commit_id TEXT NOT NULL,
+	command   TEXT NOT NULL,
+	user      TEXT NOT NULL DEFAULT '',
+	since     TEXT NOT NULL DEFAULT '',
+	pipeline  TEXT NOT NULL DEFAULT '',
+	step      TEXT NOT NULL DEFAULT '',
+	tests     TEXT NOT NULL DEFAULT '', -- a TestReport as JSON
+	coverage  REAL -- percent, NULL without a coverage gate
+);
+CREATE INDEX IF NOT EXISTS history_project ON history (project, phase);
+`
+
+// SQLiteStorage keeps the configuration and run history in a SQLite database.
+// Unlike the JSON files it can be written to by several processes at once.
+type SQLiteStorage struct {
+	db   *sql.DB
+	path string
+	// ConfigDir, if set, is the directory the overrides of the
+	// configuration are read from and its includes are relative to.
+	ConfigDir string
+}
+
+// OpenSQLite opens (and if needed creates) the database at path.
+func OpenSQLite(path string) (*SQLiteStorage, error) {
+	Tracef("storage", "opening SQLite database %s", path)
+	if err := ensureParent(path); err != nil {
+		return nil, err
+	}
+	// WAL lets readers proceed while another process writes; the busy timeout
+	// makes concurrent writers wait for each other instead of failing.
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, column := range []string{"user", "since", "pipeline", "step", "tests"} {
+		if err := addSQLiteColumn(db, "history", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if err := addSQLiteColumn(db, "history", "coverage", "REAL"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStorage{db: db, path: path}, nil
+}
+
+// addSQLiteColumn adds a column introduced after the table was first created
+// to databases that do not have it yet.
+func addSQLiteColumn(db *sql.DB, table, column, definition string) error {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+	return err
+}
+
+// Close closes the database.
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// selectConfig reads the stored configuration document.
+const selectConfig = `SELECT data FROM config WHERE id = 1`
+
+// storedVersion returns the version of the configuration document row holds,
+// the result of selectConfig, with the database at path standing in for the
+// file; the sum is zero if no configuration was saved yet.
+func storedVersion(row *sql.Row, path string) (*fileVersion, []byte, error) {
+	var data string
+	err := row.Scan(&data)
+	if err == sql.ErrNoRows {
+		return &fileVersion{path: path}, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &fileVersion{path: path, sum: sha256.Sum256([]byte(data))}, []byte(data), nil
+}
+
+// configBackupPath returns where the configuration document is saved before
+// it is migrated from version. It holds JSON, as bild.json does, not a
+// database.
+func (s *SQLiteStorage) configBackupPath(version int) string {
+	return BackupPath(strings.TrimSuffix(s.path, filepath.Ext(s.path))+"-config.json", version)
+}
+
+// Load returns the stored configuration (or an empty config if none was saved yet).
+// A document of an older version is copied to configBackupPath and upgraded
+// in the database, as LoadConfig does with a file.
+func (s *SQLiteStorage) Load() (*Config, error) {
+	config := NewConfig()
+	version, data, err := storedVersion(s.db.QueryRow(selectConfig), s.path)
+	if err != nil {
+		return nil, err
+	}
+	config.loaded = version
+	if data == nil {
+		return s.withFiles(config)
+	}
+	migrated, from, err := migrateConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	if from != ConfigVersion {
+		backup := s.configBackupPath(from)
+		if err := writeFileAtomic(backup, data); err != nil {
+			return nil, fmt.Errorf("backing up the configuration in %s before migrating it: %w", s.path, err)
+		}
+		Tracef("config", "backed up the configuration in %s to %s", s.path, backup)
+		// Only replace the document read; if another process changed it
+		// meanwhile, the next Save reports that.
+		if _, err := s.db.Exec(`UPDATE config SET data = ? WHERE id = 1 AND data = ?`, string(migrated), string(data)); err != nil {
+			return nil, err
+		}
+		version.sum = sha256.Sum256(migrated)
+		config.Migrated = &Migration{From: from, Backup: backup}
+	}
+	if err := json.Unmarshal(migrated, config); err != nil {
+		return nil, err
+	}
+	if config.Projects == nil {
+		config.Projects = make(map[string]Project)
+	}
+	config.decryptProjects()
+	return s.withFiles(config)
+}
+
+// withFiles adds what config includes, and its overrides, from the files
+// in ConfigDir.
+func (s *SQLiteStorage) withFiles(config *Config) (*Config, error) {
+	if s.ConfigDir == "" {
+		return config, nil
+	}
+	if err := config.loadIncludes(s.ConfigDir, s.path); err != nil {
+		return nil, err
+	}
+	return config.withOverrides(filepath.Join(s.ConfigDir, OverridesName))
+}
+
+// Save replaces the stored configuration. If config was loaded from the
+// database and another process saved it since, Save leaves it alone and
+// returns ErrConfigChanged, like Config.Save.
+func (s *SQLiteStorage) Save(config *Config) error {
+	data, err := config.marshal(FormatJSON)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// BEGIN IMMEDIATE takes the write lock before the document is read, so
+	// that no one can save it between the check and the update.
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(ctx, `ROLLBACK`)
+		}
+	}()
+	if config.loaded != nil && config.loaded.path == s.path {
+		current, _, err := storedVersion(conn.QueryRowContext(ctx, selectConfig), s.path)
+		if err != nil {
+			return err
+		}
+		if current.sum != config.loaded.sum {
+			return fmt.Errorf("%s: %w", s.path, ErrConfigChanged)
+		}
+	}
+	_, err = conn.ExecContext(ctx, `INSERT INTO config (id, data) VALUES (1, ?)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data`, string(data))
+	if err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+		return err
+	}
+	committed = true
+	config.loaded = &fileVersion{path: s.path, sum: sha256.Sum256(data)}
+	return config.saveIncludes()
+}
+
+// AppendHistory records entry.
+func (s *SQLiteStorage) AppendHistory(entry HistoryEntry) error {
+	var tests string
+	if entry.Tests != nil {
+		data, err := json.Marshal(entry.Tests)
+		if err != nil {
+			return err
+		}
+		tests = string(data)
+	}
+	_, err := s.db.Exec(`INSERT INTO history
+		(run_id, project, phase, start, duration, exit_code, commit_id, command, user, since, pipeline, step, tests, coverage)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.RunID, entry.Project, entry.Phase, entry.Start.UnixNano(),
+		int64(entry.Duration), entry.ExitCode, entry.Commit, entry.Command, entry.User, entry.Since, entry.Pipeline, entry.Step, tests, entry.Coverage)
+	return err
+}
+
+// History returns the entries recorded for project (all projects if empty), oldest first.
+func (s *SQLiteStorage) History(project string) ([]HistoryEntry, error) {
+	query := `SELECT run_id, project, phase, start, duration, exit_code, commit_id, command, user, since, pipeline, step, tests, coverage
+		FROM history WHERE ? = '' OR project = ? ORDER BY id`
+	rows, err := s.db.Query(query, project, project)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		var e HistoryEntry
+		var start, duration int64
+		var tests string
+		var coverage sql.NullFloat64
+		if err := rows.Scan(&e.RunID, &e.Project, &e.Phase, &start, &duration, &e.ExitCode, &e.Commit, &e.Command, &e.User, &e.Since, &e.Pipeline, &e.Step, &tests, &coverage); err != nil {
+			return nil, err
+		}
+		if coverage.Valid {
+			e.Coverage = &coverage.Float64
+		}
+		if tests != "" {
+			if err := json.Unmarshal([]byte(tests), &e.Tests); err != nil {
+				return nil, fmt.Errorf("test report of run %s: %v", e.RunID, err)
+			}
+		}
+		e.Start = time.Unix(0, start)
+		e.Duration = time.Duration(duration)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,93 @@
+package bild
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openTestSQLite opens a database at path, closing it when the test ends.
+func openTestSQLite(t *testing.T, path string) *SQLiteStorage {
+	t.Helper()
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// loadSQLite loads the config of s, failing the test if it can't.
+func loadSQLite(t *testing.T, s *SQLiteStorage) *Config {
+	t.Helper()
+	config, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return config
+}
+
+func TestSQLiteSaveConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bild.db")
+	a, b := openTestSQLite(t, path), openTestSQLite(t, path)
+
+	mine, theirs := loadSQLite(t, a), loadSQLite(t, b)
+	mine.Projects["api"] = Project{Phases: []Phase{{Name: "build", Commands: []string{"make"}}}}
+	if err := a.Save(mine); err != nil {
+		t.Fatal(err)
+	}
+	// Saving again what it saved itself is fine.
+	if err := a.Save(mine); err != nil {
+		t.Fatal(err)
+	}
+
+	theirs.Projects["web"] = Project{}
+	if err := b.Save(theirs); !errors.Is(err, ErrConfigChanged) {
+		t.Fatalf("Save of a config changed meanwhile = %v, want ErrConfigChanged", err)
+	}
+	if _, ok := loadSQLite(t, b).Projects["web"]; ok {
+		t.Error("the conflicting save was written")
+	}
+
+	theirs = loadSQLite(t, b)
+	theirs.Projects["web"] = Project{}
+	if err := b.Save(theirs); err != nil {
+		t.Fatal(err)
+	}
+	if names := loadSQLite(t, a).ProjectNames(); len(names) != 2 {
+		t.Errorf("projects = %v, want api and web", names)
+	}
+}
+
+func TestSQLiteMigrates(t *testing.T) {
+	s := openTestSQLite(t, filepath.Join(t.TempDir(), "bild.db"))
+	const old = `{"projects": {"api": {"phases": [{"name": "build", "commands": ["make"]}]}}}`
+	if _, err := s.db.Exec(`INSERT INTO config (id, data) VALUES (1, ?)`, old); err != nil {
+		t.Fatal(err)
+	}
+
+	config := loadSQLite(t, s)
+	if config.Migrated == nil || config.Migrated.From != 0 || config.Migrated.Backup != s.configBackupPath(0) {
+		t.Fatalf("Migrated = %+v", config.Migrated)
+	}
+	if !strings.HasSuffix(config.Migrated.Backup, "bild-config.json.v0.bak") {
+		t.Errorf("backup at %s", config.Migrated.Backup)
+	}
+	backup, err := os.ReadFile(config.Migrated.Backup)
+	if err != nil || string(backup) != old {
+		t.Errorf("backup = %q, %v, want the document as it was", backup, err)
+	}
+	// The database holds the upgraded document, and the config saves
+	// without a conflict.
+	if again := loadSQLite(t, s); again.Migrated != nil {
+		t.Errorf("migrated again: %+v", again.Migrated)
+	}
+	if err := s.Save(config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSQLite(t, s).Project("api"); err != nil {
+		t.Error(err)
+	}
+}
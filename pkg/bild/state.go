@@ -0,0 +1,227 @@
+package bild
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProjectState is the directory holding what bild remembers about one
+// project between runs, below the state directory:
+//
+//	<state>/<project-id>/
+//	    project.json     the project's name, so that IDs can be mapped back
+//	    lock             locked while a bild process updates the state
+//	    last_green.json  the last successful run of each phase
+//	    inputs.json      the inputs of the last successful run of each phase
+//	    coverage.json    the coverage of the last run of each phase with a gate
+//	    once/            markers of steps that only run once
+//	    vars.json        variables captured from earlier runs
+//	    trust.json       local configurations the user has trusted
+//	    link.json        the local configuration the project is kept in sync with
+//
+// Files are created by the features using them. Writers hold the lock, and
+// replace files atomically, so that concurrent bild processes neither
+// corrupt the state nor see half-written files.
+type ProjectState struct {
+	Project string
+	Dir     string
+}
+
+// projectStateFile records the project a state directory belongs to.
+const projectStateFile = "project.json"
+
+// ProjectID returns the name of the state directory of project: the name
+// reduced to characters safe in file names, followed by a short hash of the
+// full name so that different names never share a directory.
+func ProjectID(project string) string {
+	sum := sha256.Sum256([]byte(project))
+	return fileSafe(project) + "-" + hex.EncodeToString(sum[:4])
+}
+
+// fileSafe reduces name to characters safe in file names.
+func fileSafe(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, name)
+	return strings.TrimLeft(safe, ".")
+}
+
+// ProjectState returns the state directory of project. It is created on
+// the first write.
+func (d Dirs) ProjectState(project string) *ProjectState {
+	return &ProjectState{Project: project, Dir: filepath.Join(d.State, ProjectID(project))}
+}
+
+// ProjectStates returns the state directories below the state directory,
+// sorted by project name.
+func (d Dirs) ProjectStates() ([]*ProjectState, error) {
+	paths, err := filepath.Glob(filepath.Join(d.State, "*", projectStateFile))
+	if err != nil {
+		return nil, err
+	}
+	var states []*ProjectState
+	for _, path := range paths {
+		var info struct {
+			Project string `json:"project"`
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || json.Unmarshal(data, &info) != nil {
+			continue
+		}
+		states = append(states, &ProjectState{Project: info.Project, Dir: filepath.Dir(path)})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Project < states[j].Project })
+	return states, nil
+}
+
+// Path returns the path of the state file name.
+func (s *ProjectState) Path(name string) string {
+	return filepath.Join(s.Dir, name)
+}
+
+// Exists reports whether anything has been stored for the project.
+func (s *ProjectState) Exists() bool {
+	_, err := os.Stat(s.Path(projectStateFile))
+	return err == nil
+}
+
+// init creates the state directory and records the project it belongs to.
+func (s *ProjectState) init() error {
+	if s.Exists() {
+		return nil
+	}
+	if err := ensureDir(s.Dir); err != nil {
+		return err
+	}
+	data, err := marshalConfig(map[string]any{"project": s.Project, "created": time.Now()})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path(projectStateFile), data)
+}
+
+// Lock blocks until it holds the project's state lock and returns the
+// function releasing it.
+func (s *ProjectState) Lock() (func(), error) {
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	Tracef("state", "locking %s", s.Dir)
+	return lockFile(s.Path("lock"), true)
+}
+
+// TryLock takes the project's state lock if no other process holds it.
+// It returns ErrStateLocked otherwise.
+func (s *ProjectState) TryLock() (func(), error) {
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	return lockFile(s.Path("lock"), false)
+}
+
+// ErrStateLocked is returned by TryLock while another process holds the lock.
+var ErrStateLocked = errors.New("the project's state is locked by another bild process")
+
+// ReadJSON decodes the state file name into v and reports whether it exists.
+func (s *ProjectState) ReadJSON(name string, v any) (bool, error) {
+	data, err := os.ReadFile(s.Path(name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// WriteJSON replaces the state file name with v. The caller should hold the lock.
+func (s *ProjectState) WriteJSON(name string, v any) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+	data, err := marshalConfig(v)
+	if err != nil {
+		return err
+	}
+	path := s.Path(name)
+	if err := ensureParent(path); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// Remove deletes the state file (or directory) name. Missing files are not an error.
+func (s *ProjectState) Remove(name string) error {
+	return os.RemoveAll(s.Path(name))
+}
+
+// Clear removes everything stored for the project. It fails with
+// ErrStateLocked while another process holds the lock.
+func (s *ProjectState) Clear() error {
+	if !s.Exists() {
+		return nil
+	}
+	unlock, err := s.TryLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return err
+	}
+	// The lock file goes last, once nothing else is left to protect.
+	for _, e := range entries {
+		if e.Name() != "lock" {
+			if err := os.RemoveAll(s.Path(e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return os.RemoveAll(s.Dir)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so that readers see either the old or the new content. If
+// path is a symlink, such as a config kept with one's dotfiles, the file it
+// points to is written, and the link stays.
+func writeFileAtomic(path string, data []byte) error {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package bild
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Step is a command of a phase written as an object, so that the output,
+// logs, timings and --skip can refer to it by name:
+//
+//	"commands": [
+//	  "./configure",
+//	  {"name": "compile", "run": "make -j8", "description": "Build the binaries"}
+//	]
+//
+// Phase.Commands holds what every command runs, whether written as a string
+// or as a step, and Phase.Steps the names and descriptions by index.
+type Step struct {
+	Name        string `json:"name,omitempty"`
+	Run         string `json:"run"`
+	Description string `json:"description,omitempty"`
+}
+
+// Label returns how the step is shown next to its command: its name and
+// description, either of them, or "" for a bare command.
+func (s Step) Label() string {
+	switch {
+	case s.Name != "" && s.Description != "":
+		return s.Name + ": " + s.Description
+	case s.Name != "":
+		return s.Name
+	}
+	return s.Description
+}
+
+// Step returns command i of ph as a step, with its name and description if
+// it was written as one.
+func (ph *Phase) Step(i int) Step {
+	var s Step
+	if i < len(ph.Steps) {
+		s = ph.Steps[i]
+	}
+	s.Run = ph.Commands[i]
+	return s
+}
+
+// StepIndex returns the index in Commands of the step of ph named name, or
+// -1 if there is none.
+func (ph *Phase) StepIndex(name string) int {
+	for i, s := range ph.Steps {
+		if s.Name == name && i < len(ph.Commands) {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetCommands replaces the commands of ph with commands, keeping the names
+// and descriptions of the steps that run the same as before.
+func (ph *Phase) SetCommands(commands []string) {
+	var steps []Step
+	for i, command := range commands {
+		old := slices.Index(ph.Commands, command)
+		if old < 0 || old >= len(ph.Steps) || ph.Steps[old] == (Step{}) {
+			continue
+		}
+		if steps == nil {
+			steps = make([]Step, len(commands))
+		}
+		steps[i] = ph.Steps[old]
+	}
+	ph.Commands, ph.Steps = commands, steps
+}
+
+// hasSteps reports whether a command of ph was written as a step.
+func (ph *Phase) hasSteps() bool {
+	return slices.ContainsFunc(ph.Steps, func(s Step) bool { return s != (Step{}) })
+}
+
+// phaseJSON is Phase without its JSON methods.
+type phaseJSON Phase
+
+// UnmarshalJSON reads a phase whose commands are strings or steps.
+func (ph *Phase) UnmarshalJSON(data []byte) error {
+	doc := struct {
+		*phaseJSON
+		Commands []json.RawMessage `json:"commands"`
+	}{phaseJSON: (*phaseJSON)(ph)}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	ph.Commands, ph.Steps = nil, nil
+	if doc.Commands != nil {
+		ph.Commands = make([]string, 0, len(doc.Commands))
+	}
+	for i, raw := range doc.Commands {
+		var step Step
+		switch raw = bytes.TrimSpace(raw); {
+		case len(raw) > 0 && raw[0] == '"':
+			if err := json.Unmarshal(raw, &step.Run); err != nil {
+				return err
+			}
+		case len(raw) > 0 && raw[0] == '{':
+			if err := json.Unmarshal(raw, &step); err != nil {
+				return fmt.Errorf("phase %s, command %d: %v", ph.Name, i+1, err)
+			}
+			if strings.TrimSpace(step.Run) == "" {
+				return fmt.Errorf("phase %s, command %d: a step needs a command to run", ph.Name, i+1)
+			}
+		default:
+			return fmt.Errorf("phase %s, command %d: want a command or a step with name and run, not %s", ph.Name, i+1, raw)
+		}
+		ph.Commands = append(ph.Commands, step.Run)
+		if step.Name != "" || step.Description != "" {
+			if ph.Steps == nil {
+				ph.Steps = make([]Step, len(doc.Commands))
+			}
+			ph.Steps[i] = Step{Name: step.Name, Description: step.Description}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON writes the commands of ph written as steps as objects again.
+func (ph Phase) MarshalJSON() ([]byte, error) {
+	if !ph.hasSteps() {
+		return json.Marshal(phaseJSON(ph))
+	}
+	commands := make([]any, len(ph.Commands))
+	for i := range ph.Commands {
+		if s := ph.Step(i); s.Label() != "" {
+			commands[i] = s
+		} else {
+			commands[i] = s.Run
+		}
+	}
+	list, err := json.Marshal(commands)
+	if err != nil {
+		return nil, err
+	}
+	// The commands take the place of the empty ones, so that they keep
+	// their place among the fields; the first "commands" key is the
+	// phase's own, as it follows the name and description.
+	plain := phaseJSON(ph)
+	plain.Commands = nil
+	data, err := json.Marshal(plain)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Replace(data, []byte(`"commands":null`), append([]byte(`"commands":`), list...), 1), nil
+}
+
+// WithoutSteps leaves the steps named as "phase:step" in skip out of the
+// phases of p, and returns the other names of skip, those of phases.
+func (p *Project) WithoutSteps(skip []string) ([]string, error) {
+	var phases []string
+	for _, name := range skip {
+		phase, step, ok := strings.Cut(name, ":")
+		if !ok {
+			phases = append(phases, name)
+			continue
+		}
+		ph, err := p.Phase(phase)
+		if err != nil {
+			return nil, err
+		}
+		i := ph.StepIndex(step)
+		if i < 0 {
+			return nil, fmt.Errorf("phase %s has no step %s", ph.Name, step)
+		}
+		Tracef("run", "skipping step %s of phase %s", step, ph.Name)
+		// The slices may be shared with the configuration.
+		ph.Commands = slices.Delete(slices.Clone(ph.Commands), i, i+1)
+		ph.Steps = slices.Delete(slices.Clone(ph.Steps), i, i+1)
+	}
+	return phases, nil
+}
+
+// Between narrows phases, the names of the phases of p to run (all but
+// those marked skip if empty), to the phases from that of from up to that of
+// until, and returns their names. Either bound may be empty, and is written
+// as "phase" or "phase:step"; a step cuts the commands of its phase to start
+// or end with it.
+func (p *Project) Between(phases []string, from, until string) ([]string, error) {
+	selected, err := p.SelectPhases(phases)
+	if err != nil {
+		return nil, err
+	}
+	bound := func(name string) (int, int, error) {
+		phase, step, _ := strings.Cut(name, ":")
+		ph, err := p.Phase(phase)
+		if err != nil {
+			return 0, 0, err
+		}
+		i := slices.Index(selected, ph)
+		if i < 0 {
+			return 0, 0, fmt.Errorf("phase %s is not among the phases to run", ph.Name)
+		}
+		if step == "" {
+			return i, -1, nil
+		}
+		s := ph.StepIndex(step)
+		if s < 0 {
+			return 0, 0, fmt.Errorf("phase %s has no step %s", ph.Name, step)
+		}
+		return i, s, nil
+	}
+	first, last := 0, len(selected)-1
+	fromStep, untilStep := -1, -1
+	if from != "" {
+		if first, fromStep, err = bound(from); err != nil {
+			return nil, err
+		}
+	}
+	if until != "" {
+		if last, untilStep, err = bound(until); err != nil {
+			return nil, err
+		}
+	}
+	if first > last || (first == last && fromStep >= 0 && untilStep >= 0 && fromStep > untilStep) {
+		return nil, fmt.Errorf("%s comes after %s", from, until)
+	}
+
+	// The slices may be shared with the configuration.
+	cut := func(ph *Phase, lo, hi int) {
+		Tracef("run", "running commands %d to %d of phase %s", lo+1, hi, ph.Name)
+		ph.Commands = slices.Clone(ph.Commands[lo:hi])
+		if len(ph.Steps) > 0 {
+			ph.Steps = slices.Clone(ph.Steps[min(lo, len(ph.Steps)):min(hi, len(ph.Steps))])
+		}
+	}
+	if untilStep >= 0 {
+		cut(selected[last], 0, untilStep+1)
+	}
+	if fromStep >= 0 {
+		cut(selected[first], fromStep, len(selected[first].Commands))
+	}
+	var names []string
+	for _, ph := range selected[first : last+1] {
+		names = append(names, ph.Name)
+	}
+	return names, nil
+}
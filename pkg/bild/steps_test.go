@@ -0,0 +1,91 @@
+package bild
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPhaseSteps(t *testing.T) {
+	const doc = `{"name": "build", "commands": ["./configure", {"name": "compile", "run": "make -j8", "description": "Build the binaries"}]}`
+	var ph Phase
+	if err := json.Unmarshal([]byte(doc), &ph); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"./configure", "make -j8"}; !reflect.DeepEqual(ph.Commands, want) {
+		t.Errorf("Commands = %q, want %q", ph.Commands, want)
+	}
+	if step := ph.Step(1); step.Name != "compile" || step.Run != "make -j8" || ph.StepIndex("compile") != 1 {
+		t.Errorf("Step(1) = %+v", step)
+	}
+
+	for _, format := range []string{FormatJSON, FormatTOML} {
+		data, err := encodeConfig(map[string]any{"phase": ph}, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err = toJSON(data, format); err != nil {
+			t.Fatal(err)
+		}
+		var read struct{ Phase Phase }
+		if err := json.Unmarshal(data, &read); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(read.Phase, ph) {
+			t.Errorf("%s round trip = %+v, want %+v", format, read.Phase, ph)
+		}
+	}
+
+	ph.SetCommands([]string{"make -j8", "make install"})
+	if ph.Step(0).Name != "compile" || ph.Step(1).Name != "" {
+		t.Errorf("after SetCommands the steps are %+v", ph.Steps)
+	}
+
+	if err := json.Unmarshal([]byte(`{"name": "build", "commands": [{"name": "compile"}]}`), &ph); err == nil || !strings.Contains(err.Error(), "command 1") {
+		t.Errorf("a step without run = %v", err)
+	}
+}
+
+func TestWithoutSteps(t *testing.T) {
+	commands := []string{"make", "strip bin/app"}
+	proj := &Project{Phases: []Phase{{Name: "build", Commands: commands, Steps: []Step{{Name: "compile"}, {Name: "strip"}}}}}
+	rest, err := proj.WithoutSteps([]string{"build:strip", "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rest, []string{"test"}) || !reflect.DeepEqual(proj.Phases[0].Commands, []string{"make"}) {
+		t.Errorf("WithoutSteps left %q and commands %q", rest, proj.Phases[0].Commands)
+	}
+	if commands[1] != "strip bin/app" {
+		t.Error("WithoutSteps changed the commands it was given")
+	}
+	if _, err := proj.WithoutSteps([]string{"build:lint"}); err == nil {
+		t.Error("skipping a missing step succeeded")
+	}
+}
+
+func TestBetween(t *testing.T) {
+	proj := &Project{Phases: []Phase{
+		{Name: "setup", Commands: []string{"./configure"}},
+		{Name: "build", Commands: []string{"make gen", "make", "strip bin/app"}, Steps: []Step{{Name: "gen"}, {Name: "compile"}, {Name: "strip"}}},
+		{Name: "lint", Skip: true},
+		{Name: "test", Commands: []string{"make test"}},
+	}}
+	names, err := proj.Between(nil, "build:compile", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"build", "test"}) {
+		t.Errorf("Between = %q", names)
+	}
+	if build := proj.Phases[1]; !reflect.DeepEqual(build.Commands, []string{"make", "strip bin/app"}) || build.Steps[0].Name != "compile" {
+		t.Errorf("build runs %q as %+v", build.Commands, build.Steps)
+	}
+	if _, err := proj.Between([]string{"setup"}, "test", ""); err == nil {
+		t.Error("starting with a phase not to run succeeded")
+	}
+	if _, err := proj.Between(nil, "test", "build"); err == nil {
+		t.Error("ending before the start succeeded")
+	}
+}
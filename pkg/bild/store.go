@@ -0,0 +1,226 @@
+package bild
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ConfigStore loads and saves the global configuration.
+type ConfigStore interface {
+	Load() (*Config, error)
+	Save(config *Config) error
+}
+
+// FileStore keeps the configuration in a JSON file.
+type FileStore struct {
+	Path string
+}
+
+// Load reads the configuration file (or returns an empty config if it doesn't exist).
+func (s *FileStore) Load() (*Config, error) {
+	return LoadConfig(s.Path)
+}
+
+// Save writes the configuration file, creating its directory if needed.
+func (s *FileStore) Save(config *Config) error {
+	if err := ensureParent(s.Path); err != nil {
+		return err
+	}
+	return config.Save(s.Path)
+}
+
+// MemoryStore keeps the configuration and run history in memory, as
+// serialized copies, so that later changes to a saved Config are not visible
+// until it is saved again, and all changes are dropped as bild exits; it is
+// the storage backend StorageMemory. Like the other stores, it decrypts sealed
+// projects as it loads, and reads what the configuration includes and the
+// overrides from the files in ConfigDir, if set. Once saved, included
+// projects are kept in memory with the rest rather than written back.
+// The zero value is an empty store ready to use.
+type MemoryStore struct {
+	ConfigDir string
+
+	mu      sync.Mutex
+	data    []byte
+	saved   bool // data holds the included projects as well
+	history []HistoryEntry
+}
+
+// NewMemoryStore returns a store holding a copy of config.
+func NewMemoryStore(config *Config) (*MemoryStore, error) {
+	s := &MemoryStore{}
+	if err := s.Save(config); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenMemoryStore returns a store holding the configuration and run history
+// of the JSON files below dirs, which it leaves untouched.
+func OpenMemoryStore(dirs Dirs) (*MemoryStore, error) {
+	s := &MemoryStore{ConfigDir: dirs.Config}
+	path := dirs.ConfigPath()
+	data, err := os.ReadFile(path)
+	if err == nil {
+		s.data, err = toJSON(data, ConfigFormat(path))
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.history, err = ReadHistory(dirs.HistoryPath()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Load returns a fresh copy of the stored configuration.
+func (s *MemoryStore) Load() (*Config, error) {
+	s.mu.Lock()
+	data, saved := s.data, s.saved
+	s.mu.Unlock()
+
+	config := NewConfig()
+	if data != nil {
+		migrated, _, err := migrateConfig(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(migrated, config); err != nil {
+			return nil, err
+		}
+		if config.Projects == nil {
+			config.Projects = make(map[string]Project)
+		}
+		config.decryptProjects()
+	}
+	if s.ConfigDir == "" {
+		return config, nil
+	}
+	if !saved {
+		if err := config.loadIncludes(s.ConfigDir, "the configuration in memory"); err != nil {
+			return nil, err
+		}
+	}
+	return config.withOverrides(filepath.Join(s.ConfigDir, OverridesName))
+}
+
+// Save replaces the stored configuration with a copy of config.
+func (s *MemoryStore) Save(config *Config) error {
+	all := *config
+	all.included = nil
+	data, err := all.marshal(FormatJSON)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data, s.saved = data, true
+	return nil
+}
+
+// AppendHistory records entry.
+func (s *MemoryStore) AppendHistory(entry HistoryEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = append(s.history, entry)
+	return nil
+}
+
+// History returns the entries recorded for project (all projects if empty), oldest first.
+func (s *MemoryStore) History(project string) ([]HistoryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []HistoryEntry
+	for _, e := range s.history {
+		if project == "" || e.Project == project {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (*MemoryStore) Close() error { return nil }
+
+// HistoryStore records and returns the run history.
+type HistoryStore interface {
+	AppendHistory(entry HistoryEntry) error
+	// History returns the entries recorded for project (all projects if
+	// empty), oldest first.
+	History(project string) ([]HistoryEntry, error)
+}
+
+// HistoryFile keeps the run history in a JSON Lines file.
+type HistoryFile struct {
+	Path string
+}
+
+// AppendHistory appends entry to the file.
+func (h *HistoryFile) AppendHistory(entry HistoryEntry) error {
+	return AppendHistory(h.Path, entry)
+}
+
+// History reads the file and filters it by project.
+func (h *HistoryFile) History(project string) ([]HistoryEntry, error) {
+	entries, err := ReadHistory(h.Path)
+	if err != nil || project == "" {
+		return entries, err
+	}
+	var filtered []HistoryEntry
+	for _, e := range entries {
+		if e.Project == project {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered, nil
+}
+
+// StorageEnv names the environment variable selecting the storage backend.
+const StorageEnv = "BILD_STORAGE"
+
+// Storage backends accepted by OpenStorage.
+const (
+	StorageJSON   = "json"   // bild.json and history.jsonl (the default)
+	StorageSQLite = "sqlite" // a single SQLite database, safe for concurrent writers
+	StorageMemory = "memory" // the JSON files' contents, changed in memory only
+)
+
+// Storage is where the global configuration and the run history are kept.
+type Storage interface {
+	ConfigStore
+	HistoryStore
+	Close() error
+}
+
+// jsonStorage keeps the configuration and history in plain files.
+type jsonStorage struct {
+	*FileStore
+	*HistoryFile
+}
+
+func (jsonStorage) Close() error { return nil }
+
+// OpenStorage opens the named storage backend below dirs. An empty name
+// selects the backend named by $BILD_STORAGE, or else the JSON files.
+func OpenStorage(name string, dirs Dirs) (Storage, error) {
+	if name == "" {
+		name = os.Getenv(StorageEnv)
+	}
+	Tracef("storage", "using the %q backend", name)
+	switch name {
+	case "", StorageJSON:
+		return jsonStorage{&FileStore{Path: dirs.ConfigPath()}, &HistoryFile{Path: dirs.HistoryPath()}}, nil
+	case StorageSQLite:
+		storage, err := OpenSQLite(dirs.DatabasePath())
+		if err != nil {
+			return nil, err
+		}
+		storage.ConfigDir = dirs.Config
+		return storage, nil
+	case StorageMemory:
+		return OpenMemoryStore(dirs)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q (want %s, %s or %s)", name, StorageJSON, StorageSQLite, StorageMemory)
+}
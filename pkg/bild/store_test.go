@@ -0,0 +1,132 @@
+package bild
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestDirs(t *testing.T) {
+	base := t.TempDir()
+	want := Dirs{
+		Config: base,
+		Data:   filepath.Join(base, "data"),
+		State:  filepath.Join(base, "state"),
+		Cache:  filepath.Join(base, "cache"),
+	}
+	if got := DirsUnder(base); got != want {
+		t.Errorf("DirsUnder = %+v, want %+v", got, want)
+	}
+	t.Setenv(ConfigDirEnv, base)
+	dirs, err := DefaultDirs()
+	if err != nil || dirs != want {
+		t.Errorf("with $%s: %+v, %v; want %+v", ConfigDirEnv, dirs, err, want)
+	}
+	if path := dirs.ConfigPath(); path != filepath.Join(base, "bild.json") {
+		t.Errorf("config path = %s", path)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	var empty MemoryStore
+	if config, err := empty.Load(); err != nil || config.Projects == nil || len(config.Projects) != 0 {
+		t.Fatalf("empty store: %+v, %v", config, err)
+	}
+
+	config := NewConfig()
+	config.Projects["api"] = Project{
+		Phases: []Phase{{Name: "build", Commands: []string{"go build ./..."}}},
+		Path:   "/src/api",
+	}
+	store, err := NewMemoryStore(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The store holds a copy: changes show only once saved.
+	config.Projects["api"].Phases[0].Commands[0] = "make"
+	delete(config.Projects, "api")
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api := loaded.Projects["api"]; len(api.Phases) != 1 || api.Phases[0].Commands[0] != "go build ./..." {
+		t.Errorf("loaded %+v, want the config as saved", loaded)
+	}
+
+	loaded.Projects["web"] = Project{Phases: []Phase{{Name: "test", Commands: []string{"npm test"}}}}
+	if err := store.Save(loaded); err != nil {
+		t.Fatal(err)
+	}
+	again, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again.Projects, loaded.Projects) {
+		t.Errorf("round trip: %+v, want %+v", again.Projects, loaded.Projects)
+	}
+	if again == loaded {
+		t.Error("Load returned the saved config itself")
+	}
+}
+
+func TestOpenMemoryStore(t *testing.T) {
+	dirs := DirsUnder(t.TempDir())
+	files := map[string]string{
+		"bild.json":          `{"version": 1, "includes": ["conf.d/*.json"], "projects": {"api": {"path": "/src/api", "phases": [{"name": "build", "commands": ["make"]}]}}}`,
+		"conf.d/web.json":    `{"projects": {"web": {"phases": [{"name": "test", "commands": ["npm test"]}]}}}`,
+		"overrides.json":     `{"projects": {"api": {"path": "/home/me/api"}}}`,
+		"data/history.jsonl": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(dirs.Config, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	storage, err := OpenStorage(StorageMemory, dirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+
+	config, err := storage.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api, err := config.Project("api"); err != nil || api.Path != "/home/me/api" {
+		t.Errorf("api = %+v, %v; want the overridden path", api, err)
+	}
+	if config.IncludedFrom("web") == "" {
+		t.Errorf("web was not included: %+v", config.Projects)
+	}
+
+	config.Projects["cli"] = Project{Phases: []Phase{{Name: "build", Commands: []string{"go build"}}}}
+	delete(config.Projects, "web")
+	if err := storage.Save(config); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.AppendHistory(HistoryEntry{Project: "cli", Phase: "build"}); err != nil {
+		t.Fatal(err)
+	}
+	again, err := storage.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := again.Projects["cli"]; !ok || len(again.Projects) != 2 {
+		t.Errorf("projects after saving = %v, want api and cli", slices.Collect(maps.Keys(again.Projects)))
+	}
+	if entries, err := storage.History("cli"); err != nil || len(entries) != 1 {
+		t.Errorf("history = %+v, %v", entries, err)
+	}
+	for name, content := range files {
+		if data, err := os.ReadFile(filepath.Join(dirs.Config, name)); err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v; want it untouched", name, data, err)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package bild
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// syncFileName is the file the configuration is kept in in a sync
+// repository.
+const syncFileName = "bild.json"
+
+// ErrSyncConflict is returned by Sync.Push and Sync.Pull if the
+// configuration was changed both here and in the sync repository since the
+// last sync.
+var ErrSyncConflict = errors.New("the config changed both here and in the sync repository since the last sync")
+
+// ErrNoSync is returned by Sync.Load if no sync repository was set up.
+var ErrNoSync = errors.New("no sync repository set up")
+
+// Sync keeps the global configuration in a git repository, such as a
+// GitHub gist, so that it follows its user across machines. The repository
+// is cloned below the data directory; what was last pushed or pulled is
+// recorded in the state directory, to tell which side changed since.
+//
+// Only the configuration's own projects and settings are synced: files it
+// includes and the overrides of the machine are left alone.
+type Sync struct {
+	Dir       string // the clone of the repository
+	StatePath string
+	State     SyncState
+}
+
+// SyncState records the sync repository and the configuration as last
+// synced, as SHA-256 sums in hex of its contents here and in the
+// repository; they are empty before the first sync.
+type SyncState struct {
+	Remote string    `json:"remote"`
+	Local  string    `json:"local,omitempty"`
+	Pushed string    `json:"pushed,omitempty"`
+	Synced time.Time `json:"synced"`
+}
+
+// Sync returns the sync of the configuration; see Sync.Load.
+func (d Dirs) Sync() *Sync {
+	return &Sync{Dir: filepath.Join(d.Data, "sync"), StatePath: filepath.Join(d.State, "sync.json")}
+}
+
+// GitRemote returns the git URL of remote, which may also be a gist given
+// as "gist:<id>" or by the URL of its page.
+func GitRemote(remote string) string {
+	if id, ok := strings.CutPrefix(remote, "gist:"); ok {
+		return "https://gist.github.com/" + id + ".git"
+	}
+	if u, err := url.Parse(remote); err == nil && u.Host == "gist.github.com" && !strings.HasSuffix(u.Path, ".git") {
+		// The page of a gist is gist.github.com/<user>/<id>.
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		return "https://gist.github.com/" + parts[len(parts)-1] + ".git"
+	}
+	return remote
+}
+
+// Load reads the state of s, or returns ErrNoSync if none was set up.
+func (s *Sync) Load() error {
+	data, err := os.ReadFile(s.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNoSync
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &s.State)
+}
+
+// save writes the state of s.
+func (s *Sync) save() error {
+	data, err := json.MarshalIndent(s.State, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ensureParent(s.StatePath); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.StatePath, append(data, '\n'))
+}
+
+// Init sets up syncing with remote, a git URL or a gist, cloning it anew.
+// Nothing is synced until the first push or pull.
+func (s *Sync) Init(remote string) error {
+	if NoGit {
+		return ErrNoGit
+	}
+	if err := os.RemoveAll(s.Dir); err != nil {
+		return err
+	}
+	if err := ensureParent(s.Dir); err != nil {
+		return err
+	}
+	remote = GitRemote(remote)
+	if _, err := s.git(filepath.Dir(s.Dir), "clone", "--quiet", remote, s.Dir); err != nil {
+		return err
+	}
+	s.State = SyncState{Remote: remote}
+	return s.save()
+}
+
+// git runs git with args in dir, or in the clone if dir is empty, without
+// prompting for credentials, and returns its output.
+func (s *Sync) git(dir string, args ...string) (string, error) {
+	if dir == "" {
+		dir = s.Dir
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	Tracef("sync", "git %s", strings.Join(args, " "))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// fetch fetches the repository and returns its branch and the
+// configuration in it, or nil if none was pushed yet.
+func (s *Sync) fetch() (string, []byte, error) {
+	if NoGit {
+		return "", nil, ErrNoGit
+	}
+	if _, err := s.git("", "fetch", "--quiet", "origin"); err != nil {
+		return "", nil, err
+	}
+	branch, err := Branch(s.Dir)
+	if err != nil {
+		return "", nil, err
+	}
+	cmd := exec.Command("git", "show", "origin/"+branch+":"+syncFileName)
+	cmd.Dir = s.Dir
+	data, err := cmd.Output()
+	if err != nil {
+		// An empty repository has no branch yet, and one may not have the file.
+		Tracef("sync", "no %s on origin/%s", syncFileName, branch)
+		return branch, nil, nil
+	}
+	return branch, data, nil
+}
+
+// hexSum returns the SHA-256 sum of data in hex.
+func hexSum(data []byte) string {
+	s := sha256.Sum256(data)
+	return hex.EncodeToString(s[:])
+}
+
+// localChanged reports whether config changed since the last sync. A config
+// without projects or pipelines that was never synced counts as unchanged,
+// so that a new machine can pull.
+func (s *Sync) localChanged(config *Config, data []byte) bool {
+	if s.State.Local == "" {
+		return len(config.Projects) > 0 || len(config.Pipelines) > 0
+	}
+	return hexSum(data) != s.State.Local
+}
+
+// SyncStatus tells which sides changed since the last sync.
+type SyncStatus struct {
+	Local, Remote bool
+}
+
+// Status fetches the repository and reports which of config and the
+// configuration there changed since the last sync.
+func (s *Sync) Status(config *Config) (SyncStatus, error) {
+	data, err := config.marshal(FormatJSON)
+	if err != nil {
+		return SyncStatus{}, err
+	}
+	_, remote, err := s.fetch()
+	if err != nil {
+		return SyncStatus{}, err
+	}
+	return SyncStatus{
+		Local:  s.localChanged(config, data),
+		Remote: remote != nil && hexSum(remote) != s.State.Pushed,
+	}, nil
+}
+
+// Push commits config to the repository and pushes it, unless it is
+// already there. If the configuration there changed since the last sync,
+// and differs from config, that is ErrSyncConflict unless force is set.
+// Push reports whether it pushed.
+func (s *Sync) Push(config *Config, force bool) (bool, error) {
+	data, err := config.marshal(FormatJSON)
+	if err != nil {
+		return false, err
+	}
+	branch, remote, err := s.fetch()
+	if err != nil {
+		return false, err
+	}
+	unchanged := remote != nil && hexSum(remote) == s.State.Pushed
+	if remote != nil && hexSum(remote) == hexSum(data) || unchanged && !s.localChanged(config, data) {
+		return false, s.synced(data, remote)
+	}
+	if remote != nil && !unchanged && !force {
+		return false, ErrSyncConflict
+	}
+	// The commit goes on top of what is there, which force replaces.
+	if remote != nil {
+		if _, err := s.git("", "reset", "--quiet", "--hard", "origin/"+branch); err != nil {
+			return false, err
+		}
+	}
+	if err := writeFileAtomic(filepath.Join(s.Dir, syncFileName), data); err != nil {
+		return false, err
+	}
+	host, _ := os.Hostname()
+	args := []string{"commit", "--quiet", "-m", "Update the bild config from " + host, "--", syncFileName}
+	if email, _ := s.git("", "config", "user.email"); strings.TrimSpace(email) == "" {
+		args = append([]string{"-c", "user.name=bild", "-c", "user.email=bild@" + host}, args...)
+	}
+	if _, err := s.git("", "add", syncFileName); err != nil {
+		return false, err
+	}
+	if _, err := s.git("", args...); err != nil {
+		return false, err
+	}
+	if _, err := s.git("", "push", "--quiet", "origin", "HEAD:"+branch); err != nil {
+		// Someone pushed between the fetch and now.
+		return false, err
+	}
+	return true, s.synced(data, data)
+}
+
+// Pull fetches the configuration in the repository and, if it changed
+// since the last sync, saves it with save in place of config. If config
+// changed too, that is ErrSyncConflict unless force is set. Pull reports
+// whether it saved.
+func (s *Sync) Pull(config *Config, force bool, save func(*Config) error) (bool, error) {
+	data, err := config.marshal(FormatJSON)
+	if err != nil {
+		return false, err
+	}
+	branch, remote, err := s.fetch()
+	if err != nil {
+		return false, err
+	}
+	if remote == nil {
+		return false, fmt.Errorf("%s holds no config yet; push one first", s.State.Remote)
+	}
+	if hexSum(remote) == hexSum(data) || (hexSum(remote) == s.State.Pushed && !force) {
+		return false, s.synced(data, remote)
+	}
+	if s.localChanged(config, data) && !force {
+		return false, ErrSyncConflict
+	}
+	pulled, err := config.replaced(remote)
+	if err != nil {
+		return false, fmt.Errorf("the config in %s: %w", s.State.Remote, err)
+	}
+	if err := save(pulled); err != nil {
+		return false, err
+	}
+	if _, err := s.git("", "reset", "--quiet", "--hard", "origin/"+branch); err != nil {
+		return false, err
+	}
+	saved, err := pulled.marshal(FormatJSON)
+	if err != nil {
+		return false, err
+	}
+	return true, s.synced(saved, remote)
+}
+
+// synced records local and remote as the configuration last synced.
+func (s *Sync) synced(local, remote []byte) error {
+	s.State.Local, s.State.Pushed = hexSum(local), hexSum(remote)
+	s.State.Synced = time.Now()
+	return s.save()
+}
+
+// replaced returns the configuration in data, migrated if it is of an
+// older version, which replaces c when saved where c was loaded from.
+func (c *Config) replaced(data []byte) (*Config, error) {
+	migrated, _, err := migrateConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	config := NewConfig()
+	if err := json.Unmarshal(migrated, config); err != nil {
+		return nil, err
+	}
+	if config.Projects == nil {
+		config.Projects = make(map[string]Project)
+	}
+	config.decryptProjects()
+	config.loaded = c.loaded
+	return config, nil
+}
@@ -0,0 +1,87 @@
+package bild
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitRemote(t *testing.T) {
+	for remote, want := range map[string]string{
+		"gist:4f2c9e":                        "https://gist.github.com/4f2c9e.git",
+		"https://gist.github.com/me/4f2c9e":  "https://gist.github.com/4f2c9e.git",
+		"https://gist.github.com/4f2c9e.git": "https://gist.github.com/4f2c9e.git",
+		"git@github.com:me/bild-config.git":  "git@github.com:me/bild-config.git",
+	} {
+		if got := GitRemote(remote); got != want {
+			t.Errorf("GitRemote(%q) = %q, want %q", remote, got, want)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "bild")
+	t.Setenv("GIT_AUTHOR_EMAIL", "bild@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "bild")
+	t.Setenv("GIT_COMMITTER_EMAIL", "bild@example.com")
+	remote := filepath.Join(t.TempDir(), "config.git")
+	if out, err := exec.Command("git", "init", "--quiet", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	// Two machines sharing the config through remote.
+	machine := func() (*Sync, *MemoryStore) {
+		t.Helper()
+		s := DirsUnder(t.TempDir()).Sync()
+		if err := s.Init(remote); err != nil {
+			t.Fatal(err)
+		}
+		return s, &MemoryStore{}
+	}
+	change := func(store *MemoryStore, project string) *Config {
+		t.Helper()
+		config, _ := store.Load()
+		config.Projects[project] = Project{Phases: []Phase{{Name: "build", Commands: []string{"make"}}}}
+		store.Save(config)
+		return config
+	}
+	load := func(store *MemoryStore) *Config {
+		config, _ := store.Load()
+		return config
+	}
+
+	a, aStore := machine()
+	b, bStore := machine()
+	if pushed, err := a.Push(change(aStore, "api"), false); err != nil || !pushed {
+		t.Fatalf("first push: %v, %v", pushed, err)
+	}
+	if pulled, err := b.Pull(load(bStore), false, bStore.Save); err != nil || !pulled {
+		t.Fatalf("pull on a new machine: %v, %v", pulled, err)
+	}
+	if _, ok := load(bStore).Projects["api"]; !ok {
+		t.Fatal("the pull did not bring the project")
+	}
+
+	if _, err := b.Push(change(bStore, "web"), false); err != nil {
+		t.Fatal(err)
+	}
+	changed := change(aStore, "svc")
+	if _, err := a.Push(changed, false); !errors.Is(err, ErrSyncConflict) {
+		t.Errorf("push of a config changed on both sides = %v, want ErrSyncConflict", err)
+	}
+	if _, err := a.Pull(changed, false, aStore.Save); !errors.Is(err, ErrSyncConflict) {
+		t.Errorf("pull of a config changed on both sides = %v, want ErrSyncConflict", err)
+	}
+	if pushed, err := a.Push(changed, true); err != nil || !pushed {
+		t.Fatalf("forced push: %v, %v", pushed, err)
+	}
+	if _, err := b.Pull(load(bStore), false, bStore.Save); err != nil {
+		t.Fatal(err)
+	}
+	if config := load(bStore); config.Projects["svc"].Phases == nil || config.Projects["web"].Phases != nil {
+		t.Errorf("after the forced push b has %v, want the projects of a", config.ProjectNames())
+	}
+}
@@ -0,0 +1,83 @@
+package bild
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is the data available to command templates, e.g. {{ .Project }}.
+type TemplateData struct {
+	Project string
+	Phase   string
+	Dir     string // working directory the command runs in
+	OS      string // runtime.GOOS
+	Arch    string // runtime.GOARCH
+	// Artifacts maps the names of the artifacts handed to the run to their
+	// paths; see Runner.Artifacts.
+	Artifacts map[string]string
+	// Matrix holds the values of the variant of a matrix phase running.
+	Matrix map[string]string
+	// Vars are the project's vars; Profile names the profile applied to
+	// it, if any.
+	Vars    map[string]string
+	Profile string
+}
+
+// TemplateFuncs returns the helper functions available to command templates.
+// Relative paths given to fileExists and dirExists are resolved against dir.
+func TemplateFuncs(dir string) template.FuncMap {
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) || dir == "" {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	return template.FuncMap{
+		"joinPath":  filepath.Join,
+		"isLinux":   func() bool { return runtime.GOOS == "linux" },
+		"isDarwin":  func() bool { return runtime.GOOS == "darwin" },
+		"isWindows": func() bool { return runtime.GOOS == "windows" },
+		"numCPU":    runtime.NumCPU,
+		"env":       os.Getenv,
+		// lookPath returns the full path of an executable in $PATH, or "" if it is not installed.
+		"lookPath": func(name string) string {
+			path, err := exec.LookPath(name)
+			if err != nil {
+				return ""
+			}
+			return path
+		},
+		"fileExists": func(path string) bool {
+			_, err := os.Stat(resolve(path))
+			return err == nil
+		},
+		"dirExists": func(path string) bool {
+			info, err := os.Stat(resolve(path))
+			return err == nil && info.IsDir()
+		},
+	}
+}
+
+// RenderCommand expands the template actions in command. Commands without
+// template actions are returned unchanged; a literal "{{" can be written as {{ "{{" }}.
+func RenderCommand(command string, data TemplateData) (string, error) {
+	if !strings.Contains(command, "{{") {
+		return command, nil
+	}
+	tmpl, err := template.New("command").
+		Option("missingkey=error").
+		Funcs(TemplateFuncs(data.Dir)).
+		Parse(command)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,74 @@
+package bild
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"testing"
+)
+
+func TestRenderCommand(t *testing.T) {
+	data := TemplateData{
+		Project: "api",
+		Phase:   "build",
+		Dir:     "/src/api",
+		OS:      runtime.GOOS,
+		Vars:    map[string]string{"type": "Release"},
+		Matrix:  map[string]string{"cc": "clang"},
+	}
+	tests := []struct {
+		command, want string
+		wantErr       bool
+	}{
+		{command: "make -j8", want: "make -j8"},
+		{command: "echo {{ .Project }} {{ .Phase }} {{ .Dir }}", want: "echo api build /src/api"},
+		{command: "CC={{ .Matrix.cc }} cmake -DCMAKE_BUILD_TYPE={{ .Vars.type }}", want: "CC=clang cmake -DCMAKE_BUILD_TYPE=Release"},
+		{command: `cat {{ joinPath "a" "b" }}`, want: "cat a/b"},
+		{command: `go list -f '{{ "{{" }}.Dir}}' ./...`, want: `go list -f '{{.Dir}}' ./...`},
+		// Go templates meant for the command itself are bild's too once
+		// the phase is templated.
+		{command: `go list -f '{{.ImportPath}}' ./...`, wantErr: true},
+		{command: `docker inspect -f '{{.State.Running}}' db`, wantErr: true},
+		{command: "echo {{ .Vars.missing }}", wantErr: true},
+		{command: "echo {{ if }}", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := RenderCommand(tt.command, data)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("RenderCommand(%q) = %q, want an error", tt.command, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("RenderCommand(%q) = %q, %v, want %q", tt.command, got, err, tt.want)
+		}
+	}
+}
+
+func TestRunTemplates(t *testing.T) {
+	// Without "template", commands and hooks reach the shell as written.
+	literal := `echo '{{.Dir}}' '{{.ImportPath}}' '{{.State.Running}}'`
+	proj := &Project{
+		Name:  "api",
+		Hooks: Hooks{Pre: []string{`echo '{{.Dir}}'`}},
+		Phases: []Phase{
+			{Name: "list", Commands: []string{literal}},
+			{Name: "build", Commands: []string{`echo {{ .Phase }} '{{ "{{" }}.Dir}}'`}, Template: true},
+		},
+	}
+	var stdout bytes.Buffer
+	r := &Runner{Stdout: &stdout, Dir: t.TempDir()}
+	if err := r.RunPhases(context.Background(), proj, []string{"list", "build"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{{.Dir}}\n{{.Dir}} {{.ImportPath}} {{.State.Running}}\nbuild {{.Dir}}\n"; stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+
+	proj.Template = true
+	stdout.Reset()
+	if err := r.Run(context.Background(), proj, "list"); err == nil {
+		t.Errorf("templated project ran %q: %q", literal, stdout.String())
+	}
+}
@@ -0,0 +1,120 @@
+package bild
+
+import (
+	"sync"
+	"time"
+)
+
+// PhaseTiming is how one phase of a run fared.
+type PhaseTiming struct {
+	Name    string
+	Err     error // nil if the phase succeeded
+	Elapsed time.Duration
+	// Commands is only recorded by the observer returned by
+	// Timings.WithCommands.
+	Commands []CommandTiming
+
+	commandStart time.Time // of the last command
+}
+
+// CommandTiming is how long one command of a phase ran.
+type CommandTiming struct {
+	Command string
+	Step    string // the name of the command's step, if it has one
+	Elapsed time.Duration
+}
+
+// Timings is an Observer recording how long a run and each of its phases
+// take, for summaries printed once the run is over.
+type Timings struct {
+	mu     sync.Mutex
+	start  time.Time
+	end    time.Time
+	phases []PhaseTiming
+}
+
+// NewTimings returns Timings for a run starting now.
+func NewTimings() *Timings {
+	return &Timings{start: time.Now()}
+}
+
+// WithCommands returns an Observer recording into t the time each command
+// takes as well. Runners only instrument scripts to tell commands apart for
+// such observers; see CommandObserver.
+func (t *Timings) WithCommands() Observer {
+	return commandTimings{t}
+}
+
+// Phases returns the phases that have started, in the order they started.
+func (t *Timings) Phases() []PhaseTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]PhaseTiming(nil), t.phases...)
+}
+
+// Total returns the wall time from the start of the run until the last
+// phase finished.
+func (t *Timings) Total() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.end.IsZero() {
+		return time.Since(t.start)
+	}
+	return t.end.Sub(t.start)
+}
+
+func (t *Timings) PhaseStarted(phase *Phase) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phases = append(t.phases, PhaseTiming{Name: phase.Name})
+}
+
+func (t *Timings) PhaseFinished(phase *Phase, err error, elapsed time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.end = time.Now()
+	if ph := t.phase(phase.Name); ph != nil {
+		ph.Err, ph.Elapsed = err, elapsed
+		t.finishCommand(ph)
+	}
+}
+
+// phase returns the timing of the phase started last under name, the
+// running one unless the variants of a matrix phase run concurrently;
+// callers hold t.mu.
+func (t *Timings) phase(name string) *PhaseTiming {
+	for i := len(t.phases) - 1; i >= 0; i-- {
+		if t.phases[i].Name == name {
+			return &t.phases[i]
+		}
+	}
+	return nil
+}
+
+// finishCommand records the end of the running command of ph; callers hold t.mu.
+func (t *Timings) finishCommand(ph *PhaseTiming) {
+	if n := len(ph.Commands); n > 0 && ph.Commands[n-1].Elapsed == 0 {
+		ph.Commands[n-1].Elapsed = time.Since(ph.commandStart)
+	}
+}
+
+// commandTimings records commands into Timings.
+type commandTimings struct {
+	*Timings
+}
+
+func (c commandTimings) CommandStarted(phase *Phase, index int, command string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ph := c.phase(phase.Name)
+	if ph == nil {
+		return
+	}
+	c.finishCommand(ph)
+	timing := CommandTiming{Command: command}
+	if index >= 0 && index < len(phase.Commands) {
+		timing.Step = phase.Step(index).Name
+	}
+	ph.Commands = append(ph.Commands, timing)
+	ph.commandStart = time.Now()
+}
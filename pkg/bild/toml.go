@@ -0,0 +1,108 @@
+package bild
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Formats of configuration files. TOML files have the same schema as JSON
+// ones, with the same key names.
+const (
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// LocalConfigTOMLName is the file name of a repository-local configuration
+// written in TOML; see LocalConfigPath.
+const LocalConfigTOMLName = ".bild.toml"
+
+// ConfigFormat returns the format of the configuration file at path, judged
+// by its extension.
+func ConfigFormat(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		return FormatTOML
+	}
+	return FormatJSON
+}
+
+// preferExisting returns jsonPath, unless only tomlPath exists.
+func preferExisting(jsonPath, tomlPath string) string {
+	if _, err := os.Stat(jsonPath); os.IsNotExist(err) {
+		if _, err := os.Stat(tomlPath); err == nil {
+			return tomlPath
+		}
+	}
+	return jsonPath
+}
+
+// LocalConfigPath returns the path of the local configuration in dir:
+// .bild.json, or .bild.toml if only that exists.
+func LocalConfigPath(dir string) string {
+	return preferExisting(filepath.Join(dir, LocalConfigName), filepath.Join(dir, LocalConfigTOMLName))
+}
+
+// toJSON converts a configuration document of format to JSON, which the
+// rest of bild reads.
+func toJSON(data []byte, format string) ([]byte, error) {
+	if format != FormatTOML {
+		return data, nil
+	}
+	var doc map[string]any
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// encodeConfig serializes v in format, JSON as marshalConfig does.
+func encodeConfig(v any, format string) ([]byte, error) {
+	data, err := marshalConfig(v)
+	if err != nil || format != FormatTOML {
+		return data, err
+	}
+	// Going through JSON applies the json tags, so that both formats use
+	// the same names and leave out the same empty fields.
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	table, ok := dropNulls(doc).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("cannot write %T as TOML", v)
+	}
+	var buf bytes.Buffer
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+	if err := enc.Encode(table); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// dropNulls removes the null values of a decoded JSON document, which TOML
+// cannot represent.
+func dropNulls(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if e == nil {
+				delete(v, k)
+			} else {
+				v[k] = dropNulls(e)
+			}
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = dropNulls(e)
+		}
+	}
+	return v
+}
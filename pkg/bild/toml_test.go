@@ -0,0 +1,119 @@
+package bild
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigFormat(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"bild.json", FormatJSON},
+		{".bild.toml", FormatTOML},
+		{"dir/BILD.TOML", FormatTOML},
+		{"bild.toml.bak", FormatJSON},
+		{"bild", FormatJSON},
+	}
+	for _, tt := range tests {
+		if got := ConfigFormat(tt.path); got != tt.want {
+			t.Errorf("ConfigFormat(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLoadLocalConfigTOML(t *testing.T) {
+	dir := t.TempDir()
+	const doc = `
+[api]
+secrets = { env_files = [".env"] }
+
+[[api.phases]]
+name = "build"
+commands = ["go build ./..."]
+ulimits = { nofile = "4096" }
+
+[[api.phases]]
+name = "test"
+commands = ["go test ./..."]
+needs = ["build"]
+
+[web]
+[[web.phases]]
+name = "build"
+commands = ["npm run build"]
+`
+	if err := os.WriteFile(filepath.Join(dir, LocalConfigTOMLName), []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config, ok, err := LoadLocalConfig(dir)
+	if err != nil || !ok {
+		t.Fatalf("LoadLocalConfig = %v, %t", err, ok)
+	}
+	if got, want := config.ProjectNames(), []string{"api", "web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("projects = %v, want %v", got, want)
+	}
+	api := config.Projects["api"]
+	if api.Secrets == nil || !reflect.DeepEqual(api.Secrets.EnvFiles, []string{".env"}) {
+		t.Errorf("api = %+v", api)
+	}
+	if len(api.Phases) != 2 || api.Phases[0].Ulimits["nofile"] != "4096" || !reflect.DeepEqual(api.Phases[1].Needs, []string{"build"}) {
+		t.Errorf("phases of api = %+v", api.Phases)
+	}
+
+	// A .bild.json next to it wins.
+	if err := os.WriteFile(filepath.Join(dir, LocalConfigName), []byte(`{"json": {"phases": []}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if path := LocalConfigPath(dir); filepath.Base(path) != LocalConfigName {
+		t.Errorf("LocalConfigPath = %s, want the %s", path, LocalConfigName)
+	}
+}
+
+func TestBadTOML(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, LocalConfigTOMLName), []byte("[api\nname = 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadLocalConfig(dir); err == nil {
+		t.Error("LoadLocalConfig read a broken TOML file")
+	}
+}
+
+func TestEncodeConfigTOML(t *testing.T) {
+	config := NewConfig()
+	config.Projects["api"] = Project{
+		Hooks: Hooks{Pre: []string{"go mod download"}},
+		Phases: []Phase{
+			{Name: "build", Commands: []string{"go build ./..."}, Ulimits: map[string]string{"nofile": "4096"}},
+			{Name: "test", Commands: []string{`go test ./... && echo "ok"`}, Needs: []string{"build"}, Shell: "bash"},
+		},
+	}
+	data, err := config.marshal(FormatTOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	// Empty fields are left out, as in JSON, since TOML has no null.
+	if strings.Contains(text, "null") || strings.Contains(text, "extends") {
+		t.Errorf("TOML has empty fields:\n%s", text)
+	}
+	if !strings.Contains(text, "[[projects.api.phases]]") {
+		t.Errorf("TOML has no array of phases:\n%s", text)
+	}
+
+	back := NewConfig()
+	if data, err = toJSON(data, FormatTOML); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back.Projects, config.Projects) {
+		t.Errorf("round trip through TOML:\ngot  %+v\nwant %+v", back.Projects, config.Projects)
+	}
+}
@@ -0,0 +1,273 @@
+package bild
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Conditions decide whether a phase runs; see Phase.When. They compare
+// strings with == and !=, test files with exists("path"), and combine tests
+// with !, && and || and parentheses:
+//
+//	os == "linux" && (env.CI == "true" || branch == "main")
+//	!exists("CMakeLists.txt")
+//
+// The strings compared are quoted literals and the variables os and arch
+// (runtime.GOOS and GOARCH), env.NAME (the environment variable NAME, or ""
+// if it is unset) and branch (the git branch checked out, or "" outside a
+// repository or with a detached HEAD).
+
+// whenEnv provides the variables and files conditions test.
+type whenEnv struct {
+	dir    string // relative paths given to exists start here
+	env    []string
+	branch *string // looked up on first use
+}
+
+func (e *whenEnv) variable(name string) string {
+	switch {
+	case name == "os":
+		return runtime.GOOS
+	case name == "arch":
+		return runtime.GOARCH
+	case name == "branch":
+		if e.branch == nil {
+			branch, err := Branch(e.dir)
+			if err != nil {
+				Tracef("when", "no branch in %s: %v", e.dir, err)
+			}
+			e.branch = &branch
+		}
+		return *e.branch
+	}
+	key := strings.TrimPrefix(name, "env.")
+	// Later entries win, as they do for commands.
+	for i := len(e.env) - 1; i >= 0; i-- {
+		if k, v, ok := strings.Cut(e.env[i], "="); ok && k == key {
+			return v
+		}
+	}
+	return os.Getenv(key)
+}
+
+// condition is a parsed condition.
+type condition interface {
+	eval(e *whenEnv) bool
+}
+
+// operand is a string compared by a condition.
+type operand interface {
+	value(e *whenEnv) string
+}
+
+type (
+	notCondition     struct{ c condition }
+	andCondition     struct{ a, b condition }
+	orCondition      struct{ a, b condition }
+	existsCondition  struct{ path operand }
+	compareCondition struct {
+		a, b  operand
+		equal bool
+	}
+	literalOperand  string
+	variableOperand string
+)
+
+func (c notCondition) eval(e *whenEnv) bool { return !c.c.eval(e) }
+func (c andCondition) eval(e *whenEnv) bool { return c.a.eval(e) && c.b.eval(e) }
+func (c orCondition) eval(e *whenEnv) bool  { return c.a.eval(e) || c.b.eval(e) }
+
+func (c existsCondition) eval(e *whenEnv) bool {
+	path := c.path.value(e)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(e.dir, path)
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (c compareCondition) eval(e *whenEnv) bool {
+	return (c.a.value(e) == c.b.value(e)) == c.equal
+}
+
+func (o literalOperand) value(*whenEnv) string    { return string(o) }
+func (o variableOperand) value(e *whenEnv) string { return e.variable(string(o)) }
+
+// parseCondition parses the condition expr.
+func parseCondition(expr string) (condition, error) {
+	p := &whenParser{expr: expr}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	c, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return c, nil
+}
+
+// whenParser is a recursive descent parser of conditions. tok is the
+// current token, "" at the end of the expression.
+type whenParser struct {
+	expr string
+	pos  int // where the next token starts
+	tok  string
+	at   int // where tok starts
+}
+
+func (p *whenParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("condition %q: %s at offset %d", p.expr, fmt.Sprintf(format, args...), p.at)
+}
+
+// next reads the next token.
+func (p *whenParser) next() error {
+	for p.pos < len(p.expr) && strings.ContainsRune(" \t\n", rune(p.expr[p.pos])) {
+		p.pos++
+	}
+	p.at = p.pos
+	rest := p.expr[p.pos:]
+	switch {
+	case rest == "":
+		p.tok = ""
+	case strings.HasPrefix(rest, `"`):
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return p.errorf("unterminated string")
+		}
+		p.tok = quoted
+	case isOperator(rest):
+		p.tok = rest[:2]
+	case strings.ContainsRune("!(),", rune(rest[0])):
+		p.tok = rest[:1]
+	default:
+		n := strings.IndexFunc(rest, func(r rune) bool {
+			return !(r == '_' || r == '.' || r == '-' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+		})
+		if n == 0 {
+			return p.errorf("unexpected %q", rest[:1])
+		}
+		if n < 0 {
+			n = len(rest)
+		}
+		p.tok = rest[:n]
+	}
+	p.pos += len(p.tok)
+	return nil
+}
+
+// isOperator reports whether s starts with an operator of two characters.
+func isOperator(s string) bool {
+	if len(s) < 2 {
+		return false
+	}
+	switch s[:2] {
+	case "==", "!=", "&&", "||":
+		return true
+	}
+	return false
+}
+
+// expect consumes tok.
+func (p *whenParser) expect(tok string) error {
+	if p.tok != tok {
+		if p.tok == "" {
+			return p.errorf("missing %s", tok)
+		}
+		return p.errorf("expected %s, found %s", tok, p.tok)
+	}
+	return p.next()
+}
+
+func (p *whenParser) or() (condition, error) {
+	c, err := p.and()
+	for err == nil && p.tok == "||" {
+		var b condition
+		if err = p.next(); err == nil {
+			b, err = p.and()
+			c = orCondition{c, b}
+		}
+	}
+	return c, err
+}
+
+func (p *whenParser) and() (condition, error) {
+	c, err := p.unary()
+	for err == nil && p.tok == "&&" {
+		var b condition
+		if err = p.next(); err == nil {
+			b, err = p.unary()
+			c = andCondition{c, b}
+		}
+	}
+	return c, err
+}
+
+func (p *whenParser) unary() (condition, error) {
+	switch p.tok {
+	case "!":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		c, err := p.unary()
+		return notCondition{c}, err
+	case "(":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		c, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expect(")")
+	case "exists":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		path, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return existsCondition{path}, p.expect(")")
+	}
+
+	a, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.tok
+	if op != "==" && op != "!=" {
+		return nil, p.errorf("expected == or != after %s", strings.TrimSpace(p.expr[:p.at]))
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	b, err := p.operand()
+	return compareCondition{a, b, op == "=="}, err
+}
+
+func (p *whenParser) operand() (operand, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return nil, p.errorf("missing value")
+	case strings.HasPrefix(tok, `"`):
+		s, err := strconv.Unquote(tok)
+		if err != nil {
+			return nil, p.errorf("invalid string %s", tok)
+		}
+		return literalOperand(s), p.next()
+	case tok == "os" || tok == "arch" || tok == "branch" || strings.HasPrefix(tok, "env.") && len(tok) > len("env."):
+		return variableOperand(tok), p.next()
+	}
+	return nil, p.errorf("unknown value %s (want a quoted string, os, arch, branch or env.NAME)", tok)
+}
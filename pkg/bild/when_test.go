@@ -0,0 +1,56 @@
+package bild
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCondition(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "CMakeLists.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	branch := "main"
+	env := &whenEnv{dir: dir, env: []string{"CI=false", "CI=true"}, branch: &branch}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`os == "` + runtime.GOOS + `"`, true},
+		{`os != "` + runtime.GOOS + `"`, false},
+		{`env.CI == "true"`, true},
+		{`env.BILD_UNSET_FOR_TEST == ""`, true},
+		{`branch == "main" && exists("CMakeLists.txt")`, true},
+		{`!exists("Makefile") && !(arch == "none")`, true},
+		{`branch == "dev" || env.CI == "true" && os == "none"`, false},
+		{`"a" == "a"`, true},
+	}
+	for _, tt := range tests {
+		c, err := parseCondition(tt.expr)
+		if err != nil {
+			t.Errorf("parseCondition(%s): %v", tt.expr, err)
+			continue
+		}
+		if got := c.eval(env); got != tt.want {
+			t.Errorf("%s = %t, want %t", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{
+		``,
+		`os`,
+		`os = "linux"`,
+		`platform == "linux"`,
+		`(os == "linux"`,
+		`exists("a"`,
+		`env.CI == "true`,
+		`os == "linux" &&`,
+		`env. == ""`,
+	} {
+		if _, err := parseCondition(expr); err == nil {
+			t.Errorf("parseCondition(%s) succeeded", expr)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package bild
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTerminal returns the command opening a terminal window on this
+// system when none is configured: $TERMINAL -e if TERMINAL is set, the
+// Terminal app on macOS and x-terminal-emulator -e elsewhere.
+func DefaultTerminal() []string {
+	if term := os.Getenv("TERMINAL"); term != "" {
+		return []string{term, "-e"}
+	}
+	if runtime.GOOS == "darwin" {
+		return []string{"open", "-a", "Terminal"}
+	}
+	return []string{"x-terminal-emulator", "-e"}
+}
+
+// windowExitError is the outcome of a phase that failed in a window.
+type windowExitError struct {
+	code int
+}
+
+func (e *windowExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// ExitCode returns the exit status of the phase's shell.
+func (e *windowExitError) ExitCode() int {
+	return e.code
+}
+
+// runInWindow runs script with the shell command shell in a new window of
+// r.Terminal, with env added to its environment, and waits until it is
+// done. As terminals don't tell when what they run exits, and many return
+// at once, the window reports the exit status through a file. After a
+// failure, the window stays open until Enter is pressed.
+func (r *Runner) runInWindow(ctx context.Context, ph *Phase, shell []string, script string, env []string) error {
+	tmp, err := os.MkdirTemp("", "bild-window-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	dir := r.Dir
+	if dir == "" {
+		if dir, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+
+	// The environment is exported by the script, as terminals started
+	// through a server, such as GNOME Terminal, don't pass bild's on.
+	phaseScript := filepath.Join(tmp, "phase.sh")
+	status := filepath.Join(tmp, "status")
+	var w strings.Builder
+	w.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&w, "printf '\\033]0;%%s\\007' %s\n", quoteShell(ph.Name))
+	fmt.Fprintf(&w, "cd %s || exit\n", quoteShell(dir))
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		fmt.Fprintf(&w, "export %s=%s\n", name, quoteShell(value))
+	}
+	for _, arg := range shell {
+		w.WriteString(quoteShell(arg) + " ")
+	}
+	w.WriteString(quoteShell(phaseScript) + "\n")
+	w.WriteString("status=$?\n")
+	fmt.Fprintf(&w, "echo $status >%s.tmp && mv %s.tmp %s\n", quoteShell(status), quoteShell(status), quoteShell(status))
+	fmt.Fprintf(&w, "[ $status -eq 0 ] || { printf '\\n%%s failed with exit status %%d; press Enter to close.' %s $status; read _; }\n", quoteShell(ph.Name))
+	wrapper := filepath.Join(tmp, "run.sh")
+	if err := os.WriteFile(phaseScript, []byte(script), 0o600); err != nil {
+		return err
+	}
+	// Terminals opening a script as a file, such as macOS's, need it executable.
+	if err := os.WriteFile(wrapper, []byte(w.String()), 0o700); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(r.Terminal[0], append(r.Terminal[1:], wrapper)...)
+	Tracef("runner", "phase %s: opening a window with %s", ph.Name, strings.Join(cmd.Args, " "))
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not open a terminal window with %s: %w", r.Terminal[0], err)
+	}
+	launched := make(chan error, 1)
+	go func() { launched <- cmd.Wait() }()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if data, err := os.ReadFile(status); err == nil {
+			code, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				return fmt.Errorf("phase %s reported no exit status: %q", ph.Name, data)
+			}
+			if code != 0 {
+				return &windowExitError{code: code}
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-launched:
+			// A terminal exiting once the window closes may fail anyway.
+			if _, statErr := os.Stat(status); err != nil && statErr != nil {
+				return fmt.Errorf("could not open a terminal window with %s: %w", r.Terminal[0], err)
+			}
+			// The terminal may have handed the window to a server; keep
+			// waiting for the status.
+			launched = nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// quoteShell quotes s for a POSIX shell.
+func quoteShell(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
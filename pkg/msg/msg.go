@@ -0,0 +1,100 @@
+// Package msg formats human-facing messages from per-language catalogs, with
+// plural-aware variants, so that output is generated consistently and can be
+// localized and tested in one place.
+package msg
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DefaultLanguage is used when a message is missing from the selected language.
+const DefaultLanguage = "en"
+
+// Message is a format string with an optional singular form.
+type Message struct {
+	One   string // used when the count is exactly one; empty means use Other
+	Other string
+}
+
+// Catalog maps message keys to messages of one language.
+type Catalog map[string]Message
+
+var (
+	mu       sync.RWMutex
+	catalogs = make(map[string]Catalog)
+)
+
+// Register adds the messages of catalog to the given language.
+func Register(lang string, catalog Catalog) {
+	mu.Lock()
+	defer mu.Unlock()
+	if catalogs[lang] == nil {
+		catalogs[lang] = make(Catalog)
+	}
+	for key, m := range catalog {
+		catalogs[lang][key] = m
+	}
+}
+
+// DetectLanguage returns the two-letter language code selected by $BILD_LANG,
+// $LC_ALL, $LC_MESSAGES or $LANG, falling back to DefaultLanguage.
+func DetectLanguage() string {
+	for _, env := range []string{"BILD_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(env)
+		if value == "" || value == "C" || value == "POSIX" {
+			continue
+		}
+		if i := strings.IndexAny(value, "_.@"); i >= 0 {
+			value = value[:i]
+		}
+		return strings.ToLower(value)
+	}
+	return DefaultLanguage
+}
+
+// Printer formats messages in one language.
+type Printer struct {
+	lang string
+}
+
+// NewPrinter returns a Printer for lang.
+func NewPrinter(lang string) *Printer {
+	return &Printer{lang: lang}
+}
+
+// lookup returns the message for key in the printer's language, falling back
+// to DefaultLanguage and finally to the key itself.
+func (p *Printer) lookup(key string) Message {
+	mu.RLock()
+	defer mu.RUnlock()
+	if m, ok := catalogs[p.lang][key]; ok {
+		return m
+	}
+	if m, ok := catalogs[DefaultLanguage][key]; ok {
+		return m
+	}
+	return Message{Other: key}
+}
+
+// Sprintf formats the message for key with args. Without args it returns
+// the message as is, so that it can serve as a format string itself.
+func (p *Printer) Sprintf(key string, args ...any) string {
+	if len(args) == 0 {
+		return p.lookup(key).Other
+	}
+	return fmt.Sprintf(p.lookup(key).Other, args...)
+}
+
+// Plural formats the singular or plural form of the message for key,
+// depending on n, with args.
+func (p *Printer) Plural(key string, n int, args ...any) string {
+	m := p.lookup(key)
+	format := m.Other
+	if n == 1 && m.One != "" {
+		format = m.One
+	}
+	return fmt.Sprintf(format, args...)
+}
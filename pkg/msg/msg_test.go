@@ -0,0 +1,51 @@
+package msg
+
+import "testing"
+
+func TestPrinter(t *testing.T) {
+	Register("en", Catalog{
+		"test.files":  {One: "%d file", Other: "%d files"},
+		"test.only":   {Other: "only in English"},
+		"test.static": {Other: "50%% done"},
+	})
+	Register("de", Catalog{
+		"test.files": {One: "%d Datei", Other: "%d Dateien"},
+	})
+	en, de := NewPrinter("en"), NewPrinter("de")
+	for _, tt := range []struct{ got, want string }{
+		{en.Plural("test.files", 1, 1), "1 file"},
+		{en.Plural("test.files", 0, 0), "0 files"},
+		{de.Plural("test.files", 1, 1), "1 Datei"},
+		{de.Plural("test.files", 3, 3), "3 Dateien"},
+		// Missing messages fall back to English, then to the key.
+		{de.Sprintf("test.only"), "only in English"},
+		{NewPrinter("fr").Plural("test.files", 2, 2), "2 files"},
+		{de.Sprintf("test.missing"), "test.missing"},
+		// Without args the message is left as is, to be a format string.
+		{en.Sprintf("test.static"), "50%% done"},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	for _, tt := range []struct {
+		bildLang, lcAll, lang, want string
+	}{
+		{"", "", "de_DE.UTF-8", "de"},
+		{"", "C", "de_DE.UTF-8", "de"},
+		{"", "fr_FR@euro", "de_DE.UTF-8", "fr"},
+		{"EN", "", "de_DE.UTF-8", "en"},
+		{"", "POSIX", "", DefaultLanguage},
+	} {
+		t.Setenv("BILD_LANG", tt.bildLang)
+		t.Setenv("LC_ALL", tt.lcAll)
+		t.Setenv("LC_MESSAGES", "")
+		t.Setenv("LANG", tt.lang)
+		if got := DetectLanguage(); got != tt.want {
+			t.Errorf("BILD_LANG=%q LC_ALL=%q LANG=%q: %s, want %s", tt.bildLang, tt.lcAll, tt.lang, got, tt.want)
+		}
+	}
+}
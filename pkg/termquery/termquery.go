@@ -0,0 +1,15 @@
+// Package termquery keeps bild from querying the terminal as it starts.
+//
+// bubbletea's init function asks the terminal for its background color,
+// through lipgloss, and waits up to five seconds for terminals that never
+// answer, on every run of every command. bild's styles don't depend on the
+// background, so this package answers for the terminal first: packages are
+// initialized in the order of their import paths once their imports are,
+// and bild/pkg/termquery comes before github.com/charmbracelet/bubbletea.
+package termquery
+
+import "github.com/charmbracelet/lipgloss"
+
+func init() {
+	lipgloss.SetHasDarkBackground(true)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"bild/pkg/bild"
+)
+
+// maxShownProblems bounds the problems listed after a run; the file of
+// --problems has them all.
+const maxShownProblems = 30
+
+// hasProblemMatchers reports whether a phase of proj names problem matchers.
+func hasProblemMatchers(proj *bild.Project) bool {
+	for _, ph := range proj.Phases {
+		if len(ph.Problems) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// printProblems lists the problems the phases of a run reported, below its
+// summary.
+func printProblems(problems []bild.Problem) {
+	if len(problems) == 0 {
+		return
+	}
+	var errors, warnings int
+	for _, p := range problems {
+		switch p.Severity {
+		case "error":
+			errors++
+		case "warning":
+			warnings++
+		}
+	}
+	fmt.Println()
+	fmt.Println(tn("run.problems", len(problems), len(problems), errors, warnings))
+	for i, p := range problems {
+		if i == maxShownProblems {
+			fmt.Println("  " + t("run.problems_more", len(problems)-i))
+			break
+		}
+		fmt.Printf("  %s  (%s)\n", p, p.Phase)
+	}
+}
+
+// writeProblems writes problems, found in a run in dir, to path: as SARIF
+// with paths relative to the repository if it ends in .sarif, else as a
+// JSON array.
+func writeProblems(path string, problems []bild.Problem, dir string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(path, ".sarif") {
+		root, rootErr := bild.RepoRoot(dir)
+		if rootErr != nil {
+			root = dir
+		}
+		err = bild.WriteSARIF(f, problems, dir, root)
+	} else {
+		if problems == nil {
+			problems = []bild.Problem{}
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(problems)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		fmt.Fprintln(infoOut, t("run.problems_written", len(problems), path))
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// runHistory returns the name of the project a run of projectName runs,
+// and its run history, which must not be empty.
+func runHistory(projectName string) (string, []bild.HistoryEntry, error) {
+	// The run resolves the project again, and tells about it then.
+	out := infoOut
+	infoOut = io.Discard
+	proj, _, _, err := resolveRun(projectName)
+	infoOut = out
+	if err != nil {
+		return "", nil, err
+	}
+	entries, err := loadHistory(proj.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(entries) == 0 {
+		return "", nil, fmt.Errorf("no runs of %s recorded", proj.Name)
+	}
+	return proj.Name, entries, nil
+}
+
+// resumeCmd runs a project again from where its last run failed.
+var resumeCmd = &cobra.Command{
+	Use:   "resume [project]",
+	Short: "Continue the last failed run from the phase or step that failed",
+	Long: `Looks up the last run of the project in the run history and, if it failed,
+runs the project again starting with the phase that failed, or with the
+step that failed if it is a named one, instead of restarting the whole
+pipeline. The phases after it run as with 'bild run --from', up to the
+last phase of the project or the one given with --until. If no project
+is provided, it is deduced from the Git repository.`,
+	Example: `  bild resume
+  bild resume api --until test`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var projectName string
+		if len(args) == 1 {
+			projectName = args[0]
+		}
+		name, entries, err := runHistory(projectName)
+		if err != nil {
+			return err
+		}
+		failed := bild.LastFailure(entries)
+		if failed == nil {
+			fmt.Println(t("resume.none", name))
+			return nil
+		}
+		// A failed variant of a matrix phase resumes with all of them.
+		from, _, _ := strings.Cut(failed.Phase, "[")
+		if failed.Step != "" {
+			from += ":" + failed.Step
+		}
+		fmt.Fprintln(infoOut, t("resume.from", name, from, failed.Start.Local().Format("2006-01-02 15:04:05")))
+
+		opts := runOptions{output: "text", from: from}
+		opts.until, _ = cmd.Flags().GetString("until")
+		return runProject(cmd.Context(), projectName, nil, opts)
+	},
+}
+
+// retryCmd runs the phases of a project again that failed in its last run.
+var retryCmd = &cobra.Command{
+	Use:   "retry [project]",
+	Short: "Run again only the phases and matrix variants that failed last time",
+	Long: `Looks up the last run of the project in the run history and runs again only
+the phases that failed in it, and of matrix phases only the variants that
+failed, in the order their needs call for. Phases that succeeded, and those
+the failure kept from running, are left out; 'bild resume' continues with
+those. If no project is provided, it is deduced from the Git repository.`,
+	Example: `  bild retry
+  bild retry api`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var projectName string
+		if len(args) == 1 {
+			projectName = args[0]
+		}
+		name, entries, err := runHistory(projectName)
+		if err != nil {
+			return err
+		}
+		failed := bild.LastFailures(entries)
+		if len(failed) == 0 {
+			fmt.Println(t("retry.none", name))
+			return nil
+		}
+		names := make([]string, len(failed))
+		for i, e := range failed {
+			names[i] = e.Phase
+		}
+		fmt.Fprintln(infoOut, t("retry.phases", name, strings.Join(names, ", ")))
+		return runProject(cmd.Context(), projectName, nil, runOptions{output: "text", retry: names})
+	},
+}
+
+func init() {
+	resumeCmd.Flags().String("until", "", "End the run with this phase, or with the step of a phase given as phase:step")
+	rootCmd.AddCommand(resumeCmd)
+	rootCmd.AddCommand(retryCmd)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// runAllResult is the outcome of a run-all in one project.
+type runAllResult struct {
+	project string
+	dir     string
+	elapsed time.Duration
+	err     error
+	// skipped says why the phase did not run in the project, if it didn't.
+	skipped string
+}
+
+// checkoutDir returns the directory project name of config is checked out
+// in: its path, or else a workspace of config given as an absolute path. It
+// returns "" if neither tells.
+func checkoutDir(config *bild.Config, name string) string {
+	if dir := config.Projects[name].Path; dir != "" {
+		return dir
+	}
+	for _, path := range slices.Sorted(maps.Keys(config.Workspaces)) {
+		if config.Workspaces[path] == name && filepath.IsAbs(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// runAll runs phase in every project of the global configuration, or in
+// those carrying every one of tags, in their checkouts, up to parallel of
+// them at once, and prints a summary. Projects without a checkout, with a
+// missing one or without the phase are left out. A failing project does not keep the others from
+// running; the error is that of the failed projects.
+func runAll(ctx context.Context, phase string, tags []string, parallel int) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf(t("err.load_config"), err)
+	}
+	names := config.ProjectNames()
+	if len(tags) > 0 {
+		names = config.TaggedProjects(tags)
+	}
+	ctx, stop := bild.NotifyContext(ctx)
+	defer stop()
+
+	results := make([]runAllResult, len(names))
+	projects := make(map[int]*bild.Project)
+	var queue []int
+	for i, name := range names {
+		res := &results[i]
+		res.project = name
+		dir := checkoutDir(config, name)
+		if dir == "" {
+			res.skipped = t("runall.no_path")
+			continue
+		}
+		proj, dir, err := resolveStep(bild.PipelineStep{Project: name, Dir: dir}, config)
+		res.dir = dir
+		if err != nil {
+			res.err = err
+			continue
+		}
+		if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+			res.skipped = t("runall.no_checkout", dir)
+			continue
+		}
+		if _, err := proj.Phase(phase); err != nil {
+			res.skipped = t("runall.no_phase", phase)
+			continue
+		}
+		projects[i] = proj
+		queue = append(queue, i)
+	}
+	bild.Tracef("run-all", "phase %s in %d of %d projects, %d at a time", phase, len(queue), len(names), parallel)
+
+	// Parallel runs report one at a time as they finish, with the output of
+	// those that failed.
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+	for n, i := range queue {
+		res := &results[i]
+		if ctx.Err() != nil {
+			res.skipped = t("runall.interrupted")
+			continue
+		}
+		if parallel == 1 {
+			fmt.Fprintln(infoOut, t("runall.project", n+1, len(queue), res.project, res.dir))
+			res.elapsed, res.err = runAllProject(ctx, config, projects[i], res.dir, phase, nil)
+			continue
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			var out bytes.Buffer
+			res.elapsed, res.err = runAllProject(ctx, config, projects[i], res.dir, phase, &out)
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintln(infoOut, t("runall.done", res.project, resultText(res.err == nil, exitCode(res.err)), res.elapsed.Round(time.Millisecond)))
+			// The output of failed projects tells why.
+			if res.err != nil {
+				os.Stdout.Write(out.Bytes())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !quiet {
+		printRunAllSummary(phase, results)
+	}
+	var errs []error
+	for _, res := range results {
+		if res.err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", res.project, res.err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runAllProject runs phase of proj in dir and returns how long it took. Its
+// output goes to out, or with a nil out to the terminal, as in bild run.
+func runAllProject(ctx context.Context, config *bild.Config, proj *bild.Project, dir, phase string, out io.Writer) (time.Duration, error) {
+	runner := bild.NewRunner()
+	runner.Dir = dir
+	if logDir := config.EffectiveLogDir(); logDir != "" {
+		var err error
+		if runner.LogDir, err = expandHome(logDir); err != nil {
+			return 0, err
+		}
+	}
+	if dirs, err := getDirs(); err == nil {
+		runner.State = dirs.ProjectState(proj.Name)
+		runner.Cache = dirs.ArtifactCache()
+	}
+	observers := []bild.Observer{
+		&activeRunObserver{project: proj.Name},
+		newHistoryObserver(proj.Name, dir),
+	}
+	if out != nil {
+		runner.Stdin, runner.Stdout, runner.Stderr = nil, nil, nil
+		runner.Sinks = []bild.Sink{bild.WriterSink(out)}
+	} else {
+		observers = append(observers, newConsoleObserver(runner))
+	}
+	runner.Observer = bild.MultiObserver(observers...)
+	start := time.Now()
+	err := runner.Run(ctx, proj, phase)
+	return time.Since(start), err
+}
+
+// printRunAllSummary prints a row per project with the outcome of phase in
+// it, and the totals.
+func printRunAllSummary(phase string, results []runAllResult) {
+	fmt.Println()
+	fmt.Println(t("runall.summary", phase))
+	fmt.Printf("%-20s %10s  %s\n", "PROJECT", "DURATION", "RESULT")
+	var passed, failed, skipped int
+	for _, res := range results {
+		switch {
+		case res.skipped != "":
+			skipped++
+			fmt.Printf("%-20s %10s  %s %s\n", res.project, "-", plainOr("⏭️", "skipped"), res.skipped)
+		case res.err != nil:
+			failed++
+			fmt.Printf("%-20s %10s  %s\n", res.project, res.elapsed.Round(time.Millisecond), resultText(false, exitCode(res.err)))
+		default:
+			passed++
+			fmt.Printf("%-20s %10s  %s\n", res.project, res.elapsed.Round(time.Millisecond), resultText(true, 0))
+		}
+	}
+	fmt.Println(t("runall.totals", passed, failed, skipped))
+}
+
+// runAllCmd runs a phase in every registered project.
+var runAllCmd = &cobra.Command{
+	Use:   "run-all <phase>",
+	Short: "Run a phase in every registered project",
+	Long: `Runs the phase in each project of the global config, or in those with the
+tags given with --tag, in the project's checkout: its "path", such as
+"~/src/api", or else a workspace given as an absolute path. Local configs
+there are used as by a run started in the checkout. Projects without a
+checkout, with a missing one or without the phase are left out.
+
+The projects run one after another, or with --parallel up to that many at
+once; their output is then shown once they are done, in full for those
+that failed. A failing project doesn't stop the others. A summary of all
+projects follows, and bild exits with the exit code of the first failure.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		parallel, _ := cmd.Flags().GetInt("parallel")
+		if parallel < 1 {
+			return fmt.Errorf("--parallel must be at least 1")
+		}
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+		return runAll(cmd.Context(), args[0], tags, parallel)
+	},
+}
+
+func init() {
+	runAllCmd.Flags().IntP("parallel", "j", 1, "Run the phase in this many projects at once")
+	runAllCmd.Flags().StringSlice("tag", nil, "Only run projects with this tag; repeat or separate with commas to require several")
+	rootCmd.AddCommand(runAllCmd)
+}
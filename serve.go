@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// ServeTokenEnv names the environment variable holding the token of the API
+// served by bild serve.
+const ServeTokenEnv = "BILD_SERVE_TOKEN"
+
+// apiServer serves the HTTP API of bild serve.
+type apiServer struct {
+	token string
+}
+
+// runRequest is the body of a request to start a run.
+type runRequest struct {
+	Phases  []string `json:"phases,omitempty"`
+	Skip    []string `json:"skip,omitempty"`
+	Profile string   `json:"profile,omitempty"`
+	Force   bool     `json:"force,omitempty"`
+}
+
+// handler returns the routes of the API, all behind the token.
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/projects", s.listProjects)
+	mux.HandleFunc("GET /api/projects/{project}", s.getProject)
+	mux.HandleFunc("POST /api/projects/{project}/runs", s.startRun)
+	mux.HandleFunc("GET /api/runs", s.listRuns)
+	mux.HandleFunc("GET /api/runs/{id}", s.getRun)
+	mux.HandleFunc("GET /api/runs/{id}/events", s.streamRun)
+	mux.HandleFunc("DELETE /api/runs/{id}", s.stopRun)
+	mux.HandleFunc("GET /api/history", s.history)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bild.Tracef("serve", "%s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		// EventSource can't set headers, so the token may come as a parameter.
+		token := r.URL.Query().Get("token")
+		if auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			token = auth
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, errors.New("missing or wrong token"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON answers with v as JSON.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+// writeJSONError answers with err as {"error": "..."}.
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// projectStatus returns the HTTP status of an error looking up a project.
+func projectStatus(err error) int {
+	var notFound *bild.ProjectNotFoundError
+	if errors.As(err, &notFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// apiProject is a project as listed by the API and bild lsp.
+type apiProject struct {
+	Name   string     `json:"name"`
+	Path   string     `json:"path,omitempty"`
+	Tags   []string   `json:"tags,omitempty"`
+	Phases []apiPhase `json:"phases"`
+}
+
+// apiPhase is a phase of an apiProject.
+type apiPhase struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// apiProjects returns the projects of config as listed by the API.
+func apiProjects(config *bild.Config) []apiProject {
+	list := []apiProject{}
+	for _, name := range config.ProjectNames() {
+		proj := listedProject(config, name)
+		p := apiProject{Name: name, Path: proj.Path, Tags: proj.Tags, Phases: []apiPhase{}}
+		for _, ph := range proj.Phases {
+			p.Phases = append(p.Phases, apiPhase{Name: ph.Name, Description: ph.Description})
+		}
+		list = append(list, p)
+	}
+	return list
+}
+
+func (s *apiServer) listProjects(w http.ResponseWriter, r *http.Request) {
+	config, err := loadConfig()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, apiProjects(config))
+}
+
+func (s *apiServer) getProject(w http.ResponseWriter, r *http.Request) {
+	config, err := loadConfig()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	name := r.PathValue("project")
+	proj, err := config.Project(name)
+	if err != nil {
+		writeJSONError(w, projectStatus(err), err)
+		return
+	}
+	// Where secrets come from is none of the clients' business.
+	proj.Secrets = nil
+	writeJSON(w, http.StatusOK, struct {
+		Name string `json:"name"`
+		*bild.Project
+	}{name, proj})
+}
+
+func (s *apiServer) startRun(w http.ResponseWriter, r *http.Request) {
+	var req runRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	config, err := loadConfig()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	name := r.PathValue("project")
+	proj, err := config.Project(name)
+	if err != nil {
+		writeJSONError(w, projectStatus(err), err)
+		return
+	}
+	for _, phase := range req.Phases {
+		if _, err := proj.Phase(phase); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	run, err := startBackground(name, req.args(name))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// args returns the arguments of the bild command running req for project,
+// writing its events as JSON Lines.
+func (req runRequest) args(project string) []string {
+	args := []string{"run", project}
+	if len(req.Phases) > 0 {
+		args = append(args, strings.Join(req.Phases, ","))
+	}
+	// Without bild's own messages, every line of the output is an event.
+	args = append(args, "--output=json", "--quiet")
+	args = append(args, globalArgs()...)
+	for _, skip := range req.Skip {
+		args = append(args, "--skip="+skip)
+	}
+	if req.Profile != "" {
+		args = append(args, "--profile="+req.Profile)
+	}
+	if req.Force {
+		args = append(args, "--force")
+	}
+	return args
+}
+
+// globalArgs returns the flags given to bild as a whole, such as --config,
+// for the runs it starts.
+func globalArgs() []string {
+	var args []string
+	rootCmd.PersistentFlags().Visit(func(f *pflag.Flag) {
+		args = append(args, "--"+f.Name+"="+f.Value.String())
+	})
+	return args
+}
+
+// findRun returns the background run of the request's ID, or answers that
+// there is none.
+func (s *apiServer) findRun(w http.ResponseWriter, r *http.Request) *bild.BackgroundRun {
+	dirs, err := getDirs()
+	if err == nil {
+		var runs []*bild.BackgroundRun
+		if runs, err = bild.BackgroundRuns(dirs.BackgroundDir()); err == nil {
+			for _, run := range runs {
+				if run.ID == r.PathValue("id") {
+					return run
+				}
+			}
+			writeJSONError(w, http.StatusNotFound, fmt.Errorf("no run %s", r.PathValue("id")))
+			return nil
+		}
+	}
+	writeJSONError(w, http.StatusInternalServerError, err)
+	return nil
+}
+
+func (s *apiServer) listRuns(w http.ResponseWriter, r *http.Request) {
+	dirs, err := getDirs()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	runs, err := bild.BackgroundRuns(dirs.BackgroundDir())
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if runs == nil {
+		runs = []*bild.BackgroundRun{}
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (s *apiServer) getRun(w http.ResponseWriter, r *http.Request) {
+	if run := s.findRun(w, r); run != nil {
+		writeJSON(w, http.StatusOK, run)
+	}
+}
+
+func (s *apiServer) stopRun(w http.ResponseWriter, r *http.Request) {
+	run := s.findRun(w, r)
+	if run == nil {
+		return
+	}
+	if run.Running() {
+		if err := run.Stop(stopDelay); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	if latest, err := run.Reload(); err == nil {
+		run = latest
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+// streamRun sends the output of a run as server-sent events, one per line,
+// followed by an "end" event with the run once it ended.
+func (s *apiServer) streamRun(w http.ResponseWriter, r *http.Request) {
+	run := s.findRun(w, r)
+	if run == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	f, err := os.Open(run.OutputPath())
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	in := bufio.NewReader(f)
+	var partial string
+	for {
+		ended := !run.Running()
+		for {
+			line, err := in.ReadString('\n')
+			partial += line
+			if err != nil {
+				break
+			}
+			fmt.Fprintf(w, "data: %s\n\n", strings.TrimRight(partial, "\r\n"))
+			partial = ""
+		}
+		flusher.Flush()
+		if ended {
+			break
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(200 * time.Millisecond):
+		}
+		if latest, err := run.Reload(); err == nil {
+			run = latest
+		}
+	}
+	if partial != "" {
+		fmt.Fprintf(w, "data: %s\n\n", partial)
+	}
+	if latest, err := run.Reload(); err == nil {
+		run = latest
+	}
+	data, _ := json.Marshal(run)
+	fmt.Fprintf(w, "event: end\ndata: %s\n\n", data)
+	flusher.Flush()
+}
+
+func (s *apiServer) history(w http.ResponseWriter, r *http.Request) {
+	entries, err := loadHistory(r.URL.Query().Get("project"))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("bad limit %q", limit))
+			return
+		}
+		if n < len(entries) {
+			entries = entries[len(entries)-n:]
+		}
+	}
+	if entries == nil {
+		entries = []bild.HistoryEntry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// newServeToken returns a random token for bild serve.
+func newServeToken() (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "bild_" + hex.EncodeToString(secret), nil
+}
+
+// serveCmd serves an HTTP API for editors, dashboards and other machines.
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve an HTTP API to list projects, start runs and follow them",
+	Long: `Serves a small HTTP API, so that editor plugins, dashboards or other
+machines can drive bild:
+
+  GET    /api/projects                 the projects with their phases
+  GET    /api/projects/{project}       a project as resolved
+  POST   /api/projects/{project}/runs  start a run: {"phases": [...],
+                                       "skip": [...], "profile": "...",
+                                       "force": true}, all optional
+  GET    /api/runs                     the runs started in the background
+  GET    /api/runs/{id}                a run, with its exit code once ended
+  GET    /api/runs/{id}/events         its output as server-sent events
+  DELETE /api/runs/{id}                stop a run
+  GET    /api/history?project=&limit=  the run history, oldest first
+
+Runs are started as by 'bild start' with --output json and --quiet, so
+their events are those of 'bild run --output json', one per line; an "end"
+event closes the stream. Every request carries the token, as "Authorization: Bearer <token>"
+or as the token parameter, which EventSource needs. It is taken from --token
+or $BILD_SERVE_TOKEN, or else made up and printed at start.`,
+	Example: `  bild serve --listen 127.0.0.1:7171
+  curl -H "Authorization: Bearer $BILD_SERVE_TOKEN" -d '{"phases":["test"]}' localhost:7171/api/projects/api/runs`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listen, _ := cmd.Flags().GetString("listen")
+		cert, _ := cmd.Flags().GetString("cert")
+		key, _ := cmd.Flags().GetString("key")
+		if (cert == "") != (key == "") {
+			return errors.New("--cert and --key go together")
+		}
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv(ServeTokenEnv)
+		}
+		generated := token == ""
+		if generated {
+			var err error
+			if token, err = newServeToken(); err != nil {
+				return err
+			}
+		}
+
+		l, err := net.Listen("tcp", listen)
+		if err != nil {
+			return err
+		}
+		scheme := "http"
+		if cert != "" {
+			scheme = "https"
+		}
+		fmt.Println(t("serve.listening", scheme, l.Addr()))
+		if generated {
+			fmt.Println(t("serve.token", token))
+		}
+		if host, _, _ := net.SplitHostPort(listen); cert == "" && !isLoopback(host) {
+			fmt.Fprintln(os.Stderr, "Warning: the token goes unencrypted over the network; pass --cert and --key to serve HTTPS")
+		}
+
+		server := &http.Server{Handler: (&apiServer{token: token}).handler(), ReadHeaderTimeout: 10 * time.Second}
+		ctx, stop := bild.NotifyContext(cmd.Context())
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdown)
+		}()
+		if cert != "" {
+			err = server.ServeTLS(l, cert, key)
+		} else {
+			err = server.Serve(l)
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	},
+}
+
+// isLoopback reports whether host, as given to --listen, is only reachable
+// from this machine.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func init() {
+	serveCmd.Flags().String("listen", "127.0.0.1:7171", "Address to listen on")
+	serveCmd.Flags().String("token", "", "Token clients must present (default: $"+ServeTokenEnv+", or else a new one)")
+	serveCmd.Flags().String("cert", "", "TLS certificate file, to serve HTTPS")
+	serveCmd.Flags().String("key", "", "TLS key file, to serve HTTPS")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bild/pkg/bild"
+)
+
+func TestServeAPI(t *testing.T) {
+	t.Setenv(bild.ConfigDirEnv, t.TempDir())
+	server := httptest.NewServer((&apiServer{token: "s3cret"}).handler())
+	defer server.Close()
+
+	get := func(path, token string) *http.Response {
+		req, err := http.NewRequest("GET", server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	for _, token := range []string{"", "wrong"} {
+		if resp := get("/api/projects", token); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("token %q: status %d, want 401", token, resp.StatusCode)
+		}
+	}
+	if resp := get("/api/projects?token=s3cret", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("token as parameter: status %d", resp.StatusCode)
+	}
+	if resp := get("/api/projects/nope", "s3cret"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing project: status %d, want 404", resp.StatusCode)
+	}
+	resp := get("/api/runs", "s3cret")
+	var runs []bild.BackgroundRun
+	if err := json.NewDecoder(resp.Body).Decode(&runs); err != nil || runs == nil || len(runs) != 0 {
+		t.Errorf("runs = %v, %v; want an empty list", runs, err)
+	}
+}
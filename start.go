@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// stopDelay is how long bild stop waits for an interrupted run to wind down
+// before killing it.
+const stopDelay = 10 * time.Second
+
+// finishBackground returns the function recording how the run ended if
+// this process is a background run started by bild start, and keeps the
+// commands it runs from taking it for theirs.
+func finishBackground() func(exitCode int) {
+	path := os.Getenv(bild.BackgroundEnv)
+	if path == "" {
+		return func(int) {}
+	}
+	os.Unsetenv(bild.BackgroundEnv)
+	return func(exitCode int) {
+		// The record is read at the end, as bild start writes it once the
+		// run has started.
+		run, err := bild.LoadBackgroundRun(path)
+		if err == nil {
+			err = run.Finish(exitCode)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not record the end of the background run: %v\n", err)
+		}
+	}
+}
+
+// findBackgroundRun returns the background run named by arg: its ID, or a
+// project for its latest run. Without arg, it is the latest run still
+// running, or else the latest one.
+func findBackgroundRun(arg string) (*bild.BackgroundRun, error) {
+	dirs, err := getDirs()
+	if err != nil {
+		return nil, err
+	}
+	runs, err := bild.BackgroundRuns(dirs.BackgroundDir())
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, errors.New("no background runs; start one with bild start")
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if arg == "" && runs[i].Running() || arg != "" && runs[i].ID == arg {
+			return runs[i], nil
+		}
+	}
+	if arg == "" {
+		return runs[len(runs)-1], nil
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].Project == arg {
+			return runs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no background run %s", arg)
+}
+
+// backgroundState describes how a background run fares.
+func backgroundState(run *bild.BackgroundRun) string {
+	switch {
+	case run.Finished != nil:
+		return resultText(run.ExitCode == 0, run.ExitCode)
+	case run.Running():
+		return fmt.Sprintf("running (pid %d)", run.PID)
+	}
+	return "ended without a result"
+}
+
+// completeBackgroundRuns completes the IDs of background runs.
+func completeBackgroundRuns(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	dirs, err := getDirs()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	runs, _ := bild.BackgroundRuns(dirs.BackgroundDir())
+	var ids []string
+	for _, run := range runs {
+		ids = append(ids, run.ID+"\t"+run.Project)
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
+
+// startBackground starts bild with args as a background run of project, in
+// the current directory.
+func startBackground(project string, args []string) (*bild.BackgroundRun, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := getDirs()
+	if err != nil {
+		return nil, err
+	}
+	run, err := bild.NewBackgroundRun(dirs.BackgroundDir(), project, args)
+	if err != nil {
+		return nil, err
+	}
+	child := exec.Command(self, args...)
+	if child.Dir, err = os.Getwd(); err != nil {
+		return nil, err
+	}
+	if err := run.Start(child); err != nil {
+		run.Remove()
+		return nil, err
+	}
+	return run, nil
+}
+
+// startCmd starts a run in the background.
+var startCmd = &cobra.Command{
+	Use:   "start [project] [phase[,phase...]]",
+	Short: "Start a run in the background",
+	Long: `Starts 'bild run' with the same arguments and flags, detached from the
+terminal, and returns at once. The run keeps going when the terminal is
+closed, its output goes to a file, and it is recorded below the state
+directory: 'bild status --background' lists the runs started so, 'bild
+attach' shows the output of one and follows it, and 'bild stop' stops it.`,
+	Example: `  bild start api
+  bild start api test --skip lint
+  bild attach api`,
+	Args:              cobra.RangeArgs(0, 2),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tui, _ := cmd.Flags().GetBool("tui"); tui {
+			return errors.New("a run in the background has no terminal for --tui")
+		}
+		if tags, _ := cmd.Flags().GetStringSlice("tag"); len(tags) > 0 {
+			return errors.New("bild start runs a single project; --tag is not supported")
+		}
+		var projectName string
+		if len(args) > 0 {
+			projectName = args[0]
+		}
+		// Resolving the project first reports a wrong one here rather than
+		// in the output of the run.
+		out := infoOut
+		infoOut = io.Discard
+		proj, _, _, err := resolveRun(projectName)
+		infoOut = out
+		if err != nil {
+			return err
+		}
+
+		runArgs := append([]string{"run"}, args...)
+		cmd.Flags().Visit(func(f *pflag.Flag) {
+			if values, ok := f.Value.(pflag.SliceValue); ok {
+				for _, v := range values.GetSlice() {
+					runArgs = append(runArgs, "--"+f.Name+"="+v)
+				}
+				return
+			}
+			runArgs = append(runArgs, "--"+f.Name+"="+f.Value.String())
+		})
+		run, err := startBackground(proj.Name, runArgs)
+		if err != nil {
+			return err
+		}
+		fmt.Println(t("background.started", proj.Name, run.ID))
+		return nil
+	},
+}
+
+// attachCmd shows the output of a background run.
+var attachCmd = &cobra.Command{
+	Use:   "attach [run]",
+	Short: "Show the output of a background run and follow it",
+	Long: `Prints what a run started with 'bild start' has printed so far and follows
+its output until it ends. The run is given by its ID or its project, and is
+otherwise the latest one still running, or else the latest one. Ctrl-C
+detaches without stopping the run.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeBackgroundRuns,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var arg string
+		if len(args) == 1 {
+			arg = args[0]
+		}
+		run, err := findBackgroundRun(arg)
+		if err != nil {
+			return err
+		}
+		noFollow, _ := cmd.Flags().GetBool("no-follow")
+
+		f, err := os.Open(run.OutputPath())
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		for {
+			if _, err := io.Copy(os.Stdout, f); err != nil {
+				return err
+			}
+			if noFollow {
+				return nil
+			}
+			if !run.Running() {
+				// What it printed last came before it ended.
+				if _, err := io.Copy(os.Stdout, f); err != nil {
+					return err
+				}
+				break
+			}
+			select {
+			case <-ctx.Done():
+				fmt.Println()
+				fmt.Println(t("background.detached", run.ID))
+				return nil
+			case <-time.After(200 * time.Millisecond):
+			}
+			if latest, err := run.Reload(); err == nil {
+				run = latest
+			}
+		}
+		if run, err = run.Reload(); err != nil {
+			return err
+		}
+		fmt.Println(t("background.finished", run.ID, backgroundState(run)))
+		return nil
+	},
+}
+
+// stopCmd stops a background run.
+var stopCmd = &cobra.Command{
+	Use:   "stop [run]",
+	Short: "Stop a background run",
+	Long: `Interrupts a run started with 'bild start', as Ctrl-C would, so that its
+on_failure and always hooks run, and kills it if it is still running ten
+seconds later. The run is given as for 'bild attach'. --remove also deletes
+its record and output.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeBackgroundRuns,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var arg string
+		if len(args) == 1 {
+			arg = args[0]
+		}
+		run, err := findBackgroundRun(arg)
+		if err != nil {
+			return err
+		}
+		if run.Running() {
+			if err := run.Stop(stopDelay); err != nil {
+				return err
+			}
+			fmt.Println(t("background.stopped", run.ID))
+		} else {
+			fmt.Println(t("background.not_running", run.ID))
+		}
+		if remove, _ := cmd.Flags().GetBool("remove"); remove {
+			if err := run.Remove(); err != nil {
+				return err
+			}
+			fmt.Println(t("background.removed", run.ID))
+		}
+		return nil
+	},
+}
+
+// listBackgroundRuns prints the background runs, those of project only if
+// it is set.
+func listBackgroundRuns(project string) error {
+	dirs, err := getDirs()
+	if err != nil {
+		return err
+	}
+	runs, err := bild.BackgroundRuns(dirs.BackgroundDir())
+	if err != nil {
+		return err
+	}
+	var shown int
+	for _, run := range runs {
+		if project != "" && run.Project != project {
+			continue
+		}
+		if shown == 0 {
+			fmt.Printf("%-32s %-20s %-19s %s\n", "ID", "PROJECT", "STARTED", "STATE")
+		}
+		fmt.Printf("%-32s %-20s %-19s %s\n", run.ID, run.Project, run.Started.Local().Format("2006-01-02 15:04:05"), backgroundState(run))
+		shown++
+	}
+	if shown == 0 {
+		fmt.Println(t("background.none"))
+	}
+	return nil
+}
+
+func init() {
+	// bild start takes the flags of bild run, defined in main.go.
+	startCmd.Flags().AddFlagSet(runCmd.Flags())
+	rootCmd.AddCommand(startCmd)
+	attachCmd.Flags().Bool("no-follow", false, "Print the output so far and return")
+	rootCmd.AddCommand(attachCmd)
+	stopCmd.Flags().Bool("remove", false, "Also delete the run's record and output")
+	rootCmd.AddCommand(stopCmd)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// stateCmd groups the commands inspecting what bild remembers per project.
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Inspect or clear what bild remembers about projects",
+	Long: `Besides the config and run history, bild keeps per-project state between
+runs, such as markers of steps that only run once and captured variables. Each
+project has its own directory below the state directory (~/.local/state/bild
+on Linux; see 'bild storage paths'), named after the project with a short hash
+appended.`,
+}
+
+var stateShowCmd = &cobra.Command{
+	Use:               "show [project]",
+	Short:             "Show the state kept for a project, or for all projects",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		states, err := selectStates(args)
+		if err != nil {
+			return err
+		}
+		if len(states) == 0 {
+			fmt.Println(t("state.none"))
+			return nil
+		}
+		for i, state := range states {
+			if i > 0 {
+				fmt.Println()
+			}
+			if err := showState(state); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var stateClearCmd = &cobra.Command{
+	Use:               "clear [project]",
+	Short:             "Forget the state kept for a project",
+	Long:              "Removes the state directory of the project, or of every project with --all. Projects with a bild process updating their state are left alone.",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		if all == (len(args) == 1) {
+			return errors.New("give either a project or --all")
+		}
+		states, err := selectStates(args)
+		if err != nil {
+			return err
+		}
+		var failed error
+		for _, state := range states {
+			if err := state.Clear(); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", state.Project, err)
+				failed = err
+				continue
+			}
+			fmt.Println(t("state.cleared", state.Project))
+		}
+		return failed
+	},
+}
+
+// selectStates returns the state of the project named in args, or of every
+// project with state if args is empty.
+func selectStates(args []string) ([]*bild.ProjectState, error) {
+	dirs, err := getDirs()
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return dirs.ProjectStates()
+	}
+	state := dirs.ProjectState(args[0])
+	if !state.Exists() {
+		return nil, nil
+	}
+	return []*bild.ProjectState{state}, nil
+}
+
+// showState prints the files kept for one project.
+func showState(state *bild.ProjectState) error {
+	lock := "free"
+	if unlock, err := state.TryLock(); err == nil {
+		unlock()
+	} else if errors.Is(err, bild.ErrStateLocked) {
+		lock = "held"
+	}
+	fmt.Printf("%s  %s  (lock %s)\n", state.Project, state.Dir, lock)
+
+	return filepath.WalkDir(state.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(state.Dir, path)
+		if rel == "." || rel == "lock" || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			fmt.Printf("  %s/\n", rel)
+			return nil
+		}
+		fmt.Printf("  %-30s %8d B  %s\n", rel, info.Size(), info.ModTime().Local().Format("2006-01-02 15:04:05"))
+		return nil
+	})
+}
+
+func init() {
+	stateClearCmd.Flags().Bool("all", false, "Clear the state of every project")
+	stateCmd.AddCommand(stateShowCmd)
+	stateCmd.AddCommand(stateClearCmd)
+	rootCmd.AddCommand(stateCmd)
+}
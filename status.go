@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// statusCmd shows how each phase of a project last fared and what changed
+// since it last passed.
+var statusCmd = &cobra.Command{
+	Use:   "status [project]",
+	Short: "Show the last result of each phase and what changed since it passed",
+	Long: `Shows, for each phase of the project (default: the one named after the git
+repository), the outcome of its last run and the commit it last passed at.
+With --changes, the commits made since then are listed as well, answering
+"what changed since the last green build?". With --background, the runs
+started with 'bild start' are listed instead, those of the project if one
+is given.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		changes, _ := cmd.Flags().GetBool("changes")
+		if background, _ := cmd.Flags().GetBool("background"); background {
+			var project string
+			if len(args) == 1 {
+				project = args[0]
+			}
+			return listBackgroundRuns(project)
+		}
+		var projectName string
+		var err error
+		if len(args) == 1 {
+			projectName = args[0]
+		} else if projectName, err = repoProjectName(); err != nil {
+			return errors.New(t("err.no_project_name"))
+		}
+		proj, err := findProject(projectName)
+		if err != nil {
+			return err
+		}
+		dirs, err := getDirs()
+		if err != nil {
+			return err
+		}
+		lastGreen, err := dirs.ProjectState(proj.Name).LastGreen()
+		if err != nil {
+			return err
+		}
+		entries, err := loadHistory(proj.Name)
+		if err != nil {
+			return err
+		}
+		lastRun := make(map[string]bild.HistoryEntry)
+		for _, e := range entries {
+			if !e.AdHoc() {
+				lastRun[e.Phase] = e
+			}
+		}
+
+		root, err := bild.RepoRoot("")
+		if err != nil {
+			if changes {
+				return errors.New("--changes needs a git repository")
+			}
+			root = ""
+		}
+		var head string
+		if root != "" {
+			head, _ = bild.HeadCommit(root)
+		}
+
+		fmt.Printf("%-12s %-19s %-10s %-24s %s\n", "PHASE", "LAST RUN", "GREEN AT", "SINCE GREEN", "RESULT")
+		for _, ph := range proj.Phases {
+			run, ran := lastRun[ph.Name]
+			green, passed := lastGreen[ph.Name]
+
+			when, result := "-", "never run"
+			if ran {
+				when = run.Start.Local().Format("2006-01-02 15:04:05")
+				result = resultText(run.Succeeded(), run.ExitCode)
+			}
+			greenAt, since := "-", "never passed"
+			var log []string
+			if passed {
+				greenAt = shortCommit(green.Commit)
+				since = "just now"
+				if age := time.Since(green.Time); age >= time.Minute {
+					since = fmt.Sprintf("%s ago", age.Round(time.Minute))
+				}
+				if head != "" {
+					if log, err = bild.Log(root, green.Commit, head); err != nil {
+						since = "commit not found"
+					} else {
+						since = fmt.Sprintf("%d commits, %s", len(log), since)
+					}
+				}
+			}
+			fmt.Printf("%-12s %-19s %-10s %-24s %s\n", ph.Name, when, greenAt, since, result)
+			if changes {
+				for _, line := range log {
+					fmt.Printf("    %s\n", line)
+				}
+			}
+		}
+		if runs, err := bild.BackgroundRuns(dirs.BackgroundDir()); err == nil {
+			for _, run := range runs {
+				if run.Project == proj.Name && run.Running() {
+					fmt.Println()
+					fmt.Println(t("background.running", proj.Name, run.ID))
+				}
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	statusCmd.Flags().Bool("changes", false, "List the commits made since each phase last passed")
+	statusCmd.Flags().BoolP("background", "b", false, "List the runs started in the background with bild start")
+	rootCmd.AddCommand(statusCmd)
+}
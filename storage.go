@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// storageCmd groups the commands dealing with storage backends.
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage where config and run history are stored",
+	Long: `bild keeps its global config and run history either in JSON files (the
+default) or in a SQLite database, which scales to large histories and to
+several processes writing at once. The backend is selected with --storage or
+$` + bild.StorageEnv + `. The memory backend starts from the JSON files and
+drops every change as bild exits, to try commands out.`,
+}
+
+var storageMigrateCmd = &cobra.Command{
+	Use:       "migrate <backend>",
+	Short:     "Copy config and run history into another backend",
+	Long:      "Copies the config and run history of the current backend into the given one, which must be empty. The current backend is left untouched.",
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{bild.StorageJSON, bild.StorageSQLite},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if args[0] == storageBackend() {
+			return fmt.Errorf("already using the %s backend", args[0])
+		}
+		dirs, err := getDirs()
+		if err != nil {
+			return err
+		}
+		from, err := getStorage()
+		if err != nil {
+			return err
+		}
+		defer from.Close()
+		to, err := bild.OpenStorage(args[0], dirs)
+		if err != nil {
+			return err
+		}
+		defer to.Close()
+
+		existing, err := to.Load()
+		if err != nil {
+			return err
+		}
+		existingHistory, err := to.History("")
+		if err != nil {
+			return err
+		}
+		if len(existing.Projects) > 0 || len(existingHistory) > 0 {
+			return fmt.Errorf("the %s backend already holds data", args[0])
+		}
+
+		config, err := from.Load()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		entries, err := from.History("")
+		if err != nil {
+			return err
+		}
+		if err := to.Save(config); err != nil {
+			return fmt.Errorf(t("err.save_config"), err)
+		}
+		for _, e := range entries {
+			if err := to.AppendHistory(e); err != nil {
+				return err
+			}
+		}
+		fmt.Println(t("storage.migrated", len(config.Projects), len(entries), args[0]))
+		return nil
+	},
+}
+
+var storagePathsCmd = &cobra.Command{
+	Use:   "paths",
+	Short: "Show where bild keeps its files",
+	Long: `Shows the directories bild uses. They follow $XDG_CONFIG_HOME, $XDG_DATA_HOME,
+$XDG_STATE_HOME and $XDG_CACHE_HOME, default to the conventions of the platform,
+and can all be moved below one directory with --config-dir or $` + bild.ConfigDirEnv + `.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dirs, err := getDirs()
+		if err != nil {
+			return err
+		}
+		path, err := getConfigFilePath()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-9s %s\n", "config", path)
+		fmt.Printf("%-9s %s\n", "overrides", filepath.Join(filepath.Dir(path), bild.OverridesName))
+		fmt.Printf("%-9s %s\n", "data", dirs.Data)
+		fmt.Printf("%-9s %s\n", "state", dirs.State)
+		fmt.Printf("%-9s %s\n", "cache", dirs.Cache)
+		return nil
+	},
+}
+
+func init() {
+	storageCmd.AddCommand(storageMigrateCmd)
+	storageCmd.AddCommand(storagePathsCmd)
+	rootCmd.AddCommand(storageCmd)
+}
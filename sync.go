@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// loadSync returns the sync of the global config, which must be set up.
+func loadSync() (*bild.Sync, error) {
+	dirs, err := getDirs()
+	if err != nil {
+		return nil, err
+	}
+	s := dirs.Sync()
+	if err := s.Load(); err != nil {
+		if errors.Is(err, bild.ErrNoSync) {
+			return nil, errors.New(t("err.no_sync"))
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// syncConflict explains err if it is a conflict between the two sides of a
+// sync.
+func syncConflict(err error) error {
+	if errors.Is(err, bild.ErrSyncConflict) {
+		return fmt.Errorf(t("err.sync_conflict"), err)
+	}
+	return err
+}
+
+// syncCmd groups the commands keeping the global config in a git repository.
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Share the global config across machines through a git repository or gist",
+	Long: `Keeps the global config in a git repository, such as a private GitHub gist,
+so that it follows you across machines: set it up with 'bild sync init' on
+each machine, then 'bild sync push' after changing the config and 'bild sync
+pull' to take the changes made elsewhere. git reaches the repository with
+your credentials, so ssh keys or a credential helper must be set up.
+
+bild remembers what it last pushed or pulled. If the config was changed
+both here and in the repository since, push and pull refuse to overwrite
+either side; --force then keeps the side you are syncing from. Files the
+config includes and the machine's overrides are not synced.`,
+}
+
+var syncInitCmd = &cobra.Command{
+	Use:   "init <repository>",
+	Short: "Set up the git repository or gist to sync the config with",
+	Long: `Sets up syncing with the repository: a git URL, or a gist given as
+gist:<id> or by the URL of its page. Nothing is synced until the first push
+or pull.`,
+	Example: `  bild sync init git@github.com:me/dotfiles-bild.git
+  bild sync init gist:4f2c9e1a7b`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dirs, err := getDirs()
+		if err != nil {
+			return err
+		}
+		s := dirs.Sync()
+		if err := s.Init(args[0]); err != nil {
+			return err
+		}
+		fmt.Println(t("sync.init", s.State.Remote))
+		return nil
+	},
+}
+
+var syncPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Push the global config to the sync repository",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := loadSync()
+		if err != nil {
+			return err
+		}
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		force, _ := cmd.Flags().GetBool("force")
+		pushed, err := s.Push(config, force)
+		if err != nil {
+			return syncConflict(err)
+		}
+		if !pushed {
+			fmt.Println(t("sync.up_to_date"))
+			return nil
+		}
+		fmt.Println(t("sync.pushed", s.State.Remote))
+		return nil
+	},
+}
+
+var syncPullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Replace the global config with the one in the sync repository",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := loadSync()
+		if err != nil {
+			return err
+		}
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		force, _ := cmd.Flags().GetBool("force")
+		pulled, err := s.Pull(config, force, func(pulled *bild.Config) error {
+			if err := saveConfig(pulled); err != nil {
+				return fmt.Errorf(t("err.save_config"), err)
+			}
+			return nil
+		})
+		if err != nil {
+			return syncConflict(err)
+		}
+		if !pulled {
+			fmt.Println(t("sync.up_to_date"))
+			return nil
+		}
+		fmt.Println(t("sync.pulled", s.State.Remote))
+		return nil
+	},
+}
+
+var syncStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the config changed here or in the sync repository",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := loadSync()
+		if err != nil {
+			return err
+		}
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+		status, err := s.Status(config)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-10s %s\n", "remote", s.State.Remote)
+		synced := "never"
+		if !s.State.Synced.IsZero() {
+			synced = s.State.Synced.Local().Format(time.DateTime)
+		}
+		fmt.Printf("%-10s %s\n", "synced", synced)
+		switch {
+		case status.Local && status.Remote:
+			fmt.Println(t("sync.status_both"))
+		case status.Local:
+			fmt.Println(t("sync.status_local"))
+		case status.Remote:
+			fmt.Println(t("sync.status_remote"))
+		default:
+			fmt.Println(t("sync.up_to_date"))
+		}
+		return nil
+	},
+}
+
+func init() {
+	syncPushCmd.Flags().Bool("force", false, "Push even if the config in the repository changed since the last sync, replacing it")
+	syncPullCmd.Flags().Bool("force", false, "Pull even if the config here changed since the last sync, replacing it")
+	syncCmd.AddCommand(syncInitCmd, syncPushCmd, syncPullCmd, syncStatusCmd)
+	rootCmd.AddCommand(syncCmd)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"bild/pkg/bild"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+// recordLink records project name of config as in sync with the project of
+// the same name in the local config in dir.
+func recordLink(config *bild.Config, name, dir string) error {
+	global, err := config.SharedProject(name)
+	if err != nil {
+		return err
+	}
+	local, err := bild.LinkedProject(dir, name)
+	if err != nil {
+		return err
+	}
+	dirs, err := getDirs()
+	if err != nil {
+		return err
+	}
+	return dirs.ProjectState(name).SetLink(&bild.LocalLink{
+		Dir:    dir,
+		Global: bild.ProjectSum(global),
+		Local:  bild.ProjectSum(local),
+		Synced: time.Now(),
+	})
+}
+
+// linkedProjects returns the names of the projects linked to a local
+// config.
+func linkedProjects() ([]string, error) {
+	dirs, err := getDirs()
+	if err != nil {
+		return nil, err
+	}
+	states, err := dirs.ProjectStates()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, state := range states {
+		if link, err := state.Link(); err == nil && link != nil {
+			names = append(names, state.Project)
+		}
+	}
+	return names, nil
+}
+
+// askApply asks whether to apply the changes shown and reports the answer;
+// an empty answer does not.
+func askApply() (bool, error) {
+	in := bufio.NewReader(os.Stdin)
+	fmt.Print(t("synclocal.ask"))
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes", "j", "ja":
+		return true, nil
+	}
+	return false, nil
+}
+
+// syncLocalCmd keeps linked projects of the global config and their local
+// configs in sync.
+var syncLocalCmd = &cobra.Command{
+	Use:   "sync-local [project...]",
+	Short: "Sync projects linked by bild dump --link with their local .bild.json",
+	Long: `Compares projects dumped with 'bild dump --link', by default all of them,
+with their local config in the repository, and carries the changes made on
+one side since the last sync over to the other: a changed .bild.json, say
+after a git pull, into the global config, or a project changed in the global
+config into the .bild.json. The differences are shown before anything is
+written, and applied once you agree.
+
+If both sides changed, nothing is written unless --to picks the side to
+overwrite. --unlink stops keeping the projects in sync.`,
+	Example: `  bild dump api --link
+  bild sync-local --dry-run
+  bild sync-local api --to local`,
+	ValidArgsFunction: completeProjects,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to, _ := cmd.Flags().GetString("to")
+		if to != "" && to != "global" && to != "local" {
+			return fmt.Errorf("unknown side %q (want global or local)", to)
+		}
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		yes, _ := cmd.Flags().GetBool("yes")
+		unlink, _ := cmd.Flags().GetBool("unlink")
+
+		names := args
+		if len(names) == 0 {
+			var err error
+			if names, err = linkedProjects(); err != nil {
+				return err
+			}
+			if len(names) == 0 {
+				fmt.Println(t("synclocal.none"))
+				return nil
+			}
+		}
+		dirs, err := getDirs()
+		if err != nil {
+			return err
+		}
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf(t("err.load_config"), err)
+		}
+
+		var conflicts []string
+		for _, name := range names {
+			state := dirs.ProjectState(name)
+			link, err := state.Link()
+			if err != nil {
+				return err
+			}
+			if link == nil {
+				return fmt.Errorf("project %s is not linked to a local config; link it with bild dump %s --link", name, name)
+			}
+			if unlink {
+				if err := state.Unlink(); err != nil {
+					return err
+				}
+				fmt.Println(t("synclocal.unlinked", name))
+				continue
+			}
+			global, err := config.SharedProject(name)
+			if err != nil {
+				return err
+			}
+			local, err := bild.LinkedProject(link.Dir, name)
+			if err != nil {
+				return err
+			}
+			diffs := bild.CompareProjects(name, global, local)
+			if len(diffs) == 0 {
+				if !dryRun {
+					if err := recordLink(config, name, link.Dir); err != nil {
+						return err
+					}
+				}
+				fmt.Println(t("synclocal.in_sync", name))
+				continue
+			}
+
+			side := to
+			if side == "" {
+				switch globalChanged, localChanged := link.Changed(global, local); {
+				case globalChanged && !localChanged:
+					side = "local"
+				case localChanged && !globalChanged:
+					side = "global"
+				default:
+					conflicts = append(conflicts, name)
+				}
+			}
+			localPath := bild.LocalConfigPath(link.Dir)
+			if side == "local" {
+				fmt.Println(t("synclocal.to_local", name, localPath))
+			} else if side == "global" {
+				fmt.Println(t("synclocal.to_global", localPath, name))
+			} else {
+				fmt.Println(t("synclocal.both", name, localPath))
+			}
+			for _, d := range diffs {
+				where := d.Setting
+				if d.Phase != "" {
+					where = "phase " + d.Phase
+					if d.Setting != "" {
+						where += ": " + d.Setting
+					}
+				}
+				if where == "" {
+					where = "project"
+				}
+				fmt.Printf("  %s\n", where)
+				fmt.Printf("    %-7s %s\n", "global:", orMissing(d.Mine))
+				fmt.Printf("    %-7s %s\n", "local:", orMissing(d.Theirs))
+			}
+			if side == "" || dryRun {
+				continue
+			}
+			if !yes {
+				if !isatty.IsTerminal(os.Stdin.Fd()) {
+					return errors.New("not asking without a terminal; apply the changes with --yes")
+				}
+				ok, err := askApply()
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Println(t("adopt.canceled"))
+					continue
+				}
+			}
+
+			if side == "local" {
+				format := bild.ConfigFormat(localPath)
+				if _, err := bild.WriteLocalConfigAs(link.Dir, name, *global, format); err != nil {
+					return err
+				}
+			} else {
+				// The project keeps where it is checked out on this machine.
+				local.Path = config.Projects[name].Path
+				config.Projects[name] = *local
+				if err := saveConfig(config); err != nil {
+					return fmt.Errorf(t("err.save_config"), err)
+				}
+			}
+			if err := recordLink(config, name, link.Dir); err != nil {
+				return err
+			}
+			fmt.Println(t("synclocal.synced", name))
+		}
+		if len(conflicts) > 0 {
+			return fmt.Errorf("%s changed both in the global and the local config since the last sync; choose the side to overwrite with --to global or --to local", strings.Join(conflicts, ", "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	syncLocalCmd.Flags().String("to", "", "Overwrite this side, global or local, even if it changed too (default: the side that did not change since the last sync)")
+	syncLocalCmd.Flags().Bool("dry-run", false, "Only show the differences")
+	syncLocalCmd.Flags().BoolP("yes", "y", false, "Apply the changes without asking")
+	syncLocalCmd.Flags().Bool("unlink", false, "Stop keeping the projects in sync")
+	syncLocalCmd.RegisterFlagCompletionFunc("to", cobra.FixedCompletions([]string{"global", "local"}, cobra.ShellCompDirectiveNoFileComp))
+	syncLocalCmd.MarkFlagsMutuallyExclusive("unlink", "to")
+	rootCmd.AddCommand(syncLocalCmd)
+}
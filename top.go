@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// procSample is a point-in-time reading of one process's resource usage.
+type procSample struct {
+	PID     int
+	PPID    int
+	Ticks   uint64 // user+system CPU time in clock ticks
+	RSS     uint64 // resident set size in bytes
+	Command string
+}
+
+// activeRunObserver registers each running phase as an active run so that
+// `bild top` can find its processes. Phases running at once, such as the
+// variants of a parallel matrix, each have a record of their own.
+type activeRunObserver struct {
+	project    string
+	unregister map[string]func() // by phase name
+}
+
+func (o *activeRunObserver) PhaseStarted(phase *bild.Phase) {}
+
+func (o *activeRunObserver) ProcessStarted(phase *bild.Phase, pid int) {
+	dirs, err := getDirs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record active run: %v\n", err)
+		return
+	}
+	unregister, err := bild.RegisterActiveRun(dirs.State, bild.ActiveRun{
+		Project: o.project,
+		Phase:   phase.Name,
+		PID:     pid,
+		BildPID: os.Getpid(),
+		Started: time.Now(),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record active run: %v\n", err)
+		return
+	}
+	if o.unregister == nil {
+		o.unregister = make(map[string]func())
+	}
+	if previous := o.unregister[phase.Name]; previous != nil {
+		previous()
+	}
+	o.unregister[phase.Name] = unregister
+}
+
+func (o *activeRunObserver) PhaseFinished(phase *bild.Phase, err error, elapsed time.Duration) {
+	if unregister := o.unregister[phase.Name]; unregister != nil {
+		unregister()
+		delete(o.unregister, phase.Name)
+	}
+}
+
+// descendants returns the samples of root and all of its descendant processes.
+func descendants(root int, samples map[int]procSample) []procSample {
+	children := make(map[int][]int)
+	for pid, s := range samples {
+		children[s.PPID] = append(children[s.PPID], pid)
+	}
+
+	var result []procSample
+	queue := []int{root}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		if s, ok := samples[pid]; ok {
+			result = append(result, s)
+		}
+		queue = append(queue, children[pid]...)
+	}
+	return result
+}
+
+// formatBytes renders a byte count using binary units.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// renderTop prints the resource usage of every active run, comparing the
+// current samples against the previous ones taken elapsed ago.
+func renderTop(runs []bild.ActiveRun, prev, cur map[int]procSample, elapsed time.Duration, sortBy string) {
+	if len(runs) == 0 {
+		fmt.Println(t("top.none"))
+		return
+	}
+
+	for _, run := range runs {
+		procs := descendants(run.PID, cur)
+		cpu := make(map[int]float64, len(procs))
+		var totalCPU float64
+		var totalRSS uint64
+		for _, p := range procs {
+			if before, ok := prev[p.PID]; ok && p.Ticks >= before.Ticks && elapsed > 0 {
+				cpu[p.PID] = float64(p.Ticks-before.Ticks) / clockTicks / elapsed.Seconds() * 100
+			}
+			totalCPU += cpu[p.PID]
+			totalRSS += p.RSS
+		}
+
+		sort.Slice(procs, func(i, j int) bool {
+			if sortBy == "mem" {
+				return procs[i].RSS > procs[j].RSS
+			}
+			return cpu[procs[i].PID] > cpu[procs[j].PID]
+		})
+
+		fmt.Println()
+		fmt.Println(tn("top.run", len(procs), run.Project, run.Phase, time.Since(run.Started).Round(time.Second),
+			len(procs), totalCPU, formatBytes(totalRSS)))
+		fmt.Printf("  %7s %6s %9s  %s\n", "PID", "CPU%", "MEM", "COMMAND")
+		for _, p := range procs {
+			command := p.Command
+			if len(command) > 100 {
+				command = command[:97] + "..."
+			}
+			fmt.Printf("  %7d %6.1f %9s  %s\n", p.PID, cpu[p.PID], formatBytes(p.RSS), command)
+		}
+	}
+}
+
+// topCmd shows a live view of the processes spawned by active bild runs.
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Show live CPU and memory usage of running builds",
+	Long:  "Displays per-process CPU and memory usage of every phase currently being run by bild, refreshing periodically, to quickly spot which step is hogging the machine.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		interval, _ := cmd.Flags().GetDuration("interval")
+		once, _ := cmd.Flags().GetBool("once")
+		sortBy, _ := cmd.Flags().GetString("sort")
+		if sortBy != "cpu" && sortBy != "mem" {
+			return fmt.Errorf("invalid --sort value %q (expected cpu or mem)", sortBy)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+
+		dirs, err := getDirs()
+		if err != nil {
+			return err
+		}
+		prev, err := sampleProcesses()
+		if err != nil {
+			return err
+		}
+		for {
+			select {
+			case <-cmd.Context().Done():
+				return nil
+			case <-time.After(interval):
+			}
+
+			cur, err := sampleProcesses()
+			if err != nil {
+				return err
+			}
+			runs, err := bild.ActiveRuns(dirs.State)
+			if err != nil {
+				return err
+			}
+
+			if !once {
+				fmt.Print("\033[H\033[2J")
+				fmt.Printf("bild top — %s (refresh %s, Ctrl-C to quit)\n", time.Now().Format("15:04:05"), interval)
+			}
+			renderTop(runs, prev, cur, interval, sortBy)
+			if once {
+				return nil
+			}
+			prev = cur
+		}
+	},
+}
+
+func init() {
+	topCmd.Flags().Duration("interval", 2*time.Second, "Refresh interval")
+	topCmd.Flags().Bool("once", false, "Print a single sample and exit")
+	topCmd.Flags().String("sort", "cpu", "Sort processes by cpu or mem")
+	topCmd.RegisterFlagCompletionFunc("sort", cobra.FixedCompletions([]string{"cpu", "mem"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.AddCommand(topCmd)
+}
@@ -0,0 +1,70 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// clockTicks is the kernel's USER_HZ, which is 100 on all mainstream Linux platforms.
+const clockTicks = 100
+
+// sampleProcesses reads the resource usage of every visible process from /proc.
+func sampleProcesses() (map[int]procSample, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	samples := make(map[int]procSample)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join("/proc", entry.Name())
+		stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+		if err != nil {
+			continue // the process exited in the meantime
+		}
+
+		// The command name is parenthesised and may contain spaces, so parse
+		// the remaining fields from after the last closing parenthesis.
+		end := strings.LastIndexByte(string(stat), ')')
+		if end < 0 {
+			continue
+		}
+		name := string(stat[strings.IndexByte(string(stat), '(')+1 : end])
+		fields := strings.Fields(string(stat[end+1:]))
+		if len(fields) < 22 {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		utime, _ := strconv.ParseUint(fields[11], 10, 64)
+		stime, _ := strconv.ParseUint(fields[12], 10, 64)
+		rss, _ := strconv.ParseUint(fields[21], 10, 64)
+
+		command := name
+		if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(cmdline) > 0 {
+			command = trimCommand(cmdline)
+		}
+
+		samples[pid] = procSample{
+			PID:     pid,
+			PPID:    ppid,
+			Ticks:   utime + stime,
+			RSS:     rss * pageSize,
+			Command: command,
+		}
+	}
+	return samples, nil
+}
+
+// trimCommand collapses a NUL-separated command line into a single line.
+func trimCommand(cmdline []byte) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(string(cmdline), "\x00", " ")), " ")
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+const clockTicks = 100
+
+// sampleProcesses is only implemented on Linux, where /proc is available.
+func sampleProcesses() (map[int]procSample, error) {
+	return nil, errors.New("bild top is only supported on Linux")
+}
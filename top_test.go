@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"bild/pkg/bild"
+)
+
+// Projects run at once by run-all --parallel each have an observer of their
+// own, in the same bild process.
+func TestActiveRunObserversInOneProcess(t *testing.T) {
+	t.Setenv(bild.ConfigDirEnv, t.TempDir())
+	api, web := &activeRunObserver{project: "api"}, &activeRunObserver{project: "web"}
+	build := &bild.Phase{Name: "build"}
+	api.ProcessStarted(build, 101)
+	web.ProcessStarted(build, 102)
+
+	dirs, err := getDirs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs, err := bild.ActiveRuns(dirs.State); err != nil || len(runs) != 2 {
+		t.Fatalf("runs = %+v, %v; want both projects", runs, err)
+	}
+	api.PhaseFinished(build, nil, 0)
+	runs, err := bild.ActiveRuns(dirs.State)
+	if err != nil || len(runs) != 1 || runs[0].Project != "web" || runs[0].BildPID != os.Getpid() {
+		t.Errorf("runs = %+v, %v; want web still running", runs, err)
+	}
+}
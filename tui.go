@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"bild/pkg/bild"
+	_ "bild/pkg/termquery" // initialized before bubbletea, see the package
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// phaseStatus is the lifecycle state of a phase shown in the dashboard.
+type phaseStatus int
+
+const (
+	phasePending phaseStatus = iota
+	phaseRunning
+	phasePassed
+	phaseFailed
+	phaseSkipped
+)
+
+var (
+	tuiTitleStyle   = lipgloss.NewStyle().Bold(true)
+	tuiDimStyle     = lipgloss.NewStyle().Faint(true)
+	tuiRunningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	tuiPassedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	tuiFailedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+)
+
+func (s phaseStatus) String() string {
+	switch s {
+	case phaseRunning:
+		return tuiRunningStyle.Render("● running")
+	case phasePassed:
+		return tuiPassedStyle.Render("✔ passed ")
+	case phaseFailed:
+		return tuiFailedStyle.Render("✘ failed ")
+	case phaseSkipped:
+		return tuiDimStyle.Render("– skipped")
+	default:
+		return tuiDimStyle.Render("○ pending")
+	}
+}
+
+// tuiPhase is one row of the dashboard together with the phase's captured output.
+type tuiPhase struct {
+	name    string
+	status  phaseStatus
+	start   time.Time
+	elapsed time.Duration
+	lines   []string
+	partial string // output after the last newline
+}
+
+// append adds a chunk of output, treating carriage returns as line rewrites.
+func (p *tuiPhase) append(data string) {
+	for _, r := range data {
+		switch r {
+		case '\n':
+			p.lines = append(p.lines, p.partial)
+			p.partial = ""
+		case '\r':
+			p.partial = ""
+		default:
+			p.partial += string(r)
+		}
+	}
+}
+
+// output returns all lines of the phase, including an unterminated last line.
+func (p *tuiPhase) output() []string {
+	if p.partial == "" {
+		return p.lines
+	}
+	return append(p.lines[:len(p.lines):len(p.lines)], p.partial)
+}
+
+// Messages sent from the run to the dashboard.
+type (
+	tuiPhaseStartedMsg  struct{ name string }
+	tuiPhaseSkippedMsg  struct{ name string }
+	tuiPhaseFinishedMsg struct {
+		name    string
+		err     error
+		elapsed time.Duration
+	}
+	tuiOutputMsg struct {
+		name string
+		data string
+	}
+	tuiRunDoneMsg struct{ err error }
+	tuiTickMsg    time.Time
+)
+
+// tuiModel is the bubbletea model of the dashboard.
+type tuiModel struct {
+	project  string
+	phases   []*tuiPhase
+	selected int
+	follow   bool // select the running phase automatically
+	scroll   int  // number of log lines scrolled up from the bottom
+	width    int
+	height   int
+	start    time.Time
+	done     bool
+	quitting bool
+	err      error
+	cancel   context.CancelFunc
+}
+
+func (m *tuiModel) phase(name string) (int, *tuiPhase) {
+	for i, p := range m.phases {
+		if p.name == name {
+			return i, p
+		}
+	}
+	return -1, nil
+}
+
+func tuiTick() tea.Cmd {
+	return tea.Tick(200*time.Millisecond, func(t time.Time) tea.Msg { return tuiTickMsg(t) })
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return tuiTick()
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case tuiTickMsg:
+		if m.done {
+			return m, nil
+		}
+		return m, tuiTick()
+	case tuiPhaseStartedMsg:
+		if i, p := m.phase(msg.name); p != nil {
+			p.status = phaseRunning
+			p.start = time.Now()
+			if m.follow {
+				m.selected, m.scroll = i, 0
+			}
+		}
+	case tuiPhaseFinishedMsg:
+		if _, p := m.phase(msg.name); p != nil {
+			p.elapsed = msg.elapsed
+			p.status = phasePassed
+			if msg.err != nil {
+				p.status = phaseFailed
+			}
+		}
+	case tuiPhaseSkippedMsg:
+		if _, p := m.phase(msg.name); p != nil {
+			p.status = phaseSkipped
+		}
+	case tuiOutputMsg:
+		if _, p := m.phase(msg.name); p != nil {
+			p.append(msg.data)
+		}
+	case tuiRunDoneMsg:
+		m.done, m.err = true, msg.err
+		if m.quitting {
+			return m, tea.Quit
+		}
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	}
+	return m, nil
+}
+
+func (m *tuiModel) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	page := m.logHeight() / 2
+	switch msg.String() {
+	case "q", "ctrl+c":
+		if m.done {
+			return m, tea.Quit
+		}
+		// Stop the run; the dashboard closes once the runner has returned.
+		m.quitting = true
+		m.cancel()
+	case "up", "k":
+		if m.selected > 0 {
+			m.selected, m.scroll, m.follow = m.selected-1, 0, false
+		}
+	case "down", "j":
+		if m.selected < len(m.phases)-1 {
+			m.selected, m.scroll, m.follow = m.selected+1, 0, false
+		}
+	case "f":
+		m.follow = true
+		for i, p := range m.phases {
+			if p.status == phaseRunning {
+				m.selected, m.scroll = i, 0
+			}
+		}
+	case "pgup", "ctrl+u":
+		m.scroll += page
+	case "pgdown", "ctrl+d":
+		m.scroll -= page
+	case "g", "home":
+		m.scroll = len(m.phases[m.selected].output())
+	case "G", "end":
+		m.scroll = 0
+	}
+	if m.scroll < 0 {
+		m.scroll = 0
+	}
+	return m, nil
+}
+
+// logHeight returns the number of lines available to the log pane.
+func (m *tuiModel) logHeight() int {
+	h := m.height - len(m.phases) - 4
+	if h < 3 {
+		h = 3
+	}
+	return h
+}
+
+func (m *tuiModel) View() string {
+	var b strings.Builder
+
+	elapsed := time.Since(m.start).Round(time.Second)
+	b.WriteString(tuiTitleStyle.Render("bild › "+m.project) + tuiDimStyle.Render(fmt.Sprintf("  %s", elapsed)) + "\n")
+
+	for i, p := range m.phases {
+		cursor := "  "
+		if i == m.selected {
+			cursor = "▸ "
+		}
+		var took string
+		switch p.status {
+		case phaseRunning:
+			took = time.Since(p.start).Round(100 * time.Millisecond).String()
+		case phasePassed, phaseFailed:
+			took = p.elapsed.Round(100 * time.Millisecond).String()
+		}
+		fmt.Fprintf(&b, "%s%s  %-20s %s\n", cursor, p.status, p.name, took)
+	}
+
+	// Log pane of the selected phase, scrolled up by m.scroll lines.
+	selected := m.phases[m.selected]
+	lines := selected.output()
+	height := m.logHeight()
+	end := len(lines) - m.scroll
+	if end < 0 {
+		end = 0
+	}
+	start := end - height
+	if start < 0 {
+		start = 0
+	}
+	b.WriteString(tuiDimStyle.Render(rule("── "+selected.name+" ", m.width)) + "\n")
+	for _, line := range lines[start:end] {
+		b.WriteString(ansi.Truncate(line, m.width, "…") + "\n")
+	}
+	for i := end - start; i < height; i++ {
+		b.WriteString("\n")
+	}
+
+	help := "↑/↓ phase · PgUp/PgDn scroll · f follow · q quit"
+	switch {
+	case m.done && m.err != nil:
+		help = tuiFailedStyle.Render("Run failed") + " — " + help
+	case m.done:
+		help = tuiPassedStyle.Render("Run finished") + " — " + help
+	case m.quitting:
+		help = "Stopping…"
+	}
+	b.WriteString(help)
+	return b.String()
+}
+
+// rule pads title with a horizontal line to the given width.
+func rule(title string, width int) string {
+	if n := width - ansi.StringWidth(title); n > 0 {
+		return title + strings.Repeat("─", n)
+	}
+	return title
+}
+
+// tuiObserver forwards runner notifications and output to the dashboard.
+type tuiObserver struct {
+	program *tea.Program
+
+	mu      sync.Mutex
+	current string // name of the running phase
+}
+
+func (o *tuiObserver) PhaseStarted(phase *bild.Phase) {
+	o.mu.Lock()
+	o.current = phase.Name
+	o.mu.Unlock()
+	o.program.Send(tuiPhaseStartedMsg{name: phase.Name})
+}
+
+func (o *tuiObserver) PhaseFinished(phase *bild.Phase, err error, elapsed time.Duration) {
+	o.program.Send(tuiPhaseFinishedMsg{name: phase.Name, err: err, elapsed: elapsed})
+}
+
+func (o *tuiObserver) PhaseSkipped(phase *bild.Phase, when string) {
+	o.program.Send(tuiPhaseSkippedMsg{name: phase.Name})
+}
+
+func (o *tuiObserver) PhaseUpToDate(phase *bild.Phase) {
+	o.program.Send(tuiPhaseSkippedMsg{name: phase.Name})
+}
+
+func (o *tuiObserver) PhaseRestored(phase *bild.Phase) {
+	o.program.Send(tuiPhaseSkippedMsg{name: phase.Name})
+}
+
+// Write captures the output of the running phase; that of the variants of
+// a parallel matrix phase goes through PhaseOutput instead.
+func (o *tuiObserver) Write(data []byte) (int, error) {
+	o.mu.Lock()
+	name := o.current
+	o.mu.Unlock()
+	return tuiPhaseWriter{program: o.program, name: name}.Write(data)
+}
+
+// PhaseOutput keeps the output of each variant of a parallel matrix phase
+// for the variant's row.
+func (o *tuiObserver) PhaseOutput(phase *bild.Phase) io.Writer {
+	return tuiPhaseWriter{program: o.program, name: phase.Name}
+}
+
+// tuiPhaseWriter captures the output of one phase.
+type tuiPhaseWriter struct {
+	program *tea.Program
+	name    string
+}
+
+func (w tuiPhaseWriter) Write(data []byte) (int, error) {
+	w.program.Send(tuiOutputMsg{name: w.name, data: string(data)})
+	return len(data), nil
+}
+
+// runWithTUI runs the project while showing a live dashboard with the status,
+// elapsed time and output of each phase.
+func runWithTUI(ctx context.Context, runner *bild.Runner, proj *bild.Project, phaseNames []string, observers []bild.Observer) error {
+	phases, err := proj.SelectPhases(phaseNames)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	model := &tuiModel{
+		project: proj.Name,
+		follow:  true,
+		start:   time.Now(),
+		cancel:  cancel,
+	}
+	for _, ph := range phases {
+		if variants := ph.Variants(); len(variants) > 0 {
+			for _, v := range variants {
+				model.phases = append(model.phases, &tuiPhase{name: ph.VariantName(v)})
+			}
+			continue
+		}
+		model.phases = append(model.phases, &tuiPhase{name: ph.Name})
+	}
+	if len(model.phases) == 0 {
+		return fmt.Errorf("project %s has no phases", proj.Name)
+	}
+
+	program := tea.NewProgram(model, tea.WithAltScreen())
+	observer := &tuiObserver{program: program}
+	runner.Stdin = nil // the dashboard owns the terminal
+	runner.Stdout = observer
+	runner.Stderr = observer
+	runner.Observer = bild.MultiObserver(append([]bild.Observer{observer}, observers...)...)
+
+	go func() {
+		program.Send(tuiRunDoneMsg{err: runner.RunPhases(ctx, proj, phaseNames)})
+	}()
+	if _, err := program.Run(); err != nil {
+		cancel()
+		return err
+	}
+
+	// Leave a compact summary behind once the alternate screen is gone.
+	fmt.Printf("\n%s\n", plain("📦 "+proj.Name))
+	for _, p := range model.phases {
+		fmt.Printf("  %s  %-20s %s\n", p.status, p.name, p.elapsed.Round(100*time.Millisecond))
+	}
+	return model.err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"bild/pkg/bild"
+	"github.com/spf13/cobra"
+)
+
+// verifyCmd runs a project in a fresh clone of the committed state of its repository.
+var verifyCmd = &cobra.Command{
+	Use:   "verify [project] [phase]",
+	Short: "Run a project in a throwaway clone to check it builds from committed files alone",
+	Long: `Clones the repository at HEAD into a temporary directory and runs the given
+phase (default: all phases) there. A run that passes in your checkout but fails
+here depends on untracked files, uncommitted changes or build output left
+behind by earlier runs. The clone is removed afterwards unless --keep is given.`,
+	Args: cobra.RangeArgs(0, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var projectName, phaseName string
+		if len(args) >= 1 {
+			projectName = args[0]
+		}
+		if len(args) == 2 {
+			phaseName = args[1]
+		}
+		keep, _ := cmd.Flags().GetBool("keep")
+
+		proj, dir, _, err := resolveRun(projectName)
+		if err != nil {
+			return err
+		}
+		root, err := bild.RepoRoot(dir)
+		if err != nil {
+			return fmt.Errorf("bild verify needs a git repository")
+		}
+		commit, err := bild.HeadCommit(root)
+		if err != nil {
+			return fmt.Errorf("no commit to verify: %v", err)
+		}
+		if dirty, err := bild.Dirty(root); err == nil && dirty {
+			fmt.Fprintln(infoOut, t("verify.dirty"))
+		}
+
+		tmp, err := os.MkdirTemp("", "bild-verify-")
+		if err != nil {
+			return err
+		}
+		clone := filepath.Join(tmp, filepath.Base(root))
+		if !keep {
+			defer os.RemoveAll(tmp)
+		}
+		fmt.Fprintln(infoOut, t("verify.cloning", shortCommit(commit), clone))
+		if err := bild.CloneAt(root, clone, commit); err != nil {
+			return err
+		}
+
+		runner := bild.NewRunner()
+		// A project defined in a subdirectory runs there in the clone too.
+		runner.Dir = checkoutPath(root, dir, clone)
+		runner.Observer = bild.MultiObserver(newConsoleObserver(runner), &activeRunObserver{project: proj.Name})
+		ctx, stop := bild.NotifyContext(cmd.Context())
+		defer stop()
+		err = runner.Run(ctx, proj, phaseName)
+
+		fmt.Println()
+		if err != nil {
+			fmt.Println(t("verify.failed", proj.Name, shortCommit(commit)))
+		} else {
+			fmt.Println(t("verify.passed", proj.Name, shortCommit(commit)))
+		}
+		if keep {
+			fmt.Println(t("verify.kept", clone))
+		}
+		return err
+	},
+}
+
+func init() {
+	verifyCmd.Flags().Bool("keep", false, "Keep the clone for inspection")
+	verifyCmd.ValidArgsFunction = completeProjectPhases
+	rootCmd.AddCommand(verifyCmd)
+}